@@ -0,0 +1,180 @@
+// Command backtest replays a historical transaction log (NDJSON, one
+// TransactionRequest per line) through the health calculator and routing
+// engine and compares routing configurations side by side.
+//
+//	go run ./cmd/backtest -log transactions.ndjson -a current.json -b candidate.json
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/api"
+	"github.com/yuno/techcart-failover/internal/backtest"
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func main() {
+	logPath := flag.String("log", "", "NDJSON transaction log (- for stdin)")
+	configA := flag.String("a", "", "JSON config for strategy A (default: server defaults)")
+	configB := flag.String("b", "", "JSON config for strategy B (optional)")
+	processorsPath := flag.String("processors", "", "JSON array of processors (default: derived from the log)")
+	asJSON := flag.Bool("json", false, "print reports as JSON")
+	flag.Parse()
+
+	if *logPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	txs, err := readLog(*logPath)
+	if err != nil {
+		log.Fatalf("reading log: %v", err)
+	}
+
+	processors := backtest.DeriveProcessors(txs)
+	if *processorsPath != "" {
+		if processors, err = readProcessors(*processorsPath); err != nil {
+			log.Fatalf("reading processors: %v", err)
+		}
+	}
+
+	configs := []backtest.Config{}
+	for _, path := range []string{*configA, *configB} {
+		if path == "" && len(configs) > 0 {
+			continue
+		}
+		cfg, err := readConfig(path)
+		if err != nil {
+			log.Fatalf("reading config %s: %v", path, err)
+		}
+		configs = append(configs, cfg)
+	}
+
+	reports := make([]backtest.Report, len(configs))
+	for i, cfg := range configs {
+		reports[i] = backtest.Run(txs, processors, cfg)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(reports)
+		return
+	}
+	printReports(os.Stdout, reports)
+}
+
+// readLog parses one TransactionRequest per line, skipping blank lines
+func readLog(path string) ([]domain.Transaction, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var txs []domain.Transaction
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var req api.TransactionRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if req.ProcessorID == "" || req.Timestamp == "" {
+			return nil, fmt.Errorf("line %d: processor_id and timestamp are required", line)
+		}
+		if _, err := time.Parse(time.RFC3339, req.Timestamp); err != nil {
+			return nil, fmt.Errorf("line %d: timestamp: %w", line, err)
+		}
+		txs = append(txs, req.Transaction(time.Time{}))
+	}
+	return txs, scanner.Err()
+}
+
+// readConfig overlays a JSON config onto the defaults ("" = defaults)
+func readConfig(path string) (backtest.Config, error) {
+	cfg := backtest.DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Name = path
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Policy.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Strategy.Validate()
+}
+
+func readProcessors(path string) ([]*domain.Processor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var processors []*domain.Processor
+	return processors, json.Unmarshal(data, &processors)
+}
+
+func printReports(w io.Writer, reports []backtest.Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	header := "metric"
+	for _, r := range reports {
+		header += "\t" + r.Name
+	}
+	if len(reports) == 2 {
+		header += "\tdelta (b - a)"
+	}
+	fmt.Fprintln(tw, header)
+
+	row := func(name string, value func(backtest.Report) float64, format func(float64) string) {
+		line := name
+		for _, r := range reports {
+			line += "\t" + format(value(r))
+		}
+		if len(reports) == 2 {
+			line += "\t" + format(value(reports[1])-value(reports[0]))
+		}
+		fmt.Fprintln(tw, line)
+	}
+	count := func(v float64) string { return fmt.Sprintf("%.0f", v) }
+	decimal := func(v float64) string { return fmt.Sprintf("%.1f", v) }
+	rate := func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) }
+	duration := func(v float64) string { return time.Duration(v).Round(time.Second).String() }
+
+	row("transactions", func(r backtest.Report) float64 { return float64(r.Transactions) }, count)
+	row("actual approvals", func(r backtest.Report) float64 { return float64(r.ActualApprovals) }, count)
+	row("expected approvals", func(r backtest.Report) float64 { return r.ExpectedApprovals }, decimal)
+	row("actual auth rate", func(r backtest.Report) float64 { return r.ActualAuthRate }, rate)
+	row("expected auth rate", func(r backtest.Report) float64 { return r.ExpectedAuthRate }, rate)
+	row("failovers", func(r backtest.Report) float64 { return float64(r.Failovers) }, count)
+	row("decisions to failing processor", func(r backtest.Report) float64 { return float64(r.RoutedToDown) }, count)
+	row("time routing to failing processor", func(r backtest.Report) float64 { return float64(r.TimeRoutingToDown) }, duration)
+	row("incidents", func(r backtest.Report) float64 { return float64(len(r.Incidents)) }, count)
+	row("undetected incidents", func(r backtest.Report) float64 { return float64(r.Undetected) }, count)
+	row("mean time to detect", func(r backtest.Report) float64 { return float64(r.MeanTimeToDetect) }, duration)
+	row("max time to detect", func(r backtest.Report) float64 { return float64(r.MaxTimeToDetect) }, duration)
+	row("transitions", func(r backtest.Report) float64 { return float64(len(r.Transitions)) }, count)
+}
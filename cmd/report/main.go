@@ -0,0 +1,198 @@
+// Command report prints processor SLA and uptime reports: availability,
+// outages, daily auth rates and worst corridors. It fetches the report from
+// a running server with -url, or replays a transaction log (NDJSON, one
+// TransactionRequest per line) with -log.
+//
+//	go run ./cmd/report -url http://localhost:8080 -from 2024-02-01T00:00:00Z
+//	go run ./cmd/report -log transactions.ndjson -format csv -table outages
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/api"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/report"
+)
+
+func main() {
+	baseURL := flag.String("url", "", "fetch the report from a running server at this URL")
+	tenantID := flag.String("tenant", "", "with -url, tenant to report on")
+	logPath := flag.String("log", "", "replay this NDJSON transaction log instead (- for stdin)")
+	fromFlag := flag.String("from", "", "start of the period, RFC 3339 (default: 30 days ago, or the start of the log)")
+	toFlag := flag.String("to", "", "end of the period, RFC 3339 (default: now, or the end of the log)")
+	format := flag.String("format", "text", "output format: text, json or csv")
+	table := flag.String("table", report.TableProcessors, "with -format csv, table to print: "+strings.Join(report.Tables, ", "))
+	flag.Parse()
+
+	if (*baseURL == "") == (*logPath == "") {
+		fmt.Fprintln(os.Stderr, "exactly one of -url and -log is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	from, err := parseTime("from", *fromFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	to, err := parseTime("to", *toFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var rep report.Report
+	if *baseURL != "" {
+		rep, err = fetch(*baseURL, *tenantID, *fromFlag, *toFlag)
+	} else {
+		rep, err = replay(*logPath, from, to)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch *format {
+	case "text":
+		printReport(os.Stdout, rep)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+	case "csv":
+		if err := report.WriteCSV(os.Stdout, rep, *table); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown format %q (want text, json or csv)", *format)
+	}
+}
+
+func parseTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("-%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// fetch gets the JSON report from a running server
+func fetch(baseURL, tenantID, from, to string) (report.Report, error) {
+	var rep report.Report
+	query := url.Values{}
+	for name, value := range map[string]string{"tenant_id": tenantID, "from": from, "to": to} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	resp, err := http.Get(strings.TrimSuffix(baseURL, "/") + "/api/v1/reports/sla?" + query.Encode())
+	if err != nil {
+		return rep, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return rep, fmt.Errorf("server answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return rep, json.NewDecoder(resp.Body).Decode(&rep)
+}
+
+// replay builds the report from a transaction log, over the whole log
+// unless from or to are set
+func replay(path string, from, to time.Time) (report.Report, error) {
+	txs, err := readLog(path)
+	if err != nil {
+		return report.Report{}, fmt.Errorf("reading log: %w", err)
+	}
+
+	calc := report.Replay(txs, health.DefaultPolicy())
+	if from.IsZero() {
+		for _, tx := range txs {
+			if from.IsZero() || tx.Timestamp.Before(from) {
+				from = tx.Timestamp
+			}
+		}
+	}
+	if to.IsZero() {
+		to = calc.Clock().Now()
+	}
+	return report.Build(calc, nil, from, to), nil
+}
+
+// readLog parses one TransactionRequest per line, skipping blank lines
+func readLog(path string) ([]domain.Transaction, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var txs []domain.Transaction
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var req api.TransactionRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if req.ProcessorID == "" || req.Timestamp == "" {
+			return nil, fmt.Errorf("line %d: processor_id and timestamp are required", line)
+		}
+		if _, err := time.Parse(time.RFC3339, req.Timestamp); err != nil {
+			return nil, fmt.Errorf("line %d: timestamp: %w", line, err)
+		}
+		txs = append(txs, req.Transaction(time.Time{}))
+	}
+	return txs, scanner.Err()
+}
+
+func printReport(w io.Writer, rep report.Report) {
+	fmt.Fprintf(w, "SLA report %s → %s\n\n", rep.From.UTC().Format(time.RFC3339), rep.To.UTC().Format(time.RFC3339))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "processor\tavailability\thealthy\tdegraded\tdown\tno data\toutages\tlongest outage\ttransactions\tauth rate")
+	for _, p := range rep.Processors {
+		s := p.SecondsInStatus
+		fmt.Fprintf(tw, "%s\t%.3f%%\t%s\t%s\t%s\t%s\t%d\t%s\t%d\t%.2f%%\n",
+			p.ProcessorID, p.Availability*100, duration(s.Healthy), duration(s.Degraded), duration(s.Down),
+			duration(s.NoData), p.OutageCount, duration(p.LongestOutageSeconds), p.TotalTransactions, p.AuthorizationRate*100)
+	}
+	tw.Flush()
+
+	if len(rep.WorstCorridors) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nWorst corridors")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "processor\tmethod\tcountry\ttransactions\tauth rate\terror rate")
+	for _, c := range rep.WorstCorridors {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.2f%%\t%.2f%%\n",
+			c.ProcessorID, c.PaymentMethod, c.Country, c.TotalTransactions, c.AuthorizationRate*100, c.ErrorRate*100)
+	}
+	tw.Flush()
+}
+
+func duration(seconds float64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
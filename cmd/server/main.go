@@ -1,104 +1,397 @@
 package main
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/yuno/techcart-failover/internal/api"
+	"github.com/yuno/techcart-failover/internal/auth"
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/config"
+	"github.com/yuno/techcart-failover/internal/dashboard"
 	"github.com/yuno/techcart-failover/internal/domain"
 	"github.com/yuno/techcart-failover/internal/health"
-	"github.com/yuno/techcart-failover/internal/routing"
+	"github.com/yuno/techcart-failover/internal/idempotency"
+	"github.com/yuno/techcart-failover/internal/ingest"
+	"github.com/yuno/techcart-failover/internal/logging"
+	"github.com/yuno/techcart-failover/internal/notify"
+	"github.com/yuno/techcart-failover/internal/probe"
+	"github.com/yuno/techcart-failover/internal/ratelimit"
+	"github.com/yuno/techcart-failover/internal/slo"
+	"github.com/yuno/techcart-failover/internal/tenant"
+	"github.com/yuno/techcart-failover/internal/tracing"
 )
 
 func main() {
-	// Initialize components
-	calculator := health.NewCalculator()
-	router := routing.NewEngine(calculator)
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "JSON config file (default: built-in settings)")
+	checkConfig := flag.Bool("check-config", false, "validate the configuration, print it and exit")
+	flag.Parse()
 
-	// Register mock processors (TechCart scenario)
-	registerProcessors(router)
+	cfg, err := config.Load(*configPath, os.Getenv)
+	if *checkConfig {
+		os.Exit(check(cfg, err))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	// Structured logs; the level can be changed on reload
+	level := new(slog.LevelVar)
+	level.Set(mustLevel(cfg.Logging.Level))
+	logger := logging.New(os.Stderr, cfg.Logging.Format, level)
+	slog.SetDefault(logger)
+
+	// Not ready until every component is wired up; live as soon as we serve
+	probes := probe.New()
+
+	// Background workers stop once in-flight requests have drained
+	background, stopBackground := context.WithCancel(context.Background())
 
-	// Create API handler
-	handler := api.NewHandler(calculator, router)
+	// Initialize components: per-tenant state plus a shared network view
+	clk := clock.Real()
+	tenants := tenant.NewRegistry(health.NewCalculatorWithPolicy(clk, cfg.Health), clk)
+	tenants.SetPolicy(cfg.Health)
+	tenants.SetStrategy(cfg.Routing)
+
+	// Configured processors are every tenant's defaults. Only configured
+	// tenants are created; requests for any other get 404.
+	tenants.SetDefaultProcessors(cfg.Processors)
+	defaultTenant := tenants.Get(tenant.DefaultID)
+	for _, id := range cfg.TenantIDs() {
+		tenants.Get(id)
+	}
+
+	// Push transitions and SLO alerts to the webhook, if configured
+	webhook := notify.NewWebhook(cfg.Notifications.WebhookURL)
+	webhook.OnError = func(err error) { logger.Warn("webhook delivery failed", "error", err) }
+	go webhook.Run(background)
+
+	// Every transition, from a transaction or a re-evaluation, is logged
+	// with the request that caused it and pushed to the webhook
+	tenants.SetOnTransition(func(t domain.HealthTransition) {
+		logger.LogAttrs(logging.WithRequestID(context.Background(), t.RequestID), slog.LevelWarn, "health transition",
+			slog.String("tenant", t.TenantID),
+			slog.String("processor", t.ProcessorID),
+			slog.String("from", string(t.FromStatus)),
+			slog.String("to", string(t.ToStatus)),
+			slog.String("reason", t.Reason),
+		)
+		webhook.Transition(t)
+	})
+
+	// Re-evaluate idle processors so expired data turns into STALE/UNKNOWN
+	scheduler := health.NewScheduler(clk, health.DefaultReevaluateInterval, tenants.Calculators)
+	go scheduler.Run(background)
+
+	// Evaluate SLO burn rates so budget alerts fire and resolve without traffic
+	sloScheduler := slo.NewScheduler(clk, slo.DefaultEvaluateInterval, tenants.SLOTrackers)
+	sloScheduler.OnEvent = func(a domain.SLOAlert) {
+		logger.Warn("slo alert", "tenant", a.TenantID, "processor", a.ProcessorID, "window", a.Window, "state", a.State, "reason", a.Reason)
+		webhook.SLOAlert(a)
+	}
+	go sloScheduler.Run(background)
+
+	// Bounded ingestion queue so bursts shed load instead of piling on the calculator lock
+	queue := ingest.NewQueue(cfg.Storage.QueueDepth, cfg.Storage.QueueWorkers, tenants.RecordTransactionContext)
+
+	// Create API handler, deduplicating retried transactions within the idempotency horizon
+	handler := api.NewHandler(tenants, idempotency.NewStore(cfg.Storage.IdempotencyHorizon), queue)
+	handler.SetLogger(logger)
 
 	// Setup routes
 	mux := http.NewServeMux()
 	handler.RegisterRoutes(mux)
 
-	// Add CORS middleware for testing
-	corsHandler := corsMiddleware(mux)
-
-	// Start server (use PORT env var for Railway/cloud deployment)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	addr := ":" + port
-	log.Printf("🚀 TechCart Failover API starting on %s", addr)
-	log.Printf("📊 Registered %d processors", len(router.GetProcessors()))
-	log.Println("")
-	log.Println("Endpoints:")
-	log.Println("  POST /api/v1/transactions     - Record transaction result")
-	log.Println("  GET  /api/v1/health           - Get all processor health")
-	log.Println("  GET  /api/v1/health/{id}      - Get processor health + history")
-	log.Println("  POST /api/v1/routing/recommend - Get routing recommendation")
-	log.Println("  GET  /api/v1/routing/recommend?payment_method=&country=")
-	log.Println("  GET  /api/v1/processors       - List processors")
-	log.Println("  GET  /api/v1/alerts           - Get health transitions")
-	log.Println("")
-
-	if err := http.ListenAndServe(addr, corsHandler); err != nil {
-		log.Fatal(err)
+	// Operations dashboard, sampling health trends in the background
+	dash := dashboard.New(tenants, clk)
+	dash.RegisterRoutes(mux)
+	go dash.Run(background)
+
+	// Per-client, per-route token buckets (trust_proxy to key on X-Forwarded-For)
+	limiter := ratelimit.NewLimiter(cfg.RateLimits.Rules, cfg.RateLimits.TrustProxy)
+
+	// Spans exported to an OTLP collector, if configured (nil tracer: off)
+	var tracer *tracing.Tracer
+	exporter := tracing.NewExporter(cfg.Tracing.Endpoint, cfg.Tracing.ServiceName)
+	if cfg.Tracing.Endpoint != "" {
+		exporter.OnError = func(err error) { logger.Warn("trace export failed", "error", err) }
+		go exporter.Run(background)
+		tracer = tracing.NewTracer(exporter, cfg.Tracing.SampleRatio)
 	}
+
+	// Probes bypass auth, rate limits and tracing so orchestrators always reach them
+	root := http.NewServeMux()
+	probes.RegisterRoutes(root)
+	route := func(r *http.Request) string {
+		if _, pattern := root.Handler(r); pattern != "/" {
+			return pattern
+		}
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+
+	// API keys, then rate limits; CORS outside so preflights need neither,
+	// and tracing outermost so rejected requests are traced too
+	keys := auth.NewKeys(cfg.Auth.APIKeys)
+	keys.SetTenantKeys(cfg.Auth.TenantKeys)
+	limiter.SetKeyCheck(keys.Known)
+	cors := newCORS(cfg.CORS.AllowedOrigins)
+	app := tracing.Middleware(tracer, cors.Middleware(keys.Middleware(limiter.Middleware(mux, api.RouteClass))), route)
+	root.Handle("/", app)
+
+	// Request IDs outermost so every response, even a 401 or 429, carries one
+	logged := logging.Middleware(logger, root, route, limiter.ClientID)
+
+	srv := &http.Server{
+		Addr:         cfg.Listen.Address,
+		Handler:      logged,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		ReadTimeout:  cfg.Listen.ReadTimeout,
+		WriteTimeout: cfg.Listen.WriteTimeout,
+		IdleTimeout:  cfg.Listen.IdleTimeout,
+	}
+	// Shutdown waits for idle connections; event streams never go idle
+	srv.RegisterOnShutdown(dash.Close)
+	servers := []*http.Server{srv}
+
+	// gRPC API on its own port, cleartext HTTP/2 only. Checks its own API
+	// keys and rate limits; no Read/WriteTimeout, which would cut streams.
+	if cfg.GRPC.Address != "" {
+		grpcSvc := api.NewGRPC(handler, keys, limiter)
+		grpcSrv := &http.Server{
+			Addr:        cfg.GRPC.Address,
+			Handler:     tracing.Middleware(tracer, grpcSvc, func(r *http.Request) string { return r.URL.Path }),
+			ErrorLog:    slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+			IdleTimeout: cfg.Listen.IdleTimeout,
+			Protocols:   new(http.Protocols),
+		}
+		grpcSrv.Protocols.SetUnencryptedHTTP2(true)
+		grpcSrv.RegisterOnShutdown(grpcSvc.Close)
+		servers = append(servers, grpcSrv)
+	}
+
+	logger.Info("TechCart Failover API starting", "address", srv.Addr, "tenant", defaultTenant.ID,
+		"processors", len(defaultTenant.Engine.GetProcessors()), "tenants", len(tenants.List()), "api_keys", len(cfg.Auth.APIKeys))
+	if tracer != nil {
+		logger.Info("tracing enabled", "endpoint", cfg.Tracing.Endpoint, "sample_ratio", cfg.Tracing.SampleRatio)
+	}
+	if cfg.Logging.Format == logging.FormatText {
+		printEndpoints(os.Stderr)
+	}
+
+	// SIGHUP re-reads the config and applies what can change while serving
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			next, err := config.Load(*configPath, os.Getenv)
+			if err != nil {
+				logger.Error("config reload failed, keeping current settings", "error", err)
+				continue
+			}
+			tenants.SetStrategy(next.Routing)
+			for _, id := range next.TenantIDs() {
+				tenants.Get(id)
+			}
+			keys.Set(next.Auth.APIKeys)
+			keys.SetTenantKeys(next.Auth.TenantKeys)
+			webhook.SetURL(next.Notifications.WebhookURL)
+			cors.Set(next.CORS.AllowedOrigins)
+			limiter.SetRules(next.RateLimits.Rules)
+			level.Set(mustLevel(next.Logging.Level))
+			// Compared with the startup config: these still run with it
+			for _, section := range cfg.RestartRequired(next) {
+				logger.Warn("config changed, restart to apply", "section", section)
+			}
+			logger.Info("config reloaded")
+		}
+	}()
+
+	serveErr := make(chan error, len(servers))
+	for _, s := range servers {
+		listener, err := net.Listen("tcp", s.Addr)
+		if err != nil {
+			logger.Error("listen failed", "address", s.Addr, "error", err)
+			os.Exit(1)
+		}
+		go func() { serveErr <- s.Serve(listener) }()
+	}
+	if cfg.GRPC.Address != "" {
+		logger.Info("gRPC listening", "address", cfg.GRPC.Address)
+	}
+	probes.SetReady()
+
+	// SIGTERM (deploys) or Ctrl-C: drain, then flush what is still queued
+	signals, release := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer release()
+	select {
+	case err := <-serveErr:
+		logger.Error("server failed", "error", err)
+		os.Exit(1)
+	case <-signals.Done():
+	}
+	shutdown(servers, probes, logger, cfg.Listen, func() {
+		queue.Close()
+		stopBackground()
+		flushCtx, cancel := context.WithTimeout(context.Background(), cfg.Listen.ShutdownTimeout)
+		defer cancel()
+		webhook.Flush(flushCtx)
+		exporter.Flush(flushCtx)
+	})
+}
+
+// shutdown takes the server out of rotation, waits shutdown_delay for load
+// balancers to notice, drains in-flight requests on every server within
+// shutdown_timeout and then runs flush
+func shutdown(servers []*http.Server, probes *probe.Probe, logger *slog.Logger, listen config.Listen, flush func()) {
+	logger.Info("shutting down, draining requests", "up_to", (listen.ShutdownDelay + listen.ShutdownTimeout).String())
+	probes.SetNotReady("shutting down")
+	time.Sleep(listen.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), listen.ShutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Warn("requests still in flight", "address", srv.Addr, "after", listen.ShutdownTimeout.String(), "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	flush()
+	logger.Info("stopped")
+}
+
+// mustLevel parses a level already checked by config.Validate
+func mustLevel(s string) slog.Level {
+	level, _ := logging.ParseLevel(s)
+	return level
+}
+
+// printEndpoints lists the API for people reading text logs
+func printEndpoints(w io.Writer) {
+	fmt.Fprint(w, `
+Endpoints:
+  POST /api/v1/transactions     - Record transaction result
+  GET  /api/v1/health           - Get all processor health
+  GET  /api/v1/health/{id}      - Get processor health + history
+  GET  /api/v1/health/{id}/history?from=&to=&step= - Health time series
+  POST /api/v1/routing/recommend - Get routing recommendation
+  GET  /api/v1/routing/recommend?payment_method=&country=
+  GET  /api/v1/routing/decisions/{id} - Explain a routing decision
+  GET  /api/v1/routing/feedback - Recommendation adherence and lift
+  GET  /api/v1/routing/payments/{id} - Attempts for a payment
+  GET  /api/v1/routing/attempts - Outcomes by attempt number
+  GET  /api/v1/processors       - List processors
+  POST /api/v1/processors       - Register a tenant processor
+  GET  /api/v1/processors/load - Live load vs capacity
+  GET  /api/v1/alerts           - Get health transitions
+  GET  /api/v1/slos             - SLO budgets and burn rates
+  POST /api/v1/slos             - Add or replace an SLO
+  GET  /api/v1/reports/sla?from=&to=&format=csv - Processor SLA report
+  GET  /api/v1/tenants          - List tenants
+  GET  /api/v1/network/health   - Cross-tenant network health
+  GET  /dashboard               - Operations dashboard
+  GET  /api/v1/dashboard/stream - Dashboard updates (server-sent events)
+  GET  /api/v1/openapi.json     - OpenAPI 3 document
+  GET  /livez, /readyz          - Liveness and readiness probes
+  gRPC techcart.failover.v1.Failover on grpc.address (proto/failover.proto)
+
+`)
 }
 
-// registerProcessors sets up the mock processors for TechCart
-func registerProcessors(router *routing.Engine) {
-	processors := []*domain.Processor{
-		{
-			ID:             "processor_a",
-			Name:           "GlobalPay_BR",
-			Countries:      []domain.Country{domain.CountryBR},
-			PaymentMethods: []domain.PaymentMethod{domain.MethodPIX, domain.MethodCard},
-		},
-		{
-			ID:             "processor_b",
-			Name:           "PayLatam",
-			Countries:      []domain.Country{domain.CountryBR, domain.CountryMX, domain.CountryCO},
-			PaymentMethods: []domain.PaymentMethod{domain.MethodCard},
-		},
-		{
-			ID:             "processor_c",
-			Name:           "PixMaster",
-			Countries:      []domain.Country{domain.CountryBR},
-			PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
-		},
-		{
-			ID:             "processor_d",
-			Name:           "MexPago",
-			Countries:      []domain.Country{domain.CountryMX},
-			PaymentMethods: []domain.PaymentMethod{domain.MethodCard, domain.MethodOXXO},
-		},
-		{
-			ID:             "processor_e",
-			Name:           "ColombiaPS",
-			Countries:      []domain.Country{domain.CountryCO},
-			PaymentMethods: []domain.PaymentMethod{domain.MethodPSE, domain.MethodCard},
-		},
-	}
-
-	for _, p := range processors {
-		router.RegisterProcessor(p)
+// check prints the outcome of -check-config and returns the exit code
+func check(cfg config.Config, err error) int {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "configuration is invalid:\n%v\n", err)
+		return 1
 	}
+	// Print the effective config without the keys themselves
+	redacted := make([]string, len(cfg.Auth.APIKeys))
+	for i := range redacted {
+		redacted[i] = "<redacted>"
+	}
+	cfg.Auth.APIKeys = redacted
+	tenantKeys := make(map[string][]string, len(cfg.Auth.TenantKeys))
+	for id, keys := range cfg.Auth.TenantKeys {
+		tenantKeys[id] = make([]string, len(keys))
+		for i := range keys {
+			tenantKeys[id][i] = "<redacted>"
+		}
+	}
+	cfg.Auth.TenantKeys = tenantKeys
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(cfg)
+	fmt.Fprintln(os.Stderr, "configuration is valid")
+	return 0
+}
+
+// corsPolicy adds CORS headers for the allowed origins, which can be
+// replaced on reload
+type corsPolicy struct {
+	mu      sync.RWMutex
+	origins map[string]bool
+}
+
+func newCORS(origins []string) *corsPolicy {
+	c := &corsPolicy{}
+	c.Set(origins)
+	return c
 }
 
-// corsMiddleware adds CORS headers for local testing
-func corsMiddleware(next http.Handler) http.Handler {
+// Set replaces the allowed origins ("*" allows any)
+func (c *corsPolicy) Set(origins []string) {
+	set := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		set[o] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.origins = set
+}
+
+// allowed returns the Access-Control-Allow-Origin value for origin ("" for none)
+func (c *corsPolicy) allowed(origin string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	switch {
+	case c.origins["*"]:
+		return "*"
+	case origin != "" && c.origins[origin]:
+		return origin
+	}
+	return ""
+}
+
+// Middleware answers preflights and adds CORS headers for allowed origins
+func (c *corsPolicy) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Add("Vary", "Origin")
+		if origin := c.allowed(r.Header.Get("Origin")); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+api.TenantHeader+", "+api.IdempotencyHeader+", "+auth.APIKeyHeader+", "+logging.RequestIDHeader+", "+tracing.TraceparentHeader)
+			w.Header().Set("Access-Control-Expose-Headers", logging.RequestIDHeader)
+		}
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
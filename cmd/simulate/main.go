@@ -0,0 +1,143 @@
+// Command simulate generates payment traffic from a scenario file, routing
+// every payment through the recommendation engine. By default it runs
+// in-process on a virtual clock; with -url it drives a running server.
+//
+//	go run ./cmd/simulate -scenario scenarios/techcart_outage.json
+//	go run ./cmd/simulate -scenario scenarios/techcart_outage.json -url http://localhost:8080 -speed 360
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/api"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/simulator"
+)
+
+func main() {
+	scenarioPath := flag.String("scenario", "scenarios/techcart_outage.json", "scenario JSON file")
+	seed := flag.Int64("seed", 0, "random seed (default: the scenario's seed)")
+	baseURL := flag.String("url", "", "drive a running server at this URL instead of running in-process")
+	speed := flag.Float64("speed", 360, "with -url, scenario seconds per wall-clock second")
+	tenantID := flag.String("tenant", "", "with -url, tenant to send traffic as (configured on the server)")
+	logPath := flag.String("log", "", "write generated transactions as NDJSON (usable by cmd/backtest)")
+	verbose := flag.Bool("v", false, "print progress every simulated 10 minutes and every transition")
+	asJSON := flag.Bool("json", false, "print the result as JSON")
+	flag.Parse()
+
+	scenario, err := simulator.Load(*scenarioPath)
+	if err != nil {
+		log.Fatalf("loading scenario: %v", err)
+	}
+
+	var target simulator.Target = simulator.NewLocal(scenario)
+	if *baseURL != "" {
+		remote := simulator.NewRemote(*baseURL, *speed)
+		remote.TenantID = *tenantID
+		target = remote
+	}
+
+	runner := simulator.NewRunner(scenario, target, *seed)
+	if *verbose {
+		runner.Progress = func(offset time.Duration, r *simulator.Result) {
+			if offset%(10*time.Minute) == 0 {
+				fmt.Fprintf(os.Stderr, "  %8s  payments=%d approved=%d errors=%d\n",
+					offset, r.Total.Payments, r.Total.Approved, r.Total.Errors+r.Total.Timeouts)
+			}
+		}
+	}
+
+	if *logPath != "" {
+		f, err := os.Create(*logPath)
+		if err != nil {
+			log.Fatalf("creating log: %v", err)
+		}
+		defer f.Close()
+		w := bufio.NewWriter(f)
+		defer w.Flush()
+		enc := json.NewEncoder(w)
+		runner.OnPayment = func(tx domain.Transaction) {
+			enc.Encode(api.TransactionRequest{
+				ID:            tx.ID,
+				ProcessorID:   tx.ProcessorID,
+				Result:        string(tx.Result),
+				PaymentMethod: string(tx.PaymentMethod),
+				Country:       string(tx.Country),
+				Amount:        tx.Amount,
+				Currency:      tx.Currency,
+				Timestamp:     tx.Timestamp.Format(time.RFC3339Nano),
+			})
+		}
+	}
+
+	result, err := runner.Run()
+	if err != nil {
+		log.Fatalf("simulation failed: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+		return
+	}
+	printResult(os.Stdout, scenario, result, *verbose)
+}
+
+func printResult(w io.Writer, s *simulator.Scenario, r *simulator.Result, verbose bool) {
+	fmt.Fprintf(w, "Scenario %q (seed %d, %s)\n\n", r.Scenario, r.Seed, time.Duration(s.Duration))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "processor\tpayments\tapproved\tdeclined\terrors\ttimeouts\tsuccess")
+	for _, id := range r.ProcessorIDs() {
+		t := r.Processors[id]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n", id, t.Payments, t.Approved, t.Declined, t.Errors, t.Timeouts, share(t.Approved, t.Payments))
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t%d\t%d\t%d\t%s\n", r.Total.Payments, r.Total.Approved, r.Total.Declined, r.Total.Errors, r.Total.Timeouts, share(r.Total.Approved, r.Total.Payments))
+	tw.Flush()
+
+	if r.Unroutable > 0 || r.Fallbacks > 0 || r.Errors > 0 {
+		fmt.Fprintf(w, "\nunroutable: %d  fallbacks (nothing recommended): %d  target errors: %d\n", r.Unroutable, r.Fallbacks, r.Errors)
+	}
+
+	if len(r.Events) > 0 {
+		fmt.Fprintln(w, "\nEvents")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "event\tprocessor\tscope\twindow\teligible payments\tstill routed to it")
+		for _, e := range r.Events {
+			scope := strings.TrimSpace(string(e.Event.PaymentMethod) + " " + string(e.Event.Country))
+			if scope == "" {
+				scope = "all"
+			}
+			start := time.Duration(e.Event.Start)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s-%s\t%d\t%d (%s)\n", e.Event.Type, e.Event.ProcessorID, scope,
+				start, start+time.Duration(e.Event.Duration), e.Payments, e.RoutedToAffected, share(e.RoutedToAffected, e.Payments))
+		}
+		tw.Flush()
+	}
+
+	fmt.Fprintf(w, "\nHealth transitions: %d\n", len(r.Transitions))
+	for _, t := range r.Transitions {
+		// Without -v only show the processor going down and coming back
+		if !verbose && t.ToStatus != domain.StatusDown && t.FromStatus != domain.StatusDown {
+			continue
+		}
+		fmt.Fprintf(w, "  +%-8s %s: %s → %s (%s)\n", t.Timestamp.Sub(s.Start).Round(time.Second), t.ProcessorID, t.FromStatus, t.ToStatus, t.Reason)
+	}
+}
+
+func share(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)/float64(total)*100)
+}
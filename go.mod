@@ -1,3 +1,3 @@
 module github.com/yuno/techcart-failover
 
-go 1.22
+go 1.24
@@ -0,0 +1,348 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/auth"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/idempotency"
+	"github.com/yuno/techcart-failover/internal/logging"
+	"github.com/yuno/techcart-failover/internal/ratelimit"
+	"github.com/yuno/techcart-failover/internal/rpc"
+	"github.com/yuno/techcart-failover/internal/tenant"
+	"github.com/yuno/techcart-failover/internal/tracing"
+)
+
+// GRPCService prefixes the methods of the Failover service in proto/failover.proto
+const GRPCService = "/techcart.failover.v1.Failover/"
+
+// DefaultWatchInterval is how often WatchHealth looks for health changes
+const DefaultWatchInterval = time.Second
+
+// GRPC serves the Failover gRPC service from the handler's tenants, ingest
+// queue and idempotency store, so both APIs see and move the same health.
+// API keys and rate limits are shared with the HTTP API too.
+type GRPC struct {
+	handler  *Handler
+	keys     *auth.Keys
+	limiter  *ratelimit.Limiter
+	server   *rpc.Server
+	interval time.Duration
+	closing  chan struct{}
+	close    sync.Once
+}
+
+// NewGRPC creates the gRPC service for h
+func NewGRPC(h *Handler, keys *auth.Keys, limiter *ratelimit.Limiter) *GRPC {
+	g := &GRPC{
+		handler:  h,
+		keys:     keys,
+		limiter:  limiter,
+		server:   rpc.NewServer(),
+		interval: DefaultWatchInterval,
+		closing:  make(chan struct{}),
+	}
+	g.server.Handle(GRPCService+"RecordTransaction", rpc.Unary(g.recordTransaction))
+	g.server.Handle(GRPCService+"RecordTransactions", g.recordTransactions)
+	g.server.Handle(GRPCService+"Recommend", rpc.Unary(g.recommend))
+	g.server.Handle(GRPCService+"GetHealth", rpc.Unary(g.getHealth))
+	g.server.Handle(GRPCService+"WatchHealth", g.watchHealth)
+	g.server.SetInterceptor(g.intercept)
+	return g
+}
+
+// SetWatchInterval sets how often WatchHealth looks for changes
+func (g *GRPC) SetWatchInterval(interval time.Duration) {
+	g.interval = interval
+}
+
+// ServeHTTP serves gRPC calls from an HTTP/2 server
+func (g *GRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.server.ServeHTTP(w, r)
+}
+
+// Close ends WatchHealth streams, which never finish on their own
+func (g *GRPC) Close() {
+	g.close.Do(func() { close(g.closing) })
+}
+
+// methodClass maps methods to rate limit classes. RecordTransactions is
+// limited per message instead.
+var methodClass = map[string]string{
+	GRPCService + "RecordTransaction": "ingest",
+	GRPCService + "Recommend":         "routing",
+	GRPCService + "GetHealth":         "read",
+	GRPCService + "WatchHealth":       "read",
+}
+
+// intercept gives each call a request ID, checks its API key and rate
+// limit, and logs it
+func (g *GRPC) intercept(method string, s *rpc.Stream, next rpc.Handler) error {
+	start := time.Now()
+	id := logging.IncomingRequestID(s.Request())
+	s.SetHeader(logging.RequestIDHeader, id)
+	s.SetContext(logging.WithRequestID(s.Context(), id))
+
+	err := g.authorize(method, s)
+	if err == nil {
+		err = next(s)
+	}
+
+	code := rpc.CodeOf(err)
+	level := slog.LevelInfo
+	if code == rpc.Internal || code == rpc.Unknown || code == rpc.Unavailable {
+		level = slog.LevelError
+	}
+
+	// The HTTP status is always 200; the outcome is in the grpc-status trailer
+	span := tracing.SpanFromContext(s.Context())
+	span.SetAttributes("rpc.system", "grpc", "rpc.method", method, "rpc.grpc.status_code", int(code))
+	if serverFault(code) {
+		span.SetError(err.Error())
+	}
+
+	g.handler.logger.LogAttrs(s.Context(), level, "rpc",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.String("client", logging.Client(g.limiter.ClientID(s.Request()))),
+		slog.String("tenant", s.Metadata(TenantHeader)),
+	)
+	return err
+}
+
+// serverFault reports whether a status code marks a server span as failed,
+// as OpenTelemetry does for gRPC servers; client errors do not
+func serverFault(code rpc.Code) bool {
+	switch code {
+	case rpc.Unknown, rpc.DeadlineExceeded, rpc.Unimplemented, rpc.Internal, rpc.Unavailable:
+		return true
+	}
+	return false
+}
+
+func (g *GRPC) authorize(method string, s *rpc.Stream) error {
+	key := s.Metadata(auth.APIKeyHeader)
+	if !g.keys.Valid(key) {
+		return rpc.Errorf(rpc.Unauthenticated, "missing or invalid API key")
+	}
+	if tenantID := g.keys.Tenant(key); tenantID != "" {
+		s.SetContext(auth.WithTenant(s.Context(), tenantID))
+	}
+	if class, ok := methodClass[method]; ok {
+		return g.allow(s, class)
+	}
+	return nil
+}
+
+func (g *GRPC) allow(s *rpc.Stream, class string) error {
+	if ok, wait := g.limiter.Allow(g.limiter.ClientID(s.Request()), class); !ok {
+		return rpc.Errorf(rpc.ResourceExhausted, "rate limit exceeded, retry in %ds", int(math.Ceil(wait.Seconds())))
+	}
+	return nil
+}
+
+// tenant resolves the tenant named by x-tenant-id metadata, then the
+// request's tenant_id, without creating it: unknown tenants are NotFound
+// and tenants other than the API key's PermissionDenied
+func (g *GRPC) tenant(s *rpc.Stream, bodyTenant string) (*tenant.Tenant, error) {
+	id := s.Metadata(TenantHeader)
+	if id == "" {
+		id = bodyTenant
+	}
+	t, err := g.handler.tenants.Resolve(id, auth.TenantFromContext(s.Context()))
+	switch err {
+	case nil:
+		return t, nil
+	case tenant.ErrOtherTenant:
+		return nil, rpc.Errorf(rpc.PermissionDenied, "%v", err)
+	default:
+		return nil, rpc.Errorf(rpc.NotFound, "%v", err)
+	}
+}
+
+// RecordTransaction - Record a transaction and return the processor's health
+func (g *GRPC) recordTransaction(s *rpc.Stream, msg []byte) ([]byte, error) {
+	req, err := decodeTransaction(msg)
+	if err != nil {
+		return nil, rpc.Errorf(rpc.InvalidArgument, "invalid Transaction: %v", err)
+	}
+	if msg := req.validate(); msg != "" {
+		return nil, rpc.Errorf(rpc.InvalidArgument, "%s", msg)
+	}
+
+	t, err := g.tenant(s, req.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	txID, resp, replayed, err := g.handler.ingestReport(s.Context(), req, t, false)
+	if err == idempotency.ErrConflict {
+		return nil, rpc.Errorf(rpc.AlreadyExists, errConflict)
+	}
+	switch resp.Status {
+	case http.StatusOK:
+		// Stored as JSON so HTTP retries of the same ID replay it too
+		var health domain.ProcessorHealth
+		if err := json.Unmarshal(resp.Body, &health); err != nil {
+			return nil, rpc.Errorf(rpc.Internal, "decoding recorded health: %v", err)
+		}
+		return encodeRecordResponse(txID, replayed, &health), nil
+	case http.StatusAccepted:
+		return encodeRecordResponse(txID, replayed, nil), nil
+	default:
+		return nil, rpc.Errorf(rpc.Unavailable, "%s", shedMessage(resp))
+	}
+}
+
+// RecordTransactions - Queue a stream of transactions; shed ones are
+// listed by position for the client to retry
+func (g *GRPC) recordTransactions(s *rpc.Stream) error {
+	var summary recordSummary
+	for i := int64(0); ; i++ {
+		msg, err := s.Recv()
+		if err == io.EOF {
+			return s.Send(encodeRecordSummary(summary))
+		}
+		if err != nil {
+			return err
+		}
+
+		req, err := decodeTransaction(msg)
+		if err != nil {
+			return rpc.Errorf(rpc.InvalidArgument, "transaction %d: invalid Transaction: %v", i, err)
+		}
+		if msg := req.validate(); msg != "" {
+			return rpc.Errorf(rpc.InvalidArgument, "transaction %d: %s", i, msg)
+		}
+		if g.allow(s, "ingest") != nil {
+			summary.Shed = append(summary.Shed, i)
+			continue
+		}
+
+		t, err := g.tenant(s, req.TenantID)
+		if err != nil {
+			return err
+		}
+
+		_, resp, replayed, err := g.handler.ingestReport(s.Context(), req, t, true)
+		switch {
+		case err == idempotency.ErrConflict:
+			return rpc.Errorf(rpc.AlreadyExists, "transaction %d: %s", i, errConflict)
+		case resp.Status == http.StatusServiceUnavailable:
+			summary.Shed = append(summary.Shed, i)
+		case replayed:
+			summary.Replayed++
+		default:
+			summary.Accepted++
+		}
+	}
+}
+
+// Recommend - Rank processors for a payment
+func (g *GRPC) recommend(s *rpc.Stream, msg []byte) ([]byte, error) {
+	req, err := decodeRoutingRequest(msg)
+	if err != nil {
+		return nil, rpc.Errorf(rpc.InvalidArgument, "invalid RoutingRequest: %v", err)
+	}
+	if msg := req.validate(); msg != "" {
+		return nil, rpc.Errorf(rpc.InvalidArgument, "%s", msg)
+	}
+
+	t, err := g.tenant(s, req.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	recommendation := t.Engine.RecommendPaymentContext(s.Context(), req.payment())
+	g.handler.logRecommendation(s.Context(), recommendation)
+	return encodeRecommendation(recommendation), nil
+}
+
+// GetHealth - Health of every processor, or of one
+func (g *GRPC) getHealth(s *rpc.Stream, msg []byte) ([]byte, error) {
+	req, err := decodeHealthRequest(msg)
+	if err != nil {
+		return nil, rpc.Errorf(rpc.InvalidArgument, "invalid HealthRequest: %v", err)
+	}
+	t, err := g.tenant(s, req.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	return encodeHealthList(t.ID, healthOf(t, req.ProcessorID)), nil
+}
+
+// WatchHealth - Current health, then each processor whose health changes,
+// until the client goes away or the server shuts down
+func (g *GRPC) watchHealth(s *rpc.Stream) error {
+	msg, err := s.Recv()
+	if err != nil {
+		return rpc.Errorf(rpc.InvalidArgument, "expected a HealthRequest")
+	}
+	req, err := decodeHealthRequest(msg)
+	if err != nil {
+		return rpc.Errorf(rpc.InvalidArgument, "invalid HealthRequest: %v", err)
+	}
+	t, err := g.tenant(s, req.TenantID)
+	if err != nil {
+		return err
+	}
+
+	ticker := t.Calculator.Clock().NewTicker(g.interval)
+	defer ticker.Stop()
+	sent := make(map[string]healthState)
+	for {
+		for _, h := range healthOf(t, req.ProcessorID) {
+			state := stateOf(h)
+			if prev, ok := sent[h.ProcessorID]; ok && prev == state {
+				continue
+			}
+			if err := s.Send(encodeHealth(h)); err != nil {
+				return err
+			}
+			sent[h.ProcessorID] = state
+		}
+
+		select {
+		case <-s.Context().Done():
+			return s.Context().Err()
+		case <-g.closing:
+			return nil
+		case <-ticker.C():
+		}
+	}
+}
+
+// healthOf returns every processor's health, or only processorID's
+func healthOf(t *tenant.Tenant, processorID string) []*domain.ProcessorHealth {
+	if processorID != "" {
+		return []*domain.ProcessorHealth{t.Calculator.GetHealth(processorID)}
+	}
+	return t.Calculator.GetAllHealth()
+}
+
+// healthState is what WatchHealth compares to decide a processor changed;
+// LastUpdated moves on every read and is left out
+type healthState struct {
+	status                          domain.HealthStatus
+	rate                            float64
+	total, success, failure, errors int
+}
+
+func stateOf(h *domain.ProcessorHealth) healthState {
+	return healthState{h.Status, h.AuthorizationRate, h.TotalTransactions, h.SuccessCount, h.FailureCount, h.ErrorCount}
+}
+
+// shedMessage reads the error of a shed ingest response
+func shedMessage(resp idempotency.Response) string {
+	var body ErrorResponse
+	if json.Unmarshal(resp.Body, &body) == nil && body.Error != "" {
+		return body.Error
+	}
+	return "transaction not recorded"
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/rpc"
+)
+
+// Protobuf encoding of the messages in proto/failover.proto. Field numbers
+// must match the .proto file.
+
+// healthRequest is the HealthRequest message
+type healthRequest struct {
+	TenantID    string
+	ProcessorID string
+}
+
+// recordSummary is the RecordTransactionsResponse message
+type recordSummary struct {
+	Accepted int64
+	Replayed int64
+	Shed     []int64
+}
+
+func decodeTransaction(data []byte) (TransactionRequest, error) {
+	var req TransactionRequest
+	err := rpc.Decode(data, func(field int, v rpc.Value) error {
+		switch field {
+		case 1:
+			req.ID = v.String()
+		case 2:
+			req.TenantID = v.String()
+		case 3:
+			req.ProcessorID = v.String()
+		case 4:
+			req.Result = v.String()
+		case 5:
+			req.PaymentMethod = v.String()
+		case 6:
+			req.Country = v.String()
+		case 7:
+			req.Amount = v.Double()
+		case 8:
+			req.Currency = v.String()
+		case 9:
+			t, err := v.Timestamp()
+			if err != nil {
+				return err
+			}
+			req.Timestamp = t.Format(time.RFC3339Nano)
+		case 10:
+			req.DecisionID = v.String()
+		case 11:
+			req.BIN = v.String()
+		case 12:
+			req.CardBrand = v.String()
+		case 13:
+			req.IssuerCountry = v.String()
+		case 14:
+			req.PaymentID = v.String()
+		case 15:
+			req.CustomerID = v.String()
+		}
+		return nil
+	})
+	return req, err
+}
+
+func encodeRecordResponse(txID string, replayed bool, health *domain.ProcessorHealth) []byte {
+	var e rpc.Encoder
+	e.String(1, txID)
+	e.Bool(2, replayed)
+	e.Bool(3, health == nil)
+	if health != nil {
+		e.Message(4, func(m *rpc.Encoder) { writeHealth(m, health) })
+	}
+	return e.Bytes()
+}
+
+func encodeRecordSummary(s recordSummary) []byte {
+	var e rpc.Encoder
+	e.Int64(1, s.Accepted)
+	e.Int64(2, s.Replayed)
+	e.Int64s(3, s.Shed)
+	return e.Bytes()
+}
+
+func decodeRoutingRequest(data []byte) (RoutingRequest, error) {
+	var req RoutingRequest
+	err := rpc.Decode(data, func(field int, v rpc.Value) error {
+		switch field {
+		case 1:
+			req.TenantID = v.String()
+		case 2:
+			req.PaymentMethod = v.String()
+		case 3:
+			req.Country = v.String()
+		case 4:
+			req.Amount = v.Double()
+		case 5:
+			req.Currency = v.String()
+		case 6:
+			req.BIN = v.String()
+		case 7:
+			req.CardBrand = v.String()
+		case 8:
+			req.PaymentID = v.String()
+		case 9:
+			req.Attempted = append(req.Attempted, v.String())
+		case 10:
+			req.CustomerID = v.String()
+		case 11:
+			req.Sticky = v.Bool()
+		}
+		return nil
+	})
+	return req, err
+}
+
+func encodeRecommendation(rec *domain.RoutingRecommendation) []byte {
+	var e rpc.Encoder
+	e.String(1, rec.DecisionID)
+	e.String(2, rec.TenantID)
+	for i := range rec.Recommendations {
+		rank := &rec.Recommendations[i]
+		e.Message(3, func(m *rpc.Encoder) {
+			m.String(1, rank.ProcessorID)
+			m.Int64(2, int64(rank.Rank))
+			m.String(3, string(rank.Status))
+			m.Double(4, rank.AuthorizationRate)
+			m.Double(5, rank.Score)
+			m.Bool(6, rank.Recommended)
+			m.Bool(7, rank.Saturated)
+			m.String(8, rank.Reason)
+		})
+	}
+	e.String(4, string(rec.PaymentMethod))
+	e.String(5, string(rec.Country))
+	e.Double(6, rec.Amount)
+	e.String(7, rec.Currency)
+	e.String(8, rec.PaymentID)
+	e.Int64(9, int64(rec.Attempt))
+	e.Timestamp(10, rec.Timestamp)
+	return e.Bytes()
+}
+
+func decodeHealthRequest(data []byte) (healthRequest, error) {
+	var req healthRequest
+	err := rpc.Decode(data, func(field int, v rpc.Value) error {
+		switch field {
+		case 1:
+			req.TenantID = v.String()
+		case 2:
+			req.ProcessorID = v.String()
+		}
+		return nil
+	})
+	return req, err
+}
+
+func encodeHealthList(tenantID string, healths []*domain.ProcessorHealth) []byte {
+	var e rpc.Encoder
+	e.String(1, tenantID)
+	for _, h := range healths {
+		e.Message(2, func(m *rpc.Encoder) { writeHealth(m, h) })
+	}
+	return e.Bytes()
+}
+
+func encodeHealth(h *domain.ProcessorHealth) []byte {
+	var e rpc.Encoder
+	writeHealth(&e, h)
+	return e.Bytes()
+}
+
+func writeHealth(e *rpc.Encoder, h *domain.ProcessorHealth) {
+	e.String(1, h.ProcessorID)
+	e.String(2, h.TenantID)
+	e.String(3, string(h.Status))
+	e.Double(4, h.AuthorizationRate)
+	e.Int64(5, int64(h.TotalTransactions))
+	e.Int64(6, int64(h.SuccessCount))
+	e.Int64(7, int64(h.FailureCount))
+	e.Int64(8, int64(h.ErrorCount))
+	e.Timestamp(9, h.LastUpdated)
+	if h.LastTransactionAt != nil {
+		e.Timestamp(10, *h.LastTransactionAt)
+	}
+	if h.StatusChangedAt != nil {
+		e.Timestamp(11, *h.StatusChangedAt)
+	}
+	e.String(12, string(h.PreviousStatus))
+}
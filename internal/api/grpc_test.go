@@ -0,0 +1,582 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/auth"
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/idempotency"
+	"github.com/yuno/techcart-failover/internal/ingest"
+	"github.com/yuno/techcart-failover/internal/logging"
+	"github.com/yuno/techcart-failover/internal/ratelimit"
+	"github.com/yuno/techcart-failover/internal/rpc"
+	"github.com/yuno/techcart-failover/internal/tenant"
+	"github.com/yuno/techcart-failover/internal/tracing"
+)
+
+func TestGRPC_RecommendSharesEngine(t *testing.T) {
+	env := newGRPCEnv(t, nil)
+
+	resp, err := env.client.Call(context.Background(), GRPCService+"Recommend", encodeRoutingRequest(RoutingRequest{
+		PaymentMethod: "CARD", Country: "BR", Amount: 100,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := decodeRecommendation(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Recommendations) != 2 || rec.Recommendations[0].ProcessorID != "processor_a" || rec.Currency != "BRL" {
+		t.Fatalf("expected processor_a ranked first of 2, got %+v", rec)
+	}
+	// The decision was stored by the engine the HTTP API explains from
+	if _, ok := env.tenants.Get(tenant.DefaultID).Engine.Decision(rec.DecisionID); !ok {
+		t.Errorf("expected decision %s to be retained", rec.DecisionID)
+	}
+
+	_, err = env.client.Call(context.Background(), GRPCService+"Recommend", encodeRoutingRequest(RoutingRequest{Country: "BR"}))
+	if rpc.CodeOf(err) != rpc.InvalidArgument {
+		t.Errorf("expected InvalidArgument without payment_method, got %v", err)
+	}
+}
+
+func TestGRPC_RecordTransactionIsIdempotent(t *testing.T) {
+	env := newGRPCEnv(t, nil)
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	tx := encodeTransaction(TransactionRequest{
+		ID: "tx-1", ProcessorID: "processor_a", Result: "approved", PaymentMethod: "CARD",
+		Country: "BR", Amount: 100, Currency: "BRL", Timestamp: timestamp,
+	})
+
+	resp, err := env.client.Call(context.Background(), GRPCService+"RecordTransaction", tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txID, replayed, health := decodeRecordResponse(t, resp)
+	if txID != "tx-1" || replayed || health == nil || health.TotalTransactions != 1 {
+		t.Fatalf("unexpected first report: %s %v %+v", txID, replayed, health)
+	}
+	if got := env.tenants.Get(tenant.DefaultID).Calculator.GetHealth("processor_a"); got.TotalTransactions != 1 {
+		t.Errorf("expected the shared calculator to hold the transaction, got %d", got.TotalTransactions)
+	}
+
+	resp, err = env.client.Call(context.Background(), GRPCService+"RecordTransaction", tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, replayed, _ := decodeRecordResponse(t, resp); !replayed {
+		t.Error("expected the retry to be replayed")
+	}
+
+	// The same report over HTTP is a replay too: both APIs share the idempotency store
+	body := `{"id":"tx-1","processor_id":"processor_a","result":"approved","payment_method":"CARD","country":"BR","amount":100,"currency":"BRL","timestamp":"` + timestamp + `"}`
+	rec := httptest.NewRecorder()
+	env.http.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/transactions", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || rec.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("expected an HTTP replay, got %d %q", rec.Code, rec.Header().Get(ReplayedHeader))
+	}
+
+	_, err = env.client.Call(context.Background(), GRPCService+"RecordTransaction", encodeTransaction(TransactionRequest{
+		ID: "tx-1", ProcessorID: "processor_b", Result: "approved", PaymentMethod: "CARD", Country: "BR", Amount: 100, Currency: "BRL",
+	}))
+	if rpc.CodeOf(err) != rpc.AlreadyExists {
+		t.Errorf("expected AlreadyExists for the ID reused on another processor, got %v", err)
+	}
+}
+
+func TestGRPC_RecordTransactionsStream(t *testing.T) {
+	env := newGRPCEnv(t, nil)
+
+	stream, err := env.client.NewStream(context.Background(), GRPCService+"RecordTransactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range []string{"approved", "declined", "approved"} {
+		stream.Send(encodeTransaction(TransactionRequest{
+			ID: "stream-" + string(rune('a'+i)), ProcessorID: "processor_b", Result: result,
+			PaymentMethod: "CARD", Country: "BR", Amount: 50, Currency: "BRL",
+		}))
+	}
+	stream.CloseSend()
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := decodeRecordSummary(resp)
+	if err != nil || summary.Accepted != 3 || len(summary.Shed) != 0 {
+		t.Fatalf("expected 3 accepted, got %+v, %v", summary, err)
+	}
+	if !eventually(func() bool {
+		return env.tenants.Get(tenant.DefaultID).Calculator.GetHealth("processor_b").TotalTransactions == 3
+	}) {
+		t.Error("expected the queued transactions to be recorded")
+	}
+}
+
+func TestGRPC_RecordTransactionsShedsOverLimit(t *testing.T) {
+	env := newGRPCEnv(t, map[string]ratelimit.Rule{"ingest": {Rate: 0.001, Burst: 2}})
+
+	stream, err := env.client.NewStream(context.Background(), GRPCService+"RecordTransactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		stream.Send(encodeTransaction(TransactionRequest{
+			ProcessorID: "processor_a", Result: "approved", PaymentMethod: "CARD", Country: "BR", Amount: 10, Currency: "BRL",
+		}))
+	}
+	stream.CloseSend()
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, _ := decodeRecordSummary(resp)
+	if summary.Accepted != 2 || len(summary.Shed) != 2 || summary.Shed[0] != 2 || summary.Shed[1] != 3 {
+		t.Errorf("expected the last 2 shed, got %+v", summary)
+	}
+}
+
+func TestGRPC_WatchHealth(t *testing.T) {
+	env := newGRPCEnv(t, nil)
+	env.tenants.RecordTransaction(domain.Transaction{
+		ID: "seed", ProcessorID: "processor_a", Result: domain.ResultApproved, Timestamp: time.Now(),
+		PaymentMethod: domain.MethodCard, Country: domain.CountryBR, Amount: 100, Currency: "BRL",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := env.client.NewStream(ctx, GRPCService+"WatchHealth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(encodeHealthRequest(healthRequest{}))
+	stream.CloseSend()
+
+	// Processors with traffic are sent first, as GET /api/v1/health lists them
+	if h := recvHealth(t, stream); h.ProcessorID != "processor_a" || h.TotalTransactions != 1 {
+		t.Fatalf("expected processor_a's current health, got %+v", h)
+	}
+
+	// A transaction recorded over HTTP shows up on the stream
+	rec := httptest.NewRecorder()
+	env.http.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/transactions", strings.NewReader(
+		`{"processor_id":"processor_b","result":"approved","payment_method":"CARD","country":"BR","amount":100,"currency":"BRL"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if h := recvHealth(t, stream); h.ProcessorID != "processor_b" || h.TotalTransactions != 1 {
+		t.Errorf("expected processor_b's update, got %+v", h)
+	}
+
+	// Shutdown ends the stream cleanly
+	env.grpc.Close()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("expected the stream to end with OK, got %v", err)
+	}
+}
+
+func TestGRPC_GetHealth(t *testing.T) {
+	env := newGRPCEnv(t, nil)
+
+	resp, err := env.client.Call(context.Background(), GRPCService+"GetHealth", encodeHealthRequest(healthRequest{ProcessorID: "processor_a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantID, healths := decodeHealthList(t, resp)
+	if tenantID != tenant.DefaultID || len(healths) != 1 || healths[0].ProcessorID != "processor_a" || healths[0].TotalTransactions != 0 {
+		t.Errorf("expected processor_a without traffic for %s, got %s %+v", tenant.DefaultID, tenantID, healths)
+	}
+}
+
+func TestGRPC_RequiresAPIKey(t *testing.T) {
+	env := newGRPCEnv(t, nil)
+	env.client.SetMetadata(auth.APIKeyHeader, "wrong")
+
+	_, err := env.client.Call(context.Background(), GRPCService+"GetHealth", encodeHealthRequest(healthRequest{}))
+	var status *rpc.Error
+	if !errors.As(err, &status) || status.Code != rpc.Unauthenticated {
+		t.Errorf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestGRPC_ResolvesTenantsWithoutCreatingThem(t *testing.T) {
+	env := newGRPCEnv(t, nil)
+	ctx := context.Background()
+
+	_, err := env.client.Call(ctx, GRPCService+"GetHealth", encodeHealthRequest(healthRequest{TenantID: "unknown"}))
+	if rpc.CodeOf(err) != rpc.NotFound {
+		t.Errorf("expected NotFound for an unknown tenant, got %v", err)
+	}
+	if _, ok := env.tenants.Lookup("unknown"); ok {
+		t.Error("expected the unknown tenant not to be created")
+	}
+
+	// A key bound to shop_a defaults to it and may not reach other tenants
+	env.client.SetMetadata(auth.APIKeyHeader, "shop-key")
+	resp, err := env.client.Call(ctx, GRPCService+"GetHealth", encodeHealthRequest(healthRequest{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenantID, _ := decodeHealthList(t, resp); tenantID != "shop_a" {
+		t.Errorf("expected the bound tenant shop_a, got %s", tenantID)
+	}
+	_, err = env.client.Call(ctx, GRPCService+"GetHealth", encodeHealthRequest(healthRequest{TenantID: tenant.DefaultID}))
+	if rpc.CodeOf(err) != rpc.PermissionDenied {
+		t.Errorf("expected PermissionDenied for another tenant, got %v", err)
+	}
+}
+
+func TestGRPC_SpanStatusFromGRPCStatus(t *testing.T) {
+	env := newGRPCEnv(t, nil)
+	statuses, collector := spanStatuses(t)
+	exporter := tracing.NewExporter(collector, "test")
+	traced := httptest.NewUnstartedServer(tracing.Middleware(tracing.NewTracer(exporter, 1), env.grpc,
+		func(r *http.Request) string { return r.URL.Path }))
+	traced.Config.Protocols = new(http.Protocols)
+	traced.Config.Protocols.SetUnencryptedHTTP2(true)
+	traced.Start()
+	defer traced.Close()
+
+	client := rpc.NewClient(traced.URL)
+	client.SetMetadata(auth.APIKeyHeader, "grpc-key")
+	client.Call(context.Background(), GRPCService+"Recommend", encodeRoutingRequest(RoutingRequest{Country: "BR"}))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if stream, err := client.NewStream(ctx, GRPCService+"WatchHealth"); err == nil {
+		stream.Send(encodeHealthRequest(healthRequest{}))
+		stream.CloseSend()
+		for err == nil {
+			_, err = stream.Recv()
+		}
+	}
+	traced.Close() // waits for the WatchHealth span to end
+	exporter.Flush(context.Background())
+
+	// Client errors leave the span unset; server faults mark it failed
+	got := statuses()
+	if got[GRPCService+"Recommend"] != tracing.StatusUnset || got[GRPCService+"WatchHealth"] != tracing.StatusError {
+		t.Errorf("expected Recommend unset and WatchHealth past its deadline failed, got %v", got)
+	}
+}
+
+// Helper functions
+
+// spanStatuses starts a stub OTLP/HTTP receiver and returns the status code
+// of every span received, by name
+func spanStatuses(t *testing.T) (func() map[string]int, string) {
+	t.Helper()
+	var mu sync.Mutex
+	statuses := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						Name   string `json:"name"`
+						Status struct {
+							Code int `json:"code"`
+						} `json:"status"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range body.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					statuses[span.Name] = span.Status.Code
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maps.Clone(statuses)
+	}, server.URL
+}
+
+// grpcEnv is a gRPC server and the HTTP API sharing one registry
+type grpcEnv struct {
+	client  *rpc.Client
+	grpc    *GRPC
+	http    http.Handler
+	tenants *tenant.Registry
+}
+
+func newGRPCEnv(t *testing.T, rules map[string]ratelimit.Rule) grpcEnv {
+	t.Helper()
+	clk := clock.Real()
+	tenants := tenant.NewRegistry(health.NewCalculatorWithClock(clk), clk)
+	tenants.SetDefaultProcessors([]*domain.Processor{
+		{ID: "processor_a", Name: "A", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodCard}},
+		{ID: "processor_b", Name: "B", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodCard}},
+	})
+	tenants.Get(tenant.DefaultID)
+	tenants.Get("shop_a")
+
+	queue := ingest.NewQueue(10, 1, tenants.RecordTransactionContext)
+	t.Cleanup(queue.Close)
+	handler := NewHandler(tenants, idempotency.NewStore(time.Hour), queue)
+	handler.SetLogger(logging.New(discard{}, logging.FormatJSON, nil))
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	keys := auth.NewKeys([]string{"grpc-key"})
+	keys.SetTenantKeys(map[string][]string{"shop_a": {"shop-key"}})
+	service := NewGRPC(handler, keys, ratelimit.NewLimiter(rules, false))
+	service.SetWatchInterval(10 * time.Millisecond)
+	server := httptest.NewUnstartedServer(service)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	t.Cleanup(server.Close)
+	t.Cleanup(service.Close)
+
+	client := rpc.NewClient(server.URL)
+	client.SetMetadata(auth.APIKeyHeader, "grpc-key")
+	return grpcEnv{client: client, grpc: service, http: mux, tenants: tenants}
+}
+
+func eventually(cond func() bool) bool {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+func recvHealth(t *testing.T, stream *rpc.ClientStream) *domain.ProcessorHealth {
+	t.Helper()
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := decodeHealth(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func decodeRecordResponse(t *testing.T, data []byte) (string, bool, *domain.ProcessorHealth) {
+	t.Helper()
+	var (
+		txID     string
+		replayed bool
+		h        *domain.ProcessorHealth
+	)
+	err := rpc.Decode(data, func(field int, v rpc.Value) error {
+		var err error
+		switch field {
+		case 1:
+			txID = v.String()
+		case 2:
+			replayed = v.Bool()
+		case 4:
+			h, err = decodeHealth([]byte(v.String()))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txID, replayed, h
+}
+
+func decodeHealthList(t *testing.T, data []byte) (string, []*domain.ProcessorHealth) {
+	t.Helper()
+	var (
+		tenantID string
+		healths  []*domain.ProcessorHealth
+	)
+	err := rpc.Decode(data, func(field int, v rpc.Value) error {
+		switch field {
+		case 1:
+			tenantID = v.String()
+		case 2:
+			h, err := decodeHealth([]byte(v.String()))
+			healths = append(healths, h)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tenantID, healths
+}
+
+func encodeTransaction(req TransactionRequest) []byte {
+	var e rpc.Encoder
+	e.String(1, req.ID)
+	e.String(2, req.TenantID)
+	e.String(3, req.ProcessorID)
+	e.String(4, req.Result)
+	e.String(5, req.PaymentMethod)
+	e.String(6, req.Country)
+	e.Double(7, req.Amount)
+	e.String(8, req.Currency)
+	if t, err := time.Parse(time.RFC3339, req.Timestamp); err == nil {
+		e.Timestamp(9, t)
+	}
+	e.String(10, req.DecisionID)
+	e.String(11, req.BIN)
+	e.String(12, req.CardBrand)
+	e.String(13, req.IssuerCountry)
+	e.String(14, req.PaymentID)
+	e.String(15, req.CustomerID)
+	return e.Bytes()
+}
+
+func decodeRecordSummary(data []byte) (recordSummary, error) {
+	var s recordSummary
+	err := rpc.Decode(data, func(field int, v rpc.Value) error {
+		switch field {
+		case 1:
+			s.Accepted = v.Int64()
+		case 2:
+			s.Replayed = v.Int64()
+		case 3:
+			shed, err := v.Int64s()
+			s.Shed = append(s.Shed, shed...)
+			return err
+		}
+		return nil
+	})
+	return s, err
+}
+
+func encodeRoutingRequest(req RoutingRequest) []byte {
+	var e rpc.Encoder
+	e.String(1, req.TenantID)
+	e.String(2, req.PaymentMethod)
+	e.String(3, req.Country)
+	e.Double(4, req.Amount)
+	e.String(5, req.Currency)
+	e.String(6, req.BIN)
+	e.String(7, req.CardBrand)
+	e.String(8, req.PaymentID)
+	e.Strings(9, req.Attempted)
+	e.String(10, req.CustomerID)
+	e.Bool(11, req.Sticky)
+	return e.Bytes()
+}
+
+func decodeRecommendation(data []byte) (*domain.RoutingRecommendation, error) {
+	rec := &domain.RoutingRecommendation{}
+	err := rpc.Decode(data, func(field int, v rpc.Value) error {
+		var err error
+		switch field {
+		case 1:
+			rec.DecisionID = v.String()
+		case 2:
+			rec.TenantID = v.String()
+		case 3:
+			var rank domain.ProcessorRank
+			err = v.Message(func(field int, v rpc.Value) error {
+				switch field {
+				case 1:
+					rank.ProcessorID = v.String()
+				case 2:
+					rank.Rank = int(v.Int64())
+				case 3:
+					rank.Status = domain.HealthStatus(v.String())
+				case 4:
+					rank.AuthorizationRate = v.Double()
+				case 5:
+					rank.Score = v.Double()
+				case 6:
+					rank.Recommended = v.Bool()
+				case 7:
+					rank.Saturated = v.Bool()
+				case 8:
+					rank.Reason = v.String()
+				}
+				return nil
+			})
+			rec.Recommendations = append(rec.Recommendations, rank)
+		case 4:
+			rec.PaymentMethod = domain.PaymentMethod(v.String())
+		case 5:
+			rec.Country = domain.Country(v.String())
+		case 6:
+			rec.Amount = v.Double()
+		case 7:
+			rec.Currency = v.String()
+		case 8:
+			rec.PaymentID = v.String()
+		case 9:
+			rec.Attempt = int(v.Int64())
+		case 10:
+			rec.Timestamp, err = v.Timestamp()
+		}
+		return err
+	})
+	return rec, err
+}
+
+func encodeHealthRequest(req healthRequest) []byte {
+	var e rpc.Encoder
+	e.String(1, req.TenantID)
+	e.String(2, req.ProcessorID)
+	return e.Bytes()
+}
+
+func decodeHealth(data []byte) (*domain.ProcessorHealth, error) {
+	h := &domain.ProcessorHealth{}
+	err := rpc.Decode(data, func(field int, v rpc.Value) error {
+		var err error
+		switch field {
+		case 1:
+			h.ProcessorID = v.String()
+		case 2:
+			h.TenantID = v.String()
+		case 3:
+			h.Status = domain.HealthStatus(v.String())
+		case 4:
+			h.AuthorizationRate = v.Double()
+		case 5:
+			h.TotalTransactions = int(v.Int64())
+		case 6:
+			h.SuccessCount = int(v.Int64())
+		case 7:
+			h.FailureCount = int(v.Int64())
+		case 8:
+			h.ErrorCount = int(v.Int64())
+		case 9:
+			h.LastUpdated, err = v.Timestamp()
+		case 10:
+			var t time.Time
+			t, err = v.Timestamp()
+			h.LastTransactionAt = &t
+		case 11:
+			var t time.Time
+			t, err = v.Timestamp()
+			h.StatusChangedAt = &t
+		case 12:
+			h.PreviousStatus = domain.HealthStatus(v.String())
+		}
+		return err
+	})
+	return h, err
+}
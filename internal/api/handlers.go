@@ -1,34 +1,93 @@
 package api
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/yuno/techcart-failover/internal/auth"
 	"github.com/yuno/techcart-failover/internal/domain"
-	"github.com/yuno/techcart-failover/internal/health"
-	"github.com/yuno/techcart-failover/internal/routing"
+	"github.com/yuno/techcart-failover/internal/idempotency"
+	"github.com/yuno/techcart-failover/internal/ingest"
+	"github.com/yuno/techcart-failover/internal/logging"
+	"github.com/yuno/techcart-failover/internal/report"
+	"github.com/yuno/techcart-failover/internal/slo"
+	"github.com/yuno/techcart-failover/internal/tenant"
+	"github.com/yuno/techcart-failover/internal/tracing"
 )
 
+// Request headers understood by the API
+const (
+	TenantHeader        = "X-Tenant-ID"
+	IdempotencyHeader   = "Idempotency-Key"
+	ReplayedHeader      = "Idempotent-Replayed"
+	TransactionIDHeader = "X-Transaction-ID"
+	PreferHeader        = "Prefer"
+)
+
+// openAPISpec describes the merchant-facing API; the contract test keeps it
+// in line with the handlers
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec returns the OpenAPI 3 document served at /api/v1/openapi.json
+func OpenAPISpec() []byte {
+	return slices.Clone(openAPISpec)
+}
+
+// ingestWait bounds how long a synchronous ingest waits for a queued transaction
+const ingestWait = 5 * time.Second
+
 // Handler holds API dependencies
 type Handler struct {
-	calculator *health.Calculator
-	router     *routing.Engine
+	tenants     *tenant.Registry
+	idempotency *idempotency.Store
+	ingest      *ingest.Queue
+	logger      *slog.Logger
 }
 
 // NewHandler creates a new API handler
-func NewHandler(calc *health.Calculator, router *routing.Engine) *Handler {
+func NewHandler(tenants *tenant.Registry, idem *idempotency.Store, queue *ingest.Queue) *Handler {
 	return &Handler{
-		calculator: calc,
-		router:     router,
+		tenants:     tenants,
+		idempotency: idem,
+		ingest:      queue,
+		logger:      slog.Default(),
+	}
+}
+
+// SetLogger sets where recommendations are logged
+func (h *Handler) SetLogger(logger *slog.Logger) {
+	h.logger = logger
+}
+
+// RouteClass groups requests for rate limiting: ingest, routing or read
+func RouteClass(r *http.Request) string {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/transactions":
+		return "ingest"
+	case strings.HasPrefix(r.URL.Path, "/api/v1/routing/"):
+		return "routing"
+	default:
+		return "read"
 	}
 }
 
 // Request/Response types
 
 type TransactionRequest struct {
+	ID            string  `json:"id,omitempty"`
+	TenantID      string  `json:"tenant_id,omitempty"`
 	ProcessorID   string  `json:"processor_id"`
 	Result        string  `json:"result"`
 	PaymentMethod string  `json:"payment_method"`
@@ -36,16 +95,31 @@ type TransactionRequest struct {
 	Amount        float64 `json:"amount"`
 	Currency      string  `json:"currency"`
 	Timestamp     string  `json:"timestamp,omitempty"`
+	DecisionID    string  `json:"decision_id,omitempty"`
+	BIN           string  `json:"bin,omitempty"`
+	CardBrand     string  `json:"card_brand,omitempty"`
+	IssuerCountry string  `json:"issuer_country,omitempty"`
+	PaymentID     string  `json:"payment_id,omitempty"`
+	CustomerID    string  `json:"customer_id,omitempty"`
 }
 
 type RoutingRequest struct {
-	PaymentMethod string  `json:"payment_method"`
-	Country       string  `json:"country"`
-	Amount        float64 `json:"amount"`
+	TenantID      string   `json:"tenant_id,omitempty"`
+	PaymentMethod string   `json:"payment_method"`
+	Country       string   `json:"country"`
+	Amount        float64  `json:"amount"`
+	Currency      string   `json:"currency,omitempty"`
+	BIN           string   `json:"bin,omitempty"`
+	CardBrand     string   `json:"card_brand,omitempty"`
+	PaymentID     string   `json:"payment_id,omitempty"`
+	Attempted     []string `json:"attempted,omitempty"`
+	CustomerID    string   `json:"customer_id,omitempty"`
+	Sticky        bool     `json:"sticky,omitempty"`
 }
 
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // RegisterRoutes registers all API routes
@@ -59,16 +133,41 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	// Health monitoring
 	mux.HandleFunc("GET /api/v1/health", h.GetAllHealth)
 	mux.HandleFunc("GET /api/v1/health/{processorId}", h.GetProcessorHealth)
+	mux.HandleFunc("GET /api/v1/health/{processorId}/history", h.GetProcessorHistory)
 
 	// Routing
 	mux.HandleFunc("POST /api/v1/routing/recommend", h.GetRoutingRecommendation)
 	mux.HandleFunc("GET /api/v1/routing/recommend", h.GetRoutingRecommendationQuery)
+	mux.HandleFunc("GET /api/v1/routing/decisions/{id}", h.GetRoutingDecision)
+	mux.HandleFunc("GET /api/v1/routing/feedback", h.GetRoutingFeedback)
+	mux.HandleFunc("GET /api/v1/routing/payments/{id}", h.GetPaymentAttempts)
+	mux.HandleFunc("GET /api/v1/routing/attempts", h.GetAttemptSummary)
 
 	// Processors
 	mux.HandleFunc("GET /api/v1/processors", h.GetProcessors)
+	mux.HandleFunc("POST /api/v1/processors", h.RegisterProcessor)
+	mux.HandleFunc("GET /api/v1/processors/load", h.GetProcessorLoad)
 
 	// Alerts
 	mux.HandleFunc("GET /api/v1/alerts", h.GetAlerts)
+
+	// SLOs
+	mux.HandleFunc("GET /api/v1/slos", h.GetSLOs)
+	mux.HandleFunc("POST /api/v1/slos", h.SetSLO)
+	mux.HandleFunc("DELETE /api/v1/slos/{id}", h.DeleteSLO)
+
+	// Reports
+	mux.HandleFunc("GET /api/v1/reports/sla", h.GetSLAReport)
+
+	// Tenants and shared network view
+	mux.HandleFunc("GET /api/v1/tenants", h.GetTenants)
+	mux.HandleFunc("GET /api/v1/network/health", h.GetNetworkHealth)
+
+	// Ingestion backpressure
+	mux.HandleFunc("GET /api/v1/ingest/stats", h.GetIngestStats)
+
+	// API description
+	mux.HandleFunc("GET /api/v1/openapi.json", h.GetOpenAPI)
 }
 
 // GET / - Home page with API info
@@ -78,13 +177,31 @@ func (h *Handler) Home(w http.ResponseWriter, r *http.Request) {
 		"version": "1.0.0",
 		"endpoints": map[string]string{
 			"processors":     "GET /api/v1/processors",
+			"register":       "POST /api/v1/processors",
+			"load":           "GET /api/v1/processors/load",
 			"health":         "GET /api/v1/health",
 			"health_detail":  "GET /api/v1/health/{processorId}",
+			"health_history": "GET /api/v1/health/{processorId}/history?from=&to=&step=",
 			"routing":        "GET /api/v1/routing/recommend?payment_method=PIX&country=BR",
+			"decision":       "GET /api/v1/routing/decisions/{id}",
+			"feedback":       "GET /api/v1/routing/feedback",
+			"payment":        "GET /api/v1/routing/payments/{id}",
+			"attempts":       "GET /api/v1/routing/attempts",
 			"transactions":   "POST /api/v1/transactions",
 			"alerts":         "GET /api/v1/alerts",
+			"sla_report":     "GET /api/v1/reports/sla?from=&to=&format=json|csv",
+			"slos":           "GET /api/v1/slos",
+			"set_slo":        "POST /api/v1/slos",
+			"tenants":        "GET /api/v1/tenants",
+			"network_health": "GET /api/v1/network/health",
+			"ingest_stats":   "GET /api/v1/ingest/stats",
+			"dashboard":      "GET /dashboard",
+			"liveness":       "GET /livez",
+			"readiness":      "GET /readyz",
+			"openapi":        "GET /api/v1/openapi.json",
 		},
-		"docs": "https://github.com/nicpenaloza/yuno-challenge-techcart",
+		"tenant_header": TenantHeader,
+		"docs":          "https://github.com/nicpenaloza/yuno-challenge-techcart",
 	}, http.StatusOK)
 }
 
@@ -96,21 +213,120 @@ func (h *Handler) RecordTransaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.ProcessorID == "" {
-		h.writeError(w, "processor_id is required", http.StatusBadRequest)
+	if msg := req.validate(); msg != "" {
+		h.writeError(w, msg, http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" {
+		req.ID = r.Header.Get(IdempotencyHeader)
+	}
+
+	t, ok := h.tenant(w, r, req.TenantID)
+	if !ok {
 		return
 	}
 
-	// Parse timestamp or use current time
-	timestamp := time.Now()
+	async := strings.Contains(r.Header.Get(PreferHeader), "respond-async")
+	txID, resp, replayed, err := h.ingestReport(r.Context(), req, t, async)
+	w.Header().Set(TransactionIDHeader, txID)
+	if err == idempotency.ErrConflict {
+		h.writeError(w, errConflict, http.StatusConflict)
+		return
+	}
+	h.writeIngest(w, resp, replayed)
+}
+
+// errConflict is returned when a transaction ID is reused for another processor
+const errConflict = "transaction id already recorded for a different processor"
+
+// ingestReport ingests a validated report as a transaction of t,
+// over HTTP or gRPC. Client-provided IDs make retries idempotent; without
+// one an ID is generated. It returns the transaction ID, the response and
+// whether it replays an earlier report, or idempotency.ErrConflict.
+func (h *Handler) ingestReport(ctx context.Context, req TransactionRequest, t *tenant.Tenant, async bool) (string, idempotency.Response, bool, error) {
+	txID := req.ID
+	clientID := txID != ""
+	if !clientID {
+		txID = generateID()
+	}
+
+	tx := req.Transaction(t.Calculator.Clock().Now())
+	tx.ID = txID
+	tx.TenantID = t.ID
+	tx.RequestID = logging.RequestID(ctx)
+
+	if !clientID {
+		return txID, h.ingestTransaction(ctx, tx, async), false, nil
+	}
+
+	key := tx.TenantID + "/" + txID
+	ctx, span := tracing.Start(ctx, "idempotency.Do")
+	resp, outcome, err := h.idempotency.Do(key, tx.ProcessorID, req.fingerprint(), func() idempotency.Response {
+		return h.ingestTransaction(ctx, tx, async)
+	})
+	span.SetAttributes("outcome", string(outcome))
+	span.End()
+	return txID, resp, outcome == idempotency.OutcomeReplayed, err
+}
+
+// ingestTransaction records tx through the bounded queue. Async requests
+// are acknowledged with 202 once queued; a full queue sheds with 503.
+func (h *Handler) ingestTransaction(ctx context.Context, tx domain.Transaction, async bool) idempotency.Response {
+	if async {
+		if err := h.ingest.Enqueue(ctx, tx); err != nil {
+			return shedResponse(err)
+		}
+		body, _ := json.Marshal(map[string]string{"status": "accepted", "transaction_id": tx.ID})
+		return idempotency.Response{Status: http.StatusAccepted, Body: body}
+	}
+
+	result, err := h.ingest.Submit(ctx, tx)
+	if err != nil {
+		return shedResponse(err)
+	}
+	select {
+	case health := <-result:
+		body, _ := json.Marshal(health)
+		return idempotency.Response{Status: http.StatusOK, Body: body}
+	case <-time.After(ingestWait):
+		// Still queued and will be recorded; the client may retry with the same ID
+		body, _ := json.Marshal(map[string]string{"status": "accepted", "transaction_id": tx.ID})
+		return idempotency.Response{Status: http.StatusAccepted, Body: body}
+	}
+}
+
+func shedResponse(err error) idempotency.Response {
+	body, _ := json.Marshal(ErrorResponse{Error: err.Error()})
+	return idempotency.Response{Status: http.StatusServiceUnavailable, Body: body}
+}
+
+func (h *Handler) writeIngest(w http.ResponseWriter, resp idempotency.Response, replayed bool) {
+	if replayed {
+		w.Header().Set(ReplayedHeader, "true")
+	}
+	if resp.Status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	h.writeRaw(w, resp.Body, resp.Status)
+}
+
+// errInvalidBIN is returned for a bin without 6 digits
+const errInvalidBIN = "bin must start with at least 6 digits"
+
+// Transaction converts the request into a domain transaction. The timestamp
+// falls back to now when missing or not RFC3339; ID and tenant are left as
+// sent. Only the first 8 digits of bin are kept.
+func (req TransactionRequest) Transaction(now time.Time) domain.Transaction {
+	timestamp := now
 	if req.Timestamp != "" {
 		if t, err := time.Parse(time.RFC3339, req.Timestamp); err == nil {
 			timestamp = t
 		}
 	}
 
-	tx := domain.Transaction{
-		ID:            generateID(),
+	return domain.Transaction{
+		ID:            req.ID,
+		TenantID:      req.TenantID,
 		ProcessorID:   req.ProcessorID,
 		Timestamp:     timestamp,
 		Result:        domain.TransactionResult(req.Result),
@@ -118,19 +334,45 @@ func (h *Handler) RecordTransaction(w http.ResponseWriter, r *http.Request) {
 		Country:       domain.Country(req.Country),
 		Amount:        req.Amount,
 		Currency:      req.Currency,
+		DecisionID:    req.DecisionID,
+		BIN:           domain.TruncateBIN(req.BIN),
+		CardBrand:     strings.ToUpper(req.CardBrand),
+		IssuerCountry: domain.Country(req.IssuerCountry),
+		PaymentID:     req.PaymentID,
+		CustomerID:    req.CustomerID,
 	}
+}
 
-	health := h.calculator.RecordTransaction(tx)
-	h.writeJSON(w, health, http.StatusOK)
+// validate returns why req cannot be recorded, or "" when it can
+func (req TransactionRequest) validate() string {
+	if req.ProcessorID == "" {
+		return "processor_id is required"
+	}
+	if req.BIN != "" && domain.TruncateBIN(req.BIN) == "" {
+		return errInvalidBIN
+	}
+	return ""
+}
+
+// fingerprint identifies the reported outcome; a change means an update
+func (req TransactionRequest) fingerprint() string {
+	return fmt.Sprintf("%s|%s|%s|%g|%s|%s|%s|%s|%s|%s|%s|%s",
+		req.Result, req.PaymentMethod, req.Country, req.Amount, req.Currency, req.Timestamp, req.DecisionID,
+		domain.TruncateBIN(req.BIN), strings.ToUpper(req.CardBrand), req.IssuerCountry, req.PaymentID, req.CustomerID)
 }
 
 // GET /api/v1/health - Get health status of all processors
 func (h *Handler) GetAllHealth(w http.ResponseWriter, r *http.Request) {
-	health := h.calculator.GetAllHealth()
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	health := t.Calculator.GetAllHealth()
 	h.writeJSON(w, map[string]interface{}{
+		"tenant_id":  t.ID,
 		"processors": health,
 		"count":      len(health),
-		"timestamp":  time.Now(),
+		"timestamp":  t.Calculator.Clock().Now(),
 	}, http.StatusOK)
 }
 
@@ -142,8 +384,12 @@ func (h *Handler) GetProcessorHealth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	health := h.calculator.GetHealth(processorID)
-	recentTxs := h.calculator.GetRecentTransactions(processorID, 20)
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	health := t.Calculator.GetHealth(processorID)
+	recentTxs := t.Calculator.GetRecentTransactions(processorID, 20)
 
 	h.writeJSON(w, map[string]interface{}{
 		"health":              health,
@@ -152,6 +398,56 @@ func (h *Handler) GetProcessorHealth(w http.ResponseWriter, r *http.Request) {
 	}, http.StatusOK)
 }
 
+// GET /api/v1/health/{processorId}/history?from=&to=&step= - Health over time
+// in fixed buckets (default: the last hour at the finest step kept)
+func (h *Handler) GetProcessorHistory(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+
+	from, to, err := period(query, t.Calculator.Clock().Now(), time.Hour)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var step time.Duration
+	if v := query.Get("step"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			h.writeError(w, "step must be a positive duration like 5m", http.StatusBadRequest)
+			return
+		}
+		step = parsed
+	}
+
+	history, err := t.Calculator.History(r.PathValue("processorId"), from, to, step)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeJSON(w, history, http.StatusOK)
+}
+
+// period parses the from and to query params (RFC 3339). To defaults to
+// now and from to length before to.
+func period(query url.Values, now time.Time, length time.Duration) (from, to time.Time, err error) {
+	to = now
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("to must be an RFC 3339 timestamp")
+		}
+	}
+	from = to.Add(-length)
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("from must be an RFC 3339 timestamp")
+		}
+	}
+	return from, to, nil
+}
+
 // POST /api/v1/routing/recommend - Get routing recommendation
 func (h *Handler) GetRoutingRecommendation(w http.ResponseWriter, r *http.Request) {
 	var req RoutingRequest
@@ -160,20 +456,51 @@ func (h *Handler) GetRoutingRecommendation(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if req.PaymentMethod == "" || req.Country == "" {
-		h.writeError(w, "payment_method and country are required", http.StatusBadRequest)
+	if msg := req.validate(); msg != "" {
+		h.writeError(w, msg, http.StatusBadRequest)
 		return
 	}
 
-	recommendation := h.router.Recommend(
-		domain.PaymentMethod(req.PaymentMethod),
-		domain.Country(req.Country),
-		req.Amount,
-	)
+	t, ok := h.tenant(w, r, req.TenantID)
+	if !ok {
+		return
+	}
+	recommendation := t.Engine.RecommendPaymentContext(r.Context(), req.payment())
+	h.logRecommendation(r.Context(), recommendation)
 	h.writeJSON(w, recommendation, http.StatusOK)
 }
 
-// GET /api/v1/routing/recommend?payment_method=&country=&amount=
+// validate returns why req cannot be routed, or "" when it can
+func (req RoutingRequest) validate() string {
+	switch {
+	case req.PaymentMethod == "" || req.Country == "":
+		return "payment_method and country are required"
+	case req.Amount < 0:
+		return "amount must not be negative"
+	case req.BIN != "" && domain.TruncateBIN(req.BIN) == "":
+		return errInvalidBIN
+	}
+	return ""
+}
+
+// payment converts the request into the payment to route
+func (req RoutingRequest) payment() domain.Payment {
+	return domain.Payment{
+		PaymentMethod: domain.PaymentMethod(req.PaymentMethod),
+		Country:       domain.Country(req.Country),
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+		BIN:           req.BIN,
+		CardBrand:     strings.ToUpper(req.CardBrand),
+		PaymentID:     req.PaymentID,
+		Attempted:     req.Attempted,
+		CustomerID:    req.CustomerID,
+		Sticky:        req.Sticky,
+	}
+}
+
+// GET /api/v1/routing/recommend?payment_method=&country=&amount=&currency=&bin=&card_brand=
+// &payment_id=&attempted=a,b&customer_id=&sticky=true
 func (h *Handler) GetRoutingRecommendationQuery(w http.ResponseWriter, r *http.Request) {
 	method := r.URL.Query().Get("payment_method")
 	country := r.URL.Query().Get("country")
@@ -183,53 +510,401 @@ func (h *Handler) GetRoutingRecommendationQuery(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	recommendation := h.router.Recommend(
-		domain.PaymentMethod(method),
-		domain.Country(country),
-		0,
-	)
+	var amount float64
+	if raw := r.URL.Query().Get("amount"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 {
+			h.writeError(w, "amount must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		amount = parsed
+	}
+	bin := r.URL.Query().Get("bin")
+	if bin != "" && domain.TruncateBIN(bin) == "" {
+		h.writeError(w, errInvalidBIN, http.StatusBadRequest)
+		return
+	}
+
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	recommendation := t.Engine.RecommendPaymentContext(r.Context(), domain.Payment{
+		PaymentMethod: domain.PaymentMethod(method),
+		Country:       domain.Country(country),
+		Amount:        amount,
+		Currency:      r.URL.Query().Get("currency"),
+		BIN:           bin,
+		CardBrand:     strings.ToUpper(r.URL.Query().Get("card_brand")),
+		PaymentID:     r.URL.Query().Get("payment_id"),
+		Attempted:     splitList(r.URL.Query().Get("attempted")),
+		CustomerID:    r.URL.Query().Get("customer_id"),
+		Sticky:        r.URL.Query().Get("sticky") == "true",
+	})
+	h.logRecommendation(r.Context(), recommendation)
 	h.writeJSON(w, recommendation, http.StatusOK)
 }
 
+// logRecommendation logs the chosen processor with the request's ID
+func (h *Handler) logRecommendation(ctx context.Context, rec *domain.RoutingRecommendation) {
+	chosen, status := "", domain.HealthStatus("")
+	if len(rec.Recommendations) > 0 {
+		chosen, status = rec.Recommendations[0].ProcessorID, rec.Recommendations[0].Status
+	}
+	h.logger.InfoContext(ctx, "recommendation",
+		"decision_id", rec.DecisionID,
+		"tenant", rec.TenantID,
+		"payment_method", rec.PaymentMethod,
+		"country", rec.Country,
+		"processor", chosen,
+		"processor_status", status,
+		"candidates", len(rec.Recommendations),
+	)
+}
+
+// GET /api/v1/routing/decisions/{id} - Explain a past routing decision
+func (h *Handler) GetRoutingDecision(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	decision, found := t.Engine.Decision(r.PathValue("id"))
+	if !found {
+		h.writeError(w, "Decision not found or no longer retained", http.StatusNotFound)
+		return
+	}
+	h.writeJSON(w, decision, http.StatusOK)
+}
+
+// GET /api/v1/routing/feedback - Adherence to recommendations and auth-rate lift per corridor
+func (h *Handler) GetRoutingFeedback(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	h.writeJSON(w, t.Feedback.Report(t.ID), http.StatusOK)
+}
+
+// GET /api/v1/routing/payments/{id} - Attempt sequence for a payment
+func (h *Handler) GetPaymentAttempts(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	attempts, found := t.Engine.PaymentAttempts(r.PathValue("id"))
+	if !found {
+		h.writeError(w, "No attempts recorded for this payment", http.StatusNotFound)
+		return
+	}
+	h.writeJSON(w, attempts, http.StatusOK)
+}
+
+// GET /api/v1/routing/attempts - Outcomes by attempt number across recent payments
+func (h *Handler) GetAttemptSummary(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	h.writeJSON(w, map[string]interface{}{
+		"tenant_id": t.ID,
+		"summary":   t.Engine.AttemptSummary(),
+	}, http.StatusOK)
+}
+
+// splitList parses a comma-separated query value, skipping empty items
+func splitList(raw string) []string {
+	var result []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 // GET /api/v1/processors - List all registered processors
 func (h *Handler) GetProcessors(w http.ResponseWriter, r *http.Request) {
-	processors := h.router.GetProcessors()
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	processors := t.Engine.GetProcessors()
 	h.writeJSON(w, map[string]interface{}{
+		"tenant_id":  t.ID,
 		"processors": processors,
 		"count":      len(processors),
 	}, http.StatusOK)
 }
 
+// GET /api/v1/processors/load - Live load against each processor's capacity
+func (h *Handler) GetProcessorLoad(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	h.writeJSON(w, map[string]interface{}{
+		"tenant_id":  t.ID,
+		"processors": t.Engine.Loads(),
+		"timestamp":  t.Calculator.Clock().Now(),
+	}, http.StatusOK)
+}
+
+// POST /api/v1/processors - Register a processor for the requesting tenant
+func (h *Handler) RegisterProcessor(w http.ResponseWriter, r *http.Request) {
+	var p domain.Processor
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		h.writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if p.ID == "" {
+		h.writeError(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	if len(p.Countries) == 0 || len(p.PaymentMethods) == 0 {
+		h.writeError(w, "countries and payment_methods are required", http.StatusBadRequest)
+		return
+	}
+	if p.MaxTPS < 0 || p.MaxInFlight < 0 {
+		h.writeError(w, "max_tps and max_in_flight must not be negative", http.StatusBadRequest)
+		return
+	}
+	if err := p.ValidateLimits(); err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, ok := h.tenant(w, r, p.TenantID)
+	if !ok {
+		return
+	}
+	registered := h.tenants.RegisterProcessor(t.ID, &p)
+	h.writeJSON(w, registered, http.StatusCreated)
+}
+
 // GET /api/v1/alerts - Get health status transitions
 func (h *Handler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+
 	// Default to last hour
-	since := time.Now().Add(-1 * time.Hour)
+	now := t.Calculator.Clock().Now()
+	since := now.Add(-1 * time.Hour)
 
 	if sinceParam := r.URL.Query().Get("since"); sinceParam != "" {
-		if t, err := time.Parse(time.RFC3339, sinceParam); err == nil {
-			since = t
+		if parsed, err := time.Parse(time.RFC3339, sinceParam); err == nil {
+			since = parsed
 		}
 	}
+	transitions := t.Calculator.GetTransitions(since)
+	sloAlerts := t.SLO.Alerts(since)
+	if sloAlerts == nil {
+		sloAlerts = []domain.SLOAlert{}
+	}
+	h.writeJSON(w, map[string]interface{}{
+		"tenant_id":  t.ID,
+		"alerts":     transitions,
+		"count":      len(transitions),
+		"slo_alerts": sloAlerts,
+		"slo_count":  len(sloAlerts),
+		"since":      since,
+		"timestamp":  now,
+	}, http.StatusOK)
+}
 
-	transitions := h.calculator.GetTransitions(since)
+// GET /api/v1/slos - SLOs with each processor's budget and burn rates
+func (h *Handler) GetSLOs(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
 	h.writeJSON(w, map[string]interface{}{
-		"alerts":    transitions,
-		"count":     len(transitions),
-		"since":     since,
-		"timestamp": time.Now(),
+		"tenant_id": t.ID,
+		"slos":      t.SLO.SLOs(),
+		"status":    t.SLO.Status(t.Calculator.Clock().Now()),
 	}, http.StatusOK)
 }
 
+// POST /api/v1/slos - Add or replace an SLO for a tenant
+func (h *Handler) SetSLO(w http.ResponseWriter, r *http.Request) {
+	var def slo.SLO
+	if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+		h.writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	if err := t.SLO.Set(def); err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeJSON(w, def, http.StatusCreated)
+}
+
+// DELETE /api/v1/slos/{id} - Remove an SLO
+func (h *Handler) DeleteSLO(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	if !t.SLO.Remove(r.PathValue("id")) {
+		h.writeError(w, "SLO not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/v1/reports/sla?from=&to=&format=&table= - Processor availability,
+// outages and auth rates over a period (default: the last 30 days)
+func (h *Handler) GetSLAReport(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.tenant(w, r, "")
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+
+	from, to, err := period(query, t.Calculator.Clock().Now(), report.DefaultPeriod)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !to.After(from) {
+		h.writeError(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+	rep := report.Build(t.Calculator, t.Engine.GetProcessors(), from, to)
+
+	switch format := query.Get("format"); format {
+	case "", "json":
+		h.writeJSON(w, rep, http.StatusOK)
+	case "csv":
+		table := query.Get("table")
+		if table == "" {
+			table = report.TableProcessors
+		}
+		if !slices.Contains(report.Tables, table) {
+			h.writeError(w, fmt.Sprintf("table must be one of %s", strings.Join(report.Tables, ", ")), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "sla-"+table+".csv"))
+		report.WriteCSV(w, rep, table)
+	default:
+		h.writeError(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+// GET /api/v1/tenants - List tenants with tracked state
+func (h *Handler) GetTenants(w http.ResponseWriter, r *http.Request) {
+	type tenantSummary struct {
+		ID         string `json:"id"`
+		Processors int    `json:"processors"`
+		Tracked    int    `json:"tracked_processors"`
+	}
+
+	// Keys bound to a tenant only see theirs
+	tenants := h.tenants.List()
+	if bound := auth.TenantFromContext(r.Context()); bound != "" {
+		tenants = slices.DeleteFunc(tenants, func(t *tenant.Tenant) bool { return t.ID != bound })
+	}
+	result := make([]tenantSummary, 0, len(tenants))
+	for _, t := range tenants {
+		result = append(result, tenantSummary{
+			ID:         t.ID,
+			Processors: len(t.Engine.GetProcessors()),
+			Tracked:    len(t.Calculator.GetAllHealth()),
+		})
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"tenants": result,
+		"count":   len(result),
+	}, http.StatusOK)
+}
+
+// GET /api/v1/network/health - Health aggregated across all tenants
+func (h *Handler) GetNetworkHealth(w http.ResponseWriter, r *http.Request) {
+	// Cross-tenant data is not for keys bound to one tenant
+	if auth.TenantFromContext(r.Context()) != "" {
+		h.writeError(w, tenant.ErrOtherTenant.Error(), http.StatusForbidden)
+		return
+	}
+	network := h.tenants.Network()
+	if network == nil {
+		h.writeError(w, "network view is disabled", http.StatusNotFound)
+		return
+	}
+
+	health := network.GetAllHealth()
+	h.writeJSON(w, map[string]interface{}{
+		"processors": health,
+		"count":      len(health),
+		"timestamp":  network.Clock().Now(),
+	}, http.StatusOK)
+}
+
+// GET /api/v1/ingest/stats - Ingestion queue depth and shed count
+func (h *Handler) GetIngestStats(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, h.ingest.Stats(), http.StatusOK)
+}
+
+// GET /api/v1/openapi.json - OpenAPI 3 document for the merchant API
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
+
 // Helper methods
 
+// tenantID returns the tenant named by the header, query string or body
+// field, or "" when none is
+func tenantID(r *http.Request, bodyTenant string) string {
+	if id := r.Header.Get(TenantHeader); id != "" {
+		return id
+	}
+	if id := r.URL.Query().Get("tenant_id"); id != "" {
+		return id
+	}
+	return bodyTenant
+}
+
+// tenant resolves the requested tenant without creating it. Unknown
+// tenants get 404, and tenants other than the API key's 403.
+func (h *Handler) tenant(w http.ResponseWriter, r *http.Request, bodyTenant string) (*tenant.Tenant, bool) {
+	t, err := h.tenants.Resolve(tenantID(r, bodyTenant), auth.TenantFromContext(r.Context()))
+	switch err {
+	case nil:
+		return t, true
+	case tenant.ErrOtherTenant:
+		h.writeError(w, err.Error(), http.StatusForbidden)
+	default:
+		h.writeError(w, err.Error(), http.StatusNotFound)
+	}
+	return nil, false
+}
+
 func (h *Handler) writeJSON(w http.ResponseWriter, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
 
+func (h *Handler) writeRaw(w http.ResponseWriter, body []byte, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// body may be a stored response shared by concurrent replays: no append
+	w.Write(body)
+	w.Write([]byte("\n"))
+}
+
 func (h *Handler) writeError(w http.ResponseWriter, message string, status int) {
-	h.writeJSON(w, ErrorResponse{Error: message}, status)
+	h.writeJSON(w, ErrorResponse{Error: message, RequestID: w.Header().Get(logging.RequestIDHeader)}, status)
 }
 
 var idCounter int64
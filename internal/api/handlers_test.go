@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/auth"
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/idempotency"
+	"github.com/yuno/techcart-failover/internal/ingest"
+	"github.com/yuno/techcart-failover/internal/tenant"
+)
+
+func TestHandler_NetworkHealthNeedsAnUnboundKey(t *testing.T) {
+	server := newHandlerServer(t, clock.Real())
+
+	for key, want := range map[string]int{"ops-key": http.StatusOK, "shop-key": http.StatusForbidden} {
+		if rec := serveAPI(server, http.MethodGet, "/api/v1/network/health", key, ""); rec.Code != want {
+			t.Errorf("key %s: expected %d, got %d: %s", key, want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandler_RegisterProcessorValidatesLimits(t *testing.T) {
+	server := newHandlerServer(t, clock.Real())
+
+	cases := map[string]int{
+		`{"BRL": {"min_amount": 500, "max_amount": 100}}`: http.StatusBadRequest,
+		`{"BRL": {"min_amount": -1}}`:                     http.StatusBadRequest,
+		`{"BRL": {"daily_volume_cap": -1}}`:               http.StatusBadRequest,
+		`{"BRL": {"monthly_volume_cap": -1}}`:             http.StatusBadRequest,
+		`{"BRL": {"min_amount": 10, "max_amount": 500}}`:  http.StatusCreated,
+	}
+	for limits, want := range cases {
+		body := `{"id": "p_limits", "countries": ["BR"], "payment_methods": ["PIX"], "limits": ` + limits + `}`
+		if rec := serveAPI(server, http.MethodPost, "/api/v1/processors", "ops-key", body); rec.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", limits, want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandler_UsesTheTenantClock(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	server := newHandlerServer(t, clock.NewFake(now))
+
+	// Reports without a timestamp are stamped by the clock
+	body := `{"processor_id":"processor_a","result":"approved","payment_method":"CARD","country":"BR"}`
+	var health struct {
+		LastTransactionAt time.Time `json:"last_transaction_at"`
+	}
+	decodeBody(t, serveAPI(server, http.MethodPost, "/api/v1/transactions", "ops-key", body), &health)
+	if !health.LastTransactionAt.Equal(now) {
+		t.Errorf("expected the report stamped %s, got %s", now, health.LastTransactionAt)
+	}
+
+	var alerts struct {
+		Since     time.Time `json:"since"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+	decodeBody(t, serveAPI(server, http.MethodGet, "/api/v1/alerts", "ops-key", ""), &alerts)
+	if !alerts.Timestamp.Equal(now) || !alerts.Since.Equal(now.Add(-time.Hour)) {
+		t.Errorf("expected the alert window to end at %s, got %s to %s", now, alerts.Since, alerts.Timestamp)
+	}
+}
+
+func TestHandler_WriteRawLeavesStoredBodyAlone(t *testing.T) {
+	// Stored idempotent responses are shared; spare capacity must stay untouched
+	body := make([]byte, 2, 8)
+	copy(body, "{}")
+
+	rec := httptest.NewRecorder()
+	(&Handler{}).writeRaw(rec, body, http.StatusOK)
+
+	if rec.Body.String() != "{}\n" {
+		t.Errorf("expected the body and a newline, got %q", rec.Body.String())
+	}
+	if spare := body[:cap(body)][2]; spare != 0 {
+		t.Errorf("expected the stored body's backing array unchanged, got %q", spare)
+	}
+}
+
+// Helper functions
+
+// newHandlerServer serves the API behind auth with an unbound key (ops-key)
+// and a key bound to shop_a (shop-key)
+func newHandlerServer(t *testing.T, clk clock.Clock) http.Handler {
+	t.Helper()
+	tenants := tenant.NewRegistry(health.NewCalculatorWithClock(clk), clk)
+	tenants.Get(tenant.DefaultID)
+	tenants.Get("shop_a")
+
+	queue := ingest.NewQueue(10, 1, tenants.RecordTransactionContext)
+	t.Cleanup(queue.Close)
+	mux := http.NewServeMux()
+	NewHandler(tenants, idempotency.NewStore(time.Hour), queue).RegisterRoutes(mux)
+
+	keys := auth.NewKeys([]string{"ops-key"})
+	keys.SetTenantKeys(map[string][]string{"shop_a": {"shop-key"}})
+	return keys.Middleware(mux)
+}
+
+func serveAPI(server http.Handler, method, target, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(auth.APIKeyHeader, key)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
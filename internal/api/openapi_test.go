@@ -0,0 +1,387 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/auth"
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/idempotency"
+	"github.com/yuno/techcart-failover/internal/ingest"
+	"github.com/yuno/techcart-failover/internal/logging"
+	"github.com/yuno/techcart-failover/internal/tenant"
+)
+
+// contractCase is one request whose response must match the document
+type contractCase struct {
+	operation string // "METHOD /path/{template}" as documented
+	path      string
+	body      string
+	headers   map[string]string
+	status    int
+}
+
+func TestOpenAPI_ResponsesMatchSchemas(t *testing.T) {
+	doc := loadSpec(t)
+	server, decisionID := contractServer(t)
+
+	cases := []contractCase{
+		{operation: "POST /api/v1/transactions", path: "/api/v1/transactions", status: http.StatusOK,
+			body: `{"id":"tx-contract","processor_id":"processor_a","result":"approved","payment_method":"CARD","country":"BR","amount":120,"currency":"BRL","bin":"41111111","card_brand":"visa"}`},
+		{operation: "POST /api/v1/transactions", path: "/api/v1/transactions", status: http.StatusAccepted,
+			headers: map[string]string{PreferHeader: "respond-async"},
+			body:    `{"processor_id":"processor_a","result":"declined","payment_method":"PIX","country":"BR"}`},
+		{operation: "POST /api/v1/transactions", path: "/api/v1/transactions", status: http.StatusBadRequest,
+			body: `{"result":"approved"}`},
+		{operation: "POST /api/v1/transactions", path: "/api/v1/transactions", status: http.StatusConflict,
+			body: `{"id":"tx-contract","processor_id":"processor_b","result":"approved","payment_method":"CARD","country":"BR"}`},
+		{operation: "GET /api/v1/health", path: "/api/v1/health", status: http.StatusOK},
+		{operation: "GET /api/v1/health/{processorId}", path: "/api/v1/health/processor_a", status: http.StatusOK},
+		{operation: "GET /api/v1/health/{processorId}", path: "/api/v1/health/unseen", status: http.StatusOK},
+		{operation: "POST /api/v1/routing/recommend", path: "/api/v1/routing/recommend", status: http.StatusOK,
+			body: `{"payment_method":"CARD","country":"BR","amount":120,"bin":"411111","payment_id":"pay-1","attempted":["processor_c"]}`},
+		{operation: "POST /api/v1/routing/recommend", path: "/api/v1/routing/recommend", status: http.StatusBadRequest,
+			body: `{"payment_method":"CARD"}`},
+		{operation: "GET /api/v1/routing/recommend", path: "/api/v1/routing/recommend?payment_method=OXXO&country=CO", status: http.StatusOK},
+		{operation: "GET /api/v1/routing/recommend", path: "/api/v1/routing/recommend?payment_method=PIX&country=BR&amount=-1", status: http.StatusBadRequest},
+		{operation: "GET /api/v1/routing/decisions/{id}", path: "/api/v1/routing/decisions/" + decisionID, status: http.StatusOK},
+		{operation: "GET /api/v1/routing/decisions/{id}", path: "/api/v1/routing/decisions/missing", status: http.StatusNotFound},
+		{operation: "GET /api/v1/processors", path: "/api/v1/processors", status: http.StatusOK},
+		{operation: "POST /api/v1/processors", path: "/api/v1/processors", status: http.StatusCreated,
+			body: `{"id":"processor_x","name":"X","countries":["BR"],"payment_methods":["PIX"],"currencies":["BRL"],"limits":{"BRL":{"max_amount":5000}},"max_tps":10}`},
+		{operation: "POST /api/v1/processors", path: "/api/v1/processors", status: http.StatusBadRequest,
+			body: `{"id":"processor_y"}`},
+		{operation: "GET /api/v1/alerts", path: "/api/v1/alerts", status: http.StatusOK},
+		{operation: "GET /api/v1/alerts", path: "/api/v1/alerts", status: http.StatusOK,
+			headers: map[string]string{TenantHeader: "quiet-tenant"}},
+		{operation: "GET /api/v1/alerts", path: "/api/v1/alerts", status: http.StatusNotFound,
+			headers: map[string]string{TenantHeader: "unknown-tenant"}},
+		{operation: "GET /api/v1/health", path: "/api/v1/health", status: http.StatusForbidden,
+			headers: map[string]string{"X-API-Key": "quiet-key", TenantHeader: tenant.DefaultID}},
+		{operation: "GET /api/v1/network/health", path: "/api/v1/network/health", status: http.StatusOK},
+		{operation: "GET /api/v1/network/health", path: "/api/v1/network/health", status: http.StatusForbidden,
+			headers: map[string]string{"X-API-Key": "quiet-key"}},
+		{operation: "GET /api/v1/openapi.json", path: "/api/v1/openapi.json", status: http.StatusOK},
+		{operation: "GET /api/v1/health", path: "/api/v1/health", status: http.StatusUnauthorized,
+			headers: map[string]string{"X-API-Key": "wrong"}},
+	}
+
+	covered := make(map[string]bool)
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s_%d", c.operation, c.status), func(t *testing.T) {
+			method, _, _ := strings.Cut(c.operation, " ")
+			req := httptest.NewRequest(method, c.path, strings.NewReader(c.body))
+			req.Header.Set("X-API-Key", "contract-key")
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			if rec.Code != c.status {
+				t.Fatalf("expected %d, got %d: %s", c.status, rec.Code, rec.Body.String())
+			}
+			schema, err := doc.responseSchema(c.operation, rec.Code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+			var body any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			for _, problem := range doc.validate(schema, body, "body") {
+				t.Error(problem)
+			}
+			if rec.Code >= 400 {
+				if id := body.(map[string]any)["request_id"]; id != rec.Header().Get(logging.RequestIDHeader) {
+					t.Errorf("expected error body request_id %q, got %v", rec.Header().Get(logging.RequestIDHeader), id)
+				}
+			}
+		})
+		covered[c.operation] = true
+	}
+
+	for _, op := range doc.operations() {
+		if !covered[op] {
+			t.Errorf("documented operation %s has no contract case", op)
+		}
+	}
+}
+
+func TestOpenAPI_OperationsAreRouted(t *testing.T) {
+	doc := loadSpec(t)
+	mux := http.NewServeMux()
+	NewHandler(nil, nil, nil).RegisterRoutes(mux)
+
+	for _, op := range doc.operations() {
+		method, path, _ := strings.Cut(op, " ")
+		concrete := strings.NewReplacer("{processorId}", "p1", "{id}", "x1").Replace(path)
+		if _, pattern := mux.Handler(httptest.NewRequest(method, concrete, nil)); pattern != op {
+			t.Errorf("documented %s is served by %q", op, pattern)
+		}
+	}
+}
+
+func TestOpenAPI_SchemasMatchTypes(t *testing.T) {
+	doc := loadSpec(t)
+	for name, typ := range map[string]reflect.Type{
+		"TransactionRequest":    reflect.TypeOf(TransactionRequest{}),
+		"RoutingRequest":        reflect.TypeOf(RoutingRequest{}),
+		"Error":                 reflect.TypeOf(ErrorResponse{}),
+		"Processor":             reflect.TypeOf(domain.Processor{}),
+		"ProcessorHealth":       reflect.TypeOf(domain.ProcessorHealth{}),
+		"RoutingRecommendation": reflect.TypeOf(domain.RoutingRecommendation{}),
+		"ProcessorRank":         reflect.TypeOf(domain.ProcessorRank{}),
+		"ScoreBreakdown":        reflect.TypeOf(domain.ScoreBreakdown{}),
+		"Transaction":           reflect.TypeOf(domain.Transaction{}),
+		"HealthTransition":      reflect.TypeOf(domain.HealthTransition{}),
+	} {
+		properties := doc.schema(name)["properties"].(map[string]any)
+		documented := make([]string, 0, len(properties))
+		for p := range properties {
+			documented = append(documented, p)
+		}
+		sort.Strings(documented)
+		if fields := jsonFields(typ); strings.Join(fields, ",") != strings.Join(documented, ",") {
+			t.Errorf("%s: type has %v, document has %v", name, fields, documented)
+		}
+	}
+}
+
+// Helper functions
+
+// spec is the parsed OpenAPI document
+type spec map[string]any
+
+func loadSpec(t *testing.T) spec {
+	t.Helper()
+	var doc spec
+	if err := json.Unmarshal(OpenAPISpec(), &doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	return doc
+}
+
+// operations lists "METHOD /path" for every documented operation
+func (s spec) operations() []string {
+	var result []string
+	for path, item := range s["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			result = append(result, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (s spec) schema(name string) map[string]any {
+	return s["components"].(map[string]any)["schemas"].(map[string]any)[name].(map[string]any)
+}
+
+// resolve follows a $ref within the document
+func (s spec) resolve(node map[string]any) map[string]any {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		var current any = map[string]any(s)
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			current = current.(map[string]any)[part]
+		}
+		node = current.(map[string]any)
+	}
+}
+
+// responseSchema returns the JSON schema documented for an operation's status
+func (s spec) responseSchema(operation string, status int) (map[string]any, error) {
+	method, path, _ := strings.Cut(operation, " ")
+	item, ok := s["paths"].(map[string]any)[path].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is not documented", path)
+	}
+	op, ok := item[strings.ToLower(method)].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is not documented", operation)
+	}
+	response, ok := op["responses"].(map[string]any)[strconv.Itoa(status)].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s does not document status %d", operation, status)
+	}
+	response = s.resolve(response)
+	content := response["content"].(map[string]any)["application/json"].(map[string]any)
+	return content["schema"].(map[string]any), nil
+}
+
+// validate checks value against the subset of JSON Schema the document
+// uses: type, nullable, enum, required, properties, additionalProperties,
+// items and the date-time format. Undocumented properties are reported.
+func (s spec) validate(schema map[string]any, value any, path string) []string {
+	schema = s.resolve(schema)
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{path + ": null is not allowed"}
+	}
+
+	var problems []string
+	fail := func(format string, args ...any) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == value
+		}
+		if !found {
+			fail("%v is not one of %v", value, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("expected an object, got %T", value)
+			break
+		}
+		for _, name := range asStrings(schema["required"]) {
+			if _, present := obj[name]; !present {
+				fail("missing required property %q", name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		additional, _ := schema["additionalProperties"].(map[string]any)
+		for name, v := range obj {
+			switch {
+			case properties[name] != nil:
+				problems = append(problems, s.validate(properties[name].(map[string]any), v, path+"."+name)...)
+			case additional != nil:
+				problems = append(problems, s.validate(additional, v, path+"."+name)...)
+			case properties != nil:
+				fail("undocumented property %q", name)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			fail("expected an array, got %T", value)
+			break
+		}
+		for i, item := range items {
+			problems = append(problems, s.validate(schema["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("expected a string, got %T", value)
+			break
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				fail("%q is not a date-time", str)
+			}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			fail("expected a number, got %T", value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			fail("expected an integer, got %v", value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected a boolean, got %T", value)
+		}
+	}
+	return problems
+}
+
+func asStrings(v any) []string {
+	var result []string
+	for _, item := range asSlice(v) {
+		result = append(result, item.(string))
+	}
+	return result
+}
+
+func asSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
+
+// jsonFields lists a struct's JSON property names, sorted
+func jsonFields(typ reflect.Type) []string {
+	var result []string
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// contractServer serves the API with auth and request IDs, seeded with
+// enough traffic for transitions, card segments and a decision to explain
+func contractServer(t *testing.T) (http.Handler, string) {
+	t.Helper()
+	clk := clock.Real()
+	tenants := tenant.NewRegistry(health.NewCalculatorWithClock(clk), clk)
+	tenants.SetDefaultProcessors([]*domain.Processor{
+		{ID: "processor_a", Name: "A", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodPIX, domain.MethodCard}},
+		{ID: "processor_b", Name: "B", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodCard}, MaxTPS: 100},
+		{ID: "processor_c", Name: "C", Countries: []domain.Country{domain.CountryMX}, PaymentMethods: []domain.PaymentMethod{domain.MethodOXXO}},
+	})
+	tenants.Get(tenant.DefaultID)
+	tenants.Get("quiet-tenant")
+	for i := 0; i < 15; i++ {
+		result := domain.ResultApproved
+		if i >= 5 {
+			result = domain.ResultDeclined
+		}
+		tenants.RecordTransaction(domain.Transaction{
+			ID: fmt.Sprintf("seed-%d", i), ProcessorID: "processor_b", Result: result, Timestamp: clk.Now(),
+			PaymentMethod: domain.MethodCard, Country: domain.CountryBR, Amount: 100, Currency: "BRL",
+			BIN: "41111111", CardBrand: "VISA",
+		})
+	}
+
+	queue := ingest.NewQueue(10, 1, tenants.RecordTransactionContext)
+	t.Cleanup(queue.Close)
+	logger := logging.New(discard{}, logging.FormatJSON, nil)
+	handler := NewHandler(tenants, idempotency.NewStore(time.Hour), queue)
+	handler.SetLogger(logger)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	decision := tenants.Get("").Engine.Recommend(domain.MethodCard, domain.CountryBR, 100)
+	route := func(r *http.Request) string { _, p := mux.Handler(r); return p }
+	client := func(r *http.Request) string { return "test" }
+	keys := auth.NewKeys([]string{"contract-key"})
+	keys.SetTenantKeys(map[string][]string{"quiet-tenant": {"quiet-key"}})
+	server := logging.Middleware(logger, keys.Middleware(mux), route, client)
+	return server, decision.DecisionID
+}
+
+// discard drops log output
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
@@ -0,0 +1,132 @@
+// Package auth checks API keys on /api/ routes. With no keys configured
+// every request is let through. Keys bound to a tenant only grant access
+// to that tenant; the bound tenant travels in the request context.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/yuno/techcart-failover/internal/logging"
+)
+
+// Where requests carry their API key
+const (
+	APIKeyHeader = "X-API-Key"
+	QueryParam   = "api_key" // for clients that cannot set headers (EventSource)
+)
+
+// RequestKey returns the API key of r: the X-API-Key header, else the
+// api_key query param
+func RequestKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	return r.URL.Query().Get(QueryParam)
+}
+
+// Keys is the set of accepted API keys. It can be replaced while serving.
+type Keys struct {
+	mu    sync.RWMutex
+	keys  map[string]bool
+	bound map[string]string // key -> tenant
+}
+
+// NewKeys creates a key set from keys
+func NewKeys(keys []string) *Keys {
+	k := &Keys{}
+	k.Set(keys)
+	return k
+}
+
+// Set replaces the accepted keys
+func (k *Keys) Set(keys []string) {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = set
+}
+
+// SetTenantKeys replaces the keys bound to a single tenant, listed by tenant ID
+func (k *Keys) SetTenantKeys(tenantKeys map[string][]string) {
+	bound := make(map[string]string)
+	for tenantID, keys := range tenantKeys {
+		for _, key := range keys {
+			bound[key] = tenantID
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.bound = bound
+}
+
+// Enabled reports whether any key is configured
+func (k *Keys) Enabled() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return len(k.keys) > 0 || len(k.bound) > 0
+}
+
+// Valid reports whether key is accepted; any key is while none are configured
+func (k *Keys) Valid(key string) bool {
+	return !k.Enabled() || k.Known(key)
+}
+
+// Known reports whether key is one of the configured keys; none is while
+// auth is off
+func (k *Keys) Known(key string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	_, bound := k.bound[key]
+	return k.keys[key] || bound
+}
+
+// Tenant returns the tenant key is bound to, or "" when it may access any
+func (k *Keys) Tenant(key string) string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.bound[key]
+}
+
+type tenantKey struct{}
+
+// WithTenant returns ctx carrying the tenant its request is bound to
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant the request is bound to, or ""
+func TenantFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	return id
+}
+
+// Middleware rejects /api/ requests without an accepted key (X-API-Key
+// header or api_key query param) with 401, and binds the others to their
+// key's tenant, if any. Other paths are public.
+func (k *Keys) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := RequestKey(r)
+		if !k.Valid(key) {
+			w.Header().Set("WWW-Authenticate", APIKeyHeader)
+			logging.WriteError(w, http.StatusUnauthorized, "missing or invalid API key")
+			return
+		}
+		if tenantID := k.Tenant(key); tenantID != "" {
+			r = r.WithContext(WithTenant(r.Context(), tenantID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
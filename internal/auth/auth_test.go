@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeys_NoKeysAllowsEverything(t *testing.T) {
+	keys := NewKeys(nil)
+
+	if rec := serve(keys, "/api/v1/health", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 without configured keys, got %d", rec.Code)
+	}
+	if keys.Known("anything") {
+		t.Error("expected no key to be known while auth is off")
+	}
+}
+
+func TestKeys_RequiresKeyOnAPIRoutes(t *testing.T) {
+	keys := NewKeys([]string{"secret"})
+
+	if rec := serve(keys, "/api/v1/health", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a key, got %d", rec.Code)
+	}
+	if rec := serve(keys, "/api/v1/health", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong key, got %d", rec.Code)
+	}
+	if rec := serve(keys, "/api/v1/health", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 with the key, got %d", rec.Code)
+	}
+	if rec := serve(keys, "/api/v1/dashboard/stream?api_key=secret", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the query param to be accepted, got %d", rec.Code)
+	}
+	if rec := serve(keys, "/dashboard/", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected non-API paths to be public, got %d", rec.Code)
+	}
+}
+
+func TestKeys_SetReplacesKeys(t *testing.T) {
+	keys := NewKeys([]string{"old"})
+	keys.Set([]string{"new"})
+
+	if rec := serve(keys, "/api/v1/health", "old"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the old key to be rejected, got %d", rec.Code)
+	}
+	if rec := serve(keys, "/api/v1/health", "new"); rec.Code != http.StatusOK {
+		t.Errorf("expected the new key to be accepted, got %d", rec.Code)
+	}
+}
+
+func TestKeys_TenantKeysAreBoundToTheirTenant(t *testing.T) {
+	keys := NewKeys([]string{"admin"})
+	keys.SetTenantKeys(map[string][]string{"shop_a": {"shop-key"}})
+
+	var bound string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bound = TenantFromContext(r.Context())
+	})
+	for key, want := range map[string]string{"shop-key": "shop_a", "admin": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		keys.Middleware(next).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || bound != want {
+			t.Errorf("key %s: expected 200 bound to %q, got %d bound to %q", key, want, rec.Code, bound)
+		}
+	}
+}
+
+func TestKeys_TenantKeysAloneEnableAuth(t *testing.T) {
+	keys := NewKeys(nil)
+	keys.SetTenantKeys(map[string][]string{"shop_a": {"shop-key"}})
+
+	if !keys.Enabled() || !keys.Known("shop-key") {
+		t.Error("expected tenant keys to enable auth")
+	}
+	if rec := serve(keys, "/api/v1/health", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a key, got %d", rec.Code)
+	}
+}
+
+// Helper functions
+
+func serve(keys *Keys, target, key string) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	keys.Middleware(next).ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,279 @@
+// Package backtest replays a historical transaction log through the health
+// calculator and routing engine in event time and estimates how a routing
+// configuration would have performed.
+//
+// The replay is off-policy: every configuration sees the same historical
+// traffic, and the outcome of routing to a processor other than the one
+// actually used is estimated from that processor's observed results in the
+// same minute (the "oracle" rate).
+package backtest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/routing"
+)
+
+// Replay tuning
+const (
+	Bucket            = time.Minute // Granularity of oracle rates and outage detection
+	IncidentMinVolume = 5           // Transactions needed in a bucket to judge it
+	IncidentMinLength = 2           // Consecutive bad buckets that make an incident
+)
+
+// Config is one routing configuration to evaluate
+type Config struct {
+	Name     string           `json:"name"`
+	Policy   health.Policy    `json:"policy"`
+	Strategy routing.Strategy `json:"strategy"`
+}
+
+// DefaultConfig returns the configuration the server runs with
+func DefaultConfig() Config {
+	return Config{
+		Name:     "default",
+		Policy:   health.DefaultPolicy(),
+		Strategy: routing.DefaultStrategy(),
+	}
+}
+
+// Duration is a time.Duration that marshals as a string like "1m30s"
+type Duration time.Duration
+
+// MarshalJSON writes the duration in time.Duration string form
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + time.Duration(d).String() + `"`), nil
+}
+
+// String formats the duration like time.Duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Incident is a period where a processor was failing according to the log
+type Incident struct {
+	ProcessorID  string     `json:"processor_id"`
+	Start        time.Time  `json:"start"`
+	End          time.Time  `json:"end"`
+	DetectedAt   *time.Time `json:"detected_at,omitempty"`
+	TimeToDetect *Duration  `json:"time_to_detect,omitempty"`
+}
+
+// Report holds the counterfactual metrics for one configuration
+type Report struct {
+	Name              string                    `json:"name"`
+	Transactions      int                       `json:"transactions"`
+	ActualApprovals   int                       `json:"actual_approvals"`
+	ExpectedApprovals float64                   `json:"expected_approvals"`
+	ActualAuthRate    float64                   `json:"actual_auth_rate"`
+	ExpectedAuthRate  float64                   `json:"expected_auth_rate"`
+	Failovers         int                       `json:"failovers"`
+	RoutedToDown      int                       `json:"routed_to_down"`
+	TimeRoutingToDown Duration                  `json:"time_routing_to_down"`
+	Incidents         []Incident                `json:"incidents"`
+	Undetected        int                       `json:"undetected"`
+	MeanTimeToDetect  Duration                  `json:"mean_time_to_detect"`
+	MaxTimeToDetect   Duration                  `json:"max_time_to_detect"`
+	Transitions       []domain.HealthTransition `json:"transitions"`
+}
+
+// DeriveProcessors builds a processor registry from the log: each processor
+// supports the payment methods and countries it was seen with
+func DeriveProcessors(txs []domain.Transaction) []*domain.Processor {
+	byID := make(map[string]*domain.Processor)
+	seenMethod := make(map[string]bool)
+	seenCountry := make(map[string]bool)
+
+	for _, tx := range txs {
+		p, exists := byID[tx.ProcessorID]
+		if !exists {
+			p = &domain.Processor{ID: tx.ProcessorID, Name: tx.ProcessorID}
+			byID[tx.ProcessorID] = p
+		}
+		if key := tx.ProcessorID + "|" + string(tx.PaymentMethod); !seenMethod[key] {
+			seenMethod[key] = true
+			p.PaymentMethods = append(p.PaymentMethods, tx.PaymentMethod)
+		}
+		if key := tx.ProcessorID + "|" + string(tx.Country); !seenCountry[key] {
+			seenCountry[key] = true
+			p.Countries = append(p.Countries, tx.Country)
+		}
+	}
+
+	result := make([]*domain.Processor, 0, len(byID))
+	for _, p := range byID {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// Run replays txs (in timestamp order) under cfg. Decisions are previews:
+// only the logged outcomes change the engine's state, not its picks.
+func Run(txs []domain.Transaction, processors []*domain.Processor, cfg Config) Report {
+	txs = sortedByTime(txs)
+	report := Report{Name: cfg.Name, Transactions: len(txs)}
+	if len(txs) == 0 {
+		return report
+	}
+
+	oracle := newOracle(txs)
+	incidents := oracle.incidents()
+
+	start := txs[0].Timestamp
+	clk := clock.NewFake(start)
+	calc := health.NewCalculatorWithPolicy(clk, cfg.Policy)
+	engine := routing.NewEngine(calc)
+	engine.SetStrategy(cfg.Strategy)
+	for _, p := range processors {
+		engine.RegisterProcessor(p)
+	}
+	scheduler := health.NewScheduler(clk, health.DefaultReevaluateInterval, func() []*health.Calculator {
+		return []*health.Calculator{calc}
+	})
+	nextEval := start.Add(health.DefaultReevaluateInterval)
+
+	lastPick := make(map[string]string)
+	downMinutes := make(map[string]bool)
+
+	for _, tx := range txs {
+		// Advance event time, re-evaluating idle processors on schedule
+		for !tx.Timestamp.Before(nextEval) {
+			clk.Set(nextEval)
+			scheduler.RunOnce()
+			nextEval = nextEval.Add(health.DefaultReevaluateInterval)
+		}
+		clk.Set(tx.Timestamp)
+
+		if tx.Result == domain.ResultApproved {
+			report.ActualApprovals++
+		}
+
+		// Ask for a decision before the outcome is known
+		corridor := string(tx.PaymentMethod) + "|" + string(tx.Country)
+		pick := tx.ProcessorID
+		rec := engine.Preview(domain.Payment{
+			PaymentMethod: tx.PaymentMethod,
+			Country:       tx.Country,
+			Amount:        tx.Amount,
+			Currency:      tx.Currency,
+			BIN:           tx.BIN,
+			CardBrand:     tx.CardBrand,
+		})
+		if len(rec.Recommendations) > 0 && rec.Recommendations[0].Recommended {
+			pick = rec.Recommendations[0].ProcessorID
+		}
+
+		if pick == tx.ProcessorID {
+			if tx.Result == domain.ResultApproved {
+				report.ExpectedApprovals++
+			}
+		} else {
+			report.ExpectedApprovals += oracle.rate(pick, tx.Timestamp)
+		}
+
+		if prev, seen := lastPick[corridor]; seen && prev != pick {
+			report.Failovers++
+		}
+		lastPick[corridor] = pick
+
+		if inIncident(incidents, pick, tx.Timestamp) {
+			report.RoutedToDown++
+			downMinutes[corridor+"|"+tx.Timestamp.Truncate(Bucket).String()] = true
+		}
+
+		calc.RecordTransaction(tx)
+		engine.RecordTransaction(tx)
+	}
+
+	report.TimeRoutingToDown = Duration(time.Duration(len(downMinutes)) * Bucket)
+	report.ActualAuthRate = float64(report.ActualApprovals) / float64(report.Transactions)
+	report.ExpectedAuthRate = report.ExpectedApprovals / float64(report.Transactions)
+	report.Transitions = calc.GetTransitions(start.Add(-time.Nanosecond))
+	report.Incidents = detect(incidents, report.Transitions)
+
+	var total Duration
+	detected := 0
+	for _, inc := range report.Incidents {
+		if inc.TimeToDetect == nil {
+			report.Undetected++
+			continue
+		}
+		detected++
+		total += *inc.TimeToDetect
+		if *inc.TimeToDetect > report.MaxTimeToDetect {
+			report.MaxTimeToDetect = *inc.TimeToDetect
+		}
+	}
+	if detected > 0 {
+		report.MeanTimeToDetect = total / Duration(detected)
+	}
+	return report
+}
+
+func sortedByTime(txs []domain.Transaction) []domain.Transaction {
+	sorted := make([]domain.Transaction, len(txs))
+	copy(sorted, txs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	return sorted
+}
+
+func inIncident(incidents []Incident, processorID string, at time.Time) bool {
+	for _, inc := range incidents {
+		if inc.ProcessorID == processorID && !at.Before(inc.Start) && at.Before(inc.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// detect marks when each incident was first flagged (DEGRADED or DOWN).
+// An incident that starts while the processor is already flagged is
+// detected immediately.
+func detect(incidents []Incident, transitions []domain.HealthTransition) []Incident {
+	result := make([]Incident, len(incidents))
+	for i, inc := range incidents {
+		result[i] = inc
+
+		flagged := false
+		var detectedAt *time.Time
+		for _, t := range transitions {
+			if t.ProcessorID != inc.ProcessorID {
+				continue
+			}
+			if t.Timestamp.Before(inc.Start) {
+				flagged = isFlagged(t.ToStatus)
+				continue
+			}
+			if !t.Timestamp.Before(inc.End) {
+				break
+			}
+			if isFlagged(t.ToStatus) {
+				at := t.Timestamp
+				detectedAt = &at
+				break
+			}
+		}
+
+		if flagged {
+			at := inc.Start
+			detectedAt = &at
+		}
+		if detectedAt != nil {
+			ttd := Duration(detectedAt.Sub(inc.Start))
+			result[i].DetectedAt = detectedAt
+			result[i].TimeToDetect = &ttd
+		}
+	}
+	return result
+}
+
+func isFlagged(status domain.HealthStatus) bool {
+	return status == domain.StatusDown || status == domain.StatusDegraded
+}
@@ -0,0 +1,120 @@
+package backtest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+var logStart = time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC)
+
+// outageLog: processor_a and processor_c share PIX/BR traffic for 90
+// minutes; processor_a returns 90% errors between minute 30 and 60
+func outageLog() []domain.Transaction {
+	rng := rand.New(rand.NewSource(1))
+	var txs []domain.Transaction
+	for sec := 0; sec < 90*60; sec++ {
+		at := logStart.Add(time.Duration(sec) * time.Second)
+		processorID := "processor_c"
+		if sec%2 == 0 {
+			processorID = "processor_a"
+		}
+
+		result := domain.ResultApproved
+		r := rng.Float64()
+		outage := processorID == "processor_a" && sec >= 30*60 && sec < 60*60
+		switch {
+		case outage && r < 0.9:
+			result = domain.ResultError
+		case !outage && r < 0.1:
+			result = domain.ResultDeclined
+		}
+
+		txs = append(txs, domain.Transaction{
+			ID:            fmt.Sprintf("tx-%d", sec),
+			ProcessorID:   processorID,
+			Timestamp:     at,
+			Result:        result,
+			PaymentMethod: domain.MethodPIX,
+			Country:       domain.CountryBR,
+		})
+	}
+	return txs
+}
+
+func TestRun_DetectsOutageAndRoutesAround(t *testing.T) {
+	txs := outageLog()
+	report := Run(txs, DeriveProcessors(txs), DefaultConfig())
+
+	if len(report.Incidents) != 1 || report.Incidents[0].ProcessorID != "processor_a" {
+		t.Fatalf("expected one processor_a incident, got %+v", report.Incidents)
+	}
+	inc := report.Incidents[0]
+	if !inc.Start.Equal(logStart.Add(30 * time.Minute)) {
+		t.Errorf("expected incident at minute 30, got %s", inc.Start)
+	}
+	if inc.TimeToDetect == nil || *inc.TimeToDetect > Duration(2*time.Minute) {
+		t.Errorf("expected detection within 2 minutes, got %v", inc.TimeToDetect)
+	}
+
+	// Routing around the outage recovers approvals the merchant lost
+	if report.ExpectedApprovals <= float64(report.ActualApprovals) {
+		t.Errorf("expected counterfactual approvals (%.0f) above actual (%d)",
+			report.ExpectedApprovals, report.ActualApprovals)
+	}
+	if report.Failovers == 0 {
+		t.Error("expected at least one failover")
+	}
+	if report.TimeRoutingToDown > Duration(3*time.Minute) {
+		t.Errorf("expected little time routed to the failing processor, got %s", report.TimeRoutingToDown)
+	}
+}
+
+func TestRun_BeforeEpoch(t *testing.T) {
+	// Logs may carry any RFC 3339 timestamp, including ones before 1970
+	txs := outageLog()
+	shift := time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC).Sub(logStart)
+	for i := range txs {
+		txs[i].Timestamp = txs[i].Timestamp.Add(shift)
+	}
+
+	report := Run(txs, DeriveProcessors(txs), DefaultConfig())
+	if len(report.Incidents) != 1 || report.Incidents[0].ProcessorID != "processor_a" {
+		t.Errorf("expected the processor_a incident in 1960 too, got %+v", report.Incidents)
+	}
+}
+
+func TestRun_StricterPolicyDetectsFaster(t *testing.T) {
+	txs := outageLog()
+	processors := DeriveProcessors(txs)
+
+	strict := DefaultConfig()
+	strict.Name = "strict"
+	strict.Policy.WindowSize = 20
+	strict.Policy.MinTransactions = 5
+
+	base := Run(txs, processors, DefaultConfig())
+	fast := Run(txs, processors, strict)
+
+	if fast.MeanTimeToDetect >= base.MeanTimeToDetect {
+		t.Errorf("expected smaller window to detect faster: %s vs %s", fast.MeanTimeToDetect, base.MeanTimeToDetect)
+	}
+}
+
+func TestDeriveProcessors_FromCorridors(t *testing.T) {
+	processors := DeriveProcessors([]domain.Transaction{
+		{ProcessorID: "processor_b", PaymentMethod: domain.MethodCard, Country: domain.CountryBR},
+		{ProcessorID: "processor_b", PaymentMethod: domain.MethodCard, Country: domain.CountryMX},
+		{ProcessorID: "processor_a", PaymentMethod: domain.MethodPIX, Country: domain.CountryBR},
+	})
+
+	if len(processors) != 2 || processors[0].ID != "processor_a" {
+		t.Fatalf("expected processor_a and processor_b, got %d", len(processors))
+	}
+	if len(processors[1].Countries) != 2 || len(processors[1].PaymentMethods) != 1 {
+		t.Errorf("expected processor_b with 2 countries and 1 method, got %+v", processors[1])
+	}
+}
@@ -0,0 +1,143 @@
+package backtest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+type tally struct {
+	approved int
+	declined int
+	errors   int
+	total    int
+}
+
+// oracle knows every processor's observed results per bucket of the log
+type oracle struct {
+	buckets map[string]map[int64]*tally
+	overall map[string]*tally
+}
+
+func newOracle(txs []domain.Transaction) *oracle {
+	o := &oracle{
+		buckets: make(map[string]map[int64]*tally),
+		overall: make(map[string]*tally),
+	}
+	for _, tx := range txs {
+		b := bucketOf(tx.Timestamp)
+		if o.buckets[tx.ProcessorID] == nil {
+			o.buckets[tx.ProcessorID] = make(map[int64]*tally)
+			o.overall[tx.ProcessorID] = &tally{}
+		}
+		if o.buckets[tx.ProcessorID][b] == nil {
+			o.buckets[tx.ProcessorID][b] = &tally{}
+		}
+		o.buckets[tx.ProcessorID][b].add(tx.Result)
+		o.overall[tx.ProcessorID].add(tx.Result)
+	}
+	return o
+}
+
+func bucketOf(t time.Time) int64 {
+	return t.Truncate(Bucket).Unix()
+}
+
+func (t *tally) add(result domain.TransactionResult) {
+	t.total++
+	switch result {
+	case domain.ResultApproved:
+		t.approved++
+	case domain.ResultDeclined:
+		t.declined++
+	case domain.ResultError, domain.ResultTimeout:
+		t.errors++
+	}
+}
+
+// successRate is the share of attempts approved; errors count as failures
+func (t *tally) successRate() float64 {
+	if t.total == 0 {
+		return 0
+	}
+	return float64(t.approved) / float64(t.total)
+}
+
+// rate estimates the chance a transaction sent to processorID at time at
+// would have been approved: its rate in that bucket, widening to ±2 buckets
+// and then the whole log when there is too little data
+func (o *oracle) rate(processorID string, at time.Time) float64 {
+	buckets := o.buckets[processorID]
+	if buckets == nil {
+		return 0
+	}
+
+	b := bucketOf(at)
+	step := int64(Bucket / time.Second)
+	if t := buckets[b]; t != nil && t.total >= IncidentMinVolume {
+		return t.successRate()
+	}
+
+	wide := &tally{}
+	for i := int64(-2); i <= 2; i++ {
+		if t := buckets[b+i*step]; t != nil {
+			wide.approved += t.approved
+			wide.total += t.total
+		}
+	}
+	if wide.total >= IncidentMinVolume {
+		return wide.successRate()
+	}
+	return o.overall[processorID].successRate()
+}
+
+// incidents finds runs of at least IncidentMinLength consecutive buckets
+// where a processor's error rate was above 50% or its auth rate below 30%
+func (o *oracle) incidents() []Incident {
+	var result []Incident
+	step := int64(Bucket / time.Second)
+
+	ids := make([]string, 0, len(o.buckets))
+	for id := range o.buckets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		keys := make([]int64, 0, len(o.buckets[id]))
+		for b, t := range o.buckets[id] {
+			if t.bad() {
+				keys = append(keys, b)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+		for i := 0; i < len(keys); {
+			j := i
+			for j+1 < len(keys) && keys[j+1] == keys[j]+step {
+				j++
+			}
+			if j-i+1 >= IncidentMinLength {
+				result = append(result, Incident{
+					ProcessorID: id,
+					Start:       time.Unix(keys[i], 0).UTC(),
+					End:         time.Unix(keys[j]+step, 0).UTC(),
+				})
+			}
+			i = j + 1
+		}
+	}
+	return result
+}
+
+func (t *tally) bad() bool {
+	if t.total < IncidentMinVolume {
+		return false
+	}
+	if float64(t.errors)/float64(t.total) > 0.5 {
+		return true
+	}
+	valid := t.approved + t.declined
+	return valid > 0 && float64(t.approved)/float64(valid) < 0.3
+}
@@ -0,0 +1,37 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake_AdvanceFiresDueTickers(t *testing.T) {
+	start := time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)
+	clk := NewFake(start)
+	ticker := clk.NewTicker(time.Minute)
+
+	clk.Advance(30 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	clk.Advance(30 * time.Second)
+	select {
+	case at := <-ticker.C():
+		if !at.Equal(start.Add(time.Minute)) {
+			t.Errorf("expected tick at %s, got %s", start.Add(time.Minute), at)
+		}
+	default:
+		t.Fatal("expected ticker to fire")
+	}
+
+	ticker.Stop()
+	clk.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
@@ -0,0 +1,597 @@
+// Package config loads the server configuration: a JSON file describing
+// the listen address, processors, tenants, health policy, routing strategy,
+// storage, auth, notifications, CORS, rate limits, logging and tracing,
+// with environment overrides on top and compiled-in defaults for anything
+// left out.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/idempotency"
+	"github.com/yuno/techcart-failover/internal/ingest"
+	"github.com/yuno/techcart-failover/internal/logging"
+	"github.com/yuno/techcart-failover/internal/ratelimit"
+	"github.com/yuno/techcart-failover/internal/routing"
+)
+
+// Config is the whole server configuration
+type Config struct {
+	Listen        Listen              `json:"listen"`
+	GRPC          GRPC                `json:"grpc"`
+	Processors    []*domain.Processor `json:"processors"`
+	Tenants       []string            `json:"tenants"`
+	Health        health.Policy       `json:"health"`
+	Routing       routing.Strategy    `json:"routing"`
+	Storage       Storage             `json:"storage"`
+	Auth          Auth                `json:"auth"`
+	Notifications Notifications       `json:"notifications"`
+	CORS          CORS                `json:"cors"`
+	RateLimits    RateLimits          `json:"rate_limits"`
+	Logging       Logging             `json:"logging"`
+	Tracing       Tracing             `json:"tracing"`
+}
+
+// Listen is where the server accepts connections and how long it gives
+// them. WriteTimeout does not apply to the dashboard event stream.
+type Listen struct {
+	Address         string        `json:"address"`
+	ReadTimeout     time.Duration `json:"read_timeout"`
+	WriteTimeout    time.Duration `json:"write_timeout"`
+	IdleTimeout     time.Duration `json:"idle_timeout"`
+	ShutdownDelay   time.Duration `json:"shutdown_delay"`   // not ready but serving, so load balancers stop sending
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"` // for in-flight requests to finish
+}
+
+// GRPC is where the gRPC API accepts cleartext HTTP/2 connections (empty:
+// gRPC off)
+type GRPC struct {
+	Address string `json:"address"`
+}
+
+// Storage sizes the in-memory stores
+type Storage struct {
+	IdempotencyHorizon time.Duration `json:"idempotency_horizon"`
+	QueueDepth         int           `json:"queue_depth"`
+	QueueWorkers       int           `json:"queue_workers"`
+}
+
+// Auth lists the API keys accepted on /api/ routes (none: no auth).
+// APIKeys may access any tenant; TenantKeys, by tenant ID, only theirs.
+type Auth struct {
+	APIKeys    []string            `json:"api_keys"`
+	TenantKeys map[string][]string `json:"tenant_keys"`
+}
+
+// Notifications configures where alerts are pushed (empty: log only)
+type Notifications struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+// CORS lists the origins allowed to call the API ("*" for any)
+type CORS struct {
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// RateLimits configures the per-client token buckets by route class
+type RateLimits struct {
+	TrustProxy bool                      `json:"trust_proxy"`
+	Rules      map[string]ratelimit.Rule `json:"rules"`
+}
+
+// Logging picks the log format ("json" or "text") and the minimum level
+// (debug, info, warn or error)
+type Logging struct {
+	Format string `json:"format"`
+	Level  string `json:"level"`
+}
+
+// Tracing exports spans to an OTLP/HTTP collector at Endpoint, a base URL
+// such as http://localhost:4318 (empty: tracing off). SampleRatio is the
+// share of traces kept when the caller did not decide.
+type Tracing struct {
+	Endpoint    string  `json:"endpoint"`
+	ServiceName string  `json:"service_name"`
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// Default returns the compiled-in configuration: the TechCart mock
+// processors, default policy and strategy, and no auth
+func Default() Config {
+	rules := make(map[string]ratelimit.Rule, len(ratelimit.DefaultRules))
+	for class, rule := range ratelimit.DefaultRules {
+		rules[class] = rule
+	}
+	return Config{
+		Listen: Listen{
+			Address:         ":8080",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 25 * time.Second,
+		},
+		GRPC:       GRPC{Address: ":9090"},
+		Processors: mockProcessors(),
+		Health:     health.DefaultPolicy(),
+		Routing:    routing.DefaultStrategy(),
+		Storage: Storage{
+			IdempotencyHorizon: idempotency.DefaultHorizon,
+			QueueDepth:         ingest.DefaultDepth,
+			QueueWorkers:       ingest.DefaultWorkers,
+		},
+		Tenants:    []string{},
+		Auth:       Auth{APIKeys: []string{}, TenantKeys: map[string][]string{}},
+		CORS:       CORS{AllowedOrigins: []string{"*"}},
+		RateLimits: RateLimits{Rules: rules},
+		Logging:    Logging{Format: logging.FormatJSON, Level: "info"},
+		Tracing:    Tracing{ServiceName: "techcart-failover", SampleRatio: 1},
+	}
+}
+
+// Load reads the configuration: defaults, overlaid by the file at path
+// (skipped when path is empty), overlaid by environment variables read
+// through getenv. The result is validated.
+func Load(path string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := decode(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(getenv); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// decode overlays a JSON document onto cfg, rejecting unknown fields and
+// locating syntax errors by line and column
+func decode(data []byte, cfg *Config) error {
+	// Decoding would reuse the default processors and merge the file's into
+	// them; processors in the file replace the defaults instead
+	defaults := cfg.Processors
+	cfg.Processors = nil
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(cfg)
+	if cfg.Processors == nil {
+		cfg.Processors = defaults
+	}
+
+	var syntax *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		// The offset is just past the offending character
+		line, col := position(data, syntax.Offset-1)
+		return fmt.Errorf("line %d, column %d: %w", line, col, err)
+	case errors.As(err, &typeErr):
+		line, col := position(data, typeErr.Offset)
+		return fmt.Errorf("line %d, column %d: %s must be %s, got %s", line, col, typeErr.Field, typeErr.Type, typeErr.Value)
+	}
+	return err
+}
+
+// strict decodes a section rejecting unknown fields, as decode does for
+// the sections without their own UnmarshalJSON
+func strict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (line, col int) {
+	before := data[:min(int(offset), len(data))]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// applyEnv overlays the environment: PORT (as ":PORT") and LISTEN_ADDR set
+// listen.address, GRPC_ADDR grpc.address; IDEMPOTENCY_HORIZON, INGEST_QUEUE_DEPTH and
+// INGEST_QUEUE_WORKERS the storage settings; API_KEYS and
+// CORS_ALLOWED_ORIGINS (comma-separated) the key and origin lists;
+// NOTIFY_WEBHOOK_URL the webhook, TRUST_PROXY rate_limits.trust_proxy,
+// LOG_FORMAT and LOG_LEVEL the logging settings and the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_SERVICE_NAME the tracing ones
+func (c *Config) applyEnv(getenv func(string) string) error {
+	if v := getenv("PORT"); v != "" {
+		c.Listen.Address = ":" + v
+	}
+	if v := getenv("LISTEN_ADDR"); v != "" {
+		c.Listen.Address = v
+	}
+	if v := getenv("GRPC_ADDR"); v != "" {
+		c.GRPC.Address = v
+	}
+	if v := getenv("IDEMPOTENCY_HORIZON"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("IDEMPOTENCY_HORIZON: %w", err)
+		}
+		c.Storage.IdempotencyHorizon = d
+	}
+	for name, target := range map[string]*int{
+		"INGEST_QUEUE_DEPTH":   &c.Storage.QueueDepth,
+		"INGEST_QUEUE_WORKERS": &c.Storage.QueueWorkers,
+	} {
+		if v := getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a number", name, v)
+			}
+			*target = n
+		}
+	}
+	if v := getenv("TENANTS"); v != "" {
+		c.Tenants = list(v)
+	}
+	if v := getenv("API_KEYS"); v != "" {
+		c.Auth.APIKeys = list(v)
+	}
+	if v := getenv("NOTIFY_WEBHOOK_URL"); v != "" {
+		c.Notifications.WebhookURL = v
+	}
+	if v := getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		c.CORS.AllowedOrigins = list(v)
+	}
+	if v := getenv("TRUST_PROXY"); v != "" {
+		trust, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("TRUST_PROXY: %q is not a boolean", v)
+		}
+		c.RateLimits.TrustProxy = trust
+	}
+	if v := getenv("LOG_FORMAT"); v != "" {
+		c.Logging.Format = v
+	}
+	if v := getenv("LOG_LEVEL"); v != "" {
+		c.Logging.Level = v
+	}
+	if v := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		c.Tracing.Endpoint = v
+	}
+	if v := getenv("OTEL_SERVICE_NAME"); v != "" {
+		c.Tracing.ServiceName = v
+	}
+	return nil
+}
+
+// list splits a comma-separated value, dropping blanks
+func list(v string) []string {
+	result := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Validate reports every invalid setting, one per line
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(section string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", section, err))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Listen.Address); err != nil {
+		fail("listen.address", err)
+	}
+	if c.Listen.ReadTimeout <= 0 || c.Listen.WriteTimeout <= 0 || c.Listen.IdleTimeout <= 0 || c.Listen.ShutdownTimeout <= 0 {
+		fail("listen", errors.New("read_timeout, write_timeout, idle_timeout and shutdown_timeout must be positive"))
+	}
+	if c.Listen.ShutdownDelay < 0 {
+		fail("listen.shutdown_delay", fmt.Errorf("must not be negative, got %s", c.Listen.ShutdownDelay))
+	}
+
+	if c.GRPC.Address != "" {
+		if _, _, err := net.SplitHostPort(c.GRPC.Address); err != nil {
+			fail("grpc.address", err)
+		} else if c.GRPC.Address == c.Listen.Address {
+			fail("grpc.address", fmt.Errorf("must differ from listen.address %q", c.Listen.Address))
+		}
+	}
+
+	if len(c.Processors) == 0 {
+		fail("processors", errors.New("at least one processor is required"))
+	}
+	seen := make(map[string]bool)
+	for i, p := range c.Processors {
+		section := fmt.Sprintf("processors[%d]", i)
+		switch {
+		case p == nil || p.ID == "":
+			fail(section, errors.New("id is required"))
+		case seen[p.ID]:
+			fail(section, fmt.Errorf("duplicate id %q", p.ID))
+		case len(p.Countries) == 0 || len(p.PaymentMethods) == 0:
+			fail(section, errors.New("countries and payment_methods are required"))
+		case p.MaxTPS < 0 || p.MaxInFlight < 0:
+			fail(section, errors.New("max_tps and max_in_flight must not be negative"))
+		default:
+			if err := p.ValidateLimits(); err != nil {
+				fail(section, err)
+			}
+		}
+		if p != nil {
+			seen[p.ID] = true
+		}
+	}
+
+	if err := c.Health.Validate(); err != nil {
+		fail("health", err)
+	}
+	if err := c.Routing.Validate(); err != nil {
+		fail("routing", err)
+	}
+
+	if c.Storage.IdempotencyHorizon <= 0 {
+		fail("storage.idempotency_horizon", fmt.Errorf("must be positive, got %s", c.Storage.IdempotencyHorizon))
+	}
+	if c.Storage.QueueDepth <= 0 || c.Storage.QueueWorkers <= 0 {
+		fail("storage", fmt.Errorf("queue_depth and queue_workers must be positive, got %d and %d", c.Storage.QueueDepth, c.Storage.QueueWorkers))
+	}
+
+	for i, id := range c.Tenants {
+		if strings.TrimSpace(id) == "" {
+			fail(fmt.Sprintf("tenants[%d]", i), errors.New("must not be blank"))
+		}
+	}
+
+	keys := make(map[string]bool)
+	for i, key := range c.Auth.APIKeys {
+		if strings.TrimSpace(key) == "" {
+			fail(fmt.Sprintf("auth.api_keys[%d]", i), errors.New("must not be blank"))
+		}
+		keys[key] = true
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.Auth.TenantKeys)) {
+		if strings.TrimSpace(id) == "" {
+			fail("auth.tenant_keys", errors.New("tenant ID must not be blank"))
+		}
+		for i, key := range c.Auth.TenantKeys[id] {
+			section := fmt.Sprintf("auth.tenant_keys.%s[%d]", id, i)
+			switch {
+			case strings.TrimSpace(key) == "":
+				fail(section, errors.New("must not be blank"))
+			case keys[key]:
+				fail(section, errors.New("key is already used"))
+			}
+			keys[key] = true
+		}
+	}
+
+	if raw := c.Notifications.WebhookURL; raw != "" {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("notifications.webhook_url", fmt.Errorf("must be an http(s) URL, got %q", raw))
+		}
+	}
+
+	for i, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			fail(fmt.Sprintf("cors.allowed_origins[%d]", i), fmt.Errorf("must be \"*\" or scheme://host[:port], got %q", origin))
+		}
+	}
+
+	for class, rule := range c.RateLimits.Rules {
+		section := "rate_limits.rules." + class
+		switch {
+		case class != "ingest" && class != "routing" && class != "read":
+			fail(section, errors.New("unknown route class (want ingest, routing or read)"))
+		case rule.Rate < 0:
+			fail(section, fmt.Errorf("rate must not be negative, got %g", rule.Rate))
+		case rule.Rate > 0 && rule.Burst < 1:
+			fail(section, fmt.Errorf("burst must be at least 1, got %d", rule.Burst))
+		}
+	}
+
+	if f := c.Logging.Format; f != logging.FormatJSON && f != logging.FormatText {
+		fail("logging.format", fmt.Errorf("must be %q or %q, got %q", logging.FormatJSON, logging.FormatText, f))
+	}
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		fail("logging.level", err)
+	}
+
+	if raw := c.Tracing.Endpoint; raw != "" {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("tracing.endpoint", fmt.Errorf("must be an http(s) URL, got %q", raw))
+		}
+	}
+	if c.Tracing.ServiceName == "" {
+		fail("tracing.service_name", errors.New("is required"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing.sample_ratio", fmt.Errorf("must be between 0 and 1, got %g", c.Tracing.SampleRatio))
+	}
+
+	return errors.Join(errs...)
+}
+
+// TenantIDs returns the tenants served besides tenant.DefaultID: those
+// listed in tenants and those with keys in auth.tenant_keys, sorted
+func (c Config) TenantIDs() []string {
+	ids := slices.Concat(c.Tenants, slices.Collect(maps.Keys(c.Auth.TenantKeys)))
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// RestartRequired lists the sections that differ in next but only take
+// effect on restart. Routing, auth, notifications, CORS, rate limit rules
+// and the log level are applied on reload, and so are added tenants;
+// removed tenants are served until restart.
+func (c Config) RestartRequired(next Config) []string {
+	var result []string
+	for _, s := range []struct {
+		name      string
+		old, next any
+	}{
+		{"listen", c.Listen, next.Listen},
+		{"grpc", c.GRPC, next.GRPC},
+		{"processors", c.Processors, next.Processors},
+		{"health", c.Health, next.Health},
+		{"storage", c.Storage, next.Storage},
+		{"rate_limits.trust_proxy", c.RateLimits.TrustProxy, next.RateLimits.TrustProxy},
+		{"logging.format", c.Logging.Format, next.Logging.Format},
+		{"tracing", c.Tracing, next.Tracing},
+	} {
+		if !reflect.DeepEqual(s.old, s.next) {
+			result = append(result, s.name)
+		}
+	}
+	kept := next.TenantIDs()
+	for _, id := range c.TenantIDs() {
+		if !slices.Contains(kept, id) {
+			result = append(result, "tenants")
+			break
+		}
+	}
+	return result
+}
+
+// listenJSON mirrors Listen with durations written as strings like "30s"
+type listenJSON struct {
+	Address         string `json:"address"`
+	ReadTimeout     string `json:"read_timeout"`
+	WriteTimeout    string `json:"write_timeout"`
+	IdleTimeout     string `json:"idle_timeout"`
+	ShutdownDelay   string `json:"shutdown_delay"`
+	ShutdownTimeout string `json:"shutdown_timeout"`
+}
+
+// MarshalJSON writes durations in time.Duration string form
+func (l Listen) MarshalJSON() ([]byte, error) {
+	return json.Marshal(listenJSON{
+		Address:         l.Address,
+		ReadTimeout:     l.ReadTimeout.String(),
+		WriteTimeout:    l.WriteTimeout.String(),
+		IdleTimeout:     l.IdleTimeout.String(),
+		ShutdownDelay:   l.ShutdownDelay.String(),
+		ShutdownTimeout: l.ShutdownTimeout.String(),
+	})
+}
+
+// UnmarshalJSON overlays the given fields onto l
+func (l *Listen) UnmarshalJSON(data []byte) error {
+	raw := listenJSON{Address: l.Address}
+	if err := strict(data, &raw); err != nil {
+		return err
+	}
+
+	next := Listen{Address: raw.Address}
+	for _, d := range []struct {
+		name   string
+		value  string
+		old    time.Duration
+		target *time.Duration
+	}{
+		{"read_timeout", raw.ReadTimeout, l.ReadTimeout, &next.ReadTimeout},
+		{"write_timeout", raw.WriteTimeout, l.WriteTimeout, &next.WriteTimeout},
+		{"idle_timeout", raw.IdleTimeout, l.IdleTimeout, &next.IdleTimeout},
+		{"shutdown_delay", raw.ShutdownDelay, l.ShutdownDelay, &next.ShutdownDelay},
+		{"shutdown_timeout", raw.ShutdownTimeout, l.ShutdownTimeout, &next.ShutdownTimeout},
+	} {
+		*d.target = d.old
+		if d.value != "" {
+			parsed, err := time.ParseDuration(d.value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", d.name, err)
+			}
+			*d.target = parsed
+		}
+	}
+	*l = next
+	return nil
+}
+
+// storageJSON mirrors Storage with the horizon written as a string like "24h"
+type storageJSON struct {
+	IdempotencyHorizon string `json:"idempotency_horizon"`
+	QueueDepth         int    `json:"queue_depth"`
+	QueueWorkers       int    `json:"queue_workers"`
+}
+
+// MarshalJSON writes the horizon in time.Duration string form
+func (s Storage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(storageJSON{
+		IdempotencyHorizon: s.IdempotencyHorizon.String(),
+		QueueDepth:         s.QueueDepth,
+		QueueWorkers:       s.QueueWorkers,
+	})
+}
+
+// UnmarshalJSON overlays the given fields onto s
+func (s *Storage) UnmarshalJSON(data []byte) error {
+	raw := storageJSON{QueueDepth: s.QueueDepth, QueueWorkers: s.QueueWorkers}
+	if err := strict(data, &raw); err != nil {
+		return err
+	}
+
+	horizon := s.IdempotencyHorizon
+	if raw.IdempotencyHorizon != "" {
+		var err error
+		if horizon, err = time.ParseDuration(raw.IdempotencyHorizon); err != nil {
+			return fmt.Errorf("idempotency_horizon: %w", err)
+		}
+	}
+	*s = Storage{IdempotencyHorizon: horizon, QueueDepth: raw.QueueDepth, QueueWorkers: raw.QueueWorkers}
+	return nil
+}
+
+// mockProcessors returns the mock processors for TechCart
+func mockProcessors() []*domain.Processor {
+	return []*domain.Processor{
+		{
+			ID:             "processor_a",
+			Name:           "GlobalPay_BR",
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodPIX, domain.MethodCard},
+		},
+		{
+			ID:             "processor_b",
+			Name:           "PayLatam",
+			Countries:      []domain.Country{domain.CountryBR, domain.CountryMX, domain.CountryCO},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodCard},
+		},
+		{
+			ID:             "processor_c",
+			Name:           "PixMaster",
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+		},
+		{
+			ID:             "processor_d",
+			Name:           "MexPago",
+			Countries:      []domain.Country{domain.CountryMX},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodCard, domain.MethodOXXO},
+		},
+		{
+			ID:             "processor_e",
+			Name:           "ColombiaPS",
+			Countries:      []domain.Country{domain.CountryCO},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodPSE, domain.MethodCard},
+		},
+	}
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_DefaultsWithoutFile(t *testing.T) {
+	cfg, err := Load("", env(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Listen.Address != ":8080" || len(cfg.Processors) != 5 || cfg.CORS.AllowedOrigins[0] != "*" {
+		t.Errorf("expected built-in defaults, got %+v", cfg)
+	}
+}
+
+func TestLoad_FileOverlaysDefaults(t *testing.T) {
+	path := writeConfig(t, `{
+		"processors": [{"id": "p1", "countries": ["BR"], "payment_methods": ["PIX"]}],
+		"health": {"healthy_threshold": 0.8},
+		"storage": {"idempotency_horizon": "1h"},
+		"listen": {"write_timeout": "1m"},
+		"rate_limits": {"rules": {"read": {"rate": 5, "burst": 10}}}
+	}`)
+
+	cfg, err := Load(path, env(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Processors) != 1 || cfg.Processors[0].ID != "p1" || cfg.Processors[0].Name != "" {
+		t.Errorf("expected the file's processors to replace the defaults, got %+v", cfg.Processors)
+	}
+	if cfg.Health.HealthyThreshold != 0.8 || cfg.Health.WindowSize != 50 {
+		t.Errorf("expected health to overlay the default policy, got %+v", cfg.Health)
+	}
+	if cfg.Storage.IdempotencyHorizon != time.Hour || cfg.Storage.QueueDepth != 1000 {
+		t.Errorf("expected storage to overlay defaults, got %+v", cfg.Storage)
+	}
+	if cfg.Listen.WriteTimeout != time.Minute || cfg.Listen.ReadTimeout != 10*time.Second || cfg.Listen.Address != ":8080" {
+		t.Errorf("expected listen to overlay defaults, got %+v", cfg.Listen)
+	}
+	if cfg.RateLimits.Rules["read"].Rate != 5 || cfg.RateLimits.Rules["ingest"].Rate != 200 {
+		t.Errorf("expected rules to overlay per class, got %+v", cfg.RateLimits.Rules)
+	}
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	path := writeConfig(t, `{"listen": {"address": ":9000"}, "auth": {"api_keys": ["file-key"]}}`)
+
+	cfg, err := Load(path, env(map[string]string{
+		"PORT":                        "7000",
+		"GRPC_ADDR":                   ":7001",
+		"API_KEYS":                    "a, b,",
+		"TENANTS":                     "shop_a,shop_b",
+		"IDEMPOTENCY_HORIZON":         "2h",
+		"INGEST_QUEUE_WORKERS":        "8",
+		"TRUST_PROXY":                 "true",
+		"LOG_LEVEL":                   "debug",
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Listen.Address != ":7000" {
+		t.Errorf("expected PORT to override the address, got %q", cfg.Listen.Address)
+	}
+	if cfg.GRPC.Address != ":7001" {
+		t.Errorf("expected GRPC_ADDR to override the gRPC address, got %q", cfg.GRPC.Address)
+	}
+	if strings.Join(cfg.Auth.APIKeys, ",") != "a,b" {
+		t.Errorf("expected keys from API_KEYS, got %v", cfg.Auth.APIKeys)
+	}
+	if strings.Join(cfg.Tenants, ",") != "shop_a,shop_b" {
+		t.Errorf("expected tenants from TENANTS, got %v", cfg.Tenants)
+	}
+	if cfg.Storage.IdempotencyHorizon != 2*time.Hour || cfg.Storage.QueueWorkers != 8 || !cfg.RateLimits.TrustProxy {
+		t.Errorf("unexpected overrides: %+v %+v", cfg.Storage, cfg.RateLimits)
+	}
+	if cfg.Logging.Level != "debug" || cfg.Logging.Format != "json" {
+		t.Errorf("expected LOG_LEVEL to override the level only, got %+v", cfg.Logging)
+	}
+	if cfg.Tracing.Endpoint != "http://collector:4318" || cfg.Tracing.ServiceName != "techcart-failover" {
+		t.Errorf("expected the OTLP endpoint from the environment, got %+v", cfg.Tracing)
+	}
+
+	if _, err := Load("", env(map[string]string{"INGEST_QUEUE_DEPTH": "lots"})); err == nil || !strings.Contains(err.Error(), "INGEST_QUEUE_DEPTH") {
+		t.Errorf("expected a named env error, got %v", err)
+	}
+	if _, err := Load("", env(map[string]string{"GRPC_ADDR": ":8080"})); err == nil || !strings.Contains(err.Error(), "grpc.address: must differ") {
+		t.Errorf("expected gRPC on the HTTP port to be rejected, got %v", err)
+	}
+}
+
+func TestLoad_ReportsEveryInvalidSetting(t *testing.T) {
+	path := writeConfig(t, `{
+		"listen": {"address": "8080"},
+		"grpc": {"address": "9090"},
+		"processors": [{"id": "p1", "countries": ["BR"], "payment_methods": ["PIX"]}, {"id": "p1", "countries": ["BR"], "payment_methods": ["PIX"]}],
+		"tenants": [" "],
+		"routing": {"degraded_penalty": 2},
+		"auth": {"api_keys": ["k1"], "tenant_keys": {"shop_a": ["k1", ""]}},
+		"notifications": {"webhook_url": "ftp://example.com"},
+		"cors": {"allowed_origins": ["example.com"]},
+		"logging": {"format": "xml", "level": "loud"},
+		"tracing": {"endpoint": "collector:4318", "sample_ratio": 2}
+	}`)
+
+	_, err := Load(path, env(nil))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"listen.address", "grpc.address", "processors[1]: duplicate id", "tenants[0]", "routing: degraded_penalty",
+		"auth.tenant_keys.shop_a[0]: key is already used", "auth.tenant_keys.shop_a[1]: must not be blank", "notifications.webhook_url", "cors.allowed_origins[0]", "logging.format", "logging.level", "tracing.endpoint", "tracing.sample_ratio"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoad_RejectsInvalidLimits(t *testing.T) {
+	cases := map[string]string{
+		`{"BRL": {"min_amount": 500, "max_amount": 100}}`: "limits[BRL] min_amount 500 exceeds max_amount 100",
+		`{"BRL": {"min_amount": -1}}`:                     "limits[BRL] must not be negative",
+		`{"BRL": {"max_amount": -1}}`:                     "limits[BRL] must not be negative",
+		`{"BRL": {"daily_volume_cap": -1}}`:               "limits[BRL] must not be negative",
+		`{"BRL": {"monthly_volume_cap": -1}}`:             "limits[BRL] must not be negative",
+		`{"BRL": {"min_amount": 500}}`:                    "",
+		`{"BRL": {"min_amount": 10, "max_amount": 10}}`:   "",
+	}
+	for limits, want := range cases {
+		_, err := Load(writeConfig(t, `{"processors": [{"id": "p1", "countries": ["BR"], "payment_methods": ["PIX"], "limits": `+limits+`}]}`), env(nil))
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("%s: expected valid limits, got %v", limits, err)
+		case want != "" && (err == nil || !strings.Contains(err.Error(), "processors[0]: "+want)):
+			t.Errorf("%s: expected %q, got %v", limits, want, err)
+		}
+	}
+}
+
+func TestLoad_StrictDecoding(t *testing.T) {
+	cases := map[string]string{
+		`{"listn": {}}`:                         `unknown field "listn"`,
+		`{"storage": {"depth": 1}}`:             `unknown field "depth"`,
+		"{\n  \"listen\": {\"address\": 80}\n}": "line 2, column 13: address must be string",
+		"{\n  \"listen\": {},\n}":               "line 3, column 1",
+	}
+	for doc, want := range cases {
+		_, err := Load(writeConfig(t, doc), env(nil))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestConfig_RestartRequired(t *testing.T) {
+	old := Default()
+	next := Default()
+	next.Routing.DegradedPenalty = 0.1
+	next.Auth.APIKeys = []string{"k"}
+	next.Storage.QueueDepth = 10
+	next.Listen.Address = ":9090"
+	next.Logging.Level = "debug"
+
+	got := old.RestartRequired(next)
+	if strings.Join(got, ",") != "listen,storage" {
+		t.Errorf("expected listen and storage to need a restart, got %v", got)
+	}
+}
+
+func TestConfig_TenantIDs(t *testing.T) {
+	cfg := Default()
+	cfg.Tenants = []string{"shop_b", "shop_a"}
+	cfg.Auth.TenantKeys = map[string][]string{"shop_a": {"k1"}, "shop_c": {"k2"}}
+
+	if got := strings.Join(cfg.TenantIDs(), ","); got != "shop_a,shop_b,shop_c" {
+		t.Errorf("expected configured and keyed tenants once each, got %s", got)
+	}
+
+	// Added tenants are applied on reload; removed ones need a restart
+	next := cfg
+	next.Tenants = []string{"shop_a", "shop_b", "shop_d"}
+	if got := cfg.RestartRequired(next); len(got) != 0 {
+		t.Errorf("expected an added tenant not to need a restart, got %v", got)
+	}
+	next.Auth.TenantKeys = nil
+	if got := cfg.RestartRequired(next); strings.Join(got, ",") != "tenants" {
+		t.Errorf("expected a removed tenant to need a restart, got %v", got)
+	}
+}
+
+func TestExampleConfigIsValid(t *testing.T) {
+	if _, err := Load("../../config.example.json", env(nil)); err != nil {
+		t.Errorf("config.example.json: %v", err)
+	}
+}
+
+// Helper functions
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func writeConfig(t *testing.T, doc string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
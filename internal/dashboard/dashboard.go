@@ -0,0 +1,272 @@
+// Package dashboard serves a self-contained operations dashboard: static
+// assets embedded in the binary and a server-sent event stream pushing each
+// tenant's processor health, trends, current routes and alerts.
+package dashboard
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/auth"
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/logging"
+	"github.com/yuno/techcart-failover/internal/tenant"
+)
+
+// DefaultPushInterval is how often the stream pushes a new snapshot
+const DefaultPushInterval = 2 * time.Second
+
+//go:embed static
+var static embed.FS
+
+// Dashboard serves the dashboard page and its event stream
+type Dashboard struct {
+	tenants      *tenant.Registry
+	clock        clock.Clock
+	trend        *Trend
+	pushInterval time.Duration
+	closing      chan struct{}
+	closeOnce    sync.Once
+}
+
+// New creates a dashboard over the tenant registry
+func New(tenants *tenant.Registry, clk clock.Clock) *Dashboard {
+	return &Dashboard{
+		tenants:      tenants,
+		clock:        clk,
+		trend:        NewTrend(TrendWindow),
+		pushInterval: DefaultPushInterval,
+		closing:      make(chan struct{}),
+	}
+}
+
+// Close ends every open stream, e.g. on server shutdown, which would
+// otherwise wait for clients to disconnect
+func (d *Dashboard) Close() {
+	d.closeOnce.Do(func() { close(d.closing) })
+}
+
+// SetPushInterval changes how often the stream pushes snapshots
+func (d *Dashboard) SetPushInterval(interval time.Duration) {
+	d.pushInterval = interval
+}
+
+// RegisterRoutes sets up the dashboard page, its assets and the stream
+func (d *Dashboard) RegisterRoutes(mux *http.ServeMux) {
+	assets, _ := fs.Sub(static, "static")
+	mux.Handle("GET /dashboard/", http.StripPrefix("/dashboard/", http.FileServer(http.FS(assets))))
+	mux.HandleFunc("GET /dashboard", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/dashboard/?"+r.URL.RawQuery, http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("GET /api/v1/dashboard/snapshot", d.GetSnapshot)
+	mux.HandleFunc("GET /api/v1/dashboard/stream", d.Stream)
+}
+
+// Run samples every tenant's health into the trend until ctx is cancelled
+func (d *Dashboard) Run(ctx context.Context) {
+	d.Sample()
+
+	ticker := d.clock.NewTicker(TrendInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			d.Sample()
+		}
+	}
+}
+
+// Sample records the current health of every tenant's processors
+func (d *Dashboard) Sample() {
+	now := d.clock.Now()
+	for _, t := range d.tenants.List() {
+		d.trend.Sample(t.ID, healthOf(t), now)
+	}
+}
+
+// Snapshot is what the dashboard renders for a tenant. Trend, Alerts and
+// SLOAlerts only hold entries newer than the client has already seen.
+type Snapshot struct {
+	TenantID   string                    `json:"tenant_id"`
+	Timestamp  time.Time                 `json:"timestamp"`
+	Processors []ProcessorView           `json:"processors"`
+	Routes     []Route                   `json:"routes"`
+	Trend      map[string][]Point        `json:"trend"`
+	Alerts     []domain.HealthTransition `json:"alerts"`
+	SLOAlerts  []domain.SLOAlert         `json:"slo_alerts"`
+}
+
+// ProcessorView is a processor's current health
+type ProcessorView struct {
+	ProcessorID       string              `json:"processor_id"`
+	Name              string              `json:"name"`
+	Status            domain.HealthStatus `json:"status"`
+	AuthorizationRate float64             `json:"authorization_rate"`
+	ErrorRate         float64             `json:"error_rate"`
+	Transactions      int                 `json:"transactions"`
+}
+
+// Route is the processor currently recommended for a corridor ("" when
+// every candidate is DOWN)
+type Route struct {
+	PaymentMethod domain.PaymentMethod `json:"payment_method"`
+	Country       domain.Country       `json:"country"`
+	ProcessorID   string               `json:"processor_id"`
+	Status        domain.HealthStatus  `json:"status,omitempty"`
+	Reason        string               `json:"reason"`
+}
+
+// snapshot builds a tenant's snapshot with trend points, transitions and
+// SLO alerts after their respective since
+func (d *Dashboard) snapshot(t *tenant.Tenant, trendSince, alertsSince, sloSince time.Time) Snapshot {
+	s := Snapshot{
+		TenantID:   t.ID,
+		Timestamp:  d.clock.Now(),
+		Processors: []ProcessorView{},
+		Routes:     []Route{},
+		Trend:      d.trend.Since(t.ID, trendSince),
+		Alerts:     t.Calculator.GetTransitions(alertsSince),
+		SLOAlerts:  t.SLO.Alerts(sloSince),
+	}
+	if s.Alerts == nil {
+		s.Alerts = []domain.HealthTransition{}
+	}
+	if s.SLOAlerts == nil {
+		s.SLOAlerts = []domain.SLOAlert{}
+	}
+
+	names := make(map[string]string)
+	for _, p := range t.Engine.GetProcessors() {
+		names[p.ID] = p.Name
+	}
+	for _, h := range healthOf(t) {
+		point := pointOf(h, s.Timestamp)
+		s.Processors = append(s.Processors, ProcessorView{
+			ProcessorID:       h.ProcessorID,
+			Name:              names[h.ProcessorID],
+			Status:            h.Status,
+			AuthorizationRate: h.AuthorizationRate,
+			ErrorRate:         point.ErrorRate,
+			Transactions:      h.TotalTransactions,
+		})
+	}
+
+	for _, corridor := range t.Engine.Corridors() {
+		route := Route{PaymentMethod: corridor.PaymentMethod, Country: corridor.Country, Reason: "All processors DOWN"}
+		rec := t.Engine.Preview(corridor)
+		if len(rec.Recommendations) > 0 && rec.Recommendations[0].Recommended {
+			top := rec.Recommendations[0]
+			route.ProcessorID, route.Status, route.Reason = top.ProcessorID, top.Status, top.Reason
+		}
+		s.Routes = append(s.Routes, route)
+	}
+	return s
+}
+
+// healthOf returns the health of every registered processor, sorted by ID
+func healthOf(t *tenant.Tenant) []*domain.ProcessorHealth {
+	processors := t.Engine.GetProcessors()
+	result := make([]*domain.ProcessorHealth, 0, len(processors))
+	for _, p := range processors {
+		result = append(result, t.Calculator.GetHealth(p.ID))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ProcessorID < result[j].ProcessorID })
+	return result
+}
+
+// GET /api/v1/dashboard/snapshot - Current dashboard state with the last hour of trend and alerts
+func (d *Dashboard) GetSnapshot(w http.ResponseWriter, r *http.Request) {
+	t, ok := d.tenant(w, r)
+	if !ok {
+		return
+	}
+	since := d.clock.Now().Add(-TrendWindow)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.snapshot(t, since, since, since))
+}
+
+// GET /api/v1/dashboard/stream - Server-sent events: a full snapshot, then
+// changes every push interval
+func (d *Dashboard) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	t, ok := d.tenant(w, r)
+	if !ok {
+		return
+	}
+
+	// The stream outlives the server's write timeout; it ends when the
+	// client goes away or the dashboard is closed
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	trendSince := d.clock.Now().Add(-TrendWindow)
+	alertsSince, sloSince := trendSince, trendSince
+
+	ticker := d.clock.NewTicker(d.pushInterval)
+	defer ticker.Stop()
+	for {
+		s := d.snapshot(t, trendSince, alertsSince, sloSince)
+		for _, points := range s.Trend {
+			if last := points[len(points)-1].Timestamp; last.After(trendSince) {
+				trendSince = last
+			}
+		}
+		if n := len(s.Alerts); n > 0 {
+			alertsSince = s.Alerts[n-1].Timestamp
+		}
+		if n := len(s.SLOAlerts); n > 0 {
+			sloSince = s.SLOAlerts[n-1].Timestamp
+		}
+
+		data, _ := json.Marshal(s)
+		if _, err := fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-d.closing:
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// tenant resolves the tenant from the tenant_id query param (EventSource
+// cannot set headers) or the X-Tenant-ID header without creating it.
+// Unknown tenants get 404, and tenants other than the API key's 403.
+func (d *Dashboard) tenant(w http.ResponseWriter, r *http.Request) (*tenant.Tenant, bool) {
+	id := r.URL.Query().Get("tenant_id")
+	if id == "" {
+		id = r.Header.Get("X-Tenant-ID")
+	}
+	t, err := d.tenants.Resolve(id, auth.TenantFromContext(r.Context()))
+	switch err {
+	case nil:
+		return t, true
+	case tenant.ErrOtherTenant:
+		logging.WriteError(w, http.StatusForbidden, err.Error())
+	default:
+		logging.WriteError(w, http.StatusNotFound, err.Error())
+	}
+	return nil, false
+}
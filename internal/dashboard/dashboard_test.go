@@ -0,0 +1,215 @@
+package dashboard
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/tenant"
+)
+
+func TestTrend_KeepsWindow(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	trend := NewTrend(time.Hour)
+	h := &domain.ProcessorHealth{ProcessorID: "processor_a", Status: domain.StatusHealthy}
+
+	for i := 0; i <= 90; i++ {
+		trend.Sample("techcart", []*domain.ProcessorHealth{h}, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	points := trend.Since("techcart", time.Time{})["processor_a"]
+	if len(points) != 61 {
+		t.Fatalf("expected 61 points within the hour, got %d", len(points))
+	}
+	if want := start.Add(30 * time.Minute); !points[0].Timestamp.Equal(want) {
+		t.Errorf("expected oldest point at %s, got %s", want, points[0].Timestamp)
+	}
+
+	recent := trend.Since("techcart", start.Add(88*time.Minute))["processor_a"]
+	if len(recent) != 2 {
+		t.Errorf("expected 2 points after since, got %d", len(recent))
+	}
+	if other := trend.Since("other", time.Time{}); len(other) != 0 {
+		t.Errorf("expected no points for another tenant, got %v", other)
+	}
+}
+
+func TestTrend_ErrorRate(t *testing.T) {
+	h := &domain.ProcessorHealth{ProcessorID: "processor_a", AuthorizationRate: 0.5, TotalTransactions: 20, ErrorCount: 5}
+	if p := pointOf(h, time.Now()); p.ErrorRate != 0.25 {
+		t.Errorf("expected error rate 0.25, got %v", p.ErrorRate)
+	}
+}
+
+func TestDashboard_SnapshotRoutesAndAlerts(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	tenants := newTenants(clk)
+	for i := 0; i < 50; i++ {
+		tenants.RecordTransaction(pixTx(clk, "processor_a", domain.ResultError))
+	}
+	d := New(tenants, clk)
+	d.Sample()
+
+	s := getSnapshot(t, d, "/api/v1/dashboard/snapshot?tenant_id=techcart")
+
+	if len(s.Processors) != 2 || s.Processors[0].ProcessorID != "processor_a" {
+		t.Fatalf("expected processors sorted by ID, got %+v", s.Processors)
+	}
+	if s.Processors[0].Status != domain.StatusDown || s.Processors[0].ErrorRate != 1 {
+		t.Errorf("expected processor_a DOWN with error rate 1, got %+v", s.Processors[0])
+	}
+	if len(s.Routes) != 1 || s.Routes[0].ProcessorID != "processor_b" {
+		t.Errorf("expected PIX/BR routed to processor_b, got %+v", s.Routes)
+	}
+	if len(s.Alerts) == 0 || s.Alerts[len(s.Alerts)-1].ToStatus != domain.StatusDown {
+		t.Errorf("expected an alert for processor_a going DOWN, got %+v", s.Alerts)
+	}
+	if len(s.Trend["processor_a"]) != 1 {
+		t.Errorf("expected one trend point for processor_a, got %+v", s.Trend)
+	}
+
+	// Previewing routes must not count as traffic sent to processors
+	for _, load := range tenants.Get("techcart").Engine.Loads() {
+		if load.InFlight != 0 || load.RecommendedTPS != 0 {
+			t.Errorf("expected snapshot to dispatch nothing, got %+v", load)
+		}
+	}
+}
+
+func TestDashboard_StreamSendsSnapshot(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	d := New(newTenants(clk), clk)
+	d.Sample()
+
+	server := httptest.NewServer(routes(d))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/dashboard/stream?tenant_id=techcart", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	event, _ := reader.ReadString('\n')
+	data, _ := reader.ReadString('\n')
+	if event != "event: snapshot\n" || !strings.HasPrefix(data, "data: ") {
+		t.Fatalf("expected a snapshot event, got %q %q", event, data)
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &s); err != nil {
+		t.Fatalf("invalid snapshot: %v", err)
+	}
+	if s.TenantID != "techcart" || len(s.Processors) != 2 {
+		t.Errorf("expected techcart snapshot with 2 processors, got %+v", s)
+	}
+}
+
+func TestDashboard_CloseEndsStreams(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	d := New(newTenants(clk), clk)
+
+	server := httptest.NewServer(routes(d))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/dashboard/stream")
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	reader.ReadString('\n')
+
+	d.Close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, reader)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the stream to end cleanly, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Close to end the stream")
+	}
+}
+
+func TestDashboard_ServesEmbeddedPage(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	mux := routes(New(newTenants(clk), clk))
+
+	for _, path := range []string{"/dashboard/", "/dashboard/app.js", "/dashboard/style.css"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected 200 for %s, got %d", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard?tenant_id=techcart", nil))
+	if loc := rec.Header().Get("Location"); loc != "/dashboard/?tenant_id=techcart" {
+		t.Errorf("expected redirect keeping the tenant, got %q", loc)
+	}
+}
+
+// Helper functions
+
+func newTenants(clk clock.Clock) *tenant.Registry {
+	tenants := tenant.NewRegistry(nil, clk)
+	tenants.SetDefaultProcessors([]*domain.Processor{
+		{ID: "processor_a", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodPIX}},
+		{ID: "processor_b", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodPIX}},
+	})
+	tenants.Get(tenant.DefaultID)
+	return tenants
+}
+
+func pixTx(clk clock.Clock, processorID string, result domain.TransactionResult) domain.Transaction {
+	return domain.Transaction{
+		TenantID:      "techcart",
+		ProcessorID:   processorID,
+		Timestamp:     clk.Now(),
+		Result:        result,
+		PaymentMethod: domain.MethodPIX,
+		Country:       domain.CountryBR,
+	}
+}
+
+func routes(d *Dashboard) *http.ServeMux {
+	mux := http.NewServeMux()
+	d.RegisterRoutes(mux)
+	return mux
+}
+
+func getSnapshot(t *testing.T, d *Dashboard, path string) Snapshot {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	routes(d).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var s Snapshot
+	if err := json.NewDecoder(rec.Body).Decode(&s); err != nil {
+		t.Fatalf("invalid snapshot: %v", err)
+	}
+	return s
+}
@@ -0,0 +1,163 @@
+// Operations dashboard: renders snapshots pushed over server-sent events.
+(function () {
+  "use strict";
+
+  var TREND_WINDOW_MS = 60 * 60 * 1000;
+  var params = new URLSearchParams(location.search);
+  var tenant = params.get("tenant_id") || "";
+  var apiKey = params.get("api_key") || "";
+  var trend = {};   // processor_id -> points
+  var alerts = [];  // newest first
+  var source;
+
+  document.getElementById("tenant").value = tenant;
+  document.getElementById("tenant-form").addEventListener("submit", function (e) {
+    e.preventDefault();
+    var value = document.getElementById("tenant").value.trim();
+    var query = new URLSearchParams();
+    if (value) query.set("tenant_id", value);
+    if (apiKey) query.set("api_key", apiKey);
+    location.search = query.toString();
+  });
+
+  function connect() {
+    // EventSource cannot set headers, so tenant and API key go in the query
+    var query = new URLSearchParams();
+    if (tenant) query.set("tenant_id", tenant);
+    if (apiKey) query.set("api_key", apiKey);
+    source = new EventSource("../api/v1/dashboard/stream" + (query.toString() ? "?" + query : ""));
+    source.addEventListener("open", function () { setConnection("connected"); });
+    source.addEventListener("error", function () { setConnection("disconnected"); });
+    source.addEventListener("snapshot", function (e) { render(JSON.parse(e.data)); });
+  }
+
+  function setConnection(state) {
+    var el = document.getElementById("connection");
+    el.textContent = state;
+    el.className = "badge " + state;
+  }
+
+  function render(s) {
+    mergeTrend(s.trend, new Date(s.timestamp).getTime());
+    mergeAlerts(s.alerts);
+    mergeAlerts(s.slo_alerts);
+    renderProcessors(s.processors);
+    renderRoutes(s.routes);
+    renderAlerts();
+    document.getElementById("updated").textContent = "updated " + new Date(s.timestamp).toLocaleTimeString();
+  }
+
+  function mergeTrend(update, now) {
+    Object.keys(update || {}).forEach(function (id) {
+      trend[id] = (trend[id] || []).concat(update[id]);
+    });
+    Object.keys(trend).forEach(function (id) {
+      trend[id] = trend[id].filter(function (p) {
+        return now - new Date(p.timestamp).getTime() <= TREND_WINDOW_MS;
+      });
+    });
+  }
+
+  function mergeAlerts(update) {
+    // On reconnect the server resends the last hour; skip what we have
+    var seen = {};
+    alerts.forEach(function (a) { seen[key(a)] = true; });
+    (update || []).forEach(function (a) {
+      if (!seen[key(a)]) alerts.unshift(a);
+    });
+    // Transitions and SLO alerts arrive separately; keep the feed in time order
+    alerts.sort(function (a, b) { return new Date(b.timestamp) - new Date(a.timestamp); });
+    alerts = alerts.slice(0, 200);
+  }
+
+  function key(a) {
+    if (a.type === "slo_burn_rate") {
+      return a.slo_id + "|" + a.processor_id + "|" + a.window + "|" + a.timestamp + "|" + a.state;
+    }
+    return a.processor_id + "|" + a.timestamp + "|" + a.to_status;
+  }
+
+  function renderProcessors(processors) {
+    var body = document.querySelector("#processors tbody");
+    body.innerHTML = "";
+    processors.forEach(function (p) {
+      var row = document.createElement("tr");
+      row.appendChild(cell(escape(p.processor_id) + (p.name ? ' <span class="name">' + escape(p.name) + "</span>" : ""), true));
+      row.appendChild(cell(badge(p.status), true));
+      row.appendChild(cell(percent(p.authorization_rate), false, "num"));
+      row.appendChild(cell(percent(p.error_rate), false, "num"));
+      row.appendChild(cell(String(p.transactions), false, "num"));
+      row.appendChild(cell(sparkline(trend[p.processor_id] || []), true));
+      body.appendChild(row);
+    });
+  }
+
+  function renderRoutes(routes) {
+    var body = document.querySelector("#routes tbody");
+    body.innerHTML = "";
+    routes.forEach(function (r) {
+      var row = document.createElement("tr");
+      row.appendChild(cell(r.payment_method));
+      row.appendChild(cell(r.country));
+      row.appendChild(cell(r.processor_id ? escape(r.processor_id) + " " + badge(r.status) : badge("DOWN"), true));
+      row.appendChild(cell(r.reason));
+      body.appendChild(row);
+    });
+  }
+
+  function renderAlerts() {
+    var list = document.getElementById("alerts");
+    if (alerts.length === 0) return;
+    list.innerHTML = "";
+    alerts.forEach(function (a) {
+      var item = document.createElement("li");
+      var summary = a.type === "slo_burn_rate"
+        ? escape(a.processor_id) + " " + escape(a.slo_id) + " " + escape(a.window) + " burn " +
+          '<span class="badge ' + (a.state === "firing" ? "DOWN" : "HEALTHY") + '">' + escape(a.state) + "</span>"
+        : escape(a.processor_id) + " " + badge(a.from_status) + " &rarr; " + badge(a.to_status);
+      item.innerHTML = '<span class="time">' + new Date(a.timestamp).toLocaleTimeString() + "</span>" +
+        summary + '<span class="reason">' + escape(a.reason) + "</span>";
+      list.appendChild(item);
+    });
+  }
+
+  function sparkline(points) {
+    var width = 240, height = 36;
+    if (points.length < 2) return '<svg class="spark"></svg>';
+    var t0 = new Date(points[0].timestamp).getTime();
+    var span = Math.max(new Date(points[points.length - 1].timestamp).getTime() - t0, 1);
+    function line(field, cls) {
+      var coords = points.map(function (p) {
+        var x = (new Date(p.timestamp).getTime() - t0) / span * width;
+        var y = height - 2 - p[field] * (height - 4);
+        return x.toFixed(1) + "," + y.toFixed(1);
+      });
+      return '<polyline class="' + cls + '" points="' + coords.join(" ") + '"/>';
+    }
+    return '<svg class="spark" viewBox="0 0 ' + width + " " + height + '" preserveAspectRatio="none">' +
+      line("authorization_rate", "auth") + line("error_rate", "error") + "</svg>";
+  }
+
+  function cell(content, html, cls) {
+    var td = document.createElement("td");
+    if (html) td.innerHTML = content; else td.textContent = content;
+    if (cls) td.className = cls;
+    return td;
+  }
+
+  function badge(status) {
+    return '<span class="badge ' + escape(status) + '">' + escape(status) + "</span>";
+  }
+
+  function percent(rate) {
+    return (rate * 100).toFixed(1) + "%";
+  }
+
+  function escape(s) {
+    return String(s).replace(/[&<>"']/g, function (c) {
+      return { "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" }[c];
+    });
+  }
+
+  connect();
+})();
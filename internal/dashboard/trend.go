@@ -0,0 +1,91 @@
+package dashboard
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// Trend sampling defaults
+const (
+	TrendInterval = 30 * time.Second // How often health is sampled
+	TrendWindow   = time.Hour        // How much history is kept
+)
+
+// Point is one health sample of a processor
+type Point struct {
+	Timestamp         time.Time           `json:"timestamp"`
+	Status            domain.HealthStatus `json:"status"`
+	AuthorizationRate float64             `json:"authorization_rate"`
+	ErrorRate         float64             `json:"error_rate"`
+	Transactions      int                 `json:"transactions"`
+}
+
+type seriesKey struct {
+	tenantID    string
+	processorID string
+}
+
+// Trend keeps the last TrendWindow of health samples per tenant and processor
+type Trend struct {
+	mu     sync.RWMutex
+	window time.Duration
+	series map[seriesKey][]Point
+}
+
+// NewTrend creates an empty trend keeping window of samples
+func NewTrend(window time.Duration) *Trend {
+	return &Trend{window: window, series: make(map[seriesKey][]Point)}
+}
+
+// Sample records the current health of a tenant's processors
+func (t *Trend) Sample(tenantID string, healths []*domain.ProcessorHealth, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := now.Add(-t.window)
+	for _, h := range healths {
+		key := seriesKey{tenantID: tenantID, processorID: h.ProcessorID}
+		points := append(t.series[key], pointOf(h, now))
+
+		n := 0
+		for n < len(points) && points[n].Timestamp.Before(cutoff) {
+			n++
+		}
+		t.series[key] = points[n:]
+	}
+}
+
+// Since returns a tenant's samples taken after since, by processor
+func (t *Trend) Since(tenantID string, since time.Time) map[string][]Point {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make(map[string][]Point)
+	for key, points := range t.series {
+		if key.tenantID != tenantID {
+			continue
+		}
+		for i, p := range points {
+			if p.Timestamp.After(since) {
+				result[key.processorID] = append([]Point(nil), points[i:]...)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func pointOf(h *domain.ProcessorHealth, now time.Time) Point {
+	p := Point{
+		Timestamp:         now,
+		Status:            h.Status,
+		AuthorizationRate: h.AuthorizationRate,
+		Transactions:      h.TotalTransactions,
+	}
+	if h.TotalTransactions > 0 {
+		p.ErrorRate = float64(h.ErrorCount) / float64(h.TotalTransactions)
+	}
+	return p
+}
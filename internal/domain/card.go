@@ -0,0 +1,74 @@
+package domain
+
+import "strings"
+
+// Card brands recognised from a BIN
+const (
+	BrandVisa       = "VISA"
+	BrandMastercard = "MASTERCARD"
+	BrandAmex       = "AMEX"
+	BrandElo        = "ELO"
+)
+
+// MaxBINDigits is the most of a card number that is ever stored
+const MaxBINDigits = 8
+
+// TruncateBIN keeps at most the first 8 digits of a BIN or card number,
+// ignoring spaces and dashes. It returns "" when fewer than 6 digits are
+// given or anything else is present, so a full PAN is never kept.
+func TruncateBIN(raw string) string {
+	var b strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			if b.Len() < MaxBINDigits {
+				b.WriteRune(r)
+			}
+		case r == ' ' || r == '-':
+		default:
+			return ""
+		}
+	}
+	if b.Len() < 6 {
+		return ""
+	}
+	return b.String()
+}
+
+// BINRange returns the 6-digit range a BIN belongs to
+func BINRange(bin string) string {
+	if len(bin) < 6 {
+		return ""
+	}
+	return bin[:6]
+}
+
+// CardBrandFromBIN infers the brand from the leading digits ("" if unknown)
+func CardBrandFromBIN(bin string) string {
+	if len(bin) < 6 {
+		return ""
+	}
+	prefix := func(n int) int {
+		v := 0
+		for _, r := range bin[:n] {
+			v = v*10 + int(r-'0')
+		}
+		return v
+	}
+
+	switch p6 := prefix(6); {
+	// Elo ranges overlap Visa and Mastercard, so check them first
+	case p6 == 401178 || p6 == 401179 || p6 == 431274 || p6 == 438935 || p6 == 451416 ||
+		p6 == 457393 || p6 == 504175 || p6 == 627780 || p6 == 636297 || p6 == 636368 ||
+		(p6 >= 506699 && p6 <= 506778) || (p6 >= 509000 && p6 <= 509999) ||
+		(p6 >= 650031 && p6 <= 650033) || (p6 >= 650035 && p6 <= 650051):
+		return BrandElo
+	case bin[0] == '4':
+		return BrandVisa
+	case prefix(2) >= 51 && prefix(2) <= 55, prefix(4) >= 2221 && prefix(4) <= 2720:
+		return BrandMastercard
+	case prefix(2) == 34 || prefix(2) == 37:
+		return BrandAmex
+	}
+	return ""
+}
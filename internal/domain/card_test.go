@@ -0,0 +1,44 @@
+package domain
+
+import "testing"
+
+func TestTruncateBIN(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"411111", "411111"},
+		{"41111111", "41111111"},
+		{"4111 1111 1111 1111", "41111111"}, // full PAN: only the BIN is kept
+		{"5555-5555-5555-4444", "55555555"},
+		{"41111", ""},
+		{"4111x1", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := TruncateBIN(tt.raw); got != tt.want {
+			t.Errorf("TruncateBIN(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCardBrandFromBIN(t *testing.T) {
+	tests := []struct {
+		bin  string
+		want string
+	}{
+		{"41111111", BrandVisa},
+		{"555555", BrandMastercard},
+		{"222100", BrandMastercard},
+		{"378282", BrandAmex},
+		{"636368", BrandElo},
+		{"509123", BrandElo},
+		{"601100", ""},
+		{"4111", ""},
+	}
+	for _, tt := range tests {
+		if got := CardBrandFromBIN(tt.bin); got != tt.want {
+			t.Errorf("CardBrandFromBIN(%q) = %q, want %q", tt.bin, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
 
 // TransactionResult represents the outcome of a payment transaction
 type TransactionResult string
@@ -19,6 +24,8 @@ const (
 	StatusHealthy  HealthStatus = "HEALTHY"
 	StatusDegraded HealthStatus = "DEGRADED"
 	StatusDown     HealthStatus = "DOWN"
+	StatusStale    HealthStatus = "STALE"   // No transactions recently; last rates may be outdated
+	StatusUnknown  HealthStatus = "UNKNOWN" // All transactions expired from the window
 )
 
 // PaymentMethod represents supported payment methods
@@ -40,9 +47,25 @@ const (
 	CountryCO Country = "CO"
 )
 
-// Transaction represents a transaction result received from merchants
+// DefaultCurrency returns the local currency of a country ("" if unknown)
+func DefaultCurrency(country Country) string {
+	switch country {
+	case CountryBR:
+		return "BRL"
+	case CountryMX:
+		return "MXN"
+	case CountryCO:
+		return "COP"
+	default:
+		return ""
+	}
+}
+
+// Transaction represents a transaction result received from merchants.
+// Card fields are optional; BIN holds at most the first 8 digits.
 type Transaction struct {
 	ID            string            `json:"id"`
+	TenantID      string            `json:"tenant_id,omitempty"`
 	ProcessorID   string            `json:"processor_id"`
 	Timestamp     time.Time         `json:"timestamp"`
 	Result        TransactionResult `json:"result"`
@@ -50,19 +73,90 @@ type Transaction struct {
 	Country       Country           `json:"country"`
 	Amount        float64           `json:"amount"`
 	Currency      string            `json:"currency"`
+	DecisionID    string            `json:"decision_id,omitempty"`
+	BIN           string            `json:"bin,omitempty"`
+	CardBrand     string            `json:"card_brand,omitempty"`
+	IssuerCountry Country           `json:"issuer_country,omitempty"`
+	PaymentID     string            `json:"payment_id,omitempty"`
+	CustomerID    string            `json:"customer_id,omitempty"`
+	RequestID     string            `json:"request_id,omitempty"` // X-Request-ID of the report, for tracing
 }
 
-// Processor represents a payment processor configuration
+// Processor represents a payment processor configuration. Currencies
+// lists the accepted currencies (empty accepts any); Limits holds ticket
+// sizes and volume caps per currency. MaxTPS and MaxInFlight bound the
+// load routed to it (zero means unlimited).
 type Processor struct {
-	ID             string          `json:"id"`
-	Name           string          `json:"name"`
-	Countries      []Country       `json:"countries"`
-	PaymentMethods []PaymentMethod `json:"payment_methods"`
+	ID             string                 `json:"id"`
+	TenantID       string                 `json:"tenant_id,omitempty"`
+	Name           string                 `json:"name"`
+	Countries      []Country              `json:"countries"`
+	PaymentMethods []PaymentMethod        `json:"payment_methods"`
+	Currencies     []string               `json:"currencies,omitempty"`
+	Limits         map[string]AmountLimit `json:"limits,omitempty"`
+	MaxTPS         float64                `json:"max_tps,omitempty"`
+	MaxInFlight    int                    `json:"max_in_flight,omitempty"`
+}
+
+// ProcessorLoad is the live load on a processor. Utilization is the higher
+// of TPS and in-flight usage of its capacity (0 without capacity).
+type ProcessorLoad struct {
+	ProcessorID    string  `json:"processor_id"`
+	RecommendedTPS float64 `json:"recommended_tps"` // payments sent by recommendations
+	RecordedTPS    float64 `json:"recorded_tps"`    // outcomes reported
+	InFlight       int     `json:"in_flight"`       // recommended, outcome not yet reported
+	MaxTPS         float64 `json:"max_tps,omitempty"`
+	MaxInFlight    int     `json:"max_in_flight,omitempty"`
+	Utilization    float64 `json:"utilization"`
+	Saturated      bool    `json:"saturated"`
+}
+
+// AmountLimit bounds what a processor accepts in one currency. Zero means
+// no limit. Volume caps apply to approved amounts per UTC day and month.
+type AmountLimit struct {
+	MinAmount        float64 `json:"min_amount,omitempty"`
+	MaxAmount        float64 `json:"max_amount,omitempty"`
+	DailyVolumeCap   float64 `json:"daily_volume_cap,omitempty"`
+	MonthlyVolumeCap float64 `json:"monthly_volume_cap,omitempty"`
+}
+
+// ValidateLimits rejects negative limits and a min_amount above max_amount.
+func (p *Processor) ValidateLimits() error {
+	for _, currency := range slices.Sorted(maps.Keys(p.Limits)) {
+		l := p.Limits[currency]
+		switch {
+		case l.MinAmount < 0 || l.MaxAmount < 0 || l.DailyVolumeCap < 0 || l.MonthlyVolumeCap < 0:
+			return fmt.Errorf("limits[%s] must not be negative", currency)
+		case l.MaxAmount > 0 && l.MinAmount > l.MaxAmount:
+			return fmt.Errorf("limits[%s] min_amount %g exceeds max_amount %g", currency, l.MinAmount, l.MaxAmount)
+		}
+	}
+	return nil
+}
+
+// Payment describes the payment a routing decision is made for. Currency
+// defaults to the country's local currency; a zero Amount skips ticket
+// size checks. BIN and CardBrand are optional for CARD payments.
+// PaymentID identifies the checkout across retries; processors in Attempted
+// (or already reported for the payment) are excluded. With Sticky, the
+// processor that last approved CustomerID is preferred.
+type Payment struct {
+	PaymentMethod PaymentMethod `json:"payment_method"`
+	Country       Country       `json:"country"`
+	Amount        float64       `json:"amount,omitempty"`
+	Currency      string        `json:"currency,omitempty"`
+	BIN           string        `json:"bin,omitempty"`
+	CardBrand     string        `json:"card_brand,omitempty"`
+	PaymentID     string        `json:"payment_id,omitempty"`
+	Attempted     []string      `json:"attempted,omitempty"`
+	CustomerID    string        `json:"customer_id,omitempty"`
+	Sticky        bool          `json:"sticky,omitempty"`
 }
 
 // ProcessorHealth represents the current health state of a processor
 type ProcessorHealth struct {
 	ProcessorID       string       `json:"processor_id"`
+	TenantID          string       `json:"tenant_id,omitempty"`
 	Status            HealthStatus `json:"status"`
 	AuthorizationRate float64      `json:"authorization_rate"`
 	TotalTransactions int          `json:"total_transactions"`
@@ -70,16 +164,44 @@ type ProcessorHealth struct {
 	FailureCount      int          `json:"failure_count"`
 	ErrorCount        int          `json:"error_count"`
 	LastUpdated       time.Time    `json:"last_updated"`
+	LastTransactionAt *time.Time   `json:"last_transaction_at,omitempty"`
 	StatusChangedAt   *time.Time   `json:"status_changed_at,omitempty"`
 	PreviousStatus    HealthStatus `json:"previous_status,omitempty"`
+	NetworkWeight     float64      `json:"network_weight,omitempty"`
+	Bands             []BandHealth `json:"bands,omitempty"`
+	Cards             []CardHealth `json:"cards,omitempty"`
+}
+
+// BandHealth is a processor's health for payments in one amount band of a
+// currency. MaxAmount is exclusive; zero means no upper bound.
+type BandHealth struct {
+	Currency          string       `json:"currency"`
+	MinAmount         float64      `json:"min_amount"`
+	MaxAmount         float64      `json:"max_amount,omitempty"`
+	Status            HealthStatus `json:"status"`
+	AuthorizationRate float64      `json:"authorization_rate"`
+	ErrorRate         float64      `json:"error_rate"`
+	TotalTransactions int          `json:"total_transactions"`
+	SuccessCount      int          `json:"success_count"`
+	FailureCount      int          `json:"failure_count"`
+	ErrorCount        int          `json:"error_count"`
 }
 
 // RoutingRecommendation represents the routing decision
 type RoutingRecommendation struct {
-	Recommendations []ProcessorRank `json:"recommendations"`
-	PaymentMethod   PaymentMethod   `json:"payment_method"`
-	Country         Country         `json:"country"`
-	Timestamp       time.Time       `json:"timestamp"`
+	DecisionID      string               `json:"decision_id,omitempty"`
+	TenantID        string               `json:"tenant_id,omitempty"`
+	Recommendations []ProcessorRank      `json:"recommendations"`
+	PaymentMethod   PaymentMethod        `json:"payment_method"`
+	Country         Country              `json:"country"`
+	Amount          float64              `json:"amount,omitempty"`
+	Currency        string               `json:"currency,omitempty"`
+	BIN             string               `json:"bin,omitempty"`
+	CardBrand       string               `json:"card_brand,omitempty"`
+	PaymentID       string               `json:"payment_id,omitempty"`
+	Attempt         int                  `json:"attempt,omitempty"` // 1 for the first try of a payment
+	Timestamp       time.Time            `json:"timestamp"`
+	Explanation     *DecisionExplanation `json:"explanation,omitempty"`
 }
 
 // ProcessorRank represents a processor's ranking for routing
@@ -88,15 +210,192 @@ type ProcessorRank struct {
 	Rank              int          `json:"rank"`
 	Status            HealthStatus `json:"status"`
 	AuthorizationRate float64      `json:"authorization_rate"`
+	Score             float64      `json:"score"`
 	Recommended       bool         `json:"recommended"`
+	Saturated         bool         `json:"saturated,omitempty"`
 	Reason            string       `json:"reason"`
 }
 
+// DecisionExplanation records how a routing decision was reached
+type DecisionExplanation struct {
+	Candidates []string            `json:"candidates"`
+	Filtered   []FilteredProcessor `json:"filtered"`
+	Scores     []ScoreBreakdown    `json:"scores"`
+	Sticky     string              `json:"sticky_processor,omitempty"` // promoted for a returning customer
+}
+
+// FilteredProcessor is a registered processor excluded from the candidates
+type FilteredProcessor struct {
+	ProcessorID string `json:"processor_id"`
+	Reason      string `json:"reason"`
+}
+
+// ScoreBreakdown shows each component of a candidate's score and the health
+// snapshot it was computed from. Total is the sum of the components.
+type ScoreBreakdown struct {
+	ProcessorID         string          `json:"processor_id"`
+	AuthRateScore       float64         `json:"auth_rate_score"`   // authorization rate x 100
+	StatusAdjustment    float64         `json:"status_adjustment"` // change due to health status
+	ConfidenceBonus     float64         `json:"confidence_bonus"`
+	VolumeCapAdjustment float64         `json:"volume_cap_adjustment"` // deprioritizes a processor nearing its cap
+	VolumeUtilization   float64         `json:"volume_utilization,omitempty"`
+	AmountBand          *BandHealth     `json:"amount_band,omitempty"`  // band whose rates were used, if any
+	CardSegment         *CardHealth     `json:"card_segment,omitempty"` // brand or BIN range whose rates were used, if any
+	CapacityUtilization float64         `json:"capacity_utilization,omitempty"`
+	Total               float64         `json:"total"`
+	Health              ProcessorHealth `json:"health"`
+}
+
+// CardHealth is a processor's health for CARD payments of one brand, or of
+// one 6-digit BIN range (listed once it has enough transactions)
+type CardHealth struct {
+	Brand             string       `json:"brand,omitempty"`
+	BIN               string       `json:"bin,omitempty"`
+	Status            HealthStatus `json:"status"`
+	AuthorizationRate float64      `json:"authorization_rate"`
+	ErrorRate         float64      `json:"error_rate"`
+	TotalTransactions int          `json:"total_transactions"`
+	SuccessCount      int          `json:"success_count"`
+	FailureCount      int          `json:"failure_count"`
+	ErrorCount        int          `json:"error_count"`
+}
+
+// HealthBucket is a processor's outcomes in one interval [Start, End) of
+// its history. A bucket without transactions is UNKNOWN with zero rates.
+type HealthBucket struct {
+	Start             time.Time    `json:"start"`
+	End               time.Time    `json:"end"`
+	Status            HealthStatus `json:"status"`
+	AuthorizationRate float64      `json:"authorization_rate"`
+	ErrorRate         float64      `json:"error_rate"`
+	TotalTransactions int          `json:"total_transactions"`
+	SuccessCount      int          `json:"success_count"`
+	FailureCount      int          `json:"failure_count"`
+	ErrorCount        int          `json:"error_count"`
+}
+
+// CorridorHealth is a processor's outcomes for one payment method and
+// country over a period
+type CorridorHealth struct {
+	PaymentMethod     PaymentMethod `json:"payment_method"`
+	Country           Country       `json:"country"`
+	Status            HealthStatus  `json:"status"`
+	AuthorizationRate float64       `json:"authorization_rate"`
+	ErrorRate         float64       `json:"error_rate"`
+	TotalTransactions int           `json:"total_transactions"`
+	SuccessCount      int           `json:"success_count"`
+	FailureCount      int           `json:"failure_count"`
+	ErrorCount        int           `json:"error_count"`
+}
+
+// HealthHistory is a processor's health over time in fixed steps
+type HealthHistory struct {
+	ProcessorID string         `json:"processor_id"`
+	TenantID    string         `json:"tenant_id,omitempty"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Step        string         `json:"step"`
+	Buckets     []HealthBucket `json:"buckets"`
+}
+
+// Attempt is one processor tried for a payment
+type Attempt struct {
+	TransactionID string            `json:"transaction_id,omitempty"`
+	ProcessorID   string            `json:"processor_id"`
+	DecisionID    string            `json:"decision_id,omitempty"`
+	Result        TransactionResult `json:"result"`
+	Timestamp     time.Time         `json:"timestamp"`
+}
+
+// PaymentAttempts is the sequence of attempts for one payment, in order
+type PaymentAttempts struct {
+	PaymentID      string    `json:"payment_id"`
+	Attempts       []Attempt `json:"attempts"`
+	Approved       bool      `json:"approved"`
+	FirstAttemptAt time.Time `json:"first_attempt_at"`
+	LastAttemptAt  time.Time `json:"last_attempt_at"`
+}
+
+// AttemptSummary aggregates retries across payments. Recovered counts
+// payments approved after a failed first attempt.
+type AttemptSummary struct {
+	Payments           int            `json:"payments"`
+	Attempts           int            `json:"attempts"`
+	AttemptsPerPayment float64        `json:"attempts_per_payment"`
+	Approved           int            `json:"approved"`
+	Recovered          int            `json:"recovered"`
+	ByAttempt          []AttemptStats `json:"by_attempt"`
+}
+
+// AttemptStats tallies the nth attempt of every payment
+type AttemptStats struct {
+	Attempt      int     `json:"attempt"`
+	Transactions int     `json:"transactions"`
+	Approved     int     `json:"approved"`
+	ApprovalRate float64 `json:"approval_rate"`
+}
+
 // HealthTransition records when a processor changes health status
 type HealthTransition struct {
 	ProcessorID string       `json:"processor_id"`
+	TenantID    string       `json:"tenant_id,omitempty"`
 	FromStatus  HealthStatus `json:"from_status"`
 	ToStatus    HealthStatus `json:"to_status"`
 	Timestamp   time.Time    `json:"timestamp"`
 	Reason      string       `json:"reason"`
+	RequestID   string       `json:"request_id,omitempty"` // of the transaction that caused it, if any
+}
+
+// Alert types besides status transitions
+const AlertSLOBurnRate = "slo_burn_rate"
+
+// SLO alert states
+const (
+	SLOFiring   = "firing"
+	SLOResolved = "resolved"
+)
+
+// SLOAlert is raised when a processor burns an SLO's error budget too fast
+// over both the long and short window of a burn-rate pair, and again when
+// it no longer does
+type SLOAlert struct {
+	Type          string        `json:"type"`
+	SLOID         string        `json:"slo_id"`
+	TenantID      string        `json:"tenant_id,omitempty"`
+	ProcessorID   string        `json:"processor_id"`
+	PaymentMethod PaymentMethod `json:"payment_method,omitempty"`
+	Country       Country       `json:"country,omitempty"`
+	Window        string        `json:"window"`
+	State         string        `json:"state"`
+	BurnRate      float64       `json:"burn_rate"`
+	ShortBurnRate float64       `json:"short_burn_rate"`
+	Threshold     float64       `json:"threshold"`
+	Objective     float64       `json:"objective"`
+	Timestamp     time.Time     `json:"timestamp"`
+	Reason        string        `json:"reason"`
+}
+
+// BurnRate is an SLO's burn rate over the windows of one alert pair
+type BurnRate struct {
+	Window    string  `json:"window"`
+	Long      float64 `json:"long"`
+	Short     float64 `json:"short"`
+	Threshold float64 `json:"threshold"`
+	Firing    bool    `json:"firing"`
+}
+
+// SLOStatus is how a processor is doing against an SLO over its window.
+// Compliance is the share of good events; BudgetRemaining the share of the
+// error budget left (negative once exhausted).
+type SLOStatus struct {
+	SLOID           string        `json:"slo_id"`
+	ProcessorID     string        `json:"processor_id"`
+	PaymentMethod   PaymentMethod `json:"payment_method,omitempty"`
+	Country         Country       `json:"country,omitempty"`
+	Objective       float64       `json:"objective"`
+	Events          int           `json:"events"`
+	BadEvents       int           `json:"bad_events"`
+	Compliance      float64       `json:"compliance"`
+	BudgetRemaining float64       `json:"budget_remaining"`
+	BurnRates       []BurnRate    `json:"burn_rates"`
 }
@@ -0,0 +1,242 @@
+// Package feedback links reported transactions back to the routing
+// decision that preceded them, measuring whether merchants followed the
+// recommendation and how the outcomes compare.
+package feedback
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// DefaultCapacity bounds how many linked transactions are remembered so a
+// re-reported outcome (same transaction ID) replaces the earlier one
+const DefaultCapacity = 10000
+
+// How a transaction relates to its decision
+type choice int
+
+const (
+	followed         choice = iota // Sent to the recommended processor
+	overrode                       // Sent elsewhere
+	noRecommendation               // Decision recommended nothing (all DOWN)
+	unmatched                      // Decision unknown or no longer retained
+)
+
+// Outcomes tallies results for one group of transactions
+type Outcomes struct {
+	Transactions      int     `json:"transactions"`
+	Approved          int     `json:"approved"`
+	Declined          int     `json:"declined"`
+	Errors            int     `json:"errors"`
+	AuthorizationRate float64 `json:"authorization_rate"` // approved / (approved + declined)
+}
+
+func (o *Outcomes) add(result domain.TransactionResult, delta int) {
+	o.Transactions += delta
+	switch result {
+	case domain.ResultApproved:
+		o.Approved += delta
+	case domain.ResultDeclined:
+		o.Declined += delta
+	case domain.ResultError, domain.ResultTimeout:
+		o.Errors += delta
+	}
+}
+
+func (o *Outcomes) finish() {
+	o.AuthorizationRate = 0
+	if valid := o.Approved + o.Declined; valid > 0 {
+		o.AuthorizationRate = float64(o.Approved) / float64(valid)
+	}
+}
+
+// CorridorReport compares followed and overridden recommendations for a
+// payment method and country. AuthRateLift is the followed auth rate minus
+// the overridden one, present when both have approved or declined attempts.
+type CorridorReport struct {
+	PaymentMethod    domain.PaymentMethod `json:"payment_method,omitempty"`
+	Country          domain.Country       `json:"country,omitempty"`
+	Followed         Outcomes             `json:"followed"`
+	Overrode         Outcomes             `json:"overrode"`
+	NoRecommendation Outcomes             `json:"no_recommendation"`
+	AdherenceRate    float64              `json:"adherence_rate"` // followed / (followed + overrode)
+	AuthRateLift     *float64             `json:"auth_rate_lift,omitempty"`
+}
+
+func (c *CorridorReport) outcomes(ch choice) *Outcomes {
+	switch ch {
+	case followed:
+		return &c.Followed
+	case overrode:
+		return &c.Overrode
+	default:
+		return &c.NoRecommendation
+	}
+}
+
+func (c *CorridorReport) merge(other *CorridorReport) {
+	for _, ch := range []choice{followed, overrode, noRecommendation} {
+		dst, src := c.outcomes(ch), other.outcomes(ch)
+		dst.Transactions += src.Transactions
+		dst.Approved += src.Approved
+		dst.Declined += src.Declined
+		dst.Errors += src.Errors
+	}
+}
+
+func (c *CorridorReport) finish() {
+	c.Followed.finish()
+	c.Overrode.finish()
+	c.NoRecommendation.finish()
+
+	c.AdherenceRate = 0
+	if decided := c.Followed.Transactions + c.Overrode.Transactions; decided > 0 {
+		c.AdherenceRate = float64(c.Followed.Transactions) / float64(decided)
+	}
+
+	c.AuthRateLift = nil
+	if c.Followed.Approved+c.Followed.Declined > 0 && c.Overrode.Approved+c.Overrode.Declined > 0 {
+		lift := c.Followed.AuthorizationRate - c.Overrode.AuthorizationRate
+		c.AuthRateLift = &lift
+	}
+}
+
+// Report is the adherence report for a tenant
+type Report struct {
+	TenantID  string           `json:"tenant_id"`
+	Since     time.Time        `json:"since"`
+	Total     CorridorReport   `json:"total"`
+	Corridors []CorridorReport `json:"corridors"`
+	Unmatched int              `json:"unmatched"` // Decision ID unknown or expired
+}
+
+type corridorKey struct {
+	method  domain.PaymentMethod
+	country domain.Country
+}
+
+// linked is what was counted for a transaction ID
+type linked struct {
+	key    corridorKey
+	choice choice
+	result domain.TransactionResult
+}
+
+// Tracker accumulates decision outcomes for one tenant
+type Tracker struct {
+	mu        sync.Mutex
+	since     time.Time
+	capacity  int
+	corridors map[corridorKey]*CorridorReport
+	seen      map[string]linked
+	order     []string
+	unmatched int
+}
+
+// NewTracker creates a tracker counting from since
+func NewTracker(since time.Time) *Tracker {
+	return &Tracker{
+		since:     since,
+		capacity:  DefaultCapacity,
+		corridors: make(map[corridorKey]*CorridorReport),
+		seen:      make(map[string]linked),
+	}
+}
+
+// Record links tx to decision (nil when the decision ID was not found).
+// Reporting the same transaction ID again replaces its earlier outcome.
+func (t *Tracker) Record(tx domain.Transaction, decision *domain.RoutingRecommendation) {
+	l := linked{
+		key:    corridorKey{method: tx.PaymentMethod, country: tx.Country},
+		choice: classify(tx, decision),
+		result: tx.Result,
+	}
+	if decision != nil {
+		l.key = corridorKey{method: decision.PaymentMethod, country: decision.Country}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tx.ID != "" {
+		if prev, exists := t.seen[tx.ID]; exists {
+			t.apply(prev, -1)
+		} else {
+			t.order = append(t.order, tx.ID)
+			t.evict()
+		}
+		t.seen[tx.ID] = l
+	}
+	t.apply(l, 1)
+}
+
+func classify(tx domain.Transaction, decision *domain.RoutingRecommendation) choice {
+	if decision == nil {
+		return unmatched
+	}
+	if len(decision.Recommendations) == 0 || !decision.Recommendations[0].Recommended {
+		return noRecommendation
+	}
+	if decision.Recommendations[0].ProcessorID == tx.ProcessorID {
+		return followed
+	}
+	return overrode
+}
+
+func (t *Tracker) apply(l linked, delta int) {
+	if l.choice == unmatched {
+		t.unmatched += delta
+		return
+	}
+	c, exists := t.corridors[l.key]
+	if !exists {
+		c = &CorridorReport{PaymentMethod: l.key.method, Country: l.key.country}
+		t.corridors[l.key] = c
+	}
+	c.outcomes(l.choice).add(l.result, delta)
+}
+
+// evict forgets the oldest transaction IDs beyond capacity; their outcomes
+// stay counted but can no longer be replaced
+func (t *Tracker) evict() {
+	n := len(t.order) - t.capacity
+	if n <= 0 {
+		return
+	}
+	for _, id := range t.order[:n] {
+		delete(t.seen, id)
+	}
+	t.order = t.order[n:]
+}
+
+// Report returns adherence and lift per corridor, sorted by corridor
+func (t *Tracker) Report(tenantID string) Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := Report{
+		TenantID:  tenantID,
+		Since:     t.since,
+		Corridors: make([]CorridorReport, 0, len(t.corridors)),
+		Unmatched: t.unmatched,
+	}
+	for _, c := range t.corridors {
+		cp := *c
+		cp.finish()
+		report.Corridors = append(report.Corridors, cp)
+		report.Total.merge(c)
+	}
+	report.Total.finish()
+
+	sort.Slice(report.Corridors, func(i, j int) bool {
+		a, b := report.Corridors[i], report.Corridors[j]
+		if a.PaymentMethod != b.PaymentMethod {
+			return a.PaymentMethod < b.PaymentMethod
+		}
+		return a.Country < b.Country
+	})
+	return report
+}
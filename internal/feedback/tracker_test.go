@@ -0,0 +1,103 @@
+package feedback
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestTracker_AdherenceAndLift(t *testing.T) {
+	tracker := NewTracker(time.Now())
+	dec := decision("processor_c", true)
+
+	// Followed: 8 approved, 2 declined (80%)
+	for i := 0; i < 10; i++ {
+		result := domain.ResultApproved
+		if i < 2 {
+			result = domain.ResultDeclined
+		}
+		tracker.Record(tx("", "processor_c", result), dec)
+	}
+	// Overrode: 5 approved, 5 declined (50%)
+	for i := 0; i < 10; i++ {
+		result := domain.ResultApproved
+		if i < 5 {
+			result = domain.ResultDeclined
+		}
+		tracker.Record(tx("", "processor_a", result), dec)
+	}
+
+	report := tracker.Report("techcart")
+	if len(report.Corridors) != 1 {
+		t.Fatalf("expected 1 corridor, got %d", len(report.Corridors))
+	}
+	c := report.Corridors[0]
+	if c.PaymentMethod != domain.MethodPIX || c.Country != domain.CountryBR {
+		t.Errorf("unexpected corridor %s/%s", c.PaymentMethod, c.Country)
+	}
+	if c.AdherenceRate != 0.5 {
+		t.Errorf("expected 50%% adherence, got %.2f", c.AdherenceRate)
+	}
+	if c.AuthRateLift == nil || *c.AuthRateLift < 0.299 || *c.AuthRateLift > 0.301 {
+		t.Errorf("expected +30pp lift, got %v", c.AuthRateLift)
+	}
+	if report.Total.Followed.Transactions != 10 || report.Total.Overrode.Transactions != 10 {
+		t.Errorf("unexpected totals: %+v", report.Total)
+	}
+}
+
+func TestTracker_NoRecommendationAndUnmatched(t *testing.T) {
+	tracker := NewTracker(time.Now())
+
+	tracker.Record(tx("", "processor_a", domain.ResultError), decision("processor_a", false))
+	tracker.Record(tx("", "processor_a", domain.ResultApproved), nil)
+
+	report := tracker.Report("techcart")
+	if report.Total.NoRecommendation.Transactions != 1 {
+		t.Errorf("expected 1 transaction without a recommendation, got %d", report.Total.NoRecommendation.Transactions)
+	}
+	if report.Unmatched != 1 {
+		t.Errorf("expected 1 unmatched transaction, got %d", report.Unmatched)
+	}
+	if report.Total.AuthRateLift != nil {
+		t.Error("expected no lift without followed and overridden outcomes")
+	}
+}
+
+func TestTracker_SameTransactionIDReplaces(t *testing.T) {
+	tracker := NewTracker(time.Now())
+	dec := decision("processor_c", true)
+
+	tracker.Record(tx("tx-1", "processor_c", domain.ResultDeclined), dec)
+	tracker.Record(tx("tx-1", "processor_c", domain.ResultApproved), dec)
+
+	followed := tracker.Report("techcart").Total.Followed
+	if followed.Transactions != 1 || followed.Approved != 1 || followed.Declined != 0 {
+		t.Errorf("expected the update to replace the first outcome, got %+v", followed)
+	}
+}
+
+// Helper functions
+
+func decision(top string, recommended bool) *domain.RoutingRecommendation {
+	return &domain.RoutingRecommendation{
+		DecisionID:    "dec-1",
+		PaymentMethod: domain.MethodPIX,
+		Country:       domain.CountryBR,
+		Recommendations: []domain.ProcessorRank{
+			{ProcessorID: top, Rank: 1, Recommended: recommended},
+		},
+	}
+}
+
+func tx(id, processorID string, result domain.TransactionResult) domain.Transaction {
+	return domain.Transaction{
+		ID:            id,
+		ProcessorID:   processorID,
+		Result:        result,
+		PaymentMethod: domain.MethodPIX,
+		Country:       domain.CountryBR,
+		DecisionID:    "dec-1",
+	}
+}
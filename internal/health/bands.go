@@ -0,0 +1,139 @@
+package health
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// AmountBands maps a currency to ascending band boundaries. Boundaries
+// [250, 1000] give the bands [0, 250), [250, 1000) and [1000, ∞).
+type AmountBands map[string][]float64
+
+// DefaultAmountBands returns the bands used when none are configured
+func DefaultAmountBands() AmountBands {
+	return AmountBands{
+		"BRL": {250, 1000},
+		"MXN": {1000, 5000},
+		"COP": {250000, 1000000},
+	}
+}
+
+// Validate reports the first currency with invalid boundaries
+func (b AmountBands) Validate() error {
+	for currency, bounds := range b {
+		for i, bound := range bounds {
+			if bound <= 0 || (i > 0 && bound <= bounds[i-1]) {
+				return fmt.Errorf("amount_bands %s must be positive and ascending, got %v", currency, bounds)
+			}
+		}
+	}
+	return nil
+}
+
+// bandKey identifies one band of one currency
+type bandKey struct {
+	currency string
+	index    int
+}
+
+// bandOf returns the band a transaction falls in. Transactions without an
+// amount or in a currency without bands are not banded.
+func (b AmountBands) bandOf(tx domain.Transaction) (bandKey, bool) {
+	if tx.Amount <= 0 {
+		return bandKey{}, false
+	}
+	currency := tx.Currency
+	if currency == "" {
+		currency = domain.DefaultCurrency(tx.Country)
+	}
+	bounds, exists := b[currency]
+	if !exists {
+		return bandKey{}, false
+	}
+	index := sort.Search(len(bounds), func(i int) bool { return bounds[i] > tx.Amount })
+	return bandKey{currency: currency, index: index}, true
+}
+
+// limits returns the amount range covered by a band (max 0 = unbounded)
+func (b AmountBands) limits(k bandKey) (minAmount, maxAmount float64) {
+	bounds := b[k.currency]
+	if k.index > 0 {
+		minAmount = bounds[k.index-1]
+	}
+	if k.index < len(bounds) {
+		maxAmount = bounds[k.index]
+	}
+	return minAmount, maxAmount
+}
+
+// bandHealth computes rates and status for every band with transactions in
+// the window, sorted by currency and amount. Caller must hold the shard lock.
+func (c *Calculator) bandHealth(w *window) []domain.BandHealth {
+	if len(w.bands) == 0 {
+		return nil
+	}
+
+	keys := make([]bandKey, 0, len(w.bands))
+	for k, n := range w.bands {
+		if n.total() > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].currency != keys[j].currency {
+			return keys[i].currency < keys[j].currency
+		}
+		return keys[i].index < keys[j].index
+	})
+
+	result := make([]domain.BandHealth, 0, len(keys))
+	for _, k := range keys {
+		n := w.bands[k]
+		authRate, errorRate := n.rates()
+		minAmount, maxAmount := c.policy.AmountBands.limits(k)
+		result = append(result, domain.BandHealth{
+			Currency:          k.currency,
+			MinAmount:         minAmount,
+			MaxAmount:         maxAmount,
+			Status:            c.determineStatus(authRate, errorRate, n.total()),
+			AuthorizationRate: authRate,
+			ErrorRate:         errorRate,
+			TotalTransactions: n.total(),
+			SuccessCount:      n.approved,
+			FailureCount:      n.declined,
+			ErrorCount:        n.errors,
+		})
+	}
+	return result
+}
+
+// ForAmount returns the health to score a payment with: the matching band
+// once it has at least minTransactions, otherwise h itself. A STALE or
+// UNKNOWN processor keeps its aggregate status regardless of bands.
+func ForAmount(h *domain.ProcessorHealth, currency string, amount float64, minTransactions int) (*domain.ProcessorHealth, *domain.BandHealth) {
+	if amount <= 0 || h.Status == domain.StatusStale || h.Status == domain.StatusUnknown {
+		return h, nil
+	}
+
+	for i := range h.Bands {
+		b := &h.Bands[i]
+		if b.Currency != currency || amount < b.MinAmount || (b.MaxAmount > 0 && amount >= b.MaxAmount) {
+			continue
+		}
+		if b.TotalTransactions < minTransactions {
+			return h, nil
+		}
+
+		view := *h
+		view.Status = b.Status
+		view.AuthorizationRate = b.AuthorizationRate
+		view.TotalTransactions = b.TotalTransactions
+		view.SuccessCount = b.SuccessCount
+		view.FailureCount = b.FailureCount
+		view.ErrorCount = b.ErrorCount
+		return &view, b
+	}
+	return h, nil
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestCalculator_TracksAmountBands(t *testing.T) {
+	calc := NewCalculator()
+
+	// Small BRL tickets approve, large ones (>= 1000) mostly decline
+	for i := 0; i < 30; i++ {
+		calc.RecordTransaction(bandTx(fmt.Sprintf("small-%d", i), 50, domain.ResultApproved))
+	}
+	for i := 0; i < 10; i++ {
+		result := domain.ResultDeclined
+		if i < 2 {
+			result = domain.ResultApproved
+		}
+		calc.RecordTransaction(bandTx(fmt.Sprintf("large-%d", i), 2500, result))
+	}
+
+	health := calc.GetHealth("processor_a")
+	if health.Status != domain.StatusHealthy {
+		t.Fatalf("expected aggregate HEALTHY, got %s", health.Status)
+	}
+	if len(health.Bands) != 2 {
+		t.Fatalf("expected 2 bands with data, got %+v", health.Bands)
+	}
+
+	small, large := health.Bands[0], health.Bands[1]
+	if small.MinAmount != 0 || small.MaxAmount != 250 || small.TotalTransactions != 30 || small.AuthorizationRate != 1 {
+		t.Errorf("unexpected small band: %+v", small)
+	}
+	if large.MinAmount != 1000 || large.MaxAmount != 0 || large.Status != domain.StatusDown {
+		t.Errorf("unexpected large band: %+v", large)
+	}
+}
+
+func TestForAmount_FallsBackWithoutEnoughData(t *testing.T) {
+	h := &domain.ProcessorHealth{
+		Status:            domain.StatusHealthy,
+		AuthorizationRate: 0.9,
+		TotalTransactions: 50,
+		Bands: []domain.BandHealth{
+			{Currency: "BRL", MinAmount: 0, MaxAmount: 250, Status: domain.StatusHealthy, AuthorizationRate: 0.95, TotalTransactions: 45},
+			{Currency: "BRL", MinAmount: 1000, Status: domain.StatusDown, AuthorizationRate: 0.2, TotalTransactions: 5},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		currency string
+		amount   float64
+		wantRate float64
+		banded   bool
+	}{
+		{"matching band", "BRL", 100, 0.95, true},
+		{"band below minimum data", "BRL", 5000, 0.9, false},
+		{"band without data", "BRL", 500, 0.9, false},
+		{"other currency", "MXN", 100, 0.9, false},
+		{"no amount", "BRL", 0, 0.9, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, band := ForAmount(h, tt.currency, tt.amount, MinTransactions)
+			if got.AuthorizationRate != tt.wantRate || (band != nil) != tt.banded {
+				t.Errorf("expected rate %.2f (banded %v), got %.2f (band %+v)", tt.wantRate, tt.banded, got.AuthorizationRate, band)
+			}
+		})
+	}
+}
+
+func TestPolicy_AmountBandsOverlayDefaults(t *testing.T) {
+	p := DefaultPolicy()
+	if err := json.Unmarshal([]byte(`{"amount_bands": {"BRL": [100, 500, 2000]}}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.AmountBands["BRL"]; len(got) != 3 || got[0] != 100 {
+		t.Errorf("expected BRL bands replaced, got %v", got)
+	}
+	if got := p.AmountBands["MXN"]; len(got) != 2 {
+		t.Errorf("expected MXN default bands kept, got %v", got)
+	}
+	if DefaultPolicy().AmountBands["BRL"][0] != 250 {
+		t.Error("expected defaults to be unchanged")
+	}
+
+	p.AmountBands["COP"] = []float64{500, 100}
+	if err := p.Validate(); err == nil {
+		t.Error("expected descending bands to be rejected")
+	}
+}
+
+// Helper functions
+
+func bandTx(id string, amount float64, result domain.TransactionResult) domain.Transaction {
+	return domain.Transaction{
+		ID:            id,
+		ProcessorID:   "processor_a",
+		Result:        result,
+		PaymentMethod: domain.MethodCard,
+		Country:       domain.CountryBR,
+		Amount:        amount,
+		Timestamp:     time.Now(),
+	}
+}
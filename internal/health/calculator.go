@@ -1,10 +1,14 @@
 package health
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/yuno/techcart-failover/internal/clock"
 	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/tracing"
 )
 
 // Configuration constants
@@ -16,169 +20,277 @@ const (
 	ErrorRateDown     = 0.50             // > 50% error rate = DOWN
 	ErrorRateDegraded = 0.30             // > 30% error rate = DEGRADED
 	MinTransactions   = 10               // Min transactions before changing status
+	BlendSamples      = WindowSize       // Local samples needed to ignore the network view
+	StaleAfter        = 5 * time.Minute  // No transactions for this long = STALE
 )
 
-// Calculator tracks processor health based on transaction results
+// Calculator tracks processor health based on transaction results.
+// State is sharded per processor so ingest for one processor never waits
+// on another; the registry lock is only taken to add a new processor.
 type Calculator struct {
 	mu           sync.RWMutex
-	transactions map[string][]domain.Transaction
-	processors   map[string]*domain.ProcessorHealth
+	tenantID     string
+	clock        clock.Clock
+	policy       Policy
+	resolutions  []Resolution
+	shards       map[string]*shard
+	alertsMu     sync.RWMutex
 	transitions  []domain.HealthTransition
+	onTransition func(domain.HealthTransition)
+}
+
+// shard holds one processor's window, history and current health
+type shard struct {
+	mu      sync.RWMutex
+	since   time.Time // when the processor was first seen
+	window  *window
+	history *history
+	health  *domain.ProcessorHealth
 }
 
 // NewCalculator creates a new health calculator
 func NewCalculator() *Calculator {
+	return NewTenantCalculator("", clock.Real(), DefaultPolicy())
+}
+
+// NewCalculatorWithClock creates a health calculator driven by clk,
+// e.g. a fake clock for simulations and replays
+func NewCalculatorWithClock(clk clock.Clock) *Calculator {
+	return NewTenantCalculator("", clk, DefaultPolicy())
+}
+
+// NewCalculatorWithPolicy creates a health calculator with custom thresholds
+func NewCalculatorWithPolicy(clk clock.Clock, policy Policy) *Calculator {
+	return NewTenantCalculator("", clk, policy)
+}
+
+// NewTenantCalculator creates a health calculator scoped to a single tenant
+func NewTenantCalculator(tenantID string, clk clock.Clock, policy Policy) *Calculator {
 	return &Calculator{
-		transactions: make(map[string][]domain.Transaction),
-		processors:   make(map[string]*domain.ProcessorHealth),
-		transitions:  make([]domain.HealthTransition, 0),
+		tenantID:    tenantID,
+		clock:       clk,
+		policy:      policy,
+		resolutions: DefaultResolutions(),
+		shards:      make(map[string]*shard),
+		transitions: make([]domain.HealthTransition, 0),
 	}
 }
 
-// RecordTransaction records a transaction and updates processor health
-func (c *Calculator) RecordTransaction(tx domain.Transaction) *domain.ProcessorHealth {
+// shard returns the processor's shard, creating it when create is set
+func (c *Calculator) shard(processorID string, create bool) *shard {
+	c.mu.RLock()
+	s, exists := c.shards[processorID]
+	c.mu.RUnlock()
+	if exists || !create {
+		return s
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if s, exists := c.shards[processorID]; exists {
+		return s
+	}
+	s = &shard{since: c.clock.Now(), window: newWindow(c.policy), history: newHistory(c.resolutions)}
+	c.shards[processorID] = s
+	return s
+}
+
+// RecordTransaction records a transaction and updates processor health.
+// A transaction whose ID is already in the window replaces the earlier
+// report (e.g. a timeout later resolved as approved) instead of adding to it.
+func (c *Calculator) RecordTransaction(tx domain.Transaction) *domain.ProcessorHealth {
+	return c.RecordTransactionContext(context.Background(), tx)
+}
 
-	// Add transaction
-	c.transactions[tx.ProcessorID] = append(c.transactions[tx.ProcessorID], tx)
+// RecordTransactionContext is RecordTransaction traced as a child of the
+// span in ctx, with the wait for the processor's lock as its own span
+func (c *Calculator) RecordTransactionContext(ctx context.Context, tx domain.Transaction) *domain.ProcessorHealth {
+	ctx, span := tracing.Start(ctx, "health.RecordTransaction")
+	defer span.End()
+	span.SetAttributes("tenant", c.tenantID, "processor", tx.ProcessorID)
+
+	_, wait := tracing.Start(ctx, "health.lock")
+	s := c.shard(tx.ProcessorID, true)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wait.End()
+
+	// Add or correct transaction, unless it is already outside the time window
+	now := c.clock.Now()
+	cutoff := now.Add(-c.policy.TimeWindow)
+	if tx.Timestamp.After(cutoff) {
+		if old, replaced := s.window.record(tx); replaced && c.retains(old.Timestamp, now) {
+			s.history.record(old, -1)
+		}
+	}
+	if c.retains(tx.Timestamp, now) {
+		s.history.record(tx, 1)
+	}
 
 	// Prune old transactions
-	c.pruneTransactions(tx.ProcessorID)
+	s.window.expire(cutoff)
 
 	// Recalculate health
-	return c.calculateHealth(tx.ProcessorID)
+	health, _ := c.calculateHealth(tx.ProcessorID, s, now, tx.RequestID)
+	return health
 }
 
-// pruneTransactions keeps only relevant transactions
-func (c *Calculator) pruneTransactions(processorID string) {
-	txs := c.transactions[processorID]
-	if len(txs) == 0 {
-		return
+// Reevaluate expires old transactions and recomputes health for every
+// processor as of now, so processors without traffic still change status.
+// It returns the transitions caused by the passage of time.
+func (c *Calculator) Reevaluate(now time.Time) []domain.HealthTransition {
+	c.mu.RLock()
+	ids := make([]string, 0, len(c.shards))
+	for id := range c.shards {
+		ids = append(ids, id)
 	}
+	c.mu.RUnlock()
 
-	cutoff := time.Now().Add(-TimeWindow)
-	var recent []domain.Transaction
-
-	for _, tx := range txs {
-		if tx.Timestamp.After(cutoff) {
-			recent = append(recent, tx)
+	var result []domain.HealthTransition
+	for _, id := range ids {
+		s := c.shard(id, false)
+		s.mu.Lock()
+		if s.health != nil {
+			s.window.expire(now.Add(-c.policy.TimeWindow))
+			if _, t := c.calculateHealth(id, s, now, ""); t != nil {
+				result = append(result, *t)
+			}
 		}
+		s.mu.Unlock()
 	}
-
-	// Keep max 2x window size to have history
-	if len(recent) > WindowSize*2 {
-		recent = recent[len(recent)-WindowSize*2:]
-	}
-
-	c.transactions[processorID] = recent
+	return result
 }
 
-// calculateHealth computes health status for a processor
-func (c *Calculator) calculateHealth(processorID string) *domain.ProcessorHealth {
-	txs := c.transactions[processorID]
+// calculateHealth computes health status for a processor as of now and
+// returns the transition it caused, if any, tagged with the request that
+// caused it ("" when time passing did). Caller must hold s.mu.
+func (c *Calculator) calculateHealth(processorID string, s *shard, now time.Time, requestID string) (*domain.ProcessorHealth, *domain.HealthTransition) {
+	w := s.window
 
 	health := &domain.ProcessorHealth{
 		ProcessorID: processorID,
-		LastUpdated: time.Now(),
+		TenantID:    c.tenantID,
+		LastUpdated: now,
 	}
 
-	if len(txs) == 0 {
+	if w.size() == 0 {
+		// A processor we have seen before has simply run out of data
+		if s.health != nil && s.health.LastTransactionAt != nil {
+			health.Status = domain.StatusUnknown
+			health.LastTransactionAt = s.health.LastTransactionAt
+			return health, c.applyStatus(processorID, s, health, now, "No transactions within time window", requestID)
+		}
 		health.Status = domain.StatusHealthy
 		health.AuthorizationRate = 1.0
-		c.processors[processorID] = health
-		return health
+		s.health = health
+		return health, nil
 	}
 
-	// Use rolling window
-	window := txs
-	if len(window) > WindowSize {
-		window = window[len(window)-WindowSize:]
-	}
+	newest := w.newest().Timestamp
+	health.LastTransactionAt = &newest
 
-	var approved, declined, errors int
-	for _, tx := range window {
-		switch tx.Result {
-		case domain.ResultApproved:
-			approved++
-		case domain.ResultDeclined:
-			declined++
-		case domain.ResultError, domain.ResultTimeout:
-			errors++
-		}
-	}
+	approved, declined, errors := w.counts.approved, w.counts.declined, w.counts.errors
 
-	total := len(window)
+	total := w.size()
 	health.TotalTransactions = total
 	health.SuccessCount = approved
 	health.FailureCount = declined
 	health.ErrorCount = errors
 
-	// Calculate authorization rate: approved / (approved + declined)
-	validAttempts := approved + declined
-	if validAttempts > 0 {
-		health.AuthorizationRate = float64(approved) / float64(validAttempts)
-	} else if errors > 0 {
-		health.AuthorizationRate = 0
-	} else {
-		health.AuthorizationRate = 1.0
+	// Authorization rate: approved / (approved + declined); error rate over all
+	authRate, errorRate := w.counts.rates()
+	health.AuthorizationRate = authRate
+	health.Bands = c.bandHealth(w)
+	health.Cards = c.cardHealth(w)
+
+	// Determine new status; rates go stale when traffic stops
+	health.Status = c.determineStatus(health.AuthorizationRate, errorRate, total)
+	reason := c.transitionReason(health.AuthorizationRate, errorRate)
+	if now.Sub(newest) > c.policy.StaleAfter {
+		health.Status = domain.StatusStale
+		reason = fmt.Sprintf("No transactions for over %.0f minutes", c.policy.StaleAfter.Minutes())
 	}
 
-	// Calculate error rate
-	errorRate := float64(errors) / float64(total)
+	return health, c.applyStatus(processorID, s, health, now, reason, requestID)
+}
 
+// applyStatus stores health and records a transition if the status changed.
+// Caller must hold s.mu.
+func (c *Calculator) applyStatus(processorID string, s *shard, health *domain.ProcessorHealth, now time.Time, reason, requestID string) *domain.HealthTransition {
 	// Get previous status
 	previousStatus := domain.StatusHealthy
-	if prev, exists := c.processors[processorID]; exists {
-		previousStatus = prev.Status
+	if s.health != nil {
+		previousStatus = s.health.Status
 	}
-
-	// Determine new status
-	newStatus := c.determineStatus(health.AuthorizationRate, errorRate, total)
-	health.Status = newStatus
 	health.PreviousStatus = previousStatus
 
 	// Record transition if changed
-	if newStatus != previousStatus && c.processors[processorID] != nil {
-		now := time.Now()
-		health.StatusChangedAt = &now
-		c.transitions = append(c.transitions, domain.HealthTransition{
+	var transition *domain.HealthTransition
+	if health.Status != previousStatus && s.health != nil {
+		changedAt := now
+		health.StatusChangedAt = &changedAt
+		transition = &domain.HealthTransition{
 			ProcessorID: processorID,
+			TenantID:    c.tenantID,
 			FromStatus:  previousStatus,
-			ToStatus:    newStatus,
+			ToStatus:    health.Status,
 			Timestamp:   now,
-			Reason:      c.transitionReason(health.AuthorizationRate, errorRate),
-		})
+			Reason:      reason,
+			RequestID:   requestID,
+		}
+		c.recordTransition(*transition)
 	}
 
-	c.processors[processorID] = health
-	return health
+	s.health = health
+	return transition
+}
+
+func (c *Calculator) recordTransition(t domain.HealthTransition) {
+	c.alertsMu.Lock()
+	c.transitions = append(c.transitions, t)
+	onTransition := c.onTransition
+	c.alertsMu.Unlock()
+
+	if onTransition != nil {
+		onTransition(t)
+	}
+}
+
+// SetOnTransition sets a callback for every status transition, whether
+// caused by a transaction or by time passing. It runs with the processor
+// locked, so it must be quick and must not call back into the calculator.
+func (c *Calculator) SetOnTransition(fn func(domain.HealthTransition)) {
+	c.alertsMu.Lock()
+	defer c.alertsMu.Unlock()
+	c.onTransition = fn
 }
 
 // determineStatus calculates health status based on rates
 func (c *Calculator) determineStatus(authRate, errorRate float64, total int) domain.HealthStatus {
+	p := c.policy
+
 	// Need minimum transactions to change from default
-	if total < MinTransactions {
+	if total < p.MinTransactions {
 		return domain.StatusHealthy
 	}
 
 	// High error rate = DOWN
-	if errorRate > ErrorRateDown {
+	if errorRate > p.ErrorRateDown {
 		return domain.StatusDown
 	}
 
 	// Elevated error rate = DEGRADED
-	if errorRate > ErrorRateDegraded {
+	if errorRate > p.ErrorRateDegraded {
 		return domain.StatusDegraded
 	}
 
 	// Low auth rate = DOWN
-	if authRate < DegradedThreshold {
+	if authRate < p.DegradedThreshold {
 		return domain.StatusDown
 	}
 
 	// Medium auth rate = DEGRADED
-	if authRate < HealthyThreshold {
+	if authRate < p.HealthyThreshold {
 		return domain.StatusDegraded
 	}
 
@@ -187,54 +299,93 @@ func (c *Calculator) determineStatus(authRate, errorRate float64, total int) dom
 
 // transitionReason generates human-readable reason
 func (c *Calculator) transitionReason(authRate, errorRate float64) string {
-	if errorRate > ErrorRateDown {
-		return "High error/timeout rate (>50%)"
+	p := c.policy
+	if errorRate > p.ErrorRateDown {
+		return fmt.Sprintf("High error/timeout rate (>%s)", percent(p.ErrorRateDown))
 	}
-	if errorRate > ErrorRateDegraded {
-		return "Elevated error/timeout rate (>30%)"
+	if errorRate > p.ErrorRateDegraded {
+		return fmt.Sprintf("Elevated error/timeout rate (>%s)", percent(p.ErrorRateDegraded))
 	}
-	if authRate < DegradedThreshold {
-		return "Very low authorization rate (<30%)"
+	if authRate < p.DegradedThreshold {
+		return fmt.Sprintf("Very low authorization rate (<%s)", percent(p.DegradedThreshold))
 	}
-	if authRate < HealthyThreshold {
-		return "Low authorization rate (<65%)"
+	if authRate < p.HealthyThreshold {
+		return fmt.Sprintf("Low authorization rate (<%s)", percent(p.HealthyThreshold))
 	}
 	return "Performance recovered"
 }
 
+func percent(rate float64) string {
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+// Policy returns the thresholds this calculator applies
+func (c *Calculator) Policy() Policy {
+	return c.policy
+}
+
+// Clock returns the clock driving this calculator
+func (c *Calculator) Clock() clock.Clock {
+	return c.clock
+}
+
+// TenantID returns the tenant this calculator belongs to ("" for the network view)
+func (c *Calculator) TenantID() string {
+	return c.tenantID
+}
+
 // GetHealth returns current health for a processor
 func (c *Calculator) GetHealth(processorID string) *domain.ProcessorHealth {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	if health, exists := c.processors[processorID]; exists {
-		return health
+	if s := c.shard(processorID, false); s != nil {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if s.health != nil {
+			return s.health
+		}
 	}
 
 	return &domain.ProcessorHealth{
 		ProcessorID:       processorID,
+		TenantID:          c.tenantID,
 		Status:            domain.StatusHealthy,
 		AuthorizationRate: 1.0,
-		LastUpdated:       time.Now(),
+		LastUpdated:       c.clock.Now(),
 	}
 }
 
+// TrackedSince returns when the calculator first saw a processor
+func (c *Calculator) TrackedSince(processorID string) (time.Time, bool) {
+	s := c.shard(processorID, false)
+	if s == nil {
+		return time.Time{}, false
+	}
+	return s.since, true
+}
+
 // GetAllHealth returns health for all tracked processors
 func (c *Calculator) GetAllHealth() []*domain.ProcessorHealth {
 	c.mu.RLock()
-	defer c.mu.RUnlock()
+	shards := make([]*shard, 0, len(c.shards))
+	for _, s := range c.shards {
+		shards = append(shards, s)
+	}
+	c.mu.RUnlock()
 
-	result := make([]*domain.ProcessorHealth, 0, len(c.processors))
-	for _, h := range c.processors {
-		result = append(result, h)
+	result := make([]*domain.ProcessorHealth, 0, len(shards))
+	for _, s := range shards {
+		s.mu.RLock()
+		if s.health != nil {
+			result = append(result, s.health)
+		}
+		s.mu.RUnlock()
 	}
 	return result
 }
 
 // GetTransitions returns health transitions (alerts) since given time
 func (c *Calculator) GetTransitions(since time.Time) []domain.HealthTransition {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.alertsMu.RLock()
+	defer c.alertsMu.RUnlock()
 
 	var result []domain.HealthTransition
 	for _, t := range c.transitions {
@@ -247,16 +398,32 @@ func (c *Calculator) GetTransitions(since time.Time) []domain.HealthTransition {
 
 // GetRecentTransactions returns recent transactions for a processor
 func (c *Calculator) GetRecentTransactions(processorID string, limit int) []domain.Transaction {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	txs := c.transactions[processorID]
-	if len(txs) == 0 {
+	s := c.shard(processorID, false)
+	if s == nil {
 		return nil
 	}
 
-	if limit > 0 && len(txs) > limit {
-		return txs[len(txs)-limit:]
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.window.recent(limit)
+}
+
+// Blend mixes a tenant's local health with the shared network view.
+// The local view gets full weight once it has BlendSamples transactions;
+// below MinTransactions the network status is used as well.
+func Blend(local, network *domain.ProcessorHealth) *domain.ProcessorHealth {
+	if network == nil || network.TotalTransactions == 0 || local.TotalTransactions >= BlendSamples {
+		return local
+	}
+
+	localWeight := float64(local.TotalTransactions) / float64(BlendSamples)
+	networkWeight := 1 - localWeight
+
+	blended := *local
+	blended.AuthorizationRate = localWeight*local.AuthorizationRate + networkWeight*network.AuthorizationRate
+	blended.NetworkWeight = networkWeight
+	if local.TotalTransactions < MinTransactions {
+		blended.Status = network.Status
 	}
-	return txs
+	return &blended
 }
@@ -0,0 +1,66 @@
+package health
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+var benchProcessors = []string{"processor_a", "processor_b", "processor_c", "processor_d", "processor_e"}
+
+func benchTx(i int64) domain.Transaction {
+	result := domain.ResultApproved
+	if i%5 == 0 {
+		result = domain.ResultDeclined
+	}
+	return domain.Transaction{
+		ID:          fmt.Sprintf("bench-%d", i),
+		ProcessorID: benchProcessors[i%int64(len(benchProcessors))],
+		Timestamp:   time.Now(),
+		Result:      result,
+	}
+}
+
+func BenchmarkCalculator_RecordTransaction(b *testing.B) {
+	calc := NewCalculator()
+	txs := make([]domain.Transaction, b.N)
+	for i := range txs {
+		txs[i] = benchTx(int64(i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		calc.RecordTransaction(txs[i])
+	}
+}
+
+func BenchmarkCalculator_RecordTransactionParallel(b *testing.B) {
+	calc := NewCalculator()
+	var seq int64
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			calc.RecordTransaction(benchTx(atomic.AddInt64(&seq, 1)))
+		}
+	})
+}
+
+// Half the operations record transactions, half read health
+func BenchmarkCalculator_IngestWithReads(b *testing.B) {
+	calc := NewCalculator()
+	var seq int64
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddInt64(&seq, 1)
+			if i%2 == 0 {
+				calc.RecordTransaction(benchTx(i))
+			} else {
+				calc.GetHealth(benchProcessors[i%int64(len(benchProcessors))])
+			}
+		}
+	})
+}
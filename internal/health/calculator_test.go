@@ -1,6 +1,7 @@
 package health
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -143,10 +144,35 @@ func TestCalculator_StatusTransition_RecordsAlert(t *testing.T) {
 	}
 }
 
+func TestCalculator_OnTransitionCarriesRequestID(t *testing.T) {
+	calc := NewCalculator()
+	var seen []domain.HealthTransition
+	calc.SetOnTransition(func(t domain.HealthTransition) { seen = append(seen, t) })
+
+	for i := 0; i < 50; i++ {
+		tx := createTx("processor_a", domain.ResultError)
+		tx.RequestID = fmt.Sprintf("req-%d", i)
+		calc.RecordTransaction(tx)
+	}
+
+	if len(seen) != 1 || seen[0].ToStatus != domain.StatusDown {
+		t.Fatalf("expected one transition to DOWN, got %+v", seen)
+	}
+	if seen[0].RequestID != "req-9" {
+		t.Errorf("expected the 10th report (MinTransactions reached) to be blamed, got %q", seen[0].RequestID)
+	}
+	if recorded := calc.GetTransitions(time.Time{}); recorded[0].RequestID != seen[0].RequestID {
+		t.Errorf("expected the recorded transition to carry the request ID, got %+v", recorded)
+	}
+}
+
 // Helper function
+var txCounter int
+
 func createTx(processorID string, result domain.TransactionResult) domain.Transaction {
+	txCounter++
 	return domain.Transaction{
-		ID:            "test-tx",
+		ID:            fmt.Sprintf("test-tx-%d", txCounter),
 		ProcessorID:   processorID,
 		Timestamp:     time.Now(),
 		Result:        result,
@@ -156,3 +182,47 @@ func createTx(processorID string, result domain.TransactionResult) domain.Transa
 		Currency:      "BRL",
 	}
 }
+
+func TestCalculator_SameTransactionID_CorrectsInsteadOfCounting(t *testing.T) {
+	calc := NewCalculator()
+
+	for i := 0; i < 20; i++ {
+		calc.RecordTransaction(createTx("processor_a", domain.ResultApproved))
+	}
+
+	timedOut := createTx("processor_a", domain.ResultTimeout)
+	calc.RecordTransaction(timedOut)
+
+	resolved := timedOut
+	resolved.Result = domain.ResultApproved
+	health := calc.RecordTransaction(resolved)
+
+	if health.TotalTransactions != 21 {
+		t.Errorf("expected 21 transactions after correction, got %d", health.TotalTransactions)
+	}
+	if health.ErrorCount != 0 {
+		t.Errorf("expected timeout to be corrected away, got %d errors", health.ErrorCount)
+	}
+	if health.SuccessCount != 21 {
+		t.Errorf("expected 21 approved, got %d", health.SuccessCount)
+	}
+}
+
+func TestBlend_WeightsByLocalSamples(t *testing.T) {
+	local := &domain.ProcessorHealth{Status: domain.StatusHealthy, AuthorizationRate: 1.0, TotalTransactions: BlendSamples / 2}
+	network := &domain.ProcessorHealth{Status: domain.StatusDown, AuthorizationRate: 0.0, TotalTransactions: 100}
+
+	blended := Blend(local, network)
+
+	if blended.AuthorizationRate < 0.49 || blended.AuthorizationRate > 0.51 {
+		t.Errorf("expected ~0.50 blended auth rate, got %f", blended.AuthorizationRate)
+	}
+	if blended.Status != domain.StatusHealthy {
+		t.Errorf("expected local status once above MinTransactions, got %s", blended.Status)
+	}
+
+	local.TotalTransactions = BlendSamples
+	if Blend(local, network) != local {
+		t.Error("expected local health unchanged with enough samples")
+	}
+}
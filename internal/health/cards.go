@@ -0,0 +1,113 @@
+package health
+
+import (
+	"sort"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// cardKey identifies a card brand or a 6-digit BIN range (one is set)
+type cardKey struct {
+	brand string
+	bin   string
+}
+
+// cardKeys returns the card segments a CARD transaction counts toward
+func cardKeys(tx *domain.Transaction) []cardKey {
+	if tx.PaymentMethod != domain.MethodCard {
+		return nil
+	}
+	var keys []cardKey
+	brand := tx.CardBrand
+	if brand == "" {
+		brand = domain.CardBrandFromBIN(tx.BIN)
+	}
+	if brand != "" {
+		keys = append(keys, cardKey{brand: brand})
+	}
+	if bin := domain.BINRange(tx.BIN); bin != "" {
+		keys = append(keys, cardKey{bin: bin})
+	}
+	return keys
+}
+
+// cardHealth computes rates and status per brand, and per BIN range once it
+// has policy.MinTransactions, sorted by brand then BIN. Caller must hold the
+// shard lock.
+func (c *Calculator) cardHealth(w *window) []domain.CardHealth {
+	if len(w.cards) == 0 {
+		return nil
+	}
+
+	keys := make([]cardKey, 0, len(w.cards))
+	for k, n := range w.cards {
+		if k.bin != "" && n.total() < c.policy.MinTransactions {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if (a.brand == "") != (b.brand == "") {
+			return a.brand != "" // brands before BIN ranges
+		}
+		if a.brand != b.brand {
+			return a.brand < b.brand
+		}
+		return a.bin < b.bin
+	})
+
+	result := make([]domain.CardHealth, 0, len(keys))
+	for _, k := range keys {
+		n := w.cards[k]
+		authRate, errorRate := n.rates()
+		result = append(result, domain.CardHealth{
+			Brand:             k.brand,
+			BIN:               k.bin,
+			Status:            c.determineStatus(authRate, errorRate, n.total()),
+			AuthorizationRate: authRate,
+			ErrorRate:         errorRate,
+			TotalTransactions: n.total(),
+			SuccessCount:      n.approved,
+			FailureCount:      n.declined,
+			ErrorCount:        n.errors,
+		})
+	}
+	return result
+}
+
+// ForCard returns the health to score a CARD payment with: its BIN range,
+// else its brand, whichever first has at least minTransactions. It returns
+// h itself when neither does. A STALE or UNKNOWN processor keeps its
+// aggregate status.
+func ForCard(h *domain.ProcessorHealth, bin, brand string, minTransactions int) (*domain.ProcessorHealth, *domain.CardHealth) {
+	if h.Status == domain.StatusStale || h.Status == domain.StatusUnknown {
+		return h, nil
+	}
+	if brand == "" {
+		brand = domain.CardBrandFromBIN(bin)
+	}
+	bin = domain.BINRange(bin)
+
+	for _, want := range []cardKey{{bin: bin}, {brand: brand}} {
+		if want.bin == "" && want.brand == "" {
+			continue
+		}
+		for i := range h.Cards {
+			seg := &h.Cards[i]
+			if seg.BIN != want.bin || seg.Brand != want.brand || seg.TotalTransactions < minTransactions {
+				continue
+			}
+
+			view := *h
+			view.Status = seg.Status
+			view.AuthorizationRate = seg.AuthorizationRate
+			view.TotalTransactions = seg.TotalTransactions
+			view.SuccessCount = seg.SuccessCount
+			view.FailureCount = seg.FailureCount
+			view.ErrorCount = seg.ErrorCount
+			return &view, seg
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestCalculator_TracksCardSegments(t *testing.T) {
+	calc := NewCalculator()
+
+	// Visa approves; one Mastercard BIN range declines everything
+	for i := 0; i < 30; i++ {
+		calc.RecordTransaction(cardTx("41111111", domain.ResultApproved))
+	}
+	for i := 0; i < 12; i++ {
+		calc.RecordTransaction(cardTx("55555555", domain.ResultDeclined))
+	}
+	for i := 0; i < 3; i++ {
+		calc.RecordTransaction(cardTx("51000000", domain.ResultApproved))
+	}
+	// PIX transactions are not card segmented
+	calc.RecordTransaction(domain.Transaction{ProcessorID: "processor_a", Result: domain.ResultApproved,
+		PaymentMethod: domain.MethodPIX, Timestamp: time.Now()})
+
+	health := calc.GetHealth("processor_a")
+	want := []domain.CardHealth{
+		{Brand: domain.BrandMastercard, Status: domain.StatusDown, TotalTransactions: 15},
+		{Brand: domain.BrandVisa, Status: domain.StatusHealthy, TotalTransactions: 30},
+		{BIN: "411111", Status: domain.StatusHealthy, TotalTransactions: 30},
+		{BIN: "555555", Status: domain.StatusDown, TotalTransactions: 12},
+	}
+	if len(health.Cards) != len(want) {
+		t.Fatalf("expected %d card segments (510000 below minimum), got %+v", len(want), health.Cards)
+	}
+	for i, w := range want {
+		got := health.Cards[i]
+		if got.Brand != w.Brand || got.BIN != w.BIN || got.Status != w.Status || got.TotalTransactions != w.TotalTransactions {
+			t.Errorf("segment %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+}
+
+func TestForCard_PrefersBINThenBrand(t *testing.T) {
+	h := &domain.ProcessorHealth{
+		Status:            domain.StatusHealthy,
+		AuthorizationRate: 0.8,
+		Cards: []domain.CardHealth{
+			{Brand: domain.BrandMastercard, Status: domain.StatusDegraded, AuthorizationRate: 0.5, TotalTransactions: 20},
+			{BIN: "555555", Status: domain.StatusDown, AuthorizationRate: 0.1, TotalTransactions: 12},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		bin      string
+		brand    string
+		wantRate float64
+	}{
+		{"BIN range", "55555555", "", 0.1},
+		{"brand from BIN", "51000000", "", 0.5},
+		{"brand only", "", domain.BrandMastercard, 0.5},
+		{"no data", "41111111", "", 0.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := ForCard(h, tt.bin, tt.brand, MinTransactions)
+			if got.AuthorizationRate != tt.wantRate {
+				t.Errorf("expected rate %.2f, got %.2f", tt.wantRate, got.AuthorizationRate)
+			}
+		})
+	}
+}
+
+// Helper functions
+
+func cardTx(bin string, result domain.TransactionResult) domain.Transaction {
+	return domain.Transaction{
+		ProcessorID:   "processor_a",
+		Result:        result,
+		PaymentMethod: domain.MethodCard,
+		Country:       domain.CountryBR,
+		BIN:           bin,
+		Timestamp:     time.Now(),
+	}
+}
@@ -0,0 +1,334 @@
+package health
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// History limits
+const (
+	MaxHistoryBuckets = 1440        // Most buckets a single history query returns
+	HistorySkew       = time.Minute // Transactions further in the future are not kept in history
+	MaxCorridors      = 32          // Most payment method/country pairs kept per processor
+)
+
+// historyPage is how many buckets of a series are allocated together, on
+// first write, so idle stretches and little-used processors cost little
+const historyPage = 60
+
+// Resolution is one granularity of health history: buckets of Step kept
+// for Retention
+type Resolution struct {
+	Step      time.Duration
+	Retention time.Duration
+}
+
+// DefaultResolutions returns the resolutions every processor's history is
+// downsampled into, finest first
+func DefaultResolutions() []Resolution {
+	return []Resolution{
+		{Step: time.Minute, Retention: 24 * time.Hour},
+		{Step: 5 * time.Minute, Retention: 7 * 24 * time.Hour},
+		{Step: time.Hour, Retention: 90 * 24 * time.Hour},
+	}
+}
+
+// bucket holds the outcomes of one step, numbered from the Unix epoch
+type bucket struct {
+	num    int64
+	counts counts
+}
+
+// series is a ring of the buckets of one resolution: bucket n lives at
+// n % size, so a slot holding a newer bucket means n is past retention
+type series struct {
+	res   Resolution
+	size  int64
+	pages [][]bucket // of historyPage buckets, nil until written
+}
+
+func newSeries(r Resolution) *series {
+	size := int64(r.Retention / r.Step)
+	return &series{res: r, size: size, pages: make([][]bucket, (size+historyPage-1)/historyPage)}
+}
+
+func (s *series) number(t time.Time) int64 {
+	return t.Unix() / int64(s.res.Step/time.Second)
+}
+
+// slot is where bucket num lives, also for buckets before 1970. Unless
+// alloc is set it is nil when nothing was written to its page yet.
+func (s *series) slot(num int64, alloc bool) *bucket {
+	i := (num%s.size + s.size) % s.size
+	page := s.pages[i/historyPage]
+	if page == nil {
+		if !alloc {
+			return nil
+		}
+		page = make([]bucket, min(historyPage, s.size-i/historyPage*historyPage))
+		s.pages[i/historyPage] = page
+	}
+	return &page[i%historyPage]
+}
+
+// add counts (delta 1) or uncounts (delta -1) a result in the bucket of at
+func (s *series) add(at time.Time, result domain.TransactionResult, delta int) {
+	num := s.number(at)
+	b := s.slot(num, delta > 0)
+	if b == nil {
+		return
+	}
+	if b.num != num {
+		// Past retention, or a correction for a bucket already recycled
+		if b.num > num || delta < 0 {
+			return
+		}
+		*b = bucket{num: num}
+	}
+	b.counts.add(result, delta)
+}
+
+// sum adds up the buckets in [from, to). Only the part of the period the
+// series can still hold as of now is walked, however long the period is.
+func (s *series) sum(from, to, now time.Time) counts {
+	first := max(s.number(from), s.number(now)-s.size+1)
+	end := min(s.number(to), s.number(now.Add(HistorySkew))+1)
+
+	var total counts
+	for num := first; num < end; num++ {
+		if b := s.slot(num, false); b != nil && b.num == num {
+			total.approved += b.counts.approved
+			total.declined += b.counts.declined
+			total.errors += b.counts.errors
+		}
+	}
+	return total
+}
+
+// corridorKey identifies a payment method and country
+type corridorKey struct {
+	method  domain.PaymentMethod
+	country domain.Country
+}
+
+// history downsamples one processor's transactions into every resolution,
+// and per corridor (up to MaxCorridors) into the coarsest one
+type history struct {
+	series    []*series
+	corridors map[corridorKey]*series
+}
+
+func newHistory(resolutions []Resolution) *history {
+	h := &history{series: make([]*series, len(resolutions)), corridors: make(map[corridorKey]*series)}
+	for i, r := range resolutions {
+		h.series[i] = newSeries(r)
+	}
+	return h
+}
+
+// retains reports whether a transaction at t belongs in history as of now:
+// within the longest retention and at most HistorySkew ahead
+func (c *Calculator) retains(t, now time.Time) bool {
+	oldest := now.Add(-c.resolutions[len(c.resolutions)-1].Retention)
+	return t.After(oldest) && !t.After(now.Add(HistorySkew))
+}
+
+func (h *history) record(tx domain.Transaction, delta int) {
+	for _, s := range h.series {
+		s.add(tx.Timestamp, tx.Result, delta)
+	}
+
+	k := corridorKey{method: tx.PaymentMethod, country: tx.Country}
+	s, exists := h.corridors[k]
+	if !exists {
+		// Unbounded values in reports must not grow history without limit
+		if delta < 0 || len(h.corridors) >= MaxCorridors {
+			return
+		}
+		s = newSeries(h.series[len(h.series)-1].res)
+		h.corridors[k] = s
+	}
+	s.add(tx.Timestamp, tx.Result, delta)
+}
+
+// History returns a processor's outcomes between from and to in buckets of
+// step, aligned to multiples of step. Step must be a multiple of a resolution;
+// the coarsest one still retaining from is used. A zero step picks the
+// finest resolution retaining from within MaxHistoryBuckets.
+func (c *Calculator) History(processorID string, from, to time.Time, step time.Duration) (*domain.HealthHistory, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("to must be after from")
+	}
+	now := c.clock.Now()
+
+	index, err := c.resolutionFor(now, from, to, step)
+	if err != nil {
+		return nil, err
+	}
+	if step == 0 {
+		step = c.resolutions[index].Step
+	}
+
+	from, to = widen(from, to, step)
+	if n := int(to.Sub(from) / step); n > MaxHistoryBuckets {
+		return nil, fmt.Errorf("range holds %d buckets of %s, at most %d allowed", n, step, MaxHistoryBuckets)
+	}
+
+	result := &domain.HealthHistory{
+		ProcessorID: processorID,
+		TenantID:    c.tenantID,
+		From:        from,
+		To:          to,
+		Step:        step.String(),
+		Buckets:     make([]domain.HealthBucket, 0, int(to.Sub(from)/step)),
+	}
+
+	s := c.shard(processorID, false)
+	if s != nil {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	for start := from; start.Before(to); start = start.Add(step) {
+		end := start.Add(step)
+		var n counts
+		if s != nil {
+			n = s.history.series[index].sum(start, end, now)
+		}
+		result.Buckets = append(result.Buckets, c.historyBucket(start, end, n))
+	}
+	return result, nil
+}
+
+// Summary returns a processor's outcomes between from and to as a single
+// bucket, from the finest resolution still retaining from. The period is
+// widened to whole steps of that resolution.
+func (c *Calculator) Summary(processorID string, from, to time.Time) domain.HealthBucket {
+	now := c.clock.Now()
+	index := len(c.resolutions) - 1
+	for i, r := range c.resolutions {
+		if !from.Before(now.Add(-r.Retention)) {
+			index = i
+			break
+		}
+	}
+	from, to = widen(from, to, c.resolutions[index].Step)
+
+	var n counts
+	if s := c.shard(processorID, false); s != nil {
+		s.mu.RLock()
+		n = s.history.series[index].sum(from, to, now)
+		s.mu.RUnlock()
+	}
+	return c.historyBucket(from, to, n)
+}
+
+// Corridors returns a processor's outcomes between from and to per payment
+// method and country, sorted by method and country. Corridors are kept at
+// the coarsest resolution, so from and to are widened to its step.
+func (c *Calculator) Corridors(processorID string, from, to time.Time) []domain.CorridorHealth {
+	s := c.shard(processorID, false)
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	from, to = widen(from, to, c.resolutions[len(c.resolutions)-1].Step)
+	now := c.clock.Now()
+
+	result := make([]domain.CorridorHealth, 0, len(s.history.corridors))
+	for k, series := range s.history.corridors {
+		n := series.sum(from, to, now)
+		if n.total() == 0 {
+			continue
+		}
+		b := c.historyBucket(from, to, n)
+		result = append(result, domain.CorridorHealth{
+			PaymentMethod:     k.method,
+			Country:           k.country,
+			Status:            b.Status,
+			AuthorizationRate: b.AuthorizationRate,
+			ErrorRate:         b.ErrorRate,
+			TotalTransactions: b.TotalTransactions,
+			SuccessCount:      b.SuccessCount,
+			FailureCount:      b.FailureCount,
+			ErrorCount:        b.ErrorCount,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].PaymentMethod != result[j].PaymentMethod {
+			return result[i].PaymentMethod < result[j].PaymentMethod
+		}
+		return result[i].Country < result[j].Country
+	})
+	return result
+}
+
+// widen aligns from down and to up to multiples of step
+func widen(from, to time.Time, step time.Duration) (time.Time, time.Time) {
+	if end := to.Truncate(step); end.Before(to) {
+		to = end.Add(step)
+	}
+	return from.Truncate(step), to
+}
+
+// resolutionFor picks the index of the resolution a history query is
+// answered from
+func (c *Calculator) resolutionFor(now, from, to time.Time, step time.Duration) (int, error) {
+	retains := func(r Resolution) bool { return !from.Before(now.Add(-r.Retention)) }
+
+	if step == 0 {
+		for i, r := range c.resolutions {
+			if retains(r) && int(to.Sub(from)/r.Step) <= MaxHistoryBuckets {
+				return i, nil
+			}
+		}
+		return len(c.resolutions) - 1, nil
+	}
+
+	best := -1
+	for i, r := range c.resolutions {
+		if step%r.Step != 0 {
+			continue
+		}
+		// Prefer the coarsest resolution retaining from; otherwise the longest kept
+		if best < 0 {
+			best = i
+			continue
+		}
+		b := c.resolutions[best]
+		if retains(r) && (!retains(b) || r.Step > b.Step) || !retains(b) && r.Retention > b.Retention {
+			best = i
+		}
+	}
+	if best < 0 {
+		steps := make([]string, len(c.resolutions))
+		for i, r := range c.resolutions {
+			steps[i] = r.Step.String()
+		}
+		return 0, fmt.Errorf("step must be a multiple of %s", strings.Join(steps, ", "))
+	}
+	return best, nil
+}
+
+// historyBucket computes rates and status for a bucket's outcomes
+func (c *Calculator) historyBucket(start, end time.Time, n counts) domain.HealthBucket {
+	b := domain.HealthBucket{
+		Start:             start,
+		End:               end,
+		Status:            domain.StatusUnknown,
+		TotalTransactions: n.total(),
+		SuccessCount:      n.approved,
+		FailureCount:      n.declined,
+		ErrorCount:        n.errors,
+	}
+	if n.total() > 0 {
+		b.AuthorizationRate, b.ErrorRate = n.rates()
+		b.Status = c.determineStatus(b.AuthorizationRate, b.ErrorRate, n.total())
+	}
+	return b
+}
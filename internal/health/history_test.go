@@ -0,0 +1,206 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestCalculator_HistoryBucketsOutcomes(t *testing.T) {
+	start := time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	calc := NewCalculatorWithClock(clk)
+
+	// 12:00 all approved, 12:01 all errors, 12:02 idle, 12:03 mixed
+	for minute, results := range [][]domain.TransactionResult{
+		{domain.ResultApproved, domain.ResultApproved, domain.ResultApproved},
+		{domain.ResultError, domain.ResultTimeout},
+		nil,
+		{domain.ResultApproved, domain.ResultDeclined, domain.ResultError, domain.ResultApproved},
+	} {
+		clk.Set(start.Add(time.Duration(minute)*time.Minute + 30*time.Second))
+		for _, result := range results {
+			calc.RecordTransaction(historyTx(clk.Now(), result))
+		}
+	}
+
+	history, err := calc.History("processor_a", start, start.Add(4*time.Minute), time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if history.Step != "1m0s" || len(history.Buckets) != 4 {
+		t.Fatalf("expected 4 one-minute buckets, got %s %d", history.Step, len(history.Buckets))
+	}
+
+	b := history.Buckets
+	if b[0].SuccessCount != 3 || b[0].AuthorizationRate != 1 {
+		t.Errorf("unexpected 12:00 bucket: %+v", b[0])
+	}
+	if b[1].ErrorCount != 2 || b[1].ErrorRate != 1 || b[1].AuthorizationRate != 0 {
+		t.Errorf("unexpected 12:01 bucket: %+v", b[1])
+	}
+	if b[2].TotalTransactions != 0 || b[2].Status != domain.StatusUnknown {
+		t.Errorf("expected empty UNKNOWN 12:02 bucket, got %+v", b[2])
+	}
+	if b[3].SuccessCount != 2 || b[3].FailureCount != 1 || b[3].ErrorCount != 1 {
+		t.Errorf("unexpected 12:03 bucket: %+v", b[3])
+	}
+
+	// Coarser steps aggregate the same outcomes
+	history, _ = calc.History("processor_a", start, start.Add(5*time.Minute), 5*time.Minute)
+	if len(history.Buckets) != 1 || history.Buckets[0].TotalTransactions != 9 {
+		t.Errorf("expected one 5m bucket of 9 transactions, got %+v", history.Buckets)
+	}
+}
+
+func TestCalculator_HistoryAppliesCorrections(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC))
+	calc := NewCalculatorWithClock(clk)
+
+	tx := historyTx(clk.Now(), domain.ResultTimeout)
+	tx.ID = "tx-1"
+	calc.RecordTransaction(tx)
+	tx.Result = domain.ResultApproved
+	calc.RecordTransaction(tx)
+
+	history, _ := calc.History("processor_a", clk.Now(), clk.Now().Add(time.Minute), time.Minute)
+	if b := history.Buckets[0]; b.TotalTransactions != 1 || b.SuccessCount != 1 {
+		t.Errorf("expected the correction to replace the timeout, got %+v", b)
+	}
+}
+
+func TestCalculator_HistoryRetention(t *testing.T) {
+	start := time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	calc := NewCalculatorWithClock(clk)
+	calc.RecordTransaction(historyTx(start, domain.ResultApproved))
+
+	// Two days later the minute buckets are gone but hourly ones remain
+	clk.Advance(48 * time.Hour)
+	calc.RecordTransaction(historyTx(clk.Now(), domain.ResultApproved))
+
+	minutes, _ := calc.History("processor_a", start, start.Add(time.Hour), time.Minute)
+	for _, b := range minutes.Buckets {
+		if b.TotalTransactions != 0 {
+			t.Fatalf("expected minute buckets past retention to be empty, got %+v", b)
+		}
+	}
+	hours, _ := calc.History("processor_a", start, start.Add(time.Hour), time.Hour)
+	if hours.Buckets[0].TotalTransactions != 1 {
+		t.Errorf("expected the hourly bucket to be retained, got %+v", hours.Buckets[0])
+	}
+
+	// Without a step the finest resolution retaining from is used
+	auto, _ := calc.History("processor_a", start, clk.Now(), 0)
+	if auto.Step != "5m0s" || len(auto.Buckets) != 48*12 {
+		t.Errorf("expected 5m steps over 48h, got %s with %d buckets", auto.Step, len(auto.Buckets))
+	}
+}
+
+func TestCalculator_HistoryIgnoresTimestampsBeforeRetention(t *testing.T) {
+	start := time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)
+	calc := NewCalculatorWithClock(clock.NewFake(start))
+	calc.RecordTransaction(historyTx(time.Unix(-86400, 0), domain.ResultApproved))
+	calc.RecordTransaction(historyTx(start.Add(-91*24*time.Hour), domain.ResultApproved))
+
+	if b := calc.Summary("processor_a", time.Unix(-2*86400, 0), start); b.TotalTransactions != 0 {
+		t.Errorf("expected nothing kept past retention, got %+v", b)
+	}
+}
+
+func TestCalculator_SummaryOnlyWalksRetainedBuckets(t *testing.T) {
+	start := time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)
+	calc := NewCalculatorWithClock(clock.NewFake(start))
+	calc.RecordTransaction(historyTx(start.Add(-time.Hour), domain.ResultApproved))
+
+	// Without clamping this walks tens of millions of buckets per series
+	from, to := time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	began := time.Now()
+	summary := calc.Summary("processor_a", from, to)
+	corridors := calc.Corridors("processor_a", from, to)
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("expected a bounded walk, took %s", elapsed)
+	}
+
+	if summary.TotalTransactions != 1 || len(corridors) != 1 || corridors[0].TotalTransactions != 1 {
+		t.Errorf("expected the retained transaction, got %+v and %+v", summary, corridors)
+	}
+	if !summary.Start.Equal(from) {
+		t.Errorf("expected the requested period, got %s", summary.Start)
+	}
+}
+
+func TestSeries_BeforeEpochDoesNotPanic(t *testing.T) {
+	// Negative bucket numbers must still map into the ring
+	s := newSeries(Resolution{Step: time.Minute, Retention: 7 * time.Minute})
+	at := time.Unix(-86400, 0)
+	s.add(at, domain.ResultApproved, 1)
+	s.sum(at, at.Add(7*time.Minute), at)
+}
+
+func TestHistory_AllocatesOnWrite(t *testing.T) {
+	h := newHistory(DefaultResolutions())
+	at := time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < MaxCorridors+10; i++ {
+		tx := historyTx(at, domain.ResultApproved)
+		tx.Country = domain.Country(fmt.Sprintf("C%d", i))
+		h.record(tx, 1)
+	}
+
+	// One page per resolution; corridors stop at the cap
+	for _, s := range h.series {
+		if n := allocatedPages(s); n != 1 {
+			t.Errorf("expected 1 page of %s buckets, got %d", s.res.Step, n)
+		}
+	}
+	if len(h.corridors) != MaxCorridors {
+		t.Errorf("expected %d corridors, got %d", MaxCorridors, len(h.corridors))
+	}
+	if got := h.series[0].sum(at, at.Add(time.Minute), at); got.approved != MaxCorridors+10 {
+		t.Errorf("expected every transaction in the processor's series, got %+v", got)
+	}
+}
+
+func TestCalculator_HistoryRejectsInvalidQueries(t *testing.T) {
+	calc := NewCalculatorWithClock(clock.NewFake(time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)))
+	now := calc.Clock().Now()
+
+	tests := []struct {
+		from, to time.Time
+		step     time.Duration
+		want     string
+	}{
+		{now, now.Add(-time.Hour), time.Minute, "to must be after from"},
+		{now.Add(-time.Hour), now, 90 * time.Second, "step must be a multiple of"},
+		{now.Add(-48 * time.Hour), now, time.Minute, "at most 1440"},
+	}
+	for _, tt := range tests {
+		if _, err := calc.History("processor_a", tt.from, tt.to, tt.step); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected error containing %q, got %v", tt.want, err)
+		}
+	}
+}
+
+func allocatedPages(s *series) int {
+	n := 0
+	for _, page := range s.pages {
+		if page != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func historyTx(at time.Time, result domain.TransactionResult) domain.Transaction {
+	return domain.Transaction{
+		ProcessorID:   "processor_a",
+		Timestamp:     at,
+		Result:        result,
+		PaymentMethod: domain.MethodPIX,
+		Country:       domain.CountryBR,
+	}
+}
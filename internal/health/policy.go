@@ -0,0 +1,137 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Policy holds the tunable thresholds used to derive a processor's status.
+// The package constants are the defaults.
+type Policy struct {
+	WindowSize        int           `json:"window_size"`
+	TimeWindow        time.Duration `json:"time_window"`
+	HealthyThreshold  float64       `json:"healthy_threshold"`
+	DegradedThreshold float64       `json:"degraded_threshold"`
+	ErrorRateDown     float64       `json:"error_rate_down"`
+	ErrorRateDegraded float64       `json:"error_rate_degraded"`
+	MinTransactions   int           `json:"min_transactions"`
+	StaleAfter        time.Duration `json:"stale_after"`
+	AmountBands       AmountBands   `json:"amount_bands,omitempty"`
+}
+
+// DefaultPolicy returns the policy built from the package constants
+func DefaultPolicy() Policy {
+	return Policy{
+		WindowSize:        WindowSize,
+		TimeWindow:        TimeWindow,
+		HealthyThreshold:  HealthyThreshold,
+		DegradedThreshold: DegradedThreshold,
+		ErrorRateDown:     ErrorRateDown,
+		ErrorRateDegraded: ErrorRateDegraded,
+		MinTransactions:   MinTransactions,
+		StaleAfter:        StaleAfter,
+		AmountBands:       DefaultAmountBands(),
+	}
+}
+
+// Validate reports the first inconsistent setting
+func (p Policy) Validate() error {
+	switch {
+	case p.WindowSize <= 0:
+		return fmt.Errorf("window_size must be positive, got %d", p.WindowSize)
+	case p.TimeWindow <= 0:
+		return fmt.Errorf("time_window must be positive, got %s", p.TimeWindow)
+	case p.DegradedThreshold < 0 || p.HealthyThreshold > 1 || p.DegradedThreshold > p.HealthyThreshold:
+		return fmt.Errorf("thresholds must satisfy 0 <= degraded_threshold (%g) <= healthy_threshold (%g) <= 1",
+			p.DegradedThreshold, p.HealthyThreshold)
+	case p.ErrorRateDegraded < 0 || p.ErrorRateDown > 1 || p.ErrorRateDegraded > p.ErrorRateDown:
+		return fmt.Errorf("error rates must satisfy 0 <= error_rate_degraded (%g) <= error_rate_down (%g) <= 1",
+			p.ErrorRateDegraded, p.ErrorRateDown)
+	case p.MinTransactions < 0 || p.MinTransactions > p.WindowSize:
+		return fmt.Errorf("min_transactions must be between 0 and window_size (%d), got %d", p.WindowSize, p.MinTransactions)
+	case p.StaleAfter <= 0:
+		return fmt.Errorf("stale_after must be positive, got %s", p.StaleAfter)
+	}
+	return p.AmountBands.Validate()
+}
+
+// historySize is how many transactions are kept per processor for history
+func (p Policy) historySize() int {
+	return p.WindowSize * 2
+}
+
+// policyJSON mirrors Policy with durations written as strings like "10m"
+type policyJSON struct {
+	WindowSize        int         `json:"window_size"`
+	TimeWindow        string      `json:"time_window"`
+	HealthyThreshold  float64     `json:"healthy_threshold"`
+	DegradedThreshold float64     `json:"degraded_threshold"`
+	ErrorRateDown     float64     `json:"error_rate_down"`
+	ErrorRateDegraded float64     `json:"error_rate_degraded"`
+	MinTransactions   int         `json:"min_transactions"`
+	StaleAfter        string      `json:"stale_after"`
+	AmountBands       AmountBands `json:"amount_bands,omitempty"`
+}
+
+// MarshalJSON writes durations in time.Duration string form
+func (p Policy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(policyJSON{
+		WindowSize:        p.WindowSize,
+		TimeWindow:        p.TimeWindow.String(),
+		HealthyThreshold:  p.HealthyThreshold,
+		DegradedThreshold: p.DegradedThreshold,
+		ErrorRateDown:     p.ErrorRateDown,
+		ErrorRateDegraded: p.ErrorRateDegraded,
+		MinTransactions:   p.MinTransactions,
+		StaleAfter:        p.StaleAfter.String(),
+		AmountBands:       p.AmountBands,
+	})
+}
+
+// UnmarshalJSON overlays the given fields onto p, so decoding into
+// DefaultPolicy() only changes the settings present in the document
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	raw := policyJSON{
+		WindowSize:        p.WindowSize,
+		HealthyThreshold:  p.HealthyThreshold,
+		DegradedThreshold: p.DegradedThreshold,
+		ErrorRateDown:     p.ErrorRateDown,
+		ErrorRateDegraded: p.ErrorRateDegraded,
+		MinTransactions:   p.MinTransactions,
+	}
+	// Currencies in the document replace their default bands; others are kept
+	raw.AmountBands = make(AmountBands, len(p.AmountBands))
+	for currency, bounds := range p.AmountBands {
+		raw.AmountBands[currency] = bounds
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	timeWindow, staleAfter := p.TimeWindow, p.StaleAfter
+	var err error
+	if raw.TimeWindow != "" {
+		if timeWindow, err = time.ParseDuration(raw.TimeWindow); err != nil {
+			return fmt.Errorf("time_window: %w", err)
+		}
+	}
+	if raw.StaleAfter != "" {
+		if staleAfter, err = time.ParseDuration(raw.StaleAfter); err != nil {
+			return fmt.Errorf("stale_after: %w", err)
+		}
+	}
+
+	*p = Policy{
+		WindowSize:        raw.WindowSize,
+		TimeWindow:        timeWindow,
+		HealthyThreshold:  raw.HealthyThreshold,
+		DegradedThreshold: raw.DegradedThreshold,
+		ErrorRateDown:     raw.ErrorRateDown,
+		ErrorRateDegraded: raw.ErrorRateDegraded,
+		MinTransactions:   raw.MinTransactions,
+		StaleAfter:        staleAfter,
+		AmountBands:       raw.AmountBands,
+	}
+	return nil
+}
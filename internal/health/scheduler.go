@@ -0,0 +1,25 @@
+package health
+
+import (
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/schedule"
+)
+
+// DefaultReevaluateInterval is how often idle processors are re-evaluated
+const DefaultReevaluateInterval = 10 * time.Second
+
+// Scheduler periodically re-evaluates health so that processors without
+// traffic expire to STALE/UNKNOWN instead of keeping their last status.
+// OnEvent receives every time-based transition.
+type Scheduler = schedule.Scheduler[*Calculator, domain.HealthTransition]
+
+// NewScheduler creates a scheduler over the calculators returned by source
+func NewScheduler(clk clock.Clock, interval time.Duration, source func() []*Calculator) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultReevaluateInterval
+	}
+	return schedule.New(clk, interval, source, (*Calculator).Reevaluate)
+}
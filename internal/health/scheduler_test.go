@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestScheduler_IdleDownProcessorGoesStaleThenUnknown(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC))
+	calc := NewCalculatorWithClock(clk)
+	for i := 0; i < 50; i++ {
+		tx := createTx("processor_a", domain.ResultError)
+		tx.Timestamp = clk.Now()
+		calc.RecordTransaction(tx)
+	}
+	if got := calc.GetHealth("processor_a").Status; got != domain.StatusDown {
+		t.Fatalf("expected DOWN, got %s", got)
+	}
+
+	sched := NewScheduler(clk, time.Minute, func() []*Calculator { return []*Calculator{calc} })
+
+	// Still fresh: no change
+	clk.Advance(time.Minute)
+	if transitions := sched.RunOnce(); len(transitions) != 0 {
+		t.Errorf("expected no transitions while fresh, got %v", transitions)
+	}
+
+	clk.Advance(5 * time.Minute)
+	transitions := sched.RunOnce()
+	if len(transitions) != 1 || transitions[0].FromStatus != domain.StatusDown || transitions[0].ToStatus != domain.StatusStale {
+		t.Fatalf("expected DOWN → STALE, got %v", transitions)
+	}
+
+	clk.Advance(5 * time.Minute)
+	transitions = sched.RunOnce()
+	if len(transitions) != 1 || transitions[0].ToStatus != domain.StatusUnknown {
+		t.Fatalf("expected STALE → UNKNOWN, got %v", transitions)
+	}
+
+	h := calc.GetHealth("processor_a")
+	if h.Status != domain.StatusUnknown || h.TotalTransactions != 0 {
+		t.Errorf("expected empty UNKNOWN health, got %s with %d transactions", h.Status, h.TotalTransactions)
+	}
+	if len(calc.GetTransitions(clk.Now().Add(-time.Hour))) < 3 {
+		t.Error("expected time-based transitions to be recorded as alerts")
+	}
+
+	// Steady state: no repeated transitions
+	clk.Advance(time.Minute)
+	if transitions := sched.RunOnce(); len(transitions) != 0 {
+		t.Errorf("expected no transitions once UNKNOWN, got %v", transitions)
+	}
+}
+
+func TestScheduler_RunTicksWithClock(t *testing.T) {
+	calc := NewCalculator()
+	for i := 0; i < 20; i++ {
+		calc.RecordTransaction(createTx("processor_a", domain.ResultApproved))
+	}
+
+	clk := clock.NewFake(time.Now())
+	sched := NewScheduler(clk, time.Minute, func() []*Calculator { return []*Calculator{calc} })
+	got := make(chan domain.HealthTransition, 1)
+	sched.OnEvent = func(t domain.HealthTransition) { got <- t }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sched.Run(ctx)
+
+	// Keep advancing until the ticker goroutine has registered and fired;
+	// depending on timing the first tick may already see STALE or UNKNOWN
+	deadline := time.After(2 * time.Second)
+	for {
+		clk.Advance(6 * time.Minute)
+		select {
+		case tr := <-got:
+			if tr.FromStatus != domain.StatusHealthy {
+				t.Errorf("expected transition from HEALTHY, got %s", tr.FromStatus)
+			}
+			return
+		case <-deadline:
+			t.Fatal("scheduler never ran")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
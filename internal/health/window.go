@@ -0,0 +1,194 @@
+package health
+
+import (
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// HistorySize is how many transactions are kept per processor for history
+// with the default policy
+const HistorySize = WindowSize * 2
+
+// counts tallies results in the scoring window
+type counts struct {
+	approved int
+	declined int
+	errors   int
+}
+
+func (c *counts) add(result domain.TransactionResult, delta int) {
+	switch result {
+	case domain.ResultApproved:
+		c.approved += delta
+	case domain.ResultDeclined:
+		c.declined += delta
+	case domain.ResultError, domain.ResultTimeout:
+		c.errors += delta
+	}
+}
+
+func (c *counts) total() int {
+	return c.approved + c.declined + c.errors
+}
+
+// rates returns approved / (approved + declined) and errors / total. With
+// only errors the auth rate is 0; without any transactions it is 1.
+func (c *counts) rates() (authRate, errorRate float64) {
+	authRate = 1.0
+	if valid := c.approved + c.declined; valid > 0 {
+		authRate = float64(c.approved) / float64(valid)
+	} else if c.errors > 0 {
+		authRate = 0
+	}
+	if total := c.total(); total > 0 {
+		errorRate = float64(c.errors) / float64(total)
+	}
+	return authRate, errorRate
+}
+
+// window is a fixed-size ring buffer of a processor's recent transactions.
+// Transactions are addressed by a monotonically increasing sequence number:
+// the ring holds [oldest, next) and the scoring window is [start, next),
+// the last size of them. Counters for the scoring window are kept
+// incrementally so recording is O(1).
+//
+// Time-based expiry walks from the oldest entry, so it assumes transactions
+// arrive roughly in timestamp order.
+type window struct {
+	ring        []domain.Transaction
+	limit       int
+	next        uint64
+	oldest      uint64
+	start       uint64
+	counts      counts
+	ids         map[string]uint64
+	amountBands AmountBands
+	bands       map[bandKey]*counts
+	cards       map[cardKey]*counts
+}
+
+func newWindow(p Policy) *window {
+	return &window{
+		ring:        make([]domain.Transaction, p.historySize()),
+		limit:       p.WindowSize,
+		ids:         make(map[string]uint64),
+		amountBands: p.AmountBands,
+		bands:       make(map[bandKey]*counts),
+		cards:       make(map[cardKey]*counts),
+	}
+}
+
+// count adds (delta 1) or removes (delta -1) tx from the scoring window
+// counters, overall and for its amount band and card segments
+func (w *window) count(tx *domain.Transaction, delta int) {
+	w.counts.add(tx.Result, delta)
+
+	if k, banded := w.amountBands.bandOf(*tx); banded {
+		c, exists := w.bands[k]
+		if !exists {
+			c = &counts{}
+			w.bands[k] = c
+		}
+		c.add(tx.Result, delta)
+	}
+
+	// BINs are open-ended, so drop segments that leave the window
+	for _, k := range cardKeys(tx) {
+		c, exists := w.cards[k]
+		if !exists {
+			c = &counts{}
+			w.cards[k] = c
+		}
+		c.add(tx.Result, delta)
+		if c.total() == 0 {
+			delete(w.cards, k)
+		}
+	}
+}
+
+func (w *window) at(seq uint64) *domain.Transaction {
+	return &w.ring[seq%uint64(len(w.ring))]
+}
+
+// size returns how many transactions are in the scoring window
+func (w *window) size() int {
+	return int(w.next - w.start)
+}
+
+// record adds tx, or replaces an earlier transaction with the same ID and
+// returns the one replaced
+func (w *window) record(tx domain.Transaction) (domain.Transaction, bool) {
+	if tx.ID != "" {
+		if seq, exists := w.ids[tx.ID]; exists {
+			old := w.at(seq)
+			replaced := *old
+			if seq >= w.start {
+				w.count(old, -1)
+				w.count(&tx, 1)
+			}
+			*old = tx
+			return replaced, true
+		}
+	}
+
+	// Ring full: the oldest entry is already outside the scoring window
+	if w.next-w.oldest == uint64(len(w.ring)) {
+		w.evictOldest()
+	}
+
+	*w.at(w.next) = tx
+	if tx.ID != "" {
+		w.ids[tx.ID] = w.next
+	}
+	w.next++
+	w.count(&tx, 1)
+
+	for w.size() > w.limit {
+		w.count(w.at(w.start), -1)
+		w.start++
+	}
+	return domain.Transaction{}, false
+}
+
+// expire drops transactions older than cutoff from both ring and window
+func (w *window) expire(cutoff time.Time) {
+	for w.oldest < w.next && !w.at(w.oldest).Timestamp.After(cutoff) {
+		w.evictOldest()
+	}
+}
+
+func (w *window) evictOldest() {
+	tx := w.at(w.oldest)
+	if w.oldest >= w.start {
+		w.count(tx, -1)
+		w.start = w.oldest + 1
+	}
+	if tx.ID != "" && w.ids[tx.ID] == w.oldest {
+		delete(w.ids, tx.ID)
+	}
+	*tx = domain.Transaction{}
+	w.oldest++
+}
+
+// recent returns up to limit of the newest transactions, oldest first
+func (w *window) recent(limit int) []domain.Transaction {
+	from := w.oldest
+	if limit > 0 && w.next-from > uint64(limit) {
+		from = w.next - uint64(limit)
+	}
+	if from == w.next {
+		return nil
+	}
+
+	result := make([]domain.Transaction, 0, w.next-from)
+	for seq := from; seq < w.next; seq++ {
+		result = append(result, *w.at(seq))
+	}
+	return result
+}
+
+// newest returns the most recently recorded transaction. Window must not be empty.
+func (w *window) newest() *domain.Transaction {
+	return w.at(w.next - 1)
+}
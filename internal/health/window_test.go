@@ -0,0 +1,82 @@
+package health
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// Counters must always match a recount of the last WindowSize transactions
+func TestWindow_CountersMatchRecount(t *testing.T) {
+	results := []domain.TransactionResult{
+		domain.ResultApproved, domain.ResultDeclined, domain.ResultError, domain.ResultTimeout,
+	}
+	rng := rand.New(rand.NewSource(1))
+	w := newWindow(DefaultPolicy())
+	var all []domain.Transaction
+
+	for i := 0; i < 1000; i++ {
+		tx := domain.Transaction{
+			ID:        fmt.Sprintf("tx-%d", i),
+			Result:    results[rng.Intn(len(results))],
+			Timestamp: time.Now(),
+		}
+
+		// Occasionally correct a recent transaction instead of adding one
+		if len(all) > 0 && rng.Intn(5) == 0 {
+			j := len(all) - 1 - rng.Intn(min(len(all), HistorySize))
+			tx.ID = all[j].ID
+			all[j] = tx
+		} else {
+			all = append(all, tx)
+		}
+		w.record(tx)
+
+		var want counts
+		from := max(0, len(all)-WindowSize)
+		for _, tx := range all[from:] {
+			want.add(tx.Result, 1)
+		}
+		if w.counts != want {
+			t.Fatalf("step %d: counters %+v, recount %+v", i, w.counts, want)
+		}
+	}
+}
+
+func TestWindow_KeepsHistorySizeForRecent(t *testing.T) {
+	w := newWindow(DefaultPolicy())
+	for i := 0; i < HistorySize+25; i++ {
+		w.record(domain.Transaction{ID: fmt.Sprintf("tx-%d", i), Timestamp: time.Now()})
+	}
+
+	recent := w.recent(0)
+	if len(recent) != HistorySize {
+		t.Fatalf("expected %d transactions of history, got %d", HistorySize, len(recent))
+	}
+	if recent[len(recent)-1].ID != fmt.Sprintf("tx-%d", HistorySize+24) {
+		t.Errorf("expected newest last, got %s", recent[len(recent)-1].ID)
+	}
+	if len(w.ids) != HistorySize {
+		t.Errorf("expected evicted IDs to be forgotten, tracking %d", len(w.ids))
+	}
+}
+
+func TestWindow_ExpireDropsOldTransactions(t *testing.T) {
+	w := newWindow(DefaultPolicy())
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		w.record(domain.Transaction{Result: domain.ResultError, Timestamp: now.Add(-20 * time.Minute)})
+	}
+	for i := 0; i < 5; i++ {
+		w.record(domain.Transaction{Result: domain.ResultApproved, Timestamp: now})
+	}
+
+	w.expire(now.Add(-TimeWindow))
+
+	if w.size() != 5 || w.counts.errors != 0 || w.counts.approved != 5 {
+		t.Errorf("expected only 5 recent approvals, got size %d counts %+v", w.size(), w.counts)
+	}
+}
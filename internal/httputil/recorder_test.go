@@ -0,0 +1,33 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder_CapturesStatusAndSize(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewRecorder(w)
+	rec.WriteHeader(http.StatusTeapot)
+	rec.WriteHeader(http.StatusInternalServerError) // superfluous, ignored
+	rec.Write([]byte("short"))
+	rec.Write([]byte(" and stout"))
+
+	if rec.Status != http.StatusTeapot || rec.Bytes != 15 {
+		t.Errorf("expected 418 and 15 bytes, got %d and %d", rec.Status, rec.Bytes)
+	}
+}
+
+func TestRecorder_DefaultsToOKAndFlushes(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewRecorder(w)
+	rec.Write([]byte("data"))
+	if err := http.NewResponseController(rec).Flush(); err != nil {
+		t.Fatalf("expected Flush to reach the underlying writer: %v", err)
+	}
+
+	if rec.Status != http.StatusOK || !w.Flushed {
+		t.Errorf("expected 200 and a flushed response, got %d, flushed %v", rec.Status, w.Flushed)
+	}
+}
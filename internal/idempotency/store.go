@@ -0,0 +1,162 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultHorizon is how long a key is remembered when none is configured
+const DefaultHorizon = 24 * time.Hour
+
+// ErrConflict is returned when a key is reused for a different identity
+// (e.g. the same transaction ID reported for another processor)
+var ErrConflict = errors.New("idempotency key reused with different identity")
+
+// Outcome describes how a request was resolved against the store
+type Outcome string
+
+const (
+	OutcomeCreated  Outcome = "created"
+	OutcomeReplayed Outcome = "replayed"
+	OutcomeUpdated  Outcome = "updated"
+)
+
+// Response is a stored result that can be replayed to the client
+type Response struct {
+	Status int
+	Body   []byte
+}
+
+type entry struct {
+	identity string
+	payload  string
+	response Response
+	seenAt   time.Time
+	done     chan struct{} // closed once response is set
+}
+
+type queued struct {
+	key    string
+	seenAt time.Time
+}
+
+// Store deduplicates requests by key within a time horizon.
+// A key is bound to an identity that must never change, and to a payload
+// that may change: a repeated payload replays the stored response, a new
+// payload is treated as an update of the original request.
+type Store struct {
+	mu      sync.Mutex
+	horizon time.Duration
+	entries map[string]*entry
+	order   []queued
+	now     func() time.Time
+}
+
+// NewStore creates a store that remembers keys for the given horizon
+func NewStore(horizon time.Duration) *Store {
+	if horizon <= 0 {
+		horizon = DefaultHorizon
+	}
+	return &Store{
+		horizon: horizon,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// Horizon returns how long keys are remembered
+func (s *Store) Horizon() time.Duration {
+	return s.horizon
+}
+
+// Do runs fn at most once per (key, payload) within the horizon.
+// Concurrent calls for the same key wait for the in-flight one to finish.
+func (s *Store) Do(key, identity, payload string, fn func() Response) (Response, Outcome, error) {
+	s.mu.Lock()
+	s.expire()
+
+	outcome := OutcomeCreated
+	var previous *entry
+	for {
+		e, exists := s.entries[key]
+		if !exists {
+			break
+		}
+		if e.done != nil {
+			done := e.done
+			s.mu.Unlock()
+			<-done
+			s.mu.Lock()
+			continue
+		}
+		if e.identity != identity {
+			s.mu.Unlock()
+			return Response{}, "", ErrConflict
+		}
+		if e.payload == payload {
+			resp := e.response
+			s.mu.Unlock()
+			return resp, OutcomeReplayed, nil
+		}
+		outcome = OutcomeUpdated
+		previous = e
+		break
+	}
+
+	// Reserve the key before releasing the lock so fn runs only once
+	e := &entry{identity: identity, payload: payload, done: make(chan struct{})}
+	s.entries[key] = e
+	s.mu.Unlock()
+
+	resp := fn()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(e.done)
+	e.done = nil
+
+	// Transient failures (shed load, rate limits) must stay retryable
+	if !retain(resp.Status) {
+		if previous != nil {
+			s.entries[key] = previous
+		} else {
+			delete(s.entries, key)
+		}
+		return resp, outcome, nil
+	}
+
+	e.response = resp
+	e.seenAt = s.now()
+	s.order = append(s.order, queued{key: key, seenAt: e.seenAt})
+	return resp, outcome, nil
+}
+
+// retain reports whether a response status is final and worth replaying
+func retain(status int) bool {
+	return status != http.StatusTooManyRequests && status < http.StatusInternalServerError
+}
+
+// expire drops keys older than the horizon. Caller must hold s.mu.
+func (s *Store) expire() {
+	cutoff := s.now().Add(-s.horizon)
+
+	n := 0
+	for n < len(s.order) && s.order[n].seenAt.Before(cutoff) {
+		q := s.order[n]
+		// Only delete if the key was not refreshed by a later update
+		if e, exists := s.entries[q.key]; exists && e.done == nil && e.seenAt.Equal(q.seenAt) {
+			delete(s.entries, q.key)
+		}
+		n++
+	}
+	s.order = s.order[n:]
+}
+
+// Len returns the number of remembered keys
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
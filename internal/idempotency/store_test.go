@@ -0,0 +1,102 @@
+package idempotency
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func respond(body string) func() Response {
+	return func() Response { return Response{Status: 200, Body: []byte(body)} }
+}
+
+func TestStore_ReplaysSamePayload(t *testing.T) {
+	s := NewStore(time.Hour)
+
+	s.Do("k", "processor_a", "timeout", respond("first"))
+	resp, outcome, err := s.Do("k", "processor_a", "timeout", respond("second"))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outcome != OutcomeReplayed {
+		t.Errorf("expected replayed, got %s", outcome)
+	}
+	if string(resp.Body) != "first" {
+		t.Errorf("expected original response, got %q", resp.Body)
+	}
+}
+
+func TestStore_NewPayloadIsUpdate(t *testing.T) {
+	s := NewStore(time.Hour)
+
+	s.Do("k", "processor_a", "timeout", respond("first"))
+	resp, outcome, _ := s.Do("k", "processor_a", "approved", respond("second"))
+
+	if outcome != OutcomeUpdated {
+		t.Errorf("expected updated, got %s", outcome)
+	}
+	if string(resp.Body) != "second" {
+		t.Errorf("expected new response, got %q", resp.Body)
+	}
+}
+
+func TestStore_IdentityChangeConflicts(t *testing.T) {
+	s := NewStore(time.Hour)
+
+	s.Do("k", "processor_a", "approved", respond("first"))
+	_, _, err := s.Do("k", "processor_b", "approved", respond("second"))
+
+	if err != ErrConflict {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestStore_ForgetsAfterHorizon(t *testing.T) {
+	s := NewStore(time.Minute)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Do("k", "processor_a", "approved", respond("first"))
+	now = now.Add(2 * time.Minute)
+	_, outcome, _ := s.Do("k", "processor_a", "approved", respond("second"))
+
+	if outcome != OutcomeCreated {
+		t.Errorf("expected key forgotten after horizon, got %s", outcome)
+	}
+}
+
+func TestStore_ConcurrentRetriesRunOnce(t *testing.T) {
+	s := NewStore(time.Hour)
+	var calls int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Do("k", "processor_a", "approved", func() Response {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(5 * time.Millisecond)
+				return Response{Status: 200}
+			})
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected fn to run once, ran %d times", calls)
+	}
+}
+
+func TestStore_TransientFailuresAreNotReplayed(t *testing.T) {
+	s := NewStore(time.Hour)
+
+	s.Do("k", "processor_a", "approved", func() Response { return Response{Status: 503} })
+	resp, outcome, _ := s.Do("k", "processor_a", "approved", respond("ok"))
+
+	if outcome != OutcomeCreated || string(resp.Body) != "ok" {
+		t.Errorf("expected retry after 503 to run again, got %s %q", outcome, resp.Body)
+	}
+}
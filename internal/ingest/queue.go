@@ -0,0 +1,151 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/tracing"
+)
+
+// Default queue sizing
+const (
+	DefaultDepth   = 1000
+	DefaultWorkers = 4
+)
+
+// ErrQueueFull is returned when the queue is at capacity and the
+// transaction was shed instead of being queued
+var ErrQueueFull = errors.New("ingestion queue is full")
+
+// ErrClosed is returned when submitting to a queue that is shutting down
+var ErrClosed = errors.New("ingestion queue is closed")
+
+// RecordFunc records a transaction and returns the updated health. ctx
+// carries the submitter's trace, not its cancellation.
+type RecordFunc func(context.Context, domain.Transaction) *domain.ProcessorHealth
+
+type job struct {
+	ctx    context.Context
+	wait   *tracing.Span // time spent queued
+	tx     domain.Transaction
+	result chan *domain.ProcessorHealth
+}
+
+// Queue bounds how many transactions wait to be recorded and how many
+// are recorded concurrently, so ingest bursts cannot starve routing reads
+type Queue struct {
+	mu      sync.RWMutex
+	jobs    chan job
+	record  RecordFunc
+	closed  bool
+	wg      sync.WaitGroup
+	shed    atomic.Uint64
+	workers int
+}
+
+// NewQueue starts workers that drain a queue of the given depth
+func NewQueue(depth, workers int, record RecordFunc) *Queue {
+	if depth <= 0 {
+		depth = DefaultDepth
+	}
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	q := &Queue{
+		jobs:    make(chan job, depth),
+		record:  record,
+		workers: workers,
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for j := range q.jobs {
+		j.wait.End()
+		h := q.record(j.ctx, j.tx)
+		if j.result != nil {
+			j.result <- h
+		}
+	}
+}
+
+// Submit queues a transaction and returns a channel with the resulting health
+func (q *Queue) Submit(ctx context.Context, tx domain.Transaction) (<-chan *domain.ProcessorHealth, error) {
+	result := make(chan *domain.ProcessorHealth, 1)
+	if err := q.push(ctx, job{tx: tx, result: result}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Enqueue queues a transaction without waiting for it to be recorded
+func (q *Queue) Enqueue(ctx context.Context, tx domain.Transaction) error {
+	return q.push(ctx, job{tx: tx})
+}
+
+func (q *Queue) push(ctx context.Context, j job) error {
+	// Recording may outlive the request, e.g. for async ingest
+	j.ctx = context.WithoutCancel(ctx)
+	_, j.wait = tracing.Start(ctx, "ingest.queue")
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		j.wait.SetError(ErrClosed.Error())
+		j.wait.End()
+		return ErrClosed
+	}
+	select {
+	case q.jobs <- j:
+		return nil
+	default:
+		q.shed.Add(1)
+		j.wait.SetError(ErrQueueFull.Error())
+		j.wait.End()
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting transactions and waits for queued ones to be recorded
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.jobs)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
+
+// Stats reports queue depth, capacity and shed count
+type Stats struct {
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Workers  int    `json:"workers"`
+	Shed     uint64 `json:"shed"`
+}
+
+// Stats returns a snapshot of the queue state
+func (q *Queue) Stats() Stats {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return Stats{
+		Depth:    len(q.jobs),
+		Capacity: cap(q.jobs),
+		Workers:  q.workers,
+		Shed:     q.shed.Load(),
+	}
+}
@@ -0,0 +1,69 @@
+package ingest
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestQueue_SubmitReturnsHealth(t *testing.T) {
+	q := NewQueue(10, 2, func(ctx context.Context, tx domain.Transaction) *domain.ProcessorHealth {
+		return &domain.ProcessorHealth{ProcessorID: tx.ProcessorID}
+	})
+	defer q.Close()
+
+	result, err := q.Submit(context.Background(), domain.Transaction{ProcessorID: "processor_a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h := <-result; h.ProcessorID != "processor_a" {
+		t.Errorf("expected processor_a health, got %s", h.ProcessorID)
+	}
+}
+
+func TestQueue_ShedsWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	q := NewQueue(1, 1, func(ctx context.Context, tx domain.Transaction) *domain.ProcessorHealth {
+		<-release
+		return &domain.ProcessorHealth{}
+	})
+
+	// One job blocks the worker, one fills the queue
+	q.Enqueue(context.Background(), domain.Transaction{})
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		err = q.Enqueue(context.Background(), domain.Transaction{})
+	}
+
+	if err != ErrQueueFull {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+	if q.Stats().Shed == 0 {
+		t.Error("expected shed count to be reported")
+	}
+
+	close(release)
+	q.Close()
+}
+
+func TestQueue_CloseDrainsQueued(t *testing.T) {
+	var recorded int32
+	q := NewQueue(100, 1, func(ctx context.Context, tx domain.Transaction) *domain.ProcessorHealth {
+		atomic.AddInt32(&recorded, 1)
+		return &domain.ProcessorHealth{}
+	})
+
+	for i := 0; i < 50; i++ {
+		q.Enqueue(context.Background(), domain.Transaction{})
+	}
+	q.Close()
+
+	if recorded != 50 {
+		t.Errorf("expected 50 recorded after close, got %d", recorded)
+	}
+	if err := q.Enqueue(context.Background(), domain.Transaction{}); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware_EchoesClientRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	var seen string
+	h := middleware(&buf, func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transactions", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Errorf("expected echoed request ID abc-123, got %q", got)
+	}
+	if seen != "abc-123" {
+		t.Errorf("expected handler context to carry abc-123, got %q", seen)
+	}
+
+	line := decodeLine(t, &buf)
+	if line["request_id"] != "abc-123" || line["status"] != float64(http.StatusAccepted) {
+		t.Errorf("unexpected log line %v", line)
+	}
+	if line["method"] != "POST" || line["route"] != "test-route" || line["msg"] != "request" {
+		t.Errorf("unexpected log line %v", line)
+	}
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	for _, given := range []string{"", "has spaces", strings.Repeat("x", 129)} {
+		var buf bytes.Buffer
+		h := middleware(&buf, func(w http.ResponseWriter, r *http.Request) {})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		if given != "" {
+			req.Header.Set(RequestIDHeader, given)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if len(id) != 32 || id == given {
+			t.Errorf("expected a generated request ID for %q, got %q", given, id)
+		}
+	}
+}
+
+func TestMiddleware_RedactsAPIKey(t *testing.T) {
+	var buf bytes.Buffer
+	h := middleware(&buf, func(w http.ResponseWriter, r *http.Request) {})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+
+	line := decodeLine(t, &buf)
+	client, _ := line["client"].(string)
+	if !strings.HasPrefix(client, "key:") || strings.Contains(client, "secret") {
+		t.Errorf("expected a redacted key fingerprint, got %q", client)
+	}
+}
+
+func TestMiddleware_ProbesLoggedAtDebug(t *testing.T) {
+	var buf bytes.Buffer
+	h := middleware(&buf, func(w http.ResponseWriter, r *http.Request) {})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	if buf.Len() != 0 {
+		t.Errorf("expected probe request below info level to be dropped, got %s", buf.String())
+	}
+}
+
+func TestMiddleware_ServerErrorsLoggedAtError(t *testing.T) {
+	var buf bytes.Buffer
+	h := middleware(&buf, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+
+	if line := decodeLine(t, &buf); line["level"] != "ERROR" {
+		t.Errorf("expected ERROR level for a 500, got %v", line["level"])
+	}
+}
+
+func TestLogger_AddsRequestIDFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, FormatJSON, slog.LevelInfo)
+
+	logger.InfoContext(WithRequestID(context.Background(), "req-1"), "recommendation")
+	if line := decodeLine(t, &buf); line["request_id"] != "req-1" {
+		t.Errorf("expected request_id req-1, got %v", line)
+	}
+
+	buf.Reset()
+	logger.Info("no context")
+	if line := decodeLine(t, &buf); line["request_id"] != nil {
+		t.Errorf("expected no request_id without a context, got %v", line)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("warn"); err != nil || level != slog.LevelWarn {
+		t.Errorf("expected warn, got %v, %v", level, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+// Helper functions
+
+func middleware(buf *bytes.Buffer, next http.HandlerFunc) http.Handler {
+	logger := New(buf, FormatJSON, slog.LevelInfo)
+	route := func(*http.Request) string { return "test-route" }
+	client := func(*http.Request) string { return "key:secret-key" }
+	return Middleware(logger, next, route, client)
+}
+
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected one JSON log line, got %q: %v", buf.String(), err)
+	}
+	return line
+}
@@ -0,0 +1,149 @@
+// Package notify pushes health transitions and SLO alerts to a webhook as
+// they happen, so on-call tooling does not have to poll /api/v1/alerts.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// Webhook delivery tuning
+const (
+	QueueDepth = 256             // Events waiting for delivery before new ones are dropped
+	Timeout    = 5 * time.Second // Per delivery attempt
+)
+
+// EventHealthTransition is the type of events carrying a status transition
+const EventHealthTransition = "health_transition"
+
+// Event is the JSON body posted to the webhook: a transition or an SLO
+// alert, by type
+type Event struct {
+	Type       string                   `json:"type"`
+	Transition *domain.HealthTransition `json:"transition,omitempty"`
+	SLOAlert   *domain.SLOAlert         `json:"slo_alert,omitempty"`
+}
+
+// Webhook posts events to a URL from a background worker. Events are
+// dropped, not retried, when the URL fails or the queue is full.
+type Webhook struct {
+	mu      sync.RWMutex
+	url     string
+	client  *http.Client
+	queue   chan Event
+	OnError func(error)
+}
+
+// NewWebhook creates a webhook posting to url (empty: disabled)
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: Timeout},
+		queue:  make(chan Event, QueueDepth),
+	}
+}
+
+// SetURL changes where events are posted (empty: disabled)
+func (w *Webhook) SetURL(url string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.url = url
+}
+
+// URL returns where events are posted
+func (w *Webhook) URL() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.url
+}
+
+// Transition queues a status transition
+func (w *Webhook) Transition(t domain.HealthTransition) {
+	w.enqueue(Event{Type: EventHealthTransition, Transition: &t})
+}
+
+// SLOAlert queues an SLO burn-rate alert
+func (w *Webhook) SLOAlert(a domain.SLOAlert) {
+	w.enqueue(Event{Type: a.Type, SLOAlert: &a})
+}
+
+func (w *Webhook) enqueue(e Event) {
+	if w.URL() == "" {
+		return
+	}
+	select {
+	case w.queue <- e:
+	default:
+		w.fail(fmt.Errorf("webhook queue full, dropping %s event", e.Type))
+	}
+}
+
+// Run delivers queued events until ctx is cancelled
+func (w *Webhook) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-w.queue:
+			if err := w.post(ctx, e); err != nil {
+				w.fail(err)
+			}
+		}
+	}
+}
+
+// Flush delivers the events still queued, e.g. on shutdown after Run has
+// stopped, until the queue is empty or ctx is done
+func (w *Webhook) Flush(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-w.queue:
+			if err := w.post(ctx, e); err != nil {
+				w.fail(err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (w *Webhook) post(ctx context.Context, e Event) error {
+	url := w.URL()
+	if url == "" {
+		return nil
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *Webhook) fail(err error) {
+	if w.OnError != nil {
+		w.OnError(err)
+	}
+}
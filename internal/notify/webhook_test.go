@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestWebhook_PostsEvents(t *testing.T) {
+	received := make(chan Event, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		json.NewDecoder(r.Body).Decode(&e)
+		received <- e
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hook := NewWebhook(server.URL)
+	go hook.Run(ctx)
+
+	hook.Transition(domain.HealthTransition{ProcessorID: "processor_a", ToStatus: domain.StatusDown})
+	hook.SLOAlert(domain.SLOAlert{Type: domain.AlertSLOBurnRate, SLOID: "availability"})
+
+	first, second := wait(t, received), wait(t, received)
+	if first.Type != EventHealthTransition || first.Transition == nil || first.Transition.ToStatus != domain.StatusDown {
+		t.Errorf("unexpected transition event: %+v", first)
+	}
+	if second.Type != domain.AlertSLOBurnRate || second.SLOAlert == nil || second.SLOAlert.SLOID != "availability" {
+		t.Errorf("unexpected SLO event: %+v", second)
+	}
+}
+
+func TestWebhook_ReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	hook := NewWebhook(server.URL)
+	hook.OnError = func(err error) { errs <- err }
+	go hook.Run(ctx)
+
+	hook.Transition(domain.HealthTransition{ProcessorID: "processor_a"})
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected an error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the failure to be reported")
+	}
+}
+
+func TestWebhook_DisabledWithoutURL(t *testing.T) {
+	hook := NewWebhook("")
+	hook.Transition(domain.HealthTransition{})
+
+	if len(hook.queue) != 0 {
+		t.Error("expected nothing to be queued without a URL")
+	}
+}
+
+// Helper functions
+
+func wait(t *testing.T, received chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-received:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the webhook")
+		return Event{}
+	}
+}
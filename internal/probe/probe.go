@@ -0,0 +1,71 @@
+// Package probe serves the liveness and readiness endpoints used by
+// orchestrators, kept apart from the business health of processors at
+// /api/v1/health
+package probe
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Probe tracks whether the server should receive traffic. It starts not
+// ready until startup completes and goes not ready again on shutdown.
+type Probe struct {
+	mu     sync.RWMutex
+	ready  bool
+	reason string
+}
+
+// New creates a probe that is not ready yet
+func New() *Probe {
+	return &Probe{reason: "starting"}
+}
+
+// SetReady marks the server ready for traffic
+func (p *Probe) SetReady() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ready, p.reason = true, ""
+}
+
+// SetNotReady takes the server out of rotation, e.g. while shutting down
+func (p *Probe) SetNotReady(reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ready, p.reason = false, reason
+}
+
+// Ready reports whether the server is ready and, if not, why
+func (p *Probe) Ready() (bool, string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.ready, p.reason
+}
+
+// RegisterRoutes sets up /livez and /readyz
+func (p *Probe) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /livez", p.Livez)
+	mux.HandleFunc("GET /readyz", p.Readyz)
+}
+
+// GET /livez - The process is up and serving HTTP
+func (p *Probe) Livez(w http.ResponseWriter, r *http.Request) {
+	write(w, map[string]string{"status": "ok"}, http.StatusOK)
+}
+
+// GET /readyz - 200 when ready for traffic, 503 with the reason otherwise
+func (p *Probe) Readyz(w http.ResponseWriter, r *http.Request) {
+	if ready, reason := p.Ready(); !ready {
+		write(w, map[string]string{"status": "not_ready", "reason": reason}, http.StatusServiceUnavailable)
+		return
+	}
+	write(w, map[string]string{"status": "ready"}, http.StatusOK)
+}
+
+func write(w http.ResponseWriter, body map[string]string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
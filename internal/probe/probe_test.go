@@ -0,0 +1,48 @@
+package probe
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbe_ReadinessFollowsLifecycle(t *testing.T) {
+	p := New()
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+
+	if code, body := get(mux, "/readyz"); code != http.StatusServiceUnavailable || body["reason"] != "starting" {
+		t.Errorf("expected not ready while starting, got %d %v", code, body)
+	}
+
+	p.SetReady()
+	if code, body := get(mux, "/readyz"); code != http.StatusOK || body["status"] != "ready" {
+		t.Errorf("expected ready, got %d %v", code, body)
+	}
+
+	p.SetNotReady("shutting down")
+	if code, body := get(mux, "/readyz"); code != http.StatusServiceUnavailable || body["reason"] != "shutting down" {
+		t.Errorf("expected not ready while shutting down, got %d %v", code, body)
+	}
+}
+
+func TestProbe_LiveWhileNotReady(t *testing.T) {
+	p := New()
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+
+	if code, body := get(mux, "/livez"); code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("expected live before ready, got %d %v", code, body)
+	}
+}
+
+// Helper functions
+
+func get(h http.Handler, path string) (int, map[string]string) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]string
+	json.NewDecoder(rec.Body).Decode(&body)
+	return rec.Code, body
+}
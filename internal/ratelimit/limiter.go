@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/auth"
+	"github.com/yuno/techcart-failover/internal/logging"
+)
+
+// Rule configures a token bucket: Rate tokens per second, up to Burst
+type Rule struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// DefaultRules are the per-client limits for each route class
+var DefaultRules = map[string]Rule{
+	"ingest":  {Rate: 200, Burst: 400},
+	"routing": {Rate: 500, Burst: 1000},
+	"read":    {Rate: 50, Burst: 100},
+}
+
+type bucket struct {
+	class  string
+	tokens float64
+	last   time.Time
+}
+
+// Limiter applies token-bucket limits per (client, route class)
+type Limiter struct {
+	mu             sync.Mutex
+	rules          map[string]Rule
+	buckets        map[string]*bucket
+	lastSweep      time.Time
+	trustForwarded bool
+	knownKey       func(string) bool
+	now            func() time.Time
+}
+
+// NewLimiter creates a limiter. Route classes without a rule are unlimited.
+// When trustForwarded is set the first X-Forwarded-For hop identifies the client.
+func NewLimiter(rules map[string]Rule, trustForwarded bool) *Limiter {
+	return &Limiter{
+		rules:          rules,
+		buckets:        make(map[string]*bucket),
+		trustForwarded: trustForwarded,
+		now:            time.Now,
+	}
+}
+
+// SetRules replaces the limits. Existing buckets keep their tokens and are
+// capped at the new burst on their next request.
+func (l *Limiter) SetRules(rules map[string]Rule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules = rules
+}
+
+// SetKeyCheck makes ClientID identify clients by API key once known accepts
+// the key. Without it, and for keys it rejects, clients are keyed on their IP.
+func (l *Limiter) SetKeyCheck(known func(key string) bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.knownKey = known
+}
+
+// Allow takes a token for the client in the given class. When the bucket
+// is empty it returns false and how long until a token is available.
+func (l *Limiter) Allow(client, class string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rule, limited := l.rules[class]
+	if !limited || rule.Rate <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	key := class + "|" + client
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{class: class, tokens: float64(rule.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill since last request
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed*rule.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / rule.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets idle long enough to have refilled, which a new bucket
+// matches, and those of classes no longer limited. Caller must hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		rule, limited := l.rules[b.class]
+		if !limited || rule.Rate <= 0 || b.tokens+now.Sub(b.last).Seconds()*rule.Rate >= float64(rule.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ClientID identifies the caller by API key (read like auth does) when the
+// key is known (see SetKeyCheck), falling back to its IP
+func (l *Limiter) ClientID(r *http.Request) string {
+	l.mu.Lock()
+	known := l.knownKey
+	l.mu.Unlock()
+	if key := auth.RequestKey(r); key != "" && known != nil && known(key) {
+		return "key:" + key
+	}
+	if l.trustForwarded {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return "ip:" + strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Middleware rejects requests over the limit with 429 and Retry-After.
+// classify maps a request to its route class.
+func (l *Limiter) Middleware(next http.Handler, classify func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.Allow(l.ClientID(r), classify(r))
+		if !ok {
+			WriteRetryAfter(w, wait, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteRetryAfter writes a JSON error with a Retry-After header in whole seconds
+func WriteRetryAfter(w http.ResponseWriter, wait time.Duration, status int, message string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+	logging.WriteError(w, status, message)
+}
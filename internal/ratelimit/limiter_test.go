@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/auth"
+)
+
+func TestLimiter_BurstThenReject(t *testing.T) {
+	l := NewLimiter(map[string]Rule{"ingest": {Rate: 1, Burst: 3}}, false)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("client", "ingest"); !ok {
+			t.Fatalf("request %d within burst was rejected", i+1)
+		}
+	}
+
+	ok, wait := l.Allow("client", "ingest")
+	if ok {
+		t.Fatal("expected request over burst to be rejected")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("expected retry within 1s, got %s", wait)
+	}
+
+	// Refills at Rate tokens per second
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("client", "ingest"); !ok {
+		t.Error("expected a token after refill")
+	}
+}
+
+func TestLimiter_ClientsAndClassesAreIndependent(t *testing.T) {
+	l := NewLimiter(map[string]Rule{"ingest": {Rate: 1, Burst: 1}}, false)
+
+	l.Allow("a", "ingest")
+	if ok, _ := l.Allow("b", "ingest"); !ok {
+		t.Error("expected client b to have its own bucket")
+	}
+	if ok, _ := l.Allow("a", "routing"); !ok {
+		t.Error("expected unconfigured class to be unlimited")
+	}
+}
+
+func TestLimiter_MiddlewareReturns429WithRetryAfter(t *testing.T) {
+	l := NewLimiter(map[string]Rule{"read": {Rate: 0.5, Burst: 1}}, false)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := l.Middleware(next, func(*http.Request) string { return "read" })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	req.Header.Set(auth.APIKeyHeader, "merchant-1")
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After 2, got %q", got)
+	}
+}
+
+func TestLimiter_SetRulesAppliesToExistingClients(t *testing.T) {
+	l := NewLimiter(map[string]Rule{"ingest": {Rate: 1, Burst: 1}}, false)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	l.Allow("client", "ingest")
+	if ok, _ := l.Allow("client", "ingest"); ok {
+		t.Fatal("expected second request to be rejected")
+	}
+
+	l.SetRules(map[string]Rule{})
+	if ok, _ := l.Allow("client", "ingest"); !ok {
+		t.Error("expected class without a rule to be unlimited after SetRules")
+	}
+}
+
+func TestLimiter_ClientIDUsesOnlyKnownKeys(t *testing.T) {
+	l := NewLimiter(nil, false)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set(auth.APIKeyHeader, "merchant-1")
+
+	if got := l.ClientID(req); got != "ip:10.0.0.1" {
+		t.Errorf("expected the IP without a key check, got %s", got)
+	}
+
+	l.SetKeyCheck(func(key string) bool { return key == "merchant-1" })
+	if got := l.ClientID(req); got != "key:merchant-1" {
+		t.Errorf("expected the known key, got %s", got)
+	}
+	req.Header.Set(auth.APIKeyHeader, "made-up")
+	if got := l.ClientID(req); got != "ip:10.0.0.1" {
+		t.Errorf("expected the IP for an unknown key, got %s", got)
+	}
+}
+
+func TestLimiter_ClientIDReadsTheKeyLikeAuth(t *testing.T) {
+	l := NewLimiter(nil, false)
+	l.SetKeyCheck(func(key string) bool { return key == "merchant-1" })
+
+	// EventSource clients send the key as a query param
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/stream?"+auth.QueryParam+"=merchant-1", nil)
+	if got := l.ClientID(req); got != "key:merchant-1" {
+		t.Errorf("expected the key from the query param, got %s", got)
+	}
+}
+
+func TestLimiter_SweepDropsRefilledBuckets(t *testing.T) {
+	l := NewLimiter(map[string]Rule{"ingest": {Rate: 1, Burst: 100}, "read": {Rate: 0.01, Burst: 100}}, false)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 50; i++ {
+		l.Allow("client", "ingest")
+		l.Allow("client", "read")
+	}
+
+	// After two minutes ingest has refilled its 50 tokens; read has not
+	now = now.Add(2 * time.Minute)
+	l.Allow("other", "ingest")
+	if got := bucketKeys(l); len(got) != 2 || !got["read|client"] || !got["ingest|other"] {
+		t.Errorf("expected only the refilling read bucket and the new one, got %v", got)
+	}
+}
+
+// Helper functions
+
+func bucketKeys(l *Limiter) map[string]bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys := make(map[string]bool, len(l.buckets))
+	for key := range l.buckets {
+		keys[key] = true
+	}
+	return keys
+}
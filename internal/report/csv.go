@@ -0,0 +1,85 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSV tables a report can be exported as
+const (
+	TableProcessors = "processors"
+	TableOutages    = "outages"
+	TableDaily      = "daily"
+	TableCorridors  = "corridors"
+)
+
+// Tables lists the CSV tables, the default first
+var Tables = []string{TableProcessors, TableOutages, TableDaily, TableCorridors}
+
+// WriteCSV writes one table of the report as CSV with a header row
+func WriteCSV(w io.Writer, r Report, table string) error {
+	var rows [][]string
+	switch table {
+	case TableProcessors:
+		rows = append(rows, []string{"processor_id", "name", "availability",
+			"healthy_seconds", "degraded_seconds", "down_seconds", "no_data_seconds",
+			"outage_count", "outage_seconds", "longest_outage_seconds",
+			"total_transactions", "authorization_rate", "error_rate"})
+		for _, p := range r.Processors {
+			s := p.SecondsInStatus
+			rows = append(rows, []string{p.ProcessorID, p.Name, rate(p.Availability),
+				seconds(s.Healthy), seconds(s.Degraded), seconds(s.Down), seconds(s.NoData),
+				strconv.Itoa(p.OutageCount), seconds(p.OutageSeconds), seconds(p.LongestOutageSeconds),
+				strconv.Itoa(p.TotalTransactions), rate(p.AuthorizationRate), rate(p.ErrorRate)})
+		}
+	case TableOutages:
+		rows = append(rows, []string{"processor_id", "start", "end", "duration_seconds", "ongoing", "reason"})
+		for _, p := range r.Processors {
+			for _, o := range p.Outages {
+				rows = append(rows, []string{o.ProcessorID, timestamp(o.Start), timestamp(o.End),
+					seconds(o.DurationSeconds), strconv.FormatBool(o.Ongoing), o.Reason})
+			}
+		}
+	case TableDaily:
+		rows = append(rows, []string{"processor_id", "date", "total_transactions",
+			"success_count", "failure_count", "error_count", "authorization_rate", "error_rate"})
+		for _, p := range r.Processors {
+			for _, d := range p.Daily {
+				rows = append(rows, []string{p.ProcessorID, d.Date, strconv.Itoa(d.TotalTransactions),
+					strconv.Itoa(d.SuccessCount), strconv.Itoa(d.FailureCount), strconv.Itoa(d.ErrorCount),
+					rate(d.AuthorizationRate), rate(d.ErrorRate)})
+			}
+		}
+	case TableCorridors:
+		rows = append(rows, []string{"processor_id", "payment_method", "country", "total_transactions",
+			"success_count", "failure_count", "error_count", "authorization_rate", "error_rate"})
+		for _, p := range r.Processors {
+			for _, c := range p.Corridors {
+				rows = append(rows, []string{c.ProcessorID, string(c.PaymentMethod), string(c.Country),
+					strconv.Itoa(c.TotalTransactions), strconv.Itoa(c.SuccessCount), strconv.Itoa(c.FailureCount),
+					strconv.Itoa(c.ErrorCount), rate(c.AuthorizationRate), rate(c.ErrorRate)})
+			}
+		}
+	default:
+		return fmt.Errorf("unknown table %q (want one of %v)", table, Tables)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
+
+func rate(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+func seconds(v float64) string {
+	return strconv.FormatFloat(v, 'f', 0, 64)
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
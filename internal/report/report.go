@@ -0,0 +1,308 @@
+// Package report computes processor SLA and uptime reports from a health
+// calculator: availability and outages from its status transitions, auth
+// rates per day and per corridor from its health history.
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+)
+
+// Report tuning
+const (
+	DefaultPeriod  = 30 * 24 * time.Hour // Reported when no period is given
+	WorstCorridors = 5                   // Corridors listed in the report's worst corridors
+)
+
+// StatusSeconds is the time spent in each status, in seconds. NoData covers
+// STALE, UNKNOWN and time before the processor was first seen.
+type StatusSeconds struct {
+	Healthy  float64 `json:"healthy"`
+	Degraded float64 `json:"degraded"`
+	Down     float64 `json:"down"`
+	NoData   float64 `json:"no_data"`
+}
+
+// observed is the time with a known status
+func (s StatusSeconds) observed() float64 {
+	return s.Healthy + s.Degraded + s.Down
+}
+
+// Outage is a continuous period a processor was DOWN. Ongoing outages end
+// at the end of the report.
+type Outage struct {
+	ProcessorID     string    `json:"processor_id"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Ongoing         bool      `json:"ongoing"`
+	Reason          string    `json:"reason"`
+}
+
+// Day is a processor's outcomes on one UTC day
+type Day struct {
+	Date              string  `json:"date"`
+	AuthorizationRate float64 `json:"authorization_rate"`
+	ErrorRate         float64 `json:"error_rate"`
+	TotalTransactions int     `json:"total_transactions"`
+	SuccessCount      int     `json:"success_count"`
+	FailureCount      int     `json:"failure_count"`
+	ErrorCount        int     `json:"error_count"`
+}
+
+// Corridor is a processor's outcomes for one method and country
+type Corridor struct {
+	ProcessorID string `json:"processor_id"`
+	domain.CorridorHealth
+}
+
+// ProcessorReport is one processor's SLA figures. Availability is the share
+// of observed time the processor was not DOWN (0 without observed time).
+type ProcessorReport struct {
+	ProcessorID          string        `json:"processor_id"`
+	Name                 string        `json:"name,omitempty"`
+	Availability         float64       `json:"availability"`
+	SecondsInStatus      StatusSeconds `json:"seconds_in_status"`
+	OutageCount          int           `json:"outage_count"`
+	OutageSeconds        float64       `json:"outage_seconds"`
+	LongestOutageSeconds float64       `json:"longest_outage_seconds"`
+	Outages              []Outage      `json:"outages"`
+	AuthorizationRate    float64       `json:"authorization_rate"`
+	ErrorRate            float64       `json:"error_rate"`
+	TotalTransactions    int           `json:"total_transactions"`
+	Daily                []Day         `json:"daily"`
+	Corridors            []Corridor    `json:"corridors"`
+}
+
+// Report is the SLA report of a tenant's processors over [From, To)
+type Report struct {
+	TenantID       string            `json:"tenant_id,omitempty"`
+	From           time.Time         `json:"from"`
+	To             time.Time         `json:"to"`
+	GeneratedAt    time.Time         `json:"generated_at"`
+	Processors     []ProcessorReport `json:"processors"`
+	WorstCorridors []Corridor        `json:"worst_corridors"`
+}
+
+// Build reports on every processor registered or tracked by calc between
+// from and to. To is clamped to the calculator's clock; processors supplies
+// names and may be nil.
+func Build(calc *health.Calculator, processors []*domain.Processor, from, to time.Time) Report {
+	now := calc.Clock().Now()
+	if to.After(now) {
+		to = now
+	}
+	if from.After(to) {
+		from = to
+	}
+
+	r := Report{
+		TenantID:       calc.TenantID(),
+		From:           from,
+		To:             to,
+		GeneratedAt:    now,
+		Processors:     []ProcessorReport{},
+		WorstCorridors: []Corridor{},
+	}
+
+	names := make(map[string]string)
+	for _, p := range processors {
+		names[p.ID] = p.Name
+	}
+	for _, h := range calc.GetAllHealth() {
+		if _, exists := names[h.ProcessorID]; !exists {
+			names[h.ProcessorID] = ""
+		}
+	}
+	ids := make([]string, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	transitions := calc.GetTransitions(time.Time{})
+	for _, id := range ids {
+		p := processorReport(calc, id, transitions, from, to)
+		p.Name = names[id]
+		r.Processors = append(r.Processors, p)
+	}
+	r.WorstCorridors = worstCorridors(r.Processors, calc.Policy().MinTransactions)
+	return r
+}
+
+func processorReport(calc *health.Calculator, processorID string, transitions []domain.HealthTransition, from, to time.Time) ProcessorReport {
+	p := ProcessorReport{ProcessorID: processorID, Outages: []Outage{}, Daily: []Day{}, Corridors: []Corridor{}}
+
+	var own []domain.HealthTransition
+	for _, t := range transitions {
+		if t.ProcessorID == processorID {
+			own = append(own, t)
+		}
+	}
+	since, tracked := calc.TrackedSince(processorID)
+	if !tracked {
+		since = to
+	}
+	p.SecondsInStatus, p.Outages = timeline(own, calc.GetHealth(processorID).Status, since, from, to)
+
+	for i, o := range p.Outages {
+		p.Outages[i].ProcessorID = processorID
+		p.OutageSeconds += o.DurationSeconds
+		p.LongestOutageSeconds = max(p.LongestOutageSeconds, o.DurationSeconds)
+	}
+	p.OutageCount = len(p.Outages)
+	if observed := p.SecondsInStatus.observed(); observed > 0 {
+		p.Availability = 1 - p.SecondsInStatus.Down/observed
+	}
+
+	if !to.After(from) {
+		return p
+	}
+	summary := calc.Summary(processorID, from, to)
+	p.AuthorizationRate, p.ErrorRate, p.TotalTransactions = summary.AuthorizationRate, summary.ErrorRate, summary.TotalTransactions
+
+	if days, err := calc.History(processorID, from, to, 24*time.Hour); err == nil {
+		for _, b := range days.Buckets {
+			p.Daily = append(p.Daily, Day{
+				Date:              b.Start.UTC().Format(time.DateOnly),
+				AuthorizationRate: b.AuthorizationRate,
+				ErrorRate:         b.ErrorRate,
+				TotalTransactions: b.TotalTransactions,
+				SuccessCount:      b.SuccessCount,
+				FailureCount:      b.FailureCount,
+				ErrorCount:        b.ErrorCount,
+			})
+		}
+	}
+
+	for _, c := range calc.Corridors(processorID, from, to) {
+		p.Corridors = append(p.Corridors, Corridor{ProcessorID: processorID, CorridorHealth: c})
+	}
+	sort.SliceStable(p.Corridors, func(i, j int) bool {
+		return p.Corridors[i].AuthorizationRate < p.Corridors[j].AuthorizationRate
+	})
+	return p
+}
+
+// timeline splits [from, to) into time per status and DOWN periods, from a
+// processor's transitions (oldest first) and its current status. Before
+// the first transition the processor had that transition's from status;
+// before since it had not been seen.
+func timeline(transitions []domain.HealthTransition, current domain.HealthStatus, since, from, to time.Time) (StatusSeconds, []Outage) {
+	var seconds StatusSeconds
+	outages := []Outage{}
+
+	status, reason := current, ""
+	if len(transitions) > 0 {
+		status = transitions[0].FromStatus
+	}
+	at := from
+	if since.After(to) {
+		since = to
+	}
+	if since.After(at) {
+		seconds.NoData += since.Sub(at).Seconds()
+		at = since
+	}
+
+	var outage *Outage
+	spend := func(until time.Time) {
+		if !until.After(at) {
+			return
+		}
+		d := until.Sub(at).Seconds()
+		switch status {
+		case domain.StatusHealthy:
+			seconds.Healthy += d
+		case domain.StatusDegraded:
+			seconds.Degraded += d
+		case domain.StatusDown:
+			seconds.Down += d
+			if outage == nil {
+				outage = &Outage{Start: at, Reason: reason}
+			}
+			outage.End = until
+		default:
+			seconds.NoData += d
+		}
+		at = until
+	}
+	closeOutage := func() {
+		if outage != nil {
+			outage.DurationSeconds = outage.End.Sub(outage.Start).Seconds()
+			outages = append(outages, *outage)
+			outage = nil
+		}
+	}
+
+	for _, t := range transitions {
+		if !t.Timestamp.After(at) {
+			// Before the reported span: only the status carries over
+			status, reason = t.ToStatus, t.Reason
+			continue
+		}
+		if !t.Timestamp.Before(to) {
+			break
+		}
+		spend(t.Timestamp)
+		if t.ToStatus != domain.StatusDown {
+			closeOutage()
+		}
+		status, reason = t.ToStatus, t.Reason
+	}
+	spend(to)
+	if outage != nil {
+		outage.Ongoing = status == domain.StatusDown
+		closeOutage()
+	}
+	return seconds, outages
+}
+
+// worstCorridors ranks every processor's corridors with at least
+// minTransactions by auth rate, worst first
+func worstCorridors(processors []ProcessorReport, minTransactions int) []Corridor {
+	result := []Corridor{}
+	for _, p := range processors {
+		for _, c := range p.Corridors {
+			if c.TotalTransactions >= minTransactions {
+				result = append(result, c)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].AuthorizationRate < result[j].AuthorizationRate })
+	if len(result) > WorstCorridors {
+		result = result[:WorstCorridors]
+	}
+	return result
+}
+
+// Replay feeds txs (in timestamp order) through a calculator on a fake
+// clock, re-evaluating idle processors as time passes, and returns the
+// calculator as of the last transaction
+func Replay(txs []domain.Transaction, policy health.Policy) *health.Calculator {
+	txs = append([]domain.Transaction(nil), txs...)
+	sort.SliceStable(txs, func(i, j int) bool { return txs[i].Timestamp.Before(txs[j].Timestamp) })
+
+	var start time.Time
+	if len(txs) > 0 {
+		start = txs[0].Timestamp
+	}
+	clk := clock.NewFake(start)
+	calc := health.NewCalculatorWithPolicy(clk, policy)
+
+	next := start.Add(health.DefaultReevaluateInterval)
+	for _, tx := range txs {
+		for !next.After(tx.Timestamp) {
+			clk.Set(next)
+			calc.Reevaluate(next)
+			next = next.Add(health.DefaultReevaluateInterval)
+		}
+		clk.Set(tx.Timestamp)
+		calc.RecordTransaction(tx)
+	}
+	return calc
+}
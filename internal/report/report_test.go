@@ -0,0 +1,154 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+)
+
+var start = time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)
+
+func TestTimeline_SplitsStatusesAndOutages(t *testing.T) {
+	transitions := []domain.HealthTransition{
+		transition(10*time.Minute, domain.StatusHealthy, domain.StatusDown),
+		transition(25*time.Minute, domain.StatusDown, domain.StatusDegraded),
+		transition(30*time.Minute, domain.StatusDegraded, domain.StatusHealthy),
+		transition(50*time.Minute, domain.StatusHealthy, domain.StatusDown),
+	}
+
+	seconds, outages := timeline(transitions, domain.StatusDown, start, start, start.Add(time.Hour))
+
+	want := StatusSeconds{Healthy: 30 * 60, Degraded: 5 * 60, Down: 25 * 60}
+	if seconds != want {
+		t.Errorf("expected %+v, got %+v", want, seconds)
+	}
+	if len(outages) != 2 {
+		t.Fatalf("expected 2 outages, got %+v", outages)
+	}
+	if outages[0].DurationSeconds != 15*60 || outages[0].Ongoing {
+		t.Errorf("unexpected first outage: %+v", outages[0])
+	}
+	if outages[1].DurationSeconds != 10*60 || !outages[1].Ongoing {
+		t.Errorf("expected an ongoing 10m outage, got %+v", outages[1])
+	}
+}
+
+func TestTimeline_StatusCarriesIntoPeriod(t *testing.T) {
+	transitions := []domain.HealthTransition{
+		transition(-time.Hour, domain.StatusHealthy, domain.StatusDown),
+		transition(15*time.Minute, domain.StatusDown, domain.StatusHealthy),
+	}
+
+	seconds, outages := timeline(transitions, domain.StatusHealthy, start.Add(-2*time.Hour), start, start.Add(time.Hour))
+
+	if seconds.Down != 15*60 || seconds.Healthy != 45*60 {
+		t.Errorf("expected 15m DOWN then 45m HEALTHY, got %+v", seconds)
+	}
+	if len(outages) != 1 || !outages[0].Start.Equal(start) || outages[0].Reason != "test" {
+		t.Errorf("expected the outage to be clipped to the period, got %+v", outages)
+	}
+}
+
+func TestTimeline_NoDataBeforeFirstSeen(t *testing.T) {
+	seconds, _ := timeline(nil, domain.StatusHealthy, start.Add(20*time.Minute), start, start.Add(time.Hour))
+
+	if seconds.NoData != 20*60 || seconds.Healthy != 40*60 {
+		t.Errorf("expected 20m without data then 40m HEALTHY, got %+v", seconds)
+	}
+}
+
+func TestBuild_ReportsOutagesAndCorridors(t *testing.T) {
+	clk := clock.NewFake(start)
+	calc := health.NewCalculatorWithClock(clk)
+
+	// PIX/BR healthy throughout; CARD/BR fails for 20 minutes in the middle
+	for minute := 0; minute < 60; minute++ {
+		clk.Set(start.Add(time.Duration(minute) * time.Minute))
+		for i := 0; i < 5; i++ {
+			calc.RecordTransaction(reportTx(clk.Now(), domain.MethodPIX, domain.ResultApproved))
+		}
+		result := domain.ResultApproved
+		if minute >= 20 && minute < 40 {
+			result = domain.ResultError
+		}
+		for i := 0; i < 10; i++ {
+			calc.RecordTransaction(reportTx(clk.Now(), domain.MethodCard, result))
+		}
+		calc.Reevaluate(clk.Now())
+	}
+	clk.Set(start.Add(time.Hour))
+
+	processors := []*domain.Processor{{ID: "processor_a", Name: "GlobalPay_BR"}, {ID: "processor_b"}}
+	rep := Build(calc, processors, start, start.Add(time.Hour))
+
+	if len(rep.Processors) != 2 {
+		t.Fatalf("expected registered and tracked processors, got %+v", rep.Processors)
+	}
+	a, b := rep.Processors[0], rep.Processors[1]
+	if a.Name != "GlobalPay_BR" || a.OutageCount == 0 || a.Availability >= 1 || a.Availability <= 0 {
+		t.Errorf("expected processor_a to show an outage, got %+v", a)
+	}
+	if a.TotalTransactions != 900 || len(a.Daily) != 1 || a.Daily[0].Date != "2024-02-20" {
+		t.Errorf("expected 900 transactions on one day, got %d %+v", a.TotalTransactions, a.Daily)
+	}
+	if b.SecondsInStatus.NoData != 3600 || b.Availability != 0 {
+		t.Errorf("expected processor_b never seen, got %+v", b)
+	}
+
+	if len(rep.WorstCorridors) != 2 || rep.WorstCorridors[0].PaymentMethod != domain.MethodCard {
+		t.Errorf("expected CARD/BR as worst corridor, got %+v", rep.WorstCorridors)
+	}
+}
+
+func TestWriteCSV_Tables(t *testing.T) {
+	rep := Report{Processors: []ProcessorReport{{
+		ProcessorID:  "processor_a",
+		Availability: 0.99,
+		Outages:      []Outage{{ProcessorID: "processor_a", Start: start, End: start.Add(time.Minute), DurationSeconds: 60, Reason: "High error/timeout rate (>50%)"}},
+		Daily:        []Day{{Date: "2024-02-20", TotalTransactions: 10}},
+	}}}
+
+	for table, rows := range map[string]int{TableProcessors: 2, TableOutages: 2, TableDaily: 2, TableCorridors: 1} {
+		var buf bytes.Buffer
+		if err := WriteCSV(&buf, rep, table); err != nil {
+			t.Fatalf("%s: unexpected error: %v", table, err)
+		}
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil || len(records) != rows {
+			t.Errorf("%s: expected %d rows, got %d (%v)", table, rows, len(records), err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, rep, "bogus"); err == nil || !strings.Contains(err.Error(), "unknown table") {
+		t.Errorf("expected unknown table error, got %v", err)
+	}
+}
+
+// Helper functions
+
+func transition(offset time.Duration, from, to domain.HealthStatus) domain.HealthTransition {
+	return domain.HealthTransition{
+		ProcessorID: "processor_a",
+		FromStatus:  from,
+		ToStatus:    to,
+		Timestamp:   start.Add(offset),
+		Reason:      "test",
+	}
+}
+
+func reportTx(at time.Time, method domain.PaymentMethod, result domain.TransactionResult) domain.Transaction {
+	return domain.Transaction{
+		ProcessorID:   "processor_a",
+		Timestamp:     at,
+		Result:        result,
+		PaymentMethod: method,
+		Country:       domain.CountryBR,
+	}
+}
@@ -0,0 +1,274 @@
+package routing
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// Attempt log and sticky assignment defaults
+const (
+	DefaultAttemptCapacity  = 10000          // Payments whose attempts are kept per engine
+	DefaultAttemptRetention = 24 * time.Hour // How long a payment's attempts are kept
+	DefaultStickyCapacity   = 10000          // Customers with a sticky processor per engine
+)
+
+// AttemptLog records the sequence of processors tried for each payment
+// (checkout or session), from transactions reported with a payment ID. It
+// is bounded by count and age like the decision log.
+type AttemptLog struct {
+	mu        sync.RWMutex
+	capacity  int
+	retention time.Duration
+	byID      map[string]*domain.PaymentAttempts
+	order     []string
+}
+
+// NewAttemptLog creates a log holding up to capacity payments for retention
+func NewAttemptLog(capacity int, retention time.Duration) *AttemptLog {
+	return &AttemptLog{
+		capacity:  capacity,
+		retention: retention,
+		byID:      make(map[string]*domain.PaymentAttempts),
+	}
+}
+
+// Record adds tx as an attempt of its payment. A transaction reported again
+// (same ID) updates its attempt instead of adding one.
+func (l *AttemptLog) Record(tx domain.Transaction) {
+	if tx.PaymentID == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p, exists := l.byID[tx.PaymentID]
+	if !exists {
+		p = &domain.PaymentAttempts{PaymentID: tx.PaymentID, FirstAttemptAt: tx.Timestamp}
+		l.byID[tx.PaymentID] = p
+		l.order = append(l.order, tx.PaymentID)
+	}
+
+	attempt := domain.Attempt{
+		TransactionID: tx.ID,
+		ProcessorID:   tx.ProcessorID,
+		DecisionID:    tx.DecisionID,
+		Result:        tx.Result,
+		Timestamp:     tx.Timestamp,
+	}
+	replaced := false
+	if tx.ID != "" {
+		for i := range p.Attempts {
+			if p.Attempts[i].TransactionID == tx.ID {
+				p.Attempts[i] = attempt
+				replaced = true
+				break
+			}
+		}
+	}
+	if !replaced {
+		p.Attempts = append(p.Attempts, attempt)
+	}
+	p.LastAttemptAt = tx.Timestamp
+	p.Approved = false
+	for _, a := range p.Attempts {
+		if a.Result == domain.ResultApproved {
+			p.Approved = true
+		}
+	}
+
+	l.evict(tx.Timestamp)
+}
+
+// evict drops payments beyond capacity or last attempted before retention.
+// Caller must hold l.mu.
+func (l *AttemptLog) evict(now time.Time) {
+	cutoff := now.Add(-l.retention)
+	n := 0
+	for n < len(l.order) {
+		oldest := l.byID[l.order[n]]
+		if len(l.order)-n <= l.capacity && !oldest.LastAttemptAt.Before(cutoff) {
+			break
+		}
+		delete(l.byID, l.order[n])
+		n++
+	}
+	l.order = l.order[n:]
+}
+
+// Get returns a copy of the attempts for a payment still retained at now
+func (l *AttemptLog) Get(paymentID string, now time.Time) (domain.PaymentAttempts, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	p, exists := l.byID[paymentID]
+	if !exists || p.LastAttemptAt.Before(now.Add(-l.retention)) {
+		return domain.PaymentAttempts{}, false
+	}
+	cp := *p
+	cp.Attempts = append([]domain.Attempt(nil), p.Attempts...)
+	return cp, true
+}
+
+// Tried returns the processors already attempted for a payment
+func (l *AttemptLog) Tried(paymentID string, now time.Time) []string {
+	if paymentID == "" {
+		return nil
+	}
+	p, found := l.Get(paymentID, now)
+	if !found {
+		return nil
+	}
+	tried := make([]string, 0, len(p.Attempts))
+	for _, a := range p.Attempts {
+		tried = append(tried, a.ProcessorID)
+	}
+	return tried
+}
+
+// Summary aggregates outcomes by attempt number over the retained payments
+func (l *AttemptLog) Summary(now time.Time) domain.AttemptSummary {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	summary := domain.AttemptSummary{ByAttempt: []domain.AttemptStats{}}
+	cutoff := now.Add(-l.retention)
+	for _, p := range l.byID {
+		if p.LastAttemptAt.Before(cutoff) {
+			continue
+		}
+		summary.Payments++
+		summary.Attempts += len(p.Attempts)
+		if p.Approved {
+			summary.Approved++
+			if p.Attempts[0].Result != domain.ResultApproved {
+				summary.Recovered++
+			}
+		}
+
+		for i, a := range p.Attempts {
+			for len(summary.ByAttempt) <= i {
+				summary.ByAttempt = append(summary.ByAttempt, domain.AttemptStats{Attempt: len(summary.ByAttempt) + 1})
+			}
+			s := &summary.ByAttempt[i]
+			s.Transactions++
+			if a.Result == domain.ResultApproved {
+				s.Approved++
+			}
+		}
+	}
+
+	for i := range summary.ByAttempt {
+		s := &summary.ByAttempt[i]
+		s.ApprovalRate = float64(s.Approved) / float64(s.Transactions)
+	}
+	if summary.Payments > 0 {
+		summary.AttemptsPerPayment = float64(summary.Attempts) / float64(summary.Payments)
+	}
+	return summary
+}
+
+// stickyAssignments remembers the processor that last approved a payment
+// for each customer, evicting the oldest customers beyond capacity
+type stickyAssignments struct {
+	mu         sync.RWMutex
+	capacity   int
+	byCustomer map[string]string
+	order      []string
+}
+
+func newStickyAssignments(capacity int) *stickyAssignments {
+	return &stickyAssignments{capacity: capacity, byCustomer: make(map[string]string)}
+}
+
+// record assigns the customer to the processor of an approved transaction
+func (s *stickyAssignments) record(tx domain.Transaction) {
+	if tx.CustomerID == "" || tx.Result != domain.ResultApproved {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.byCustomer[tx.CustomerID]; !exists {
+		s.order = append(s.order, tx.CustomerID)
+	}
+	s.byCustomer[tx.CustomerID] = tx.ProcessorID
+
+	if n := len(s.order) - s.capacity; n > 0 {
+		for _, id := range s.order[:n] {
+			delete(s.byCustomer, id)
+		}
+		s.order = s.order[n:]
+	}
+}
+
+func (s *stickyAssignments) get(customerID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	processorID, exists := s.byCustomer[customerID]
+	return processorID, exists
+}
+
+// promote moves processorID to the top of the rankings when it is ranked,
+// not DOWN, not saturated and at least as healthy as the current top, keeping
+// breakdowns in rank order. It reports whether the rankings changed.
+func promote(rankings []domain.ProcessorRank, breakdowns []domain.ScoreBreakdown, processorID string) bool {
+	at := -1
+	for i, r := range rankings {
+		if r.ProcessorID == processorID && r.Status != domain.StatusDown && !r.Saturated {
+			at = i
+		}
+	}
+	if at < 0 || impairment(rankings[at].Status) > impairment(rankings[0].Status) {
+		return false
+	}
+
+	sticky, breakdown := rankings[at], breakdowns[at]
+	copy(rankings[1:at+1], rankings[:at])
+	copy(breakdowns[1:at+1], breakdowns[:at])
+	rankings[0], breakdowns[0] = sticky, breakdown
+
+	for i := range rankings {
+		rankings[i].Rank = i + 1
+		rankings[i].Recommended = i == 0
+	}
+	rankings[0].Reason = "Sticky assignment - last approved this customer"
+	if at > 0 && rankings[1].Status != domain.StatusDown {
+		rankings[1].Reason = "Best scored option - fallback to sticky processor"
+	}
+	return true
+}
+
+// impairment orders statuses for sticky promotion: HEALTHY, then any
+// status with impaired or outdated rates, then DOWN
+func impairment(status domain.HealthStatus) int {
+	switch status {
+	case domain.StatusHealthy:
+		return 0
+	case domain.StatusDown:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// attempted reports whether processorID is in the sorted tried list
+func attempted(tried []string, processorID string) bool {
+	i := sort.SearchStrings(tried, processorID)
+	return i < len(tried) && tried[i] == processorID
+}
+
+// distinct removes duplicates from a sorted list
+func distinct(sorted []string) []string {
+	result := sorted[:0:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestAttemptLog_RecordsSequence(t *testing.T) {
+	log := NewAttemptLog(10, time.Hour)
+	now := time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC)
+
+	log.Record(attemptTx("tx-1", "pay-1", "processor_a", domain.ResultTimeout, now))
+	log.Record(attemptTx("tx-2", "pay-1", "processor_b", domain.ResultApproved, now.Add(time.Second)))
+	log.Record(attemptTx("tx-3", "", "processor_a", domain.ResultApproved, now)) // no payment ID
+
+	got, found := log.Get("pay-1", now)
+	if !found || len(got.Attempts) != 2 || !got.Approved {
+		t.Fatalf("expected 2 attempts ending approved, got %+v", got)
+	}
+	if got.Attempts[0].ProcessorID != "processor_a" || got.Attempts[1].ProcessorID != "processor_b" {
+		t.Errorf("unexpected order: %+v", got.Attempts)
+	}
+
+	// A timeout later resolved as approved updates the attempt in place
+	log.Record(attemptTx("tx-1", "pay-1", "processor_a", domain.ResultApproved, now.Add(2*time.Second)))
+	got, _ = log.Get("pay-1", now)
+	if len(got.Attempts) != 2 || got.Attempts[0].Result != domain.ResultApproved {
+		t.Errorf("expected first attempt corrected, got %+v", got.Attempts)
+	}
+
+	if _, found := log.Get("pay-1", now.Add(2*time.Hour)); found {
+		t.Error("expected attempts to expire after the retention period")
+	}
+}
+
+func TestAttemptLog_Summary(t *testing.T) {
+	log := NewAttemptLog(10, time.Hour)
+	now := time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC)
+
+	log.Record(attemptTx("tx-1", "pay-1", "processor_a", domain.ResultApproved, now))
+	log.Record(attemptTx("tx-2", "pay-2", "processor_a", domain.ResultDeclined, now))
+	log.Record(attemptTx("tx-3", "pay-2", "processor_b", domain.ResultApproved, now))
+	log.Record(attemptTx("tx-4", "pay-3", "processor_a", domain.ResultError, now))
+
+	s := log.Summary(now)
+	if s.Payments != 3 || s.Attempts != 4 || s.Approved != 2 || s.Recovered != 1 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+	if len(s.ByAttempt) != 2 || s.ByAttempt[0].Transactions != 3 || s.ByAttempt[1].ApprovalRate != 1 {
+		t.Errorf("unexpected by-attempt stats: %+v", s.ByAttempt)
+	}
+}
+
+func TestStickyAssignments_EvictsOldestCustomers(t *testing.T) {
+	s := newStickyAssignments(2)
+	now := time.Now()
+
+	for i, customer := range []string{"cus-1", "cus-2", "cus-3"} {
+		tx := attemptTx("", "", "processor_a", domain.ResultApproved, now)
+		tx.CustomerID = customer
+		if i == 1 {
+			tx.Result = domain.ResultDeclined // only approvals assign
+		}
+		s.record(tx)
+	}
+
+	if _, assigned := s.get("cus-2"); assigned {
+		t.Error("expected no assignment after a decline")
+	}
+	if p, assigned := s.get("cus-3"); !assigned || p != "processor_a" {
+		t.Errorf("expected cus-3 on processor_a, got %q", p)
+	}
+}
+
+func TestPromote_NeverOutranksAHealthierLeader(t *testing.T) {
+	cases := []struct {
+		top, sticky domain.HealthStatus
+		promoted    bool
+	}{
+		{domain.StatusHealthy, domain.StatusHealthy, true},
+		{domain.StatusHealthy, domain.StatusDegraded, false},
+		{domain.StatusHealthy, domain.StatusStale, false},
+		{domain.StatusHealthy, domain.StatusUnknown, false},
+		{domain.StatusDegraded, domain.StatusStale, true},
+		{domain.StatusStale, domain.StatusHealthy, true},
+		{domain.StatusDegraded, domain.StatusDown, false},
+	}
+	for _, c := range cases {
+		rankings := []domain.ProcessorRank{
+			{ProcessorID: "processor_a", Rank: 1, Status: c.top, Recommended: true},
+			{ProcessorID: "processor_b", Rank: 2, Status: c.sticky},
+		}
+		breakdowns := []domain.ScoreBreakdown{{ProcessorID: "processor_a"}, {ProcessorID: "processor_b"}}
+
+		got := promote(rankings, breakdowns, "processor_b")
+		if got != c.promoted || (rankings[0].ProcessorID == "processor_b") != c.promoted {
+			t.Errorf("%s sticky under %s top: expected promoted=%v, got %v with %+v", c.sticky, c.top, c.promoted, got, rankings)
+		}
+	}
+}
+
+// Helper functions
+
+func attemptTx(id, paymentID, processorID string, result domain.TransactionResult, at time.Time) domain.Transaction {
+	return domain.Transaction{
+		ID:          id,
+		PaymentID:   paymentID,
+		ProcessorID: processorID,
+		Result:      result,
+		Timestamp:   at,
+	}
+}
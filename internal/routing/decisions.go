@@ -0,0 +1,83 @@
+package routing
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// Decision log defaults
+const (
+	DefaultDecisionCapacity  = 10000          // Decisions kept per engine
+	DefaultDecisionRetention = 24 * time.Hour // How long a decision can be looked up
+)
+
+var decisionCounter int64
+
+// newDecisionID returns a unique decision ID
+func newDecisionID(now time.Time) string {
+	return fmt.Sprintf("dec-%d-%d", now.UnixNano(), atomic.AddInt64(&decisionCounter, 1))
+}
+
+// DecisionLog retains recent routing decisions so they can be explained
+// after the fact. It is bounded by count and by age; the oldest decisions
+// are evicted first. Stored recommendations must not be modified.
+type DecisionLog struct {
+	mu        sync.RWMutex
+	capacity  int
+	retention time.Duration
+	byID      map[string]*domain.RoutingRecommendation
+	order     []string
+}
+
+// NewDecisionLog creates a log holding up to capacity decisions for retention
+func NewDecisionLog(capacity int, retention time.Duration) *DecisionLog {
+	return &DecisionLog{
+		capacity:  capacity,
+		retention: retention,
+		byID:      make(map[string]*domain.RoutingRecommendation),
+	}
+}
+
+// Add records a decision, evicting the oldest beyond capacity or retention
+func (l *DecisionLog) Add(rec *domain.RoutingRecommendation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.byID[rec.DecisionID] = rec
+	l.order = append(l.order, rec.DecisionID)
+
+	cutoff := rec.Timestamp.Add(-l.retention)
+	n := 0
+	for n < len(l.order) {
+		oldest := l.byID[l.order[n]]
+		if len(l.order)-n <= l.capacity && !oldest.Timestamp.Before(cutoff) {
+			break
+		}
+		delete(l.byID, l.order[n])
+		n++
+	}
+	l.order = l.order[n:]
+}
+
+// Get returns the decision with id if it is still retained at now
+func (l *DecisionLog) Get(id string, now time.Time) (*domain.RoutingRecommendation, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	rec, exists := l.byID[id]
+	if !exists || rec.Timestamp.Before(now.Add(-l.retention)) {
+		return nil, false
+	}
+	return rec, true
+}
+
+// Len returns the number of retained decisions
+func (l *DecisionLog) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.byID)
+}
@@ -0,0 +1,43 @@
+package routing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestDecisionLog_EvictsBeyondCapacity(t *testing.T) {
+	log := NewDecisionLog(3, time.Hour)
+	now := time.Now()
+
+	for i := 1; i <= 5; i++ {
+		log.Add(&domain.RoutingRecommendation{DecisionID: fmt.Sprintf("dec-%d", i), Timestamp: now})
+	}
+
+	if log.Len() != 3 {
+		t.Fatalf("expected 3 decisions, got %d", log.Len())
+	}
+	if _, found := log.Get("dec-2", now); found {
+		t.Error("expected dec-2 to be evicted")
+	}
+	if _, found := log.Get("dec-5", now); !found {
+		t.Error("expected dec-5 to be retained")
+	}
+}
+
+func TestDecisionLog_EvictsExpired(t *testing.T) {
+	log := NewDecisionLog(100, time.Hour)
+	start := time.Now()
+
+	log.Add(&domain.RoutingRecommendation{DecisionID: "old", Timestamp: start})
+	log.Add(&domain.RoutingRecommendation{DecisionID: "new", Timestamp: start.Add(2 * time.Hour)})
+
+	if log.Len() != 1 {
+		t.Errorf("expected the old decision to be dropped, got %d decisions", log.Len())
+	}
+	if _, found := log.Get("new", start.Add(2*time.Hour)); !found {
+		t.Error("expected the new decision to be retained")
+	}
+}
@@ -1,29 +1,67 @@
 package routing
 
 import (
+	"context"
+	"fmt"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/yuno/techcart-failover/internal/domain"
 	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/tracing"
 )
 
 // Engine handles intelligent routing decisions
 type Engine struct {
 	mu         sync.RWMutex
 	calculator *health.Calculator
+	network    *health.Calculator
+	strategy   Strategy
 	processors map[string]*domain.Processor
+	decisions  *DecisionLog
+	volume     *Volume
+	attempts   *AttemptLog
+	sticky     *stickyAssignments
+	load       *Load
 }
 
-// NewEngine creates a new routing engine
+// NewEngine creates a new routing engine. It shares the calculator's clock.
 func NewEngine(calc *health.Calculator) *Engine {
 	return &Engine{
 		calculator: calc,
+		strategy:   DefaultStrategy(),
 		processors: make(map[string]*domain.Processor),
+		decisions:  NewDecisionLog(DefaultDecisionCapacity, DefaultDecisionRetention),
+		volume:     NewVolume(),
+		attempts:   NewAttemptLog(DefaultAttemptCapacity, DefaultAttemptRetention),
+		sticky:     newStickyAssignments(DefaultStickyCapacity),
+		load:       NewLoad(),
 	}
 }
 
+// SetNetworkView enables blending a shared cross-tenant health view
+// into rankings for processors with little local data. nil disables it.
+func (e *Engine) SetNetworkView(network *health.Calculator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.network = network
+}
+
+// SetStrategy replaces the scoring weights
+func (e *Engine) SetStrategy(s Strategy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.strategy = s
+}
+
+// Strategy returns the scoring weights in use
+func (e *Engine) Strategy() Strategy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.strategy
+}
+
 // RegisterProcessor adds a processor configuration
 func (e *Engine) RegisterProcessor(p *domain.Processor) {
 	e.mu.Lock()
@@ -43,36 +81,287 @@ func (e *Engine) GetProcessors() []*domain.Processor {
 	return result
 }
 
-// Recommend returns ranked processors for a transaction scenario
-func (e *Engine) Recommend(method domain.PaymentMethod, country domain.Country, amount float64) *domain.RoutingRecommendation {
+// Corridors returns the payment method and country pairs served by at
+// least one registered processor, sorted
+func (e *Engine) Corridors() []domain.Payment {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	// Find candidates that support method + country
-	candidates := e.findCandidates(method, country)
+	type corridor struct {
+		method  domain.PaymentMethod
+		country domain.Country
+	}
+	seen := make(map[corridor]bool)
+	var result []domain.Payment
+	for _, p := range e.processors {
+		for _, m := range p.PaymentMethods {
+			for _, c := range p.Countries {
+				if !seen[corridor{m, c}] {
+					seen[corridor{m, c}] = true
+					result = append(result, domain.Payment{PaymentMethod: m, Country: c})
+				}
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].PaymentMethod != result[j].PaymentMethod {
+			return result[i].PaymentMethod < result[j].PaymentMethod
+		}
+		return result[i].Country < result[j].Country
+	})
+	return result
+}
+
+// Recommend returns ranked processors for a transaction scenario in the
+// country's local currency
+func (e *Engine) Recommend(method domain.PaymentMethod, country domain.Country, amount float64) *domain.RoutingRecommendation {
+	return e.RecommendPayment(domain.Payment{PaymentMethod: method, Country: country, Amount: amount})
+}
+
+// RecommendPayment returns ranked processors for a payment. The decision
+// and its explanation are kept in the decision log.
+func (e *Engine) RecommendPayment(p domain.Payment) *domain.RoutingRecommendation {
+	return e.RecommendPaymentContext(context.Background(), p)
+}
+
+// RecommendPaymentContext is RecommendPayment traced as a child of the span
+// in ctx, with filtering, scoring and the decision log as their own spans
+func (e *Engine) RecommendPaymentContext(ctx context.Context, p domain.Payment) *domain.RoutingRecommendation {
+	ctx, span := tracing.Start(ctx, "routing.Recommend")
+	defer span.End()
+	span.SetAttributes("tenant", e.calculator.TenantID(), "payment_method", string(p.PaymentMethod), "country", string(p.Country))
+
+	rec := e.decide(ctx, p)
+	rec.DecisionID = newDecisionID(rec.Timestamp)
+	if len(rec.Recommendations) > 0 && rec.Recommendations[0].Recommended {
+		e.load.Dispatch(rec.Recommendations[0].ProcessorID, rec.DecisionID, rec.Timestamp)
+	}
+
+	e.mu.RLock()
+	decisions := e.decisions
+	e.mu.RUnlock()
+	_, store := tracing.Start(ctx, "routing.decisions.Add")
+	decisions.Add(rec)
+	store.End()
+
+	if len(rec.Recommendations) > 0 {
+		span.SetAttributes("decision_id", rec.DecisionID, "processor", rec.Recommendations[0].ProcessorID)
+	}
+	return rec
+}
+
+// Preview ranks processors for a payment like RecommendPayment, but without
+// a decision ID: nothing is logged and no load is attributed
+func (e *Engine) Preview(p domain.Payment) *domain.RoutingRecommendation {
+	return e.decide(context.Background(), p)
+}
+
+// decide ranks processors for a payment and explains the result
+func (e *Engine) decide(ctx context.Context, p domain.Payment) *domain.RoutingRecommendation {
+	if p.Currency == "" {
+		p.Currency = domain.DefaultCurrency(p.Country)
+	}
+	p.BIN = domain.TruncateBIN(p.BIN)
+	if p.CardBrand == "" {
+		p.CardBrand = domain.CardBrandFromBIN(p.BIN)
+	}
+	now := e.calculator.Clock().Now()
+
+	// Processors already tried for this payment, sorted for lookup
+	tried := append(append([]string(nil), p.Attempted...), e.attempts.Tried(p.PaymentID, now)...)
+	sort.Strings(tried)
+	p.Attempted = tried
+
+	e.mu.RLock()
+
+	// Find candidates that support method + country, currency and amount
+	_, filter := tracing.Start(ctx, "routing.filter")
+	candidates, filtered := e.findCandidates(p, now)
+	filter.SetAttributes("processors", len(e.processors), "candidates", len(candidates))
+	filter.End()
 
 	// Rank by health
-	rankings := e.rankProcessors(candidates)
+	_, score := tracing.Start(ctx, "routing.score")
+	rankings, scores := e.rankProcessors(candidates, p, now)
+	score.End()
+	e.mu.RUnlock()
+
+	// Returning customers stay on the processor that last approved them
+	var sticky string
+	if p.Sticky && p.CustomerID != "" {
+		if processorID, assigned := e.sticky.get(p.CustomerID); assigned && promote(rankings, scores, processorID) {
+			sticky = processorID
+		}
+	}
+
+	attempt := 0
+	if p.PaymentID != "" || len(tried) > 0 {
+		attempt = len(distinct(tried)) + 1
+	}
+
+	ids := make([]string, len(candidates))
+	for i, p := range candidates {
+		ids[i] = p.ID
+	}
+	sort.Strings(ids)
 
 	return &domain.RoutingRecommendation{
+		TenantID:        e.calculator.TenantID(),
 		Recommendations: rankings,
-		PaymentMethod:   method,
-		Country:         country,
-		Timestamp:       time.Now(),
+		PaymentMethod:   p.PaymentMethod,
+		Country:         p.Country,
+		Amount:          p.Amount,
+		Currency:        p.Currency,
+		BIN:             p.BIN,
+		CardBrand:       p.CardBrand,
+		PaymentID:       p.PaymentID,
+		Attempt:         attempt,
+		Timestamp:       now,
+		Explanation: &domain.DecisionExplanation{
+			Candidates: ids,
+			Filtered:   filtered,
+			Scores:     scores,
+			Sticky:     sticky,
+		},
 	}
 }
 
-// findCandidates returns processors supporting the method and country
-func (e *Engine) findCandidates(method domain.PaymentMethod, country domain.Country) []*domain.Processor {
+// Decision looks up a past recommendation by its decision ID
+func (e *Engine) Decision(id string) (*domain.RoutingRecommendation, bool) {
+	e.mu.RLock()
+	decisions := e.decisions
+	e.mu.RUnlock()
+	return decisions.Get(id, e.calculator.Clock().Now())
+}
+
+// SetDecisionLog replaces the decision log, e.g. to change its bounds
+func (e *Engine) SetDecisionLog(l *DecisionLog) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.decisions = l
+}
+
+// RecordTransaction updates routing state from a reported transaction:
+// volume caps, load, the payment's attempts and the customer's sticky
+// processor
+func (e *Engine) RecordTransaction(tx domain.Transaction) {
+	now := e.calculator.Clock().Now()
+	e.volume.Record(tx, now)
+	e.load.Complete(tx, now)
+	e.attempts.Record(tx)
+	e.sticky.record(tx)
+}
+
+// Loads returns the live load of every registered processor, sorted by ID
+func (e *Engine) Loads() []domain.ProcessorLoad {
+	now := e.calculator.Clock().Now()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]domain.ProcessorLoad, 0, len(e.processors))
+	for _, p := range e.processors {
+		load := e.load.Current(p, now)
+		load.Saturated = load.Utilization >= e.strategy.SaturationAt
+		result = append(result, load)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ProcessorID < result[j].ProcessorID })
+	return result
+}
+
+// PaymentAttempts returns the attempts reported for a payment
+func (e *Engine) PaymentAttempts(paymentID string) (domain.PaymentAttempts, bool) {
+	return e.attempts.Get(paymentID, e.calculator.Clock().Now())
+}
+
+// AttemptSummary aggregates retries across recent payments
+func (e *Engine) AttemptSummary() domain.AttemptSummary {
+	return e.attempts.Summary(e.calculator.Clock().Now())
+}
+
+// findCandidates returns processors that can take the payment, and the
+// others with the reason they were excluded (sorted by ID)
+func (e *Engine) findCandidates(payment domain.Payment, now time.Time) ([]*domain.Processor, []domain.FilteredProcessor) {
 	var candidates []*domain.Processor
+	filtered := []domain.FilteredProcessor{}
 
 	for _, p := range e.processors {
-		if e.supportsMethod(p, method) && e.supportsCountry(p, country) {
-			candidates = append(candidates, p)
+		if reason := e.exclusion(p, payment, now); reason != "" {
+			filtered = append(filtered, domain.FilteredProcessor{ProcessorID: p.ID, Reason: reason})
+			continue
 		}
+		candidates = append(candidates, p)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].ProcessorID < filtered[j].ProcessorID })
+	return candidates, filtered
+}
+
+// exclusion explains why p cannot take the payment ("" if it can)
+func (e *Engine) exclusion(p *domain.Processor, payment domain.Payment, now time.Time) string {
+	if !e.supportsMethod(p, payment.PaymentMethod) {
+		return fmt.Sprintf("Payment method %s not supported", payment.PaymentMethod)
+	}
+	if !e.supportsCountry(p, payment.Country) {
+		return fmt.Sprintf("Country %s not supported", payment.Country)
+	}
+	if attempted(payment.Attempted, p.ID) {
+		return "Already attempted for this payment"
+	}
+	if !e.supportsCurrency(p, payment.Currency) {
+		return fmt.Sprintf("Currency %s not supported", payment.Currency)
+	}
+
+	limit, limited := p.Limits[payment.Currency]
+	if !limited {
+		return ""
+	}
+	if payment.Amount > 0 && limit.MinAmount > 0 && payment.Amount < limit.MinAmount {
+		return fmt.Sprintf("Amount %g below minimum %g %s", payment.Amount, limit.MinAmount, payment.Currency)
+	}
+	if limit.MaxAmount > 0 && payment.Amount > limit.MaxAmount {
+		return fmt.Sprintf("Amount %g above maximum %g %s", payment.Amount, limit.MaxAmount, payment.Currency)
+	}
+
+	daily, monthly := e.volume.Usage(p.ID, payment.Currency, now)
+	if limit.DailyVolumeCap > 0 && daily+payment.Amount > limit.DailyVolumeCap {
+		return fmt.Sprintf("Daily volume cap reached (%.0f of %.0f %s)", daily, limit.DailyVolumeCap, payment.Currency)
+	}
+	if limit.MonthlyVolumeCap > 0 && monthly+payment.Amount > limit.MonthlyVolumeCap {
+		return fmt.Sprintf("Monthly volume cap reached (%.0f of %.0f %s)", monthly, limit.MonthlyVolumeCap, payment.Currency)
 	}
+	return ""
+}
 
-	return candidates
+func (e *Engine) supportsCurrency(p *domain.Processor, currency string) bool {
+	if len(p.Currencies) == 0 || currency == "" {
+		return true
+	}
+	for _, c := range p.Currencies {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
+
+// utilization is the share of the tightest volume cap the payment would
+// bring the processor to (0 without caps)
+func (e *Engine) utilization(p *domain.Processor, payment domain.Payment, now time.Time) float64 {
+	limit, limited := p.Limits[payment.Currency]
+	if !limited {
+		return 0
+	}
+	daily, monthly := e.volume.Usage(p.ID, payment.Currency, now)
+
+	u := 0.0
+	if limit.DailyVolumeCap > 0 {
+		u = max(u, (daily+payment.Amount)/limit.DailyVolumeCap)
+	}
+	if limit.MonthlyVolumeCap > 0 {
+		u = max(u, (monthly+payment.Amount)/limit.MonthlyVolumeCap)
+	}
+	return u
 }
 
 func (e *Engine) supportsMethod(p *domain.Processor, method domain.PaymentMethod) bool {
@@ -93,73 +382,180 @@ func (e *Engine) supportsCountry(p *domain.Processor, country domain.Country) bo
 	return false
 }
 
-// rankProcessors ranks candidates by health status and auth rate
-func (e *Engine) rankProcessors(processors []*domain.Processor) []domain.ProcessorRank {
+// rankProcessors ranks candidates by health status and auth rate, returning
+// the score breakdowns in rank order
+func (e *Engine) rankProcessors(processors []*domain.Processor, payment domain.Payment, now time.Time) ([]domain.ProcessorRank, []domain.ScoreBreakdown) {
 	if len(processors) == 0 {
-		return nil
+		return nil, []domain.ScoreBreakdown{}
 	}
 
 	type scored struct {
 		processor *domain.Processor
 		health    *domain.ProcessorHealth
 		score     float64
+		breakdown domain.ScoreBreakdown
+		load      float64
+		saturated bool
 	}
 
 	scores := make([]scored, len(processors))
+	spare := false // a HEALTHY candidate has room below saturation
 	for i, p := range processors {
-		h := e.calculator.GetHealth(p.ID)
+		h, seg := e.healthFor(p.ID, payment)
+		b := e.calculateScore(h, e.utilization(p, payment, now))
+		b.AmountBand, b.CardSegment = seg.band, seg.card
+
+		load := e.load.Current(p, now).Utilization
+		b.CapacityUtilization = load
+		saturated := load >= e.strategy.SaturationAt && h.Status != domain.StatusDown
+		spare = spare || (h.Status == domain.StatusHealthy && !saturated)
+
 		scores[i] = scored{
 			processor: p,
 			health:    h,
-			score:     e.calculateScore(h),
+			score:     b.Total,
+			breakdown: b,
+			load:      load,
+			saturated: saturated,
 		}
 	}
 
-	// Sort by score descending
+	// Sort by score descending; ties go to the lower ID so rankings don't
+	// depend on map iteration order
 	sort.Slice(scores, func(i, j int) bool {
-		return scores[i].score > scores[j].score
+		if scores[i].score != scores[j].score {
+			return scores[i].score > scores[j].score
+		}
+		return scores[i].processor.ID < scores[j].processor.ID
 	})
 
+	// Spill traffic over from processors near capacity, behind those with
+	// room but ahead of DOWN ones, while a HEALTHY one has spare capacity
+	if spare {
+		group := func(s scored) int {
+			switch {
+			case s.health.Status == domain.StatusDown:
+				return 2
+			case s.saturated:
+				return 1
+			default:
+				return 0
+			}
+		}
+		sort.SliceStable(scores, func(i, j int) bool { return group(scores[i]) < group(scores[j]) })
+	}
+
 	// Build rankings
 	rankings := make([]domain.ProcessorRank, len(scores))
+	breakdowns := make([]domain.ScoreBreakdown, len(scores))
 	for i, s := range scores {
+		breakdowns[i] = s.breakdown
 		// Only recommend if HEALTHY or DEGRADED and first place
 		recommended := i == 0 && s.health.Status != domain.StatusDown
 
+		reason := e.reasonForRank(s.health, i, recommended)
+		if s.saturated {
+			reason = saturationReason(s.load, spare)
+		}
+
 		rankings[i] = domain.ProcessorRank{
 			ProcessorID:       s.processor.ID,
 			Rank:              i + 1,
 			Status:            s.health.Status,
 			AuthorizationRate: s.health.AuthorizationRate,
+			Score:             s.score,
 			Recommended:       recommended,
-			Reason:            e.reasonForRank(s.health, i, recommended),
+			Saturated:         s.saturated,
+			Reason:            reason,
 		}
 	}
 
-	return rankings
+	return rankings, breakdowns
+}
+
+// segment is the slice of a processor's health a payment is scored on
+type segment struct {
+	band *domain.BandHealth
+	card *domain.CardHealth
+}
+
+// healthFor returns local health for the payment's most specific segment
+// with enough data (BIN range, card brand, amount band, else aggregate),
+// blended with the network view when enabled
+func (e *Engine) healthFor(processorID string, payment domain.Payment) (*domain.ProcessorHealth, segment) {
+	h, seg := forPayment(e.calculator, processorID, payment)
+	if e.network == nil {
+		return h, seg
+	}
+
+	n, _ := forPayment(e.network, processorID, payment)
+	return health.Blend(h, n), seg
 }
 
-// calculateScore computes routing score for a processor
-func (e *Engine) calculateScore(h *domain.ProcessorHealth) float64 {
+func forPayment(calc *health.Calculator, processorID string, payment domain.Payment) (*domain.ProcessorHealth, segment) {
+	h := calc.GetHealth(processorID)
+	minTransactions := calc.Policy().MinTransactions
+
+	if payment.PaymentMethod == domain.MethodCard && (payment.BIN != "" || payment.CardBrand != "") {
+		if view, card := health.ForCard(h, payment.BIN, payment.CardBrand, minTransactions); card != nil {
+			return view, segment{card: card}
+		}
+	}
+	view, band := health.ForAmount(h, payment.Currency, payment.Amount, minTransactions)
+	return view, segment{band: band}
+}
+
+// calculateScore computes routing score for a processor, component by
+// component. utilization is how close the payment brings it to a volume cap.
+func (e *Engine) calculateScore(h *domain.ProcessorHealth, utilization float64) domain.ScoreBreakdown {
 	// Base score from auth rate (0-100)
-	score := h.AuthorizationRate * 100
+	base := h.AuthorizationRate * 100
+	score := base
 
 	// Penalize by status
+	st := e.strategy
 	switch h.Status {
 	case domain.StatusDown:
 		score = 0 // Never route to DOWN
 	case domain.StatusDegraded:
-		score *= 0.5 // 50% penalty
+		score *= st.DegradedPenalty // 50% penalty by default
+	case domain.StatusStale:
+		score *= st.StalePenalty // Rates may be outdated
+	case domain.StatusUnknown:
+		score = st.UnknownScore // No recent data: below any HEALTHY processor
 	case domain.StatusHealthy:
 		// No penalty
 	}
 
+	b := domain.ScoreBreakdown{
+		ProcessorID:      h.ProcessorID,
+		AuthRateScore:    base,
+		StatusAdjustment: score - base,
+		Health:           *h,
+	}
+
 	// Small bonus for more history (confidence)
-	if h.TotalTransactions > 30 {
-		score += 5
+	if h.TotalTransactions > st.ConfidenceMin {
+		b.ConfidenceBonus = st.ConfidenceBonus
 	}
 
-	return score
+	// Taper the score to zero between the warning level and the cap
+	b.VolumeUtilization = utilization
+	if utilization > st.VolumeCapWarn {
+		keep := max(0, (1-utilization)/(1-st.VolumeCapWarn))
+		b.VolumeCapAdjustment = -(score + b.ConfidenceBonus) * (1 - keep)
+	}
+
+	b.Total = score + b.ConfidenceBonus + b.VolumeCapAdjustment
+	return b
+}
+
+// saturationReason explains the rank of a processor near capacity
+func saturationReason(load float64, spilled bool) string {
+	if spilled {
+		return fmt.Sprintf("Near capacity (%.0f%% used) - traffic spilled over to processors with room", load*100)
+	}
+	return fmt.Sprintf("Near capacity (%.0f%% used) - no healthy processor with spare capacity", load*100)
 }
 
 // reasonForRank explains the ranking
@@ -173,6 +569,12 @@ func (e *Engine) reasonForRank(h *domain.ProcessorHealth, rank int, recommended
 		}
 		return "DEGRADED - available as fallback"
 	}
+	if h.Status == domain.StatusStale || h.Status == domain.StatusUnknown {
+		if recommended {
+			return "No recent data - best available, monitor closely"
+		}
+		return "No recent data - available as fallback"
+	}
 	if recommended {
 		return "Best option - highest authorization rate"
 	}
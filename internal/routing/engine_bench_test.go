@@ -0,0 +1,41 @@
+package routing
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+)
+
+// Routing reads under concurrent ingest: 1 in 4 operations records a transaction
+func BenchmarkEngine_RecommendUnderIngest(b *testing.B) {
+	calc := health.NewCalculator()
+	engine := NewEngine(calc)
+	ids := []string{"processor_a", "processor_b", "processor_c"}
+	for _, id := range ids {
+		engine.RegisterProcessor(&domain.Processor{
+			ID:             id,
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+		})
+	}
+	var seq int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddInt64(&seq, 1)
+			if i%4 == 0 {
+				calc.RecordTransaction(domain.Transaction{
+					ProcessorID: ids[i%int64(len(ids))],
+					Result:      domain.ResultApproved,
+					Timestamp:   time.Now(),
+				})
+				continue
+			}
+			engine.Recommend(domain.MethodPIX, domain.CountryBR, 100)
+		}
+	})
+}
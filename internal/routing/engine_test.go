@@ -1,11 +1,18 @@
 package routing
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/yuno/techcart-failover/internal/clock"
 	"github.com/yuno/techcart-failover/internal/domain"
 	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/tracing"
 )
 
 func tx(processorID string, result domain.TransactionResult) domain.Transaction {
@@ -182,3 +189,542 @@ func TestEngine_AllDown_NoRecommended(t *testing.T) {
 		}
 	}
 }
+
+func TestEngine_TiesBrokenByID(t *testing.T) {
+	calc := health.NewCalculator()
+	engine := NewEngine(calc)
+
+	for _, id := range []string{"processor_c", "processor_a", "processor_b"} {
+		engine.RegisterProcessor(&domain.Processor{
+			ID:             id,
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+		})
+	}
+
+	// No data: every processor scores the same
+	for i := 0; i < 20; i++ {
+		rec := engine.Recommend(domain.MethodPIX, domain.CountryBR, 100)
+		for j, want := range []string{"processor_a", "processor_b", "processor_c"} {
+			if got := rec.Recommendations[j].ProcessorID; got != want {
+				t.Fatalf("expected rank %d to be %s, got %s", j+1, want, got)
+			}
+		}
+	}
+}
+
+func TestEngine_ExplainsDecision(t *testing.T) {
+	calc := health.NewCalculator()
+	engine := NewEngine(calc)
+
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_a",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+	})
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_b",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodCard},
+	})
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_c",
+		Countries:      []domain.Country{domain.CountryMX},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+	})
+
+	// 40 transactions at 75% auth: above the confidence threshold
+	for i := 0; i < 40; i++ {
+		result := domain.ResultApproved
+		if i%4 == 0 {
+			result = domain.ResultDeclined
+		}
+		calc.RecordTransaction(tx("processor_a", result))
+	}
+
+	rec := engine.Recommend(domain.MethodPIX, domain.CountryBR, 100)
+	if rec.DecisionID == "" {
+		t.Fatal("expected a decision ID")
+	}
+
+	exp := rec.Explanation
+	if len(exp.Candidates) != 1 || exp.Candidates[0] != "processor_a" {
+		t.Errorf("expected only processor_a as candidate, got %v", exp.Candidates)
+	}
+	if len(exp.Filtered) != 2 {
+		t.Fatalf("expected 2 filtered processors, got %v", exp.Filtered)
+	}
+	if exp.Filtered[0].ProcessorID != "processor_b" || exp.Filtered[0].Reason != "Payment method PIX not supported" {
+		t.Errorf("unexpected filter reason: %+v", exp.Filtered[0])
+	}
+	if exp.Filtered[1].ProcessorID != "processor_c" || exp.Filtered[1].Reason != "Country BR not supported" {
+		t.Errorf("unexpected filter reason: %+v", exp.Filtered[1])
+	}
+
+	score := exp.Scores[0]
+	if score.AuthRateScore != 75 || score.StatusAdjustment != 0 || score.ConfidenceBonus != 5 || score.Total != 80 {
+		t.Errorf("unexpected breakdown: %+v", score)
+	}
+	if score.Health.TotalTransactions != 40 {
+		t.Errorf("expected health snapshot with 40 transactions, got %d", score.Health.TotalTransactions)
+	}
+	if rec.Recommendations[0].Score != score.Total {
+		t.Errorf("expected rank score %.1f to match breakdown total %.1f", rec.Recommendations[0].Score, score.Total)
+	}
+}
+
+func TestEngine_DecisionLookup(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC))
+	engine := NewEngine(health.NewCalculatorWithClock(clk))
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_a",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+	})
+
+	rec := engine.Recommend(domain.MethodPIX, domain.CountryBR, 100)
+	got, found := engine.Decision(rec.DecisionID)
+	if !found || got.Recommendations[0].ProcessorID != "processor_a" {
+		t.Fatalf("expected to find decision %s", rec.DecisionID)
+	}
+
+	clk.Advance(DefaultDecisionRetention + time.Second)
+	if _, found := engine.Decision(rec.DecisionID); found {
+		t.Error("expected decision to expire after the retention period")
+	}
+}
+
+func TestEngine_FilterByCurrencyAndAmount(t *testing.T) {
+	calc := health.NewCalculator()
+	engine := NewEngine(calc)
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_a",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodCard},
+		Currencies:     []string{"BRL"},
+		Limits:         map[string]domain.AmountLimit{"BRL": {MinAmount: 10, MaxAmount: 5000}},
+	})
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_b",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodCard},
+	})
+
+	tests := []struct {
+		name     string
+		payment  domain.Payment
+		filtered string
+	}{
+		{"within limits", domain.Payment{Amount: 100}, ""},
+		{"unknown amount", domain.Payment{}, ""},
+		{"below minimum", domain.Payment{Amount: 5}, "Amount 5 below minimum 10 BRL"},
+		{"above maximum", domain.Payment{Amount: 9000}, "Amount 9000 above maximum 5000 BRL"},
+		{"other currency", domain.Payment{Amount: 100, Currency: "USD"}, "Currency USD not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.payment
+			p.PaymentMethod, p.Country = domain.MethodCard, domain.CountryBR
+			rec := engine.RecommendPayment(p)
+
+			if tt.filtered == "" {
+				if len(rec.Recommendations) != 2 {
+					t.Fatalf("expected both processors, got %d", len(rec.Recommendations))
+				}
+				return
+			}
+			if len(rec.Recommendations) != 1 || rec.Recommendations[0].ProcessorID != "processor_b" {
+				t.Fatalf("expected only processor_b, got %+v", rec.Recommendations)
+			}
+			if got := rec.Explanation.Filtered; len(got) != 1 || got[0].Reason != tt.filtered {
+				t.Errorf("expected reason %q, got %+v", tt.filtered, got)
+			}
+		})
+	}
+}
+
+func TestEngine_DefaultsCurrencyFromCountry(t *testing.T) {
+	engine := NewEngine(health.NewCalculator())
+	rec := engine.Recommend(domain.MethodOXXO, domain.CountryMX, 100)
+	if rec.Currency != "MXN" {
+		t.Errorf("expected MXN, got %q", rec.Currency)
+	}
+}
+
+func TestEngine_VolumeCap(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC))
+	calc := health.NewCalculatorWithClock(clk)
+	engine := NewEngine(calc)
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_a",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+		Limits:         map[string]domain.AmountLimit{"BRL": {DailyVolumeCap: 1000}},
+	})
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_b",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+	})
+
+	// processor_a approves everything, processor_b 80%
+	approve := func(id string, amount float64, result domain.TransactionResult) {
+		tx := domain.Transaction{ProcessorID: id, Result: result, PaymentMethod: domain.MethodPIX,
+			Country: domain.CountryBR, Amount: amount, Timestamp: clk.Now()}
+		calc.RecordTransaction(tx)
+		engine.RecordTransaction(tx)
+	}
+	for i := 0; i < 10; i++ {
+		result := domain.ResultApproved
+		if i%5 == 0 {
+			result = domain.ResultDeclined
+		}
+		approve("processor_b", 10, result)
+	}
+	approve("processor_a", 500, domain.ResultApproved)
+
+	rec := engine.Recommend(domain.MethodPIX, domain.CountryBR, 100)
+	if rec.Recommendations[0].ProcessorID != "processor_a" {
+		t.Fatalf("expected processor_a at 60%% of its cap, got %s", rec.Recommendations[0].ProcessorID)
+	}
+
+	// 900 of 1000 used: the payment brings it to 95%, past the warning level
+	approve("processor_a", 400, domain.ResultApproved)
+	rec = engine.Recommend(domain.MethodPIX, domain.CountryBR, 50)
+	if rec.Recommendations[0].ProcessorID != "processor_b" {
+		t.Fatalf("expected processor_a deprioritized near its cap, got %s", rec.Recommendations[0].ProcessorID)
+	}
+	score := findScore(rec.Explanation.Scores, "processor_a")
+	if score.VolumeCapAdjustment >= 0 || score.VolumeUtilization != 0.95 {
+		t.Errorf("unexpected breakdown: %+v", score)
+	}
+
+	// Over the cap it is filtered out, until the next UTC day
+	rec = engine.Recommend(domain.MethodPIX, domain.CountryBR, 200)
+	if len(rec.Recommendations) != 1 || rec.Explanation.Filtered[0].Reason != "Daily volume cap reached (900 of 1000 BRL)" {
+		t.Fatalf("expected processor_a filtered, got %+v", rec.Explanation.Filtered)
+	}
+	clk.Advance(14 * time.Hour)
+	rec = engine.Recommend(domain.MethodPIX, domain.CountryBR, 200)
+	if len(rec.Explanation.Filtered) != 0 {
+		t.Errorf("expected cap to reset on a new day, got %+v", rec.Explanation.Filtered)
+	}
+}
+
+func TestEngine_UsesAmountBand(t *testing.T) {
+	calc := health.NewCalculator()
+	engine := NewEngine(calc)
+	for _, id := range []string{"processor_a", "processor_b"} {
+		engine.RegisterProcessor(&domain.Processor{
+			ID:             id,
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodCard},
+		})
+	}
+
+	// processor_a approves small tickets but declines large ones; processor_b
+	// approves 75% of both
+	record := func(id string, amount float64, result domain.TransactionResult) {
+		calc.RecordTransaction(domain.Transaction{ProcessorID: id, Result: result, PaymentMethod: domain.MethodCard,
+			Country: domain.CountryBR, Amount: amount, Timestamp: time.Now()})
+	}
+	for i := 0; i < 40; i++ {
+		record("processor_a", 50, domain.ResultApproved)
+	}
+	for i := 0; i < 10; i++ {
+		record("processor_a", 3000, domain.ResultDeclined)
+	}
+	for i := 0; i < 40; i++ {
+		result := domain.ResultApproved
+		if i%4 == 0 {
+			result = domain.ResultDeclined
+		}
+		record("processor_b", []float64{50, 3000}[i%2], result)
+	}
+
+	rec := engine.Recommend(domain.MethodCard, domain.CountryBR, 50)
+	if rec.Recommendations[0].ProcessorID != "processor_a" {
+		t.Errorf("expected processor_a for a small ticket, got %s", rec.Recommendations[0].ProcessorID)
+	}
+
+	rec = engine.Recommend(domain.MethodCard, domain.CountryBR, 3000)
+	if rec.Recommendations[0].ProcessorID != "processor_b" {
+		t.Fatalf("expected processor_b for a large ticket, got %s", rec.Recommendations[0].ProcessorID)
+	}
+	score := findScore(rec.Explanation.Scores, "processor_a")
+	if score.AmountBand == nil || score.AmountBand.MinAmount != 1000 || score.Health.Status != domain.StatusDown {
+		t.Errorf("expected processor_a scored on its DOWN 1000+ band, got %+v", score)
+	}
+
+	// Without an amount the aggregate is used
+	rec = engine.Recommend(domain.MethodCard, domain.CountryBR, 0)
+	if rec.Recommendations[0].ProcessorID != "processor_a" || findScore(rec.Explanation.Scores, "processor_a").AmountBand != nil {
+		t.Errorf("expected aggregate ranking without an amount, got %+v", rec.Recommendations)
+	}
+}
+
+func TestEngine_UsesCardSegment(t *testing.T) {
+	calc := health.NewCalculator()
+	engine := NewEngine(calc)
+	for _, id := range []string{"processor_a", "processor_b"} {
+		engine.RegisterProcessor(&domain.Processor{
+			ID:             id,
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodCard},
+		})
+	}
+
+	// processor_a is better overall but declines one issuer's BIN range
+	record := func(id, bin string, result domain.TransactionResult) {
+		calc.RecordTransaction(domain.Transaction{ProcessorID: id, Result: result, PaymentMethod: domain.MethodCard,
+			Country: domain.CountryBR, BIN: bin, Timestamp: time.Now()})
+	}
+	for i := 0; i < 38; i++ {
+		record("processor_a", "41111111", domain.ResultApproved)
+	}
+	for i := 0; i < 12; i++ {
+		record("processor_a", "45674321", domain.ResultDeclined)
+	}
+	for i := 0; i < 40; i++ {
+		result := domain.ResultApproved
+		if i%3 == 0 {
+			result = domain.ResultDeclined
+		}
+		record("processor_b", "45674321", result)
+	}
+
+	rec := engine.RecommendPayment(domain.Payment{PaymentMethod: domain.MethodCard, Country: domain.CountryBR})
+	if rec.Recommendations[0].ProcessorID != "processor_a" {
+		t.Fatalf("expected processor_a without a BIN, got %s", rec.Recommendations[0].ProcessorID)
+	}
+
+	rec = engine.RecommendPayment(domain.Payment{PaymentMethod: domain.MethodCard, Country: domain.CountryBR, BIN: "4567 4321 0000 0000"})
+	if rec.Recommendations[0].ProcessorID != "processor_b" {
+		t.Fatalf("expected processor_b for BIN 456743, got %s", rec.Recommendations[0].ProcessorID)
+	}
+	if rec.BIN != "45674321" || rec.CardBrand != domain.BrandVisa {
+		t.Errorf("expected truncated BIN and inferred brand, got %q %q", rec.BIN, rec.CardBrand)
+	}
+	if seg := findScore(rec.Explanation.Scores, "processor_a").CardSegment; seg == nil || seg.BIN != "456743" {
+		t.Errorf("expected processor_a scored on BIN range 456743, got %+v", seg)
+	}
+}
+
+func TestEngine_ExcludesAttemptedProcessors(t *testing.T) {
+	calc := health.NewCalculator()
+	engine := NewEngine(calc)
+	for _, id := range []string{"processor_a", "processor_b", "processor_c"} {
+		engine.RegisterProcessor(&domain.Processor{
+			ID:             id,
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodCard},
+		})
+	}
+
+	payment := domain.Payment{PaymentMethod: domain.MethodCard, Country: domain.CountryBR, PaymentID: "checkout-1"}
+	first := engine.RecommendPayment(payment)
+	if first.Attempt != 1 || first.Recommendations[0].ProcessorID != "processor_a" {
+		t.Fatalf("expected attempt 1 on processor_a, got %d on %s", first.Attempt, first.Recommendations[0].ProcessorID)
+	}
+
+	// The first attempt is reported as failed
+	engine.RecordTransaction(domain.Transaction{ID: "tx-1", PaymentID: "checkout-1", ProcessorID: "processor_a",
+		Result: domain.ResultDeclined, Timestamp: time.Now()})
+
+	second := engine.RecommendPayment(payment)
+	if second.Attempt != 2 || second.Recommendations[0].ProcessorID != "processor_b" {
+		t.Fatalf("expected attempt 2 on processor_b, got %d on %s", second.Attempt, second.Recommendations[0].ProcessorID)
+	}
+	if f := second.Explanation.Filtered; len(f) != 1 || f[0].Reason != "Already attempted for this payment" {
+		t.Errorf("expected processor_a filtered as attempted, got %+v", f)
+	}
+
+	// Attempts the engine has not seen can be passed explicitly
+	payment.Attempted = []string{"processor_b"}
+	third := engine.RecommendPayment(payment)
+	if third.Attempt != 3 || len(third.Recommendations) != 1 || third.Recommendations[0].ProcessorID != "processor_c" {
+		t.Errorf("expected attempt 3 on processor_c, got %+v", third.Recommendations)
+	}
+}
+
+func TestEngine_StickyCustomer(t *testing.T) {
+	calc := health.NewCalculator()
+	engine := NewEngine(calc)
+	for _, id := range []string{"processor_a", "processor_b"} {
+		engine.RegisterProcessor(&domain.Processor{
+			ID:             id,
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodCard},
+		})
+	}
+
+	// The customer's card on file was last approved by processor_b
+	engine.RecordTransaction(domain.Transaction{CustomerID: "cus-1", ProcessorID: "processor_b",
+		Result: domain.ResultApproved, Timestamp: time.Now()})
+
+	payment := domain.Payment{PaymentMethod: domain.MethodCard, Country: domain.CountryBR, CustomerID: "cus-1"}
+	if rec := engine.RecommendPayment(payment); rec.Recommendations[0].ProcessorID != "processor_a" {
+		t.Errorf("expected score order without sticky, got %s", rec.Recommendations[0].ProcessorID)
+	}
+
+	payment.Sticky = true
+	rec := engine.RecommendPayment(payment)
+	top := rec.Recommendations[0]
+	if top.ProcessorID != "processor_b" || !top.Recommended || top.Rank != 1 || rec.Explanation.Sticky != "processor_b" {
+		t.Fatalf("expected sticky processor_b first, got %+v", rec.Recommendations)
+	}
+	if rec.Recommendations[1].Recommended || rec.Explanation.Scores[0].ProcessorID != "processor_b" {
+		t.Errorf("expected rankings and scores reordered, got %+v", rec)
+	}
+
+	// Sticky never overrides a DOWN processor
+	for i := 0; i < 20; i++ {
+		calc.RecordTransaction(tx("processor_b", domain.ResultError))
+	}
+	if rec := engine.RecommendPayment(payment); rec.Recommendations[0].ProcessorID != "processor_a" {
+		t.Errorf("expected processor_a while processor_b is DOWN, got %s", rec.Recommendations[0].ProcessorID)
+	}
+}
+
+func TestEngine_SpillsOverNearCapacity(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC))
+	calc := health.NewCalculatorWithClock(clk)
+	engine := NewEngine(calc)
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_a",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+		MaxInFlight:    10,
+	})
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_b",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+	})
+
+	// The first 9 payments go to processor_a; at 90% in flight it saturates
+	var leader, decisions []string
+	for i := 0; i < 11; i++ {
+		rec := engine.Recommend(domain.MethodPIX, domain.CountryBR, 0)
+		leader = append(leader, rec.Recommendations[0].ProcessorID)
+		decisions = append(decisions, rec.DecisionID)
+	}
+	if leader[8] != "processor_a" || leader[9] != "processor_b" || leader[10] != "processor_b" {
+		t.Fatalf("expected spill over after 9 in flight, got %v", leader)
+	}
+
+	rec := engine.Recommend(domain.MethodPIX, domain.CountryBR, 0)
+	a := rec.Recommendations[1]
+	if !a.Saturated || a.Reason != "Near capacity (90% used) - traffic spilled over to processors with room" {
+		t.Errorf("expected saturation reason, got %+v", a)
+	}
+	loads := engine.Loads()
+	if loads[0].ProcessorID != "processor_a" || loads[0].InFlight != 9 || !loads[0].Saturated {
+		t.Fatalf("unexpected loads: %+v", loads)
+	}
+
+	// A reported outcome frees capacity
+	engine.RecordTransaction(domain.Transaction{ProcessorID: "processor_a", DecisionID: decisions[0],
+		Result: domain.ResultApproved, Timestamp: clk.Now()})
+	if rec := engine.Recommend(domain.MethodPIX, domain.CountryBR, 0); rec.Recommendations[0].ProcessorID != "processor_a" {
+		t.Errorf("expected processor_a back below capacity, got %s", rec.Recommendations[0].ProcessorID)
+	}
+
+	// So does the in-flight timeout
+	clk.Advance(InFlightTimeout + time.Second)
+	if loads := engine.Loads(); loads[0].InFlight != 0 {
+		t.Errorf("expected in-flight payments to time out, got %d", loads[0].InFlight)
+	}
+}
+
+func TestEngine_SaturatedLeaderKeptWithoutAlternative(t *testing.T) {
+	engine := NewEngine(health.NewCalculator())
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_a",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+		MaxInFlight:    1,
+	})
+
+	engine.Recommend(domain.MethodPIX, domain.CountryBR, 0)
+	rec := engine.Recommend(domain.MethodPIX, domain.CountryBR, 0)
+	top := rec.Recommendations[0]
+	if !top.Recommended || !top.Saturated || top.Reason != "Near capacity (100% used) - no healthy processor with spare capacity" {
+		t.Errorf("expected saturated processor still recommended, got %+v", top)
+	}
+}
+
+func TestEngine_RecommendTracesFilterAndScore(t *testing.T) {
+	collector, spans := traceCollector()
+	defer collector.Close()
+	exporter := tracing.NewExporter(collector.URL, "test")
+	tracer := tracing.NewTracer(exporter, 1)
+
+	engine := NewEngine(health.NewCalculator())
+	engine.RegisterProcessor(&domain.Processor{
+		ID:             "processor_a",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+	})
+
+	ctx, root := tracer.Start(context.Background(), "request", tracing.KindServer, tracing.SpanContext{})
+	engine.RecommendPaymentContext(ctx, domain.Payment{PaymentMethod: domain.MethodPIX, Country: domain.CountryBR})
+	root.End()
+	exporter.Flush(context.Background())
+
+	parents := spans()
+	recommend, traced := parents["routing.Recommend"]
+	if !traced || recommend != root.Context().SpanID.String() {
+		t.Fatalf("expected routing.Recommend under the request span, got %v", parents)
+	}
+	for _, name := range []string{"routing.filter", "routing.score", "routing.decisions.Add"} {
+		if _, traced := parents[name]; !traced {
+			t.Errorf("expected a %s span, got %v", name, parents)
+		}
+	}
+}
+
+// Helper functions
+
+// traceCollector is a stub OTLP/HTTP receiver; spans returns the parent
+// span ID of every span received, by name
+func traceCollector() (*httptest.Server, func() map[string]string) {
+	var mu sync.Mutex
+	parents := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						Name         string `json:"name"`
+						ParentSpanID string `json:"parentSpanId"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range body.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					parents[span.Name] = span.ParentSpanID
+				}
+			}
+		}
+	}))
+	return server, func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return parents
+	}
+}
+
+func findScore(scores []domain.ScoreBreakdown, processorID string) domain.ScoreBreakdown {
+	for _, s := range scores {
+		if s.ProcessorID == processorID {
+			return s
+		}
+	}
+	return domain.ScoreBreakdown{}
+}
@@ -0,0 +1,158 @@
+package routing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// Load estimation defaults
+const (
+	LoadWindow      = 10               // Seconds of history behind TPS estimates
+	InFlightTimeout = 30 * time.Second // A dispatched payment without an outcome stops counting after this
+)
+
+// rate counts events per second over the last LoadWindow seconds
+type rate struct {
+	seconds [LoadWindow]int64
+	counts  [LoadWindow]int
+}
+
+func (r *rate) add(now time.Time) {
+	sec := now.Unix()
+	i := (sec%LoadWindow + LoadWindow) % LoadWindow // non-negative before 1970
+	if r.seconds[i] != sec {
+		r.seconds[i], r.counts[i] = sec, 0
+	}
+	r.counts[i]++
+}
+
+// perSecond returns the average rate over the window ending at now
+func (r *rate) perSecond(now time.Time) float64 {
+	sec := now.Unix()
+	total := 0
+	for i := range r.seconds {
+		if age := sec - r.seconds[i]; age >= 0 && age < LoadWindow {
+			total += r.counts[i]
+		}
+	}
+	return float64(total) / LoadWindow
+}
+
+// dispatch is a recommendation whose outcome has not been reported yet
+type dispatch struct {
+	decisionID string
+	at         time.Time
+}
+
+// processorLoad is the live load on one processor
+type processorLoad struct {
+	recommended rate
+	recorded    rate
+	inFlight    map[string]time.Time
+	pending     []dispatch // in dispatch order, for expiry
+}
+
+// Load estimates each processor's current load from the recommendations
+// that sent traffic to it and the transactions reported for it. A payment
+// is in flight from its recommendation until its outcome (echoing the
+// decision ID) is recorded, or InFlightTimeout passes.
+type Load struct {
+	mu    sync.Mutex
+	procs map[string]*processorLoad
+}
+
+// NewLoad creates an empty load tracker
+func NewLoad() *Load {
+	return &Load{procs: make(map[string]*processorLoad)}
+}
+
+// proc returns the processor's load, creating it. Caller must hold l.mu.
+func (l *Load) proc(processorID string) *processorLoad {
+	p, exists := l.procs[processorID]
+	if !exists {
+		p = &processorLoad{inFlight: make(map[string]time.Time)}
+		l.procs[processorID] = p
+	}
+	return p
+}
+
+// Dispatch counts a recommendation that sends a payment to processorID
+func (l *Load) Dispatch(processorID, decisionID string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p := l.proc(processorID)
+	p.recommended.add(now)
+	p.inFlight[decisionID] = now
+	p.pending = append(p.pending, dispatch{decisionID: decisionID, at: now})
+	p.expire(now)
+}
+
+// Complete counts a reported transaction and ends the payment in flight
+// under its decision, wherever it was dispatched
+func (l *Load) Complete(tx domain.Transaction, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.proc(tx.ProcessorID).recorded.add(now)
+	if tx.DecisionID == "" {
+		return
+	}
+	for _, p := range l.procs {
+		delete(p.inFlight, tx.DecisionID)
+	}
+}
+
+// expire stops counting dispatches older than InFlightTimeout
+func (p *processorLoad) expire(now time.Time) {
+	cutoff := now.Add(-InFlightTimeout)
+	n := 0
+	for n < len(p.pending) && !p.pending[n].at.After(cutoff) {
+		if at, exists := p.inFlight[p.pending[n].decisionID]; exists && at.Equal(p.pending[n].at) {
+			delete(p.inFlight, p.pending[n].decisionID)
+		}
+		n++
+	}
+	p.pending = p.pending[n:]
+
+	// Completed dispatches leave stale pending entries; compact once they dominate
+	if len(p.pending) > 2*len(p.inFlight)+64 {
+		live := p.pending[:0]
+		for _, d := range p.pending {
+			if _, exists := p.inFlight[d.decisionID]; exists {
+				live = append(live, d)
+			}
+		}
+		p.pending = live
+	}
+}
+
+// Current returns the processor's load at now measured against its
+// capacity. Utilization is the higher of TPS and in-flight usage, 0 when
+// the processor has no capacity configured.
+func (l *Load) Current(p *domain.Processor, now time.Time) domain.ProcessorLoad {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	load := domain.ProcessorLoad{
+		ProcessorID: p.ID,
+		MaxTPS:      p.MaxTPS,
+		MaxInFlight: p.MaxInFlight,
+	}
+	if pl, exists := l.procs[p.ID]; exists {
+		pl.expire(now)
+		load.RecommendedTPS = pl.recommended.perSecond(now)
+		load.RecordedTPS = pl.recorded.perSecond(now)
+		load.InFlight = len(pl.inFlight)
+	}
+
+	if p.MaxTPS > 0 {
+		load.Utilization = max(load.RecommendedTPS, load.RecordedTPS) / p.MaxTPS
+	}
+	if p.MaxInFlight > 0 {
+		load.Utilization = max(load.Utilization, float64(load.InFlight)/float64(p.MaxInFlight))
+	}
+	return load
+}
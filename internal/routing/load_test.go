@@ -0,0 +1,64 @@
+package routing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestLoad_EstimatesTPSAndInFlight(t *testing.T) {
+	load := NewLoad()
+	p := &domain.Processor{ID: "processor_a", MaxTPS: 10, MaxInFlight: 20}
+	now := time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC)
+
+	// 5 payments per second for 10 seconds, half reported back
+	for sec := 0; sec < LoadWindow; sec++ {
+		at := now.Add(time.Duration(sec) * time.Second)
+		for i := 0; i < 5; i++ {
+			id := fmt.Sprintf("dec-%d-%d", sec, i)
+			load.Dispatch("processor_a", id, at)
+			if i%2 == 0 {
+				load.Complete(domain.Transaction{ProcessorID: "processor_a", DecisionID: id}, at)
+			}
+		}
+	}
+
+	end := now.Add((LoadWindow - 1) * time.Second)
+	got := load.Current(p, end)
+	if got.RecommendedTPS != 5 || got.RecordedTPS != 3 || got.InFlight != 20 {
+		t.Errorf("unexpected load: %+v", got)
+	}
+	if got.Utilization != 1 {
+		t.Errorf("expected in-flight to saturate at 20/20, got %.2f", got.Utilization)
+	}
+
+	// Unreported payments stop counting after the timeout
+	got = load.Current(p, end.Add(InFlightTimeout))
+	if got.InFlight != 0 || got.RecommendedTPS != 0 || got.Utilization != 0 {
+		t.Errorf("expected load to drain, got %+v", got)
+	}
+}
+
+func TestLoad_NoCapacityNoUtilization(t *testing.T) {
+	load := NewLoad()
+	now := time.Now()
+	load.Dispatch("processor_a", "dec-1", now)
+
+	if got := load.Current(&domain.Processor{ID: "processor_a"}, now); got.InFlight != 1 || got.Utilization != 0 {
+		t.Errorf("unexpected load: %+v", got)
+	}
+}
+
+func TestLoad_BeforeEpochDoesNotPanic(t *testing.T) {
+	load := NewLoad()
+	at := time.Unix(-86403, 0)
+	for i := 0; i < 3; i++ {
+		load.Dispatch("processor_a", fmt.Sprintf("dec-%d", i), at)
+	}
+
+	if got := load.Current(&domain.Processor{ID: "processor_a"}, at); got.RecommendedTPS != 0.3 || got.InFlight != 3 {
+		t.Errorf("unexpected load before 1970: %+v", got)
+	}
+}
@@ -0,0 +1,48 @@
+package routing
+
+import "fmt"
+
+// Strategy holds the weights used to score processors
+type Strategy struct {
+	DegradedPenalty float64 `json:"degraded_penalty"` // score multiplier for DEGRADED
+	StalePenalty    float64 `json:"stale_penalty"`    // score multiplier for STALE
+	UnknownScore    float64 `json:"unknown_score"`    // flat score without recent data
+	ConfidenceBonus float64 `json:"confidence_bonus"` // added once enough history exists
+	ConfidenceMin   int     `json:"confidence_min"`   // transactions needed for the bonus
+	VolumeCapWarn   float64 `json:"volume_cap_warn"`  // cap utilization where deprioritizing starts
+	SaturationAt    float64 `json:"saturation_at"`    // capacity utilization where traffic spills over
+}
+
+// DefaultStrategy returns the scoring used when none is configured
+func DefaultStrategy() Strategy {
+	return Strategy{
+		DegradedPenalty: 0.5,
+		StalePenalty:    0.75,
+		UnknownScore:    50,
+		ConfidenceBonus: 5,
+		ConfidenceMin:   30,
+		VolumeCapWarn:   0.8,
+		SaturationAt:    0.9,
+	}
+}
+
+// Validate reports the first inconsistent setting
+func (s Strategy) Validate() error {
+	switch {
+	case s.DegradedPenalty < 0 || s.DegradedPenalty > 1:
+		return fmt.Errorf("degraded_penalty must be between 0 and 1, got %g", s.DegradedPenalty)
+	case s.StalePenalty < 0 || s.StalePenalty > 1:
+		return fmt.Errorf("stale_penalty must be between 0 and 1, got %g", s.StalePenalty)
+	case s.UnknownScore < 0 || s.UnknownScore > 100:
+		return fmt.Errorf("unknown_score must be between 0 and 100, got %g", s.UnknownScore)
+	case s.ConfidenceBonus < 0:
+		return fmt.Errorf("confidence_bonus must not be negative, got %g", s.ConfidenceBonus)
+	case s.ConfidenceMin < 0:
+		return fmt.Errorf("confidence_min must not be negative, got %d", s.ConfidenceMin)
+	case s.VolumeCapWarn <= 0 || s.VolumeCapWarn >= 1:
+		return fmt.Errorf("volume_cap_warn must be between 0 and 1 (exclusive), got %g", s.VolumeCapWarn)
+	case s.SaturationAt <= 0 || s.SaturationAt > 1:
+		return fmt.Errorf("saturation_at must be between 0 (exclusive) and 1, got %g", s.SaturationAt)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package routing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// CorrectionHorizon is how long a later report with the same transaction ID
+// replaces an earlier one's volume instead of adding to it
+const CorrectionHorizon = 24 * time.Hour
+
+type volumeKey struct {
+	processorID string
+	currency    string
+}
+
+// volumeCounter sums approved amounts for the current UTC day and month
+type volumeCounter struct {
+	day     time.Time
+	month   time.Time
+	daily   float64
+	monthly float64
+}
+
+// volumeEntry is what one transaction ID added (zero unless approved)
+type volumeEntry struct {
+	key    volumeKey
+	at     time.Time
+	amount float64
+}
+
+// volumeID is a transaction ID in the order its first report arrived
+type volumeID struct {
+	id   string
+	seen time.Time
+}
+
+// Volume tracks approved volume per processor and currency for cap checks.
+// Only the current day and month are kept; older transactions are ignored.
+type Volume struct {
+	mu       sync.Mutex
+	counters map[volumeKey]*volumeCounter
+	entries  map[string]volumeEntry // by transaction ID, for CorrectionHorizon
+	order    []volumeID
+}
+
+// NewVolume creates an empty volume tracker
+func NewVolume() *Volume {
+	return &Volume{counters: make(map[volumeKey]*volumeCounter), entries: make(map[string]volumeEntry)}
+}
+
+func dayOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func monthOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// currencyOf returns the transaction currency, defaulting to the country's
+func currencyOf(tx domain.Transaction) string {
+	if tx.Currency != "" {
+		return tx.Currency
+	}
+	return domain.DefaultCurrency(tx.Country)
+}
+
+// Record adds an approved transaction's amount, as of now. A transaction
+// whose ID was reported within CorrectionHorizon replaces the earlier
+// report, e.g. approved corrected to declined takes its amount back out.
+func (v *Volume) Record(tx domain.Transaction, now time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.forget(now.Add(-CorrectionHorizon))
+
+	prev, exists := v.entries[tx.ID]
+	if exists {
+		v.add(prev.key, prev.at, -prev.amount)
+	}
+
+	entry := volumeEntry{key: volumeKey{processorID: tx.ProcessorID, currency: currencyOf(tx)}, at: tx.Timestamp}
+	if tx.Result == domain.ResultApproved && tx.Amount > 0 {
+		entry.amount = tx.Amount
+		v.add(entry.key, entry.at, entry.amount)
+	}
+	if tx.ID != "" {
+		v.entries[tx.ID] = entry
+		if !exists {
+			v.order = append(v.order, volumeID{id: tx.ID, seen: now})
+		}
+	}
+}
+
+// add counts (or with a negative amount, takes back) volume at t. Caller
+// must hold v.mu.
+func (v *Volume) add(key volumeKey, t time.Time, amount float64) {
+	c, exists := v.counters[key]
+	if !exists {
+		if amount <= 0 {
+			return
+		}
+		c = &volumeCounter{}
+		v.counters[key] = c
+	}
+	if amount > 0 {
+		c.roll(t)
+	}
+
+	if dayOf(t).Equal(c.day) {
+		c.daily = max(c.daily+amount, 0)
+	}
+	if monthOf(t).Equal(c.month) {
+		c.monthly = max(c.monthly+amount, 0)
+	}
+}
+
+// forget drops the IDs first reported before cutoff. Caller must hold v.mu.
+func (v *Volume) forget(cutoff time.Time) {
+	for len(v.order) > 0 && v.order[0].seen.Before(cutoff) {
+		delete(v.entries, v.order[0].id)
+		v.order = v.order[1:]
+	}
+}
+
+// roll starts a new day or month when t is past the current one
+func (c *volumeCounter) roll(t time.Time) {
+	if day := dayOf(t); day.After(c.day) {
+		c.day, c.daily = day, 0
+	}
+	if month := monthOf(t); month.After(c.month) {
+		c.month, c.monthly = month, 0
+	}
+}
+
+// Usage returns approved volume for the day and month containing now
+func (v *Volume) Usage(processorID, currency string, now time.Time) (daily, monthly float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	c, exists := v.counters[volumeKey{processorID: processorID, currency: currency}]
+	if !exists {
+		return 0, 0
+	}
+	if dayOf(now).Equal(c.day) {
+		daily = c.daily
+	}
+	if monthOf(now).Equal(c.month) {
+		monthly = c.monthly
+	}
+	return daily, monthly
+}
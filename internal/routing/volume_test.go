@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+func TestVolume_CountsApprovedOnly(t *testing.T) {
+	v := NewVolume()
+	now := time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC)
+
+	v.Record(volumeTx("processor_a", domain.ResultApproved, 100, now), now)
+	v.Record(volumeTx("processor_a", domain.ResultDeclined, 500, now), now)
+	v.Record(volumeTx("processor_a", domain.ResultError, 500, now), now)
+
+	daily, monthly := v.Usage("processor_a", "BRL", now)
+	if daily != 100 || monthly != 100 {
+		t.Errorf("expected 100/100, got %.0f/%.0f", daily, monthly)
+	}
+	if daily, _ := v.Usage("processor_a", "MXN", now); daily != 0 {
+		t.Errorf("expected no MXN volume, got %.0f", daily)
+	}
+}
+
+func TestVolume_RollsOverDayAndMonth(t *testing.T) {
+	v := NewVolume()
+	day1 := time.Date(2024, 2, 28, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)   // Feb 29
+	march := day2.Add(24 * time.Hour) // Mar 1
+
+	v.Record(volumeTx("processor_a", domain.ResultApproved, 100, day1), day1)
+	v.Record(volumeTx("processor_a", domain.ResultApproved, 50, day2), day2)
+
+	if daily, monthly := v.Usage("processor_a", "BRL", day2); daily != 50 || monthly != 150 {
+		t.Errorf("expected 50/150 on Feb 29, got %.0f/%.0f", daily, monthly)
+	}
+	if daily, monthly := v.Usage("processor_a", "BRL", march); daily != 0 || monthly != 0 {
+		t.Errorf("expected 0/0 in March, got %.0f/%.0f", daily, monthly)
+	}
+
+	// A late transaction from the previous day does not reopen it
+	v.Record(volumeTx("processor_a", domain.ResultApproved, 30, day1), day1)
+	if daily, monthly := v.Usage("processor_a", "BRL", day2); daily != 50 || monthly != 180 {
+		t.Errorf("expected 50/180 after late report, got %.0f/%.0f", daily, monthly)
+	}
+}
+
+func TestVolume_CorrectionsReplaceEarlierReport(t *testing.T) {
+	v := NewVolume()
+	now := time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC)
+	tx := volumeTx("processor_a", domain.ResultApproved, 100, now)
+	tx.ID = "tx-1"
+
+	// Retried, then corrected to declined: nothing left
+	v.Record(tx, now)
+	v.Record(tx, now)
+	if daily, _ := v.Usage("processor_a", "BRL", now); daily != 100 {
+		t.Errorf("expected the retry to replace the report, got %.0f", daily)
+	}
+	tx.Result = domain.ResultDeclined
+	v.Record(tx, now)
+	if daily, monthly := v.Usage("processor_a", "BRL", now); daily != 0 || monthly != 0 {
+		t.Errorf("expected the decline to take the amount back, got %.0f/%.0f", daily, monthly)
+	}
+
+	// Declined corrected to approved with a new amount
+	tx.Result, tx.Amount = domain.ResultApproved, 70
+	v.Record(tx, now)
+	if daily, _ := v.Usage("processor_a", "BRL", now); daily != 70 {
+		t.Errorf("expected 70 after the correction, got %.0f", daily)
+	}
+
+	// Past the horizon the ID counts as a new transaction
+	later := now.Add(CorrectionHorizon + time.Minute)
+	v.Record(tx, later)
+	if _, monthly := v.Usage("processor_a", "BRL", later); monthly != 140 {
+		t.Errorf("expected 140 once the ID is forgotten, got %.0f", monthly)
+	}
+}
+
+// Helper functions
+
+func volumeTx(processorID string, result domain.TransactionResult, amount float64, at time.Time) domain.Transaction {
+	return domain.Transaction{
+		ProcessorID: processorID,
+		Result:      result,
+		Country:     domain.CountryBR,
+		Amount:      amount,
+		Timestamp:   at,
+	}
+}
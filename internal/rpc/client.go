@@ -0,0 +1,175 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls a gRPC server over cleartext HTTP/2 (h2c)
+type Client struct {
+	baseURL  string
+	http     *http.Client
+	metadata http.Header
+}
+
+// NewClient creates a client for the server at baseURL, e.g.
+// http://localhost:9090
+func NewClient(baseURL string) *Client {
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	return &Client{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		http:     &http.Client{Transport: transport},
+		metadata: http.Header{},
+	}
+}
+
+// SetMetadata sends key: value with every call, e.g. an API key
+func (c *Client) SetMetadata(key, value string) {
+	c.metadata.Set(key, value)
+}
+
+// Call makes a unary call
+func (c *Client) Call(ctx context.Context, method string, req []byte) ([]byte, error) {
+	stream, err := c.NewStream(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(req); err != nil {
+		return nil, err
+	}
+	stream.CloseSend()
+
+	resp, err := stream.Recv()
+	if err == io.EOF {
+		if err = stream.Err(); err == nil {
+			err = Errorf(Internal, "no response message")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Drain to the trailers for the call's status
+	if _, err := stream.Recv(); err != io.EOF {
+		return nil, Errorf(Internal, "expected a single response message")
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ClientStream is a call in progress. Send and CloseSend may be used
+// concurrently with Recv.
+type ClientStream struct {
+	ctx    context.Context
+	body   *io.PipeWriter
+	resp   *http.Response
+	ready  chan struct{}
+	err    error // transport error before the response
+	status error // from the trailers, once Recv returns io.EOF
+}
+
+// NewStream starts a call; the response is awaited by the first Recv
+func (c *Client) NewStream(ctx context.Context, method string) (*ClientStream, error) {
+	body, writer := io.Pipe()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.metadata {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Te", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set("Grpc-Timeout", strconv.FormatInt(time.Until(deadline).Milliseconds(), 10)+"m")
+	}
+
+	s := &ClientStream{ctx: ctx, body: writer, ready: make(chan struct{})}
+	go func() {
+		defer close(s.ready)
+		resp, err := c.http.Do(req)
+		if err != nil {
+			s.err = err
+			body.CloseWithError(err)
+			return
+		}
+		s.resp = resp
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			s.err = Errorf(Unknown, "unexpected HTTP status %s", resp.Status)
+			return
+		}
+		// A trailers-only response carries its status in the headers
+		if resp.Header.Get("Grpc-Status") != "" {
+			s.status = statusOf(resp.Header)
+		}
+	}()
+	return s, nil
+}
+
+// Send writes a request message
+func (s *ClientStream) Send(msg []byte) error {
+	return WriteMessage(s.body, msg)
+}
+
+// CloseSend tells the server no more messages follow
+func (s *ClientStream) CloseSend() error {
+	return s.body.Close()
+}
+
+// Recv reads the next response message. At the end of the call it returns
+// io.EOF when the status is OK and the status error otherwise.
+func (s *ClientStream) Recv() ([]byte, error) {
+	select {
+	case <-s.ready:
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	msg, err := ReadMessage(s.resp.Body)
+	if errors.Is(err, io.EOF) {
+		s.resp.Body.Close()
+		if s.status == nil && s.resp.Header.Get("Grpc-Status") == "" {
+			s.status = statusOf(s.resp.Trailer)
+		}
+		if s.status != nil {
+			return nil, s.status
+		}
+		return nil, io.EOF
+	}
+	if err != nil {
+		if ctxErr := s.ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, Errorf(Internal, "reading response: %v", err)
+	}
+	return msg, nil
+}
+
+// Err is the call's status error once Recv has returned io.EOF or it
+func (s *ClientStream) Err() error {
+	return s.status
+}
+
+// statusOf reads the call status from headers or trailers (nil when OK)
+func statusOf(h http.Header) error {
+	raw := h.Get("Grpc-Status")
+	code, err := strconv.Atoi(raw)
+	if err != nil {
+		return Errorf(Internal, "missing or invalid grpc-status %q", raw)
+	}
+	if code == int(OK) {
+		return nil
+	}
+	return &Error{Code: Code(code), Message: decodeMessage(h.Get("Grpc-Message"))}
+}
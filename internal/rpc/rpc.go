@@ -0,0 +1,362 @@
+// Package rpc serves gRPC over the standard library's HTTP/2: length-prefixed
+// message framing, status trailers, deadlines and a protobuf wire codec.
+// Services decode and encode their own messages with Encoder and Decode.
+// Compression and the reflection service are not supported.
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxMessageSize bounds a single message either way, as gRPC's default does
+const MaxMessageSize = 4 << 20
+
+// ContentType is the media type of gRPC requests and responses
+const ContentType = "application/grpc"
+
+// Code is a gRPC status code
+type Code int
+
+// Status codes used by this server (google.golang.org/grpc/codes)
+const (
+	OK                Code = 0
+	Canceled          Code = 1
+	Unknown           Code = 2
+	InvalidArgument   Code = 3
+	DeadlineExceeded  Code = 4
+	NotFound          Code = 5
+	AlreadyExists     Code = 6
+	PermissionDenied  Code = 7
+	ResourceExhausted Code = 8
+	Unimplemented     Code = 12
+	Internal          Code = 13
+	Unavailable       Code = 14
+	Unauthenticated   Code = 16
+)
+
+var codeNames = map[Code]string{
+	OK: "OK", Canceled: "CANCELED", Unknown: "UNKNOWN", InvalidArgument: "INVALID_ARGUMENT",
+	DeadlineExceeded: "DEADLINE_EXCEEDED", NotFound: "NOT_FOUND", AlreadyExists: "ALREADY_EXISTS",
+	PermissionDenied: "PERMISSION_DENIED", ResourceExhausted: "RESOURCE_EXHAUSTED", Unimplemented: "UNIMPLEMENTED", Internal: "INTERNAL",
+	Unavailable: "UNAVAILABLE", Unauthenticated: "UNAUTHENTICATED",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return "CODE(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Error is a call's status when it is not OK
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// Errorf creates a status error
+func Errorf(code Code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error: %s: %s", e.Code, e.Message)
+}
+
+// CodeOf returns the status code for err: OK for nil, the code of an
+// *Error, the context's code for cancellation and Unknown otherwise
+func CodeOf(err error) Code {
+	var e *Error
+	switch {
+	case err == nil:
+		return OK
+	case errors.As(err, &e):
+		return e.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	default:
+		return Unknown
+	}
+}
+
+// Handler serves one method. Returning an error ends the call with its
+// status; a plain error becomes Unknown.
+type Handler func(*Stream) error
+
+// Interceptor wraps every call, e.g. to authenticate or log it; next runs
+// the method
+type Interceptor func(method string, s *Stream, next Handler) error
+
+// Server routes gRPC calls by method name ("/package.Service/Method"). It
+// is an http.Handler for an HTTP/2 server.
+type Server struct {
+	methods     map[string]Handler
+	interceptor Interceptor
+}
+
+// NewServer creates a server without methods
+func NewServer() *Server {
+	return &Server{methods: make(map[string]Handler)}
+}
+
+// Handle registers h for method, e.g. "/techcart.failover.v1.Failover/Recommend"
+func (s *Server) Handle(method string, h Handler) {
+	s.methods[method] = h
+}
+
+// SetInterceptor wraps every call in fn
+func (s *Server) SetInterceptor(fn Interceptor) {
+	s.interceptor = fn
+}
+
+// Methods lists the registered method names
+func (s *Server) Methods() []string {
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ServeHTTP runs a call. Requests that are not gRPC get a plain HTTP error.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), ContentType) {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Grpc-Accept-Encoding", "identity")
+	stream := &Stream{w: w, r: r, ctx: r.Context()}
+
+	err := s.serve(stream)
+	if !stream.wroteHeader {
+		// Trailers-only response: the status rides in the headers
+		stream.setStatus(w.Header(), err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	stream.setStatus(trailers{w.Header()}, err)
+}
+
+func (s *Server) serve(stream *Stream) error {
+	method := stream.r.URL.Path
+	handler, ok := s.methods[method]
+	if !ok {
+		return Errorf(Unimplemented, "unknown method %s", method)
+	}
+	if enc := stream.r.Header.Get("Grpc-Encoding"); enc != "" && enc != "identity" {
+		return Errorf(Unimplemented, "compression %q is not supported", enc)
+	}
+
+	if timeout, ok := parseTimeout(stream.r.Header.Get("Grpc-Timeout")); ok {
+		ctx, cancel := context.WithTimeout(stream.ctx, timeout)
+		defer cancel()
+		stream.ctx = ctx
+	}
+	if s.interceptor != nil {
+		return s.interceptor(method, stream, handler)
+	}
+	return handler(stream)
+}
+
+// trailers sets header values as HTTP trailers
+type trailers struct{ h http.Header }
+
+func (t trailers) Set(key, value string) { t.h.Set(http.TrailerPrefix+key, value) }
+
+// Stream is one call: request metadata, incoming messages and responses
+type Stream struct {
+	w           http.ResponseWriter
+	r           *http.Request
+	ctx         context.Context
+	wroteHeader bool
+}
+
+// Context is cancelled when the client goes away or its deadline passes
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// SetContext replaces the call's context, e.g. to add values
+func (s *Stream) SetContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
+// Request is the underlying HTTP/2 request; its headers are the call's
+// metadata
+func (s *Stream) Request() *http.Request {
+	return s.r
+}
+
+// Metadata returns a request metadata value
+func (s *Stream) Metadata(key string) string {
+	return s.r.Header.Get(key)
+}
+
+// SetHeader sets response metadata; it must be called before the first Send
+func (s *Stream) SetHeader(key, value string) {
+	s.w.Header().Set(key, value)
+}
+
+// Recv reads the next message, returning io.EOF once the client is done
+func (s *Stream) Recv() ([]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	msg, err := ReadMessage(s.r.Body)
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		if ctxErr := s.ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, Errorf(InvalidArgument, "reading request: %v", err)
+	}
+	return msg, nil
+}
+
+// Send writes a message and flushes it to the client
+func (s *Stream) Send(msg []byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.wroteHeader = true
+	if err := WriteMessage(s.w, msg); err != nil {
+		return err
+	}
+	return http.NewResponseController(s.w).Flush()
+}
+
+func (s *Stream) setStatus(h interface{ Set(key, value string) }, err error) {
+	h.Set("Grpc-Status", strconv.Itoa(int(CodeOf(err))))
+	if err != nil {
+		message := err.Error()
+		var e *Error
+		if errors.As(err, &e) {
+			message = e.Message
+		}
+		h.Set("Grpc-Message", encodeMessage(message))
+	}
+}
+
+// Unary adapts fn to a handler for a single request and response
+func Unary(fn func(s *Stream, req []byte) ([]byte, error)) Handler {
+	return func(s *Stream) error {
+		req, err := s.Recv()
+		if err == io.EOF {
+			return Errorf(Internal, "expected a request message")
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := fn(s, req)
+		if err != nil {
+			return err
+		}
+		return s.Send(resp)
+	}
+}
+
+// ReadMessage reads one length-prefixed message. Compressed messages are
+// rejected.
+func ReadMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated message prefix")
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > MaxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the %d byte limit", size, MaxMessageSize)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("truncated message: %w", err)
+	}
+	return msg, nil
+}
+
+// WriteMessage writes one length-prefixed, uncompressed message
+func WriteMessage(w io.Writer, msg []byte) error {
+	if len(msg) > MaxMessageSize {
+		return Errorf(ResourceExhausted, "message of %d bytes exceeds the %d byte limit", len(msg), MaxMessageSize)
+	}
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
+
+// parseTimeout reads a grpc-timeout header such as "250m" (milliseconds).
+// Timeouts too long for a time.Duration, such as "99999999H", are capped.
+func parseTimeout(v string) (time.Duration, bool) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	unit, ok := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}[v[len(v)-1]]
+	if !ok {
+		return 0, false
+	}
+	if n > math.MaxInt64/int64(unit) {
+		return math.MaxInt64, true
+	}
+	return time.Duration(n) * unit, true
+}
+
+// encodeMessage percent-encodes a grpc-message value
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// decodeMessage reverses encodeMessage
+func decodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '%' && i+2 < len(msg) {
+			if c, err := strconv.ParseUint(msg[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(msg[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,254 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoder_MatchesProtobufEncoding(t *testing.T) {
+	var e Encoder
+	e.Int64(1, 150)
+	e.String(2, "testing")
+	e.Bool(3, true)
+	e.Double(4, 1.5)
+	e.String(5, "") // zero values are not sent
+	e.Message(6, func(m *Encoder) { m.Int64(1, 1) })
+	e.Strings(7, []string{"a", "b"})
+	e.Int64s(8, []int64{3, 270})
+
+	want := []byte{
+		0x08, 0x96, 0x01, // 1: varint 150
+		0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g', // 2: "testing"
+		0x18, 0x01, // 3: true
+		0x21, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f, // 4: fixed64 1.5
+		0x32, 0x02, 0x08, 0x01, // 6: {1: 1}
+		0x3a, 0x01, 'a', 0x3a, 0x01, 'b', // 7: repeated
+		0x42, 0x03, 0x03, 0x8e, 0x02, // 8: packed [3, 270]
+	}
+	if !bytes.Equal(e.Bytes(), want) {
+		t.Fatalf("expected % x, got % x", want, e.Bytes())
+	}
+}
+
+func TestDecode_RoundTrip(t *testing.T) {
+	now := time.Date(2024, 2, 20, 12, 0, 0, 500, time.UTC)
+	var e Encoder
+	e.Int64(1, -3)
+	e.String(2, "pix")
+	e.Double(3, 99.5)
+	e.Timestamp(4, now)
+	e.Int64(99, 7) // unknown to the reader
+
+	var (
+		n       int64
+		s       string
+		d       float64
+		ts      time.Time
+		strings []string
+		packed  []int64
+	)
+	e.Strings(5, []string{"x", "y"})
+	e.Int64s(6, []int64{1, -1})
+	err := Decode(e.Bytes(), func(field int, v Value) error {
+		switch field {
+		case 1:
+			n = v.Int64()
+		case 2:
+			s = v.String()
+		case 3:
+			d = v.Double()
+		case 4:
+			var err error
+			ts, err = v.Timestamp()
+			return err
+		case 5:
+			strings = append(strings, v.String())
+		case 6:
+			var err error
+			packed, err = v.Int64s()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != -3 || s != "pix" || d != 99.5 || !ts.Equal(now) || len(strings) != 2 || len(packed) != 2 || packed[1] != -1 {
+		t.Errorf("unexpected round trip: %d %q %g %s %v %v", n, s, d, ts, strings, packed)
+	}
+
+	if err := Decode([]byte{0x12, 0x05, 'a'}, func(int, Value) error { return nil }); err == nil {
+		t.Error("expected a truncated message to fail")
+	}
+}
+
+func TestServer_UnaryCall(t *testing.T) {
+	client := newTestServer(t)
+
+	resp, err := client.Call(context.Background(), "/test.Echo/Echo", []byte("hello"))
+	if err != nil || string(resp) != "hello" {
+		t.Fatalf("expected echo, got %q, %v", resp, err)
+	}
+	if _, err := client.Call(context.Background(), "/test.Echo/Missing", nil); CodeOf(err) != Unimplemented {
+		t.Errorf("expected Unimplemented, got %v", err)
+	}
+}
+
+func TestServer_StatusError(t *testing.T) {
+	client := newTestServer(t)
+
+	_, err := client.Call(context.Background(), "/test.Echo/Fail", []byte("x"))
+	var status *Error
+	if !errors.As(err, &status) || status.Code != InvalidArgument || status.Message != "bad 100% input: ü" {
+		t.Fatalf("expected the handler's status, got %v", err)
+	}
+}
+
+func TestServer_ClientStreaming(t *testing.T) {
+	client := newTestServer(t)
+
+	stream, err := client.NewStream(context.Background(), "/test.Echo/Join")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{"a", "b", "c"} {
+		if err := stream.Send([]byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+
+	resp, err := stream.Recv()
+	if err != nil || string(resp) != "a,b,c" {
+		t.Fatalf("expected joined parts, got %q, %v", resp, err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("expected io.EOF after the response, got %v", err)
+	}
+}
+
+func TestServer_ServerStreamingDeadline(t *testing.T) {
+	client := newTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	stream, err := client.NewStream(ctx, "/test.Echo/Tick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(nil)
+	stream.CloseSend()
+
+	received := 0
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+		received++
+	}
+	if received == 0 || received > 5 {
+		t.Errorf("expected a few ticks before the deadline, got %d", received)
+	}
+	if code := CodeOf(err); code != DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestServer_Interceptor(t *testing.T) {
+	client := newTestServer(t)
+
+	if _, err := client.Call(context.Background(), "/test.Echo/Echo", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	client.SetMetadata("x-deny", "true")
+	if _, err := client.Call(context.Background(), "/test.Echo/Echo", []byte("x")); CodeOf(err) != Unauthenticated {
+		t.Errorf("expected the interceptor to reject the call, got %v", err)
+	}
+}
+
+func TestServer_RejectsPlainHTTP(t *testing.T) {
+	server := NewServer()
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test.Echo/Echo", nil))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415, got %d", rec.Code)
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	for header, want := range map[string]time.Duration{
+		"1H": time.Hour, "250m": 250 * time.Millisecond, "10S": 10 * time.Second, "5u": 5 * time.Microsecond,
+		"99999999H": math.MaxInt64, // capped: too long for a time.Duration
+	} {
+		if got, ok := parseTimeout(header); !ok || got != want {
+			t.Errorf("%s: expected %s, got %s, %v", header, want, got, ok)
+		}
+	}
+	for _, header := range []string{"", "m", "10x", "-1m", "1234567890S"} {
+		if _, ok := parseTimeout(header); ok {
+			t.Errorf("expected %q to be rejected", header)
+		}
+	}
+}
+
+// Helper functions
+
+// newTestServer serves a test service over h2c and returns a client for it
+func newTestServer(t *testing.T) *Client {
+	t.Helper()
+	server := NewServer()
+	server.Handle("/test.Echo/Echo", Unary(func(s *Stream, req []byte) ([]byte, error) {
+		return req, nil
+	}))
+	server.Handle("/test.Echo/Fail", Unary(func(s *Stream, req []byte) ([]byte, error) {
+		return nil, Errorf(InvalidArgument, "bad 100%% input: ü")
+	}))
+	server.Handle("/test.Echo/Join", func(s *Stream) error {
+		var parts []string
+		for {
+			msg, err := s.Recv()
+			if err == io.EOF {
+				return s.Send([]byte(strings.Join(parts, ",")))
+			}
+			if err != nil {
+				return err
+			}
+			parts = append(parts, string(msg))
+		}
+	})
+	server.Handle("/test.Echo/Tick", func(s *Stream) error {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			if err := s.Send([]byte("tick")); err != nil {
+				return err
+			}
+			select {
+			case <-s.Context().Done():
+				return s.Context().Err()
+			case <-ticker.C:
+			}
+		}
+	})
+	server.SetInterceptor(func(method string, s *Stream, next Handler) error {
+		if s.Metadata("X-Deny") != "" {
+			return Errorf(Unauthenticated, "denied")
+		}
+		return next(s)
+	})
+
+	ts := httptest.NewUnstartedServer(server)
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return NewClient(ts.URL)
+}
@@ -0,0 +1,229 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Encoder appends protobuf (proto3) fields to a message. Zero values are
+// skipped, as proto3 does for scalar fields.
+type Encoder struct {
+	buf []byte
+}
+
+// Bytes returns the encoded message
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+func (e *Encoder) tag(field, wire int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wire))
+}
+
+// String encodes a string field
+func (e *Encoder) String(field int, v string) {
+	if v == "" {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// Strings encodes a repeated string field
+func (e *Encoder) Strings(field int, v []string) {
+	for _, s := range v {
+		e.tag(field, wireBytes)
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+		e.buf = append(e.buf, s...)
+	}
+}
+
+// Int64 encodes an int64 (or int32) field
+func (e *Encoder) Int64(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+// Int64s encodes a repeated int64 field, packed as proto3 does
+func (e *Encoder) Int64s(field int, v []int64) {
+	if len(v) == 0 {
+		return
+	}
+	var packed []byte
+	for _, n := range v {
+		packed = binary.AppendUvarint(packed, uint64(n))
+	}
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(packed)))
+	e.buf = append(e.buf, packed...)
+}
+
+// Bool encodes a bool field
+func (e *Encoder) Bool(field int, v bool) {
+	if v {
+		e.tag(field, wireVarint)
+		e.buf = append(e.buf, 1)
+	}
+}
+
+// Double encodes a double field
+func (e *Encoder) Double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+// Message encodes a nested message written by fn. Unlike scalars it is
+// sent even when empty, so the receiver sees it is set.
+func (e *Encoder) Message(field int, fn func(*Encoder)) {
+	var nested Encoder
+	fn(&nested)
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(nested.buf)))
+	e.buf = append(e.buf, nested.buf...)
+}
+
+// Timestamp encodes a google.protobuf.Timestamp field (skipped when zero)
+func (e *Encoder) Timestamp(field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	e.Message(field, func(m *Encoder) {
+		m.Int64(1, t.Unix())
+		m.Int64(2, int64(t.Nanosecond()))
+	})
+}
+
+// Value is one decoded field
+type Value struct {
+	wire  int
+	num   uint64
+	bytes []byte
+}
+
+// Int64 returns a varint field as int64
+func (v Value) Int64() int64 {
+	return int64(v.num)
+}
+
+// Int64s returns a repeated int64 field, packed or not. Unpacked fields
+// arrive one value per call, as a slice of one.
+func (v Value) Int64s() ([]int64, error) {
+	if v.wire == wireVarint {
+		return []int64{int64(v.num)}, nil
+	}
+	var result []int64
+	for data := v.bytes; len(data) > 0; {
+		n, size := binary.Uvarint(data)
+		if size <= 0 {
+			return nil, errTruncated
+		}
+		result, data = append(result, int64(n)), data[size:]
+	}
+	return result, nil
+}
+
+// Bool returns a varint field as bool
+func (v Value) Bool() bool {
+	return v.num != 0
+}
+
+// Double returns a fixed64 field as float64
+func (v Value) Double() float64 {
+	if v.wire != wireFixed64 {
+		return 0
+	}
+	return math.Float64frombits(v.num)
+}
+
+// String returns a length-delimited field as string
+func (v Value) String() string {
+	return string(v.bytes)
+}
+
+// Message decodes a nested message field
+func (v Value) Message(fn func(field int, v Value) error) error {
+	return Decode(v.bytes, fn)
+}
+
+// Timestamp decodes a google.protobuf.Timestamp field
+func (v Value) Timestamp() (time.Time, error) {
+	var seconds, nanos int64
+	err := v.Message(func(field int, v Value) error {
+		switch field {
+		case 1:
+			seconds = v.Int64()
+		case 2:
+			nanos = v.Int64()
+		}
+		return nil
+	})
+	return time.Unix(seconds, nanos).UTC(), err
+}
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// Decode calls fn for each field of a protobuf message in order. Fields fn
+// does not know are expected to be ignored.
+func Decode(data []byte, fn func(field int, v Value) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		field, wire := int(key>>3), int(key&7)
+		if field == 0 {
+			return errors.New("invalid protobuf field number 0")
+		}
+
+		v := Value{wire: wire}
+		switch wire {
+		case wireVarint:
+			if v.num, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			v.num, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errTruncated
+			}
+			v.bytes, data = data[n:n+int(size)], data[n+int(size):]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			v.num, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+
+		if err := fn(field, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
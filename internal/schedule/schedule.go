@@ -0,0 +1,62 @@
+// Package schedule runs periodic work over a set of targets that can grow
+// between runs, such as every tenant's calculator
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+)
+
+// Scheduler calls run on every target from source each interval and hands
+// what it returns to OnEvent
+type Scheduler[T, E any] struct {
+	clock    clock.Clock
+	interval time.Duration
+	source   func() []T
+	run      func(target T, now time.Time) []E
+	OnEvent  func(E)
+}
+
+// New creates a scheduler over the targets returned by source, which is
+// called on every run so newly added tenants are picked up
+func New[T, E any](clk clock.Clock, interval time.Duration, source func() []T, run func(T, time.Time) []E) *Scheduler[T, E] {
+	return &Scheduler[T, E]{
+		clock:    clk,
+		interval: interval,
+		source:   source,
+		run:      run,
+	}
+}
+
+// RunOnce runs every target as of now and returns their events
+func (s *Scheduler[T, E]) RunOnce() []E {
+	now := s.clock.Now()
+
+	var result []E
+	for _, target := range s.source() {
+		for _, e := range s.run(target, now) {
+			if s.OnEvent != nil {
+				s.OnEvent(e)
+			}
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Run runs on every tick until ctx is cancelled
+func (s *Scheduler[T, E]) Run(ctx context.Context) {
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			s.RunOnce()
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+)
+
+func TestScheduler_RunOnceCallsEveryTarget(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC))
+	targets := []string{"a"}
+	s := New(clk, time.Minute, func() []string { return targets }, func(target string, now time.Time) []string {
+		return []string{target + "@" + now.Format("15:04")}
+	})
+	var seen []string
+	s.OnEvent = func(e string) { seen = append(seen, e) }
+
+	// Targets added between runs are picked up
+	targets = append(targets, "b")
+	clk.Advance(time.Minute)
+	events := s.RunOnce()
+	if len(events) != 2 || events[0] != "a@12:01" || events[1] != "b@12:01" || len(seen) != 2 {
+		t.Errorf("expected both targets run at 12:01, got %v (seen %v)", events, seen)
+	}
+}
+
+func TestScheduler_RunStopsWithContext(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ran := make(chan struct{}, 1)
+	s := New(clk, time.Minute, func() []int { return []int{1} }, func(int, time.Time) []int {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { s.Run(ctx); close(done) }()
+
+	// Keep advancing until the ticker goroutine has registered and fired
+	deadline := time.After(2 * time.Second)
+	for fired := false; !fired; {
+		clk.Advance(time.Minute)
+		select {
+		case <-ran:
+			fired = true
+		case <-deadline:
+			t.Fatal("scheduler never ran")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -0,0 +1,182 @@
+// Package simtest drives the health calculator, routing engine and
+// scheduler through virtual time so scripted scenarios (outages, recoveries,
+// idle periods) can be asserted on in ordinary unit tests.
+package simtest
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/routing"
+)
+
+// Mix is the share of each non-approved result in generated traffic;
+// whatever remains is approved
+type Mix struct {
+	Declined float64
+	Error    float64
+	Timeout  float64
+}
+
+// Common traffic mixes
+var (
+	Normal = Mix{Declined: 0.20, Error: 0.05}
+	Outage = Mix{Error: 0.90}
+)
+
+// Flow is a stream of transactions for one processor and corridor
+type Flow struct {
+	ProcessorID string
+	Method      domain.PaymentMethod
+	Country     domain.Country
+	PerMinute   int
+	Mix         Mix
+}
+
+// Harness runs a calculator, engine and scheduler on a fake clock
+type Harness struct {
+	Clock      *clock.Fake
+	Calculator *health.Calculator
+	Engine     *routing.Engine
+	Scheduler  *health.Scheduler
+
+	start    time.Time
+	interval time.Duration
+	nextEval time.Time
+	rng      *rand.Rand
+	seq      int
+}
+
+// New creates a harness starting at start with a seeded random source
+func New(start time.Time, seed int64, processors ...*domain.Processor) *Harness {
+	clk := clock.NewFake(start)
+	calc := health.NewCalculatorWithClock(clk)
+	engine := routing.NewEngine(calc)
+	for _, p := range processors {
+		engine.RegisterProcessor(p)
+	}
+
+	h := &Harness{
+		Clock:      clk,
+		Calculator: calc,
+		Engine:     engine,
+		start:      start,
+		interval:   health.DefaultReevaluateInterval,
+		nextEval:   start.Add(health.DefaultReevaluateInterval),
+		rng:        rand.New(rand.NewSource(seed)),
+	}
+	h.Scheduler = health.NewScheduler(clk, h.interval, func() []*health.Calculator {
+		return []*health.Calculator{calc}
+	})
+	return h
+}
+
+// Run generates traffic for all flows over d of virtual time, one second
+// at a time, running the background scheduler as its interval comes due
+func (h *Harness) Run(d time.Duration, flows ...Flow) {
+	end := h.Clock.Now().Add(d)
+	for h.Clock.Now().Before(end) {
+		now := h.Clock.Now()
+		second := int(now.Sub(h.start) / time.Second)
+		for _, f := range flows {
+			// Spread PerMinute transactions evenly across the minute
+			if f.PerMinute <= 0 {
+				continue
+			}
+			due := (second+1)*f.PerMinute/60 - second*f.PerMinute/60
+			for i := 0; i < due; i++ {
+				h.Record(f.ProcessorID, f.Method, f.Country, h.result(f.Mix))
+			}
+		}
+		h.Advance(time.Second)
+	}
+}
+
+// Idle advances virtual time without traffic
+func (h *Harness) Idle(d time.Duration) {
+	end := h.Clock.Now().Add(d)
+	for h.Clock.Now().Before(end) {
+		step := min(h.interval, end.Sub(h.Clock.Now()))
+		h.Advance(step)
+	}
+}
+
+// Advance moves the clock and runs the scheduler if it came due
+func (h *Harness) Advance(d time.Duration) {
+	h.Clock.Advance(d)
+	for !h.Clock.Now().Before(h.nextEval) {
+		h.Scheduler.RunOnce()
+		h.nextEval = h.nextEval.Add(h.interval)
+	}
+}
+
+// Record sends one transaction at the current virtual time
+func (h *Harness) Record(processorID string, method domain.PaymentMethod, country domain.Country, result domain.TransactionResult) *domain.ProcessorHealth {
+	h.seq++
+	return h.Calculator.RecordTransaction(domain.Transaction{
+		ID:            fmt.Sprintf("sim-%d", h.seq),
+		ProcessorID:   processorID,
+		Timestamp:     h.Clock.Now(),
+		Result:        result,
+		PaymentMethod: method,
+		Country:       country,
+	})
+}
+
+func (h *Harness) result(m Mix) domain.TransactionResult {
+	r := h.rng.Float64()
+	switch {
+	case r < m.Error:
+		return domain.ResultError
+	case r < m.Error+m.Timeout:
+		return domain.ResultTimeout
+	case r < m.Error+m.Timeout+m.Declined:
+		return domain.ResultDeclined
+	default:
+		return domain.ResultApproved
+	}
+}
+
+// Elapsed returns virtual time since the start of the scenario
+func (h *Harness) Elapsed() time.Duration {
+	return h.Clock.Now().Sub(h.start)
+}
+
+// Offset converts an absolute timestamp into time since scenario start
+func (h *Harness) Offset(t time.Time) time.Duration {
+	return t.Sub(h.start)
+}
+
+// Transitions returns every transition for processorID ("" for all)
+func (h *Harness) Transitions(processorID string) []domain.HealthTransition {
+	var result []domain.HealthTransition
+	for _, t := range h.Calculator.GetTransitions(h.start.Add(-time.Nanosecond)) {
+		if processorID == "" || t.ProcessorID == processorID {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// FirstTransition returns the first transition of processorID into status
+func (h *Harness) FirstTransition(processorID string, to domain.HealthStatus) (domain.HealthTransition, bool) {
+	for _, t := range h.Transitions(processorID) {
+		if t.ToStatus == to {
+			return t, true
+		}
+	}
+	return domain.HealthTransition{}, false
+}
+
+// Top returns the recommended processor ID for a corridor ("" if none)
+func (h *Harness) Top(method domain.PaymentMethod, country domain.Country) string {
+	rec := h.Engine.Recommend(method, country, 0)
+	if len(rec.Recommendations) == 0 || !rec.Recommendations[0].Recommended {
+		return ""
+	}
+	return rec.Recommendations[0].ProcessorID
+}
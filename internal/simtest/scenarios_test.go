@@ -0,0 +1,116 @@
+package simtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+var scenarioStart = time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC)
+
+func pixProcessors() []*domain.Processor {
+	return []*domain.Processor{
+		{ID: "processor_a", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodPIX}},
+		{ID: "processor_c", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodPIX}},
+	}
+}
+
+func pixFlow(processorID string, mix Mix) Flow {
+	return Flow{ProcessorID: processorID, Method: domain.MethodPIX, Country: domain.CountryBR, PerMinute: 30, Mix: mix}
+}
+
+// The demo story (scenarios/techcart_outage.json): two-hour processor_a outage and recovery
+func TestScenario_TwoHourOutageAndRecovery(t *testing.T) {
+	h := New(scenarioStart, 42, pixProcessors()...)
+
+	h.Run(30*time.Minute, pixFlow("processor_a", Normal), pixFlow("processor_c", Normal))
+	// Sampling noise on a 50-transaction window may flap HEALTHY/DEGRADED, never DOWN
+	if down, ok := h.FirstTransition("processor_a", domain.StatusDown); ok {
+		t.Fatalf("expected no DOWN during normal traffic, got %+v", down)
+	}
+
+	outageStart := h.Elapsed()
+	h.Run(2*time.Hour, pixFlow("processor_a", Outage), pixFlow("processor_c", Normal))
+
+	down, ok := h.FirstTransition("processor_a", domain.StatusDown)
+	if !ok {
+		t.Fatal("expected processor_a to go DOWN during the outage")
+	}
+	if detect := h.Offset(down.Timestamp) - outageStart; detect > 2*time.Minute {
+		t.Errorf("expected DOWN within 2 minutes of outage start, took %s", detect)
+	}
+	if got := h.Top(domain.MethodPIX, domain.CountryBR); got != "processor_c" {
+		t.Errorf("expected processor_c recommended during outage, got %q", got)
+	}
+
+	recoveryStart := h.Elapsed()
+	h.Run(30*time.Minute, pixFlow("processor_a", Normal), pixFlow("processor_c", Normal))
+
+	var recovered *domain.HealthTransition
+	for _, tr := range h.Transitions("processor_a") {
+		if tr.ToStatus == domain.StatusHealthy && h.Offset(tr.Timestamp) >= recoveryStart {
+			recovered = &tr
+			break
+		}
+	}
+	if recovered == nil {
+		t.Fatal("expected processor_a to recover to HEALTHY")
+	}
+	if took := h.Offset(recovered.Timestamp) - recoveryStart; took > 3*time.Minute {
+		t.Errorf("expected recovery within 3 minutes, took %s", took)
+	}
+	if got := h.Calculator.GetHealth("processor_a").Status; got != domain.StatusHealthy {
+		t.Errorf("expected processor_a HEALTHY at the end, got %s", got)
+	}
+}
+
+// A DOWN processor that stops receiving traffic must not stay DOWN forever
+func TestScenario_DownProcessorGoesIdle(t *testing.T) {
+	h := New(scenarioStart, 7, pixProcessors()...)
+
+	h.Run(5*time.Minute, pixFlow("processor_a", Outage), pixFlow("processor_c", Normal))
+	if got := h.Calculator.GetHealth("processor_a").Status; got != domain.StatusDown {
+		t.Fatalf("expected DOWN, got %s", got)
+	}
+	lastTraffic := h.Elapsed()
+
+	// Merchants route away; only processor_c keeps receiving traffic
+	h.Run(15*time.Minute, pixFlow("processor_c", Normal))
+
+	stale, ok := h.FirstTransition("processor_a", domain.StatusStale)
+	if !ok {
+		t.Fatal("expected processor_a to go STALE")
+	}
+	if at := h.Offset(stale.Timestamp) - lastTraffic; at < 5*time.Minute || at > 5*time.Minute+2*h.interval {
+		t.Errorf("expected STALE about 5 minutes after last traffic, got %s", at)
+	}
+
+	unknown, ok := h.FirstTransition("processor_a", domain.StatusUnknown)
+	if !ok {
+		t.Fatal("expected processor_a to go UNKNOWN once its window expired")
+	}
+	if at := h.Offset(unknown.Timestamp) - lastTraffic; at < 10*time.Minute || at > 10*time.Minute+2*h.interval {
+		t.Errorf("expected UNKNOWN about 10 minutes after last traffic, got %s", at)
+	}
+}
+
+// Same seed, same script: identical transition timelines
+func TestScenario_Deterministic(t *testing.T) {
+	run := func() []domain.HealthTransition {
+		h := New(scenarioStart, 99, pixProcessors()...)
+		h.Run(10*time.Minute, pixFlow("processor_a", Mix{Declined: 0.5, Error: 0.2}))
+		h.Idle(15 * time.Minute)
+		return h.Transitions("")
+	}
+
+	first, second := run(), run()
+	if len(first) == 0 || len(first) != len(second) {
+		t.Fatalf("expected identical non-empty timelines, got %d and %d transitions", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("transition %d differs: %+v vs %+v", i, first[i], second[i])
+		}
+	}
+}
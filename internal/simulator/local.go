@@ -0,0 +1,49 @@
+package simulator
+
+import (
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/simtest"
+)
+
+// Local runs the scenario in-process on a fake clock, so hours of traffic
+// replay in seconds and the same seed always gives the same result
+type Local struct {
+	*simtest.Harness
+}
+
+// NewLocal creates an in-process target starting at the scenario's start
+func NewLocal(s *Scenario) *Local {
+	return &Local{Harness: simtest.New(s.Start, s.Seed)}
+}
+
+// Register adds the processors to the routing engine
+func (l *Local) Register(processors []*domain.Processor) error {
+	for _, p := range processors {
+		l.Engine.RegisterProcessor(p)
+	}
+	return nil
+}
+
+// Wait advances the fake clock, running the scheduler as it comes due
+func (l *Local) Wait(d time.Duration) {
+	l.Advance(d)
+}
+
+// Recommend asks the routing engine directly
+func (l *Local) Recommend(p domain.Payment) (*domain.RoutingRecommendation, error) {
+	return l.Engine.RecommendPayment(p), nil
+}
+
+// Record feeds the outcome to the health calculator and volume caps
+func (l *Local) Record(tx domain.Transaction) error {
+	l.Calculator.RecordTransaction(tx)
+	l.Engine.RecordTransaction(tx)
+	return nil
+}
+
+// Transitions returns transitions recorded since the scenario started
+func (l *Local) Transitions(since time.Time) ([]domain.HealthTransition, error) {
+	return l.Calculator.GetTransitions(since.Add(-time.Nanosecond)), nil
+}
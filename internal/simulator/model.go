@@ -0,0 +1,98 @@
+package simulator
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// defaultProfile applies to processors without a matching profile
+var defaultProfile = Profile{AuthRate: 0.79, ErrorRate: 0.05, LatencyMean: 400, LatencyStdDev: 150}
+
+// behaviour is the effective profile of a processor at a point in time
+type behaviour struct {
+	authRate      float64
+	errorRate     float64
+	latencyMean   float64
+	latencyStdDev float64
+}
+
+// profileFor picks the most specific profile: corridor, then processor-wide
+func (s *Scenario) profileFor(processorID string, method domain.PaymentMethod, country domain.Country) Profile {
+	best, score := defaultProfile, -1
+	for _, p := range s.Profiles {
+		if p.ProcessorID != processorID {
+			continue
+		}
+		if (p.PaymentMethod != "" && p.PaymentMethod != method) || (p.Country != "" && p.Country != country) {
+			continue
+		}
+		specificity := 0
+		if p.PaymentMethod != "" {
+			specificity++
+		}
+		if p.Country != "" {
+			specificity++
+		}
+		if specificity > score {
+			best, score = p, specificity
+		}
+	}
+	return best
+}
+
+// behaviourAt applies active events on top of the base profile
+func (s *Scenario) behaviourAt(offset time.Duration, processorID string, method domain.PaymentMethod, country domain.Country) behaviour {
+	p := s.profileFor(processorID, method, country)
+	b := behaviour{
+		authRate:      p.AuthRate,
+		errorRate:     p.ErrorRate,
+		latencyMean:   p.LatencyMean,
+		latencyStdDev: p.LatencyStdDev,
+	}
+
+	for i := range s.Events {
+		e := &s.Events[i]
+		if !e.active(offset, processorID, method, country) {
+			continue
+		}
+		switch e.Type {
+		case EventOutage, EventCountryFailure:
+			b.errorRate = *e.ErrorRate
+		case EventDegradation:
+			// Ramp linearly from the base profile to the target
+			f := e.progress(offset)
+			if e.AuthRate != nil {
+				b.authRate += (*e.AuthRate - b.authRate) * f
+			}
+			if e.ErrorRate != nil {
+				b.errorRate += (*e.ErrorRate - b.errorRate) * f
+			}
+		case EventLatencySpike:
+			b.latencyMean = *e.LatencyMean
+		}
+	}
+	return b
+}
+
+// draw samples an outcome; latency beyond timeoutAfter becomes a timeout
+func (b behaviour) draw(rng *rand.Rand, timeoutAfter time.Duration) domain.TransactionResult {
+	latencyMs := b.latencyMean + rng.NormFloat64()*b.latencyStdDev
+	if latencyMs < 0 {
+		latencyMs = 0
+	}
+	latency := time.Duration(latencyMs * float64(time.Millisecond))
+
+	r := rng.Float64()
+	switch {
+	case latency > timeoutAfter:
+		return domain.ResultTimeout
+	case r < b.errorRate:
+		return domain.ResultError
+	case rng.Float64() < b.authRate:
+		return domain.ResultApproved
+	default:
+		return domain.ResultDeclined
+	}
+}
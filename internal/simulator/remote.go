@@ -0,0 +1,156 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/api"
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// maxAttempts bounds retries on 429 and 503 responses
+const maxAttempts = 5
+
+// Remote sends the scenario to a running server over HTTP. The server
+// stamps transactions with its own clock, so scenario time is compressed
+// into wall-clock time by Speed: at Speed 60 a simulated hour takes a
+// minute. Health windows are measured in server time, so high speeds
+// squeeze more scenario time into each window.
+type Remote struct {
+	BaseURL  string
+	TenantID string
+	Speed    float64
+	Client   *http.Client
+
+	started time.Time
+}
+
+// NewRemote creates a target for the server at baseURL (e.g. http://localhost:8080)
+func NewRemote(baseURL string, speed float64) *Remote {
+	return &Remote{
+		BaseURL: baseURL,
+		Speed:   speed,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Register posts each processor to the server
+func (r *Remote) Register(processors []*domain.Processor) error {
+	r.started = time.Now()
+	for _, p := range processors {
+		if err := r.do(http.MethodPost, "/api/v1/processors", p, nil); err != nil {
+			return fmt.Errorf("%s: %w", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// Wait sleeps for d scaled down by Speed
+func (r *Remote) Wait(d time.Duration) {
+	if r.Speed > 0 {
+		time.Sleep(time.Duration(float64(d) / r.Speed))
+	}
+}
+
+// Recommend calls the routing endpoint
+func (r *Remote) Recommend(p domain.Payment) (*domain.RoutingRecommendation, error) {
+	var rec domain.RoutingRecommendation
+	req := api.RoutingRequest{
+		TenantID:      r.TenantID,
+		PaymentMethod: string(p.PaymentMethod),
+		Country:       string(p.Country),
+		Amount:        p.Amount,
+		Currency:      p.Currency,
+	}
+	if err := r.do(http.MethodPost, "/api/v1/routing/recommend", req, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Record posts the transaction without a timestamp
+func (r *Remote) Record(tx domain.Transaction) error {
+	return r.do(http.MethodPost, "/api/v1/transactions", api.TransactionRequest{
+		ID:            fmt.Sprintf("%s-%d", tx.ID, r.started.UnixNano()),
+		TenantID:      r.TenantID,
+		ProcessorID:   tx.ProcessorID,
+		Result:        string(tx.Result),
+		PaymentMethod: string(tx.PaymentMethod),
+		Country:       string(tx.Country),
+		Amount:        tx.Amount,
+		Currency:      tx.Currency,
+		DecisionID:    tx.DecisionID,
+	}, nil)
+}
+
+// Transitions fetches alerts raised since the run started, with server
+// timestamps mapped back onto the scenario timeline starting at start
+func (r *Remote) Transitions(start time.Time) ([]domain.HealthTransition, error) {
+	var resp struct {
+		Alerts []domain.HealthTransition `json:"alerts"`
+	}
+	path := "/api/v1/alerts?since=" + url.QueryEscape(r.started.UTC().Format(time.RFC3339))
+	if err := r.do(http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	for i := range resp.Alerts {
+		elapsed := resp.Alerts[i].Timestamp.Sub(r.started)
+		if r.Speed > 0 {
+			elapsed = time.Duration(float64(elapsed) * r.Speed)
+		}
+		resp.Alerts[i].Timestamp = start.Add(elapsed)
+	}
+	return resp.Alerts, nil
+}
+
+// do sends a JSON request, backing off when rate limited or shed
+func (r *Remote) do(method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(method, r.BaseURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if r.TenantID != "" {
+			req.Header.Set(api.TenantHeader, r.TenantID)
+		}
+
+		resp, err := r.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		if retryable && attempt < maxAttempts {
+			wait, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			time.Sleep(time.Duration(max(wait, 1)) * time.Second)
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(data))
+		}
+		if out != nil {
+			return json.Unmarshal(data, out)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,263 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// Target is where simulated payments are routed and reported: the routing
+// engine in-process, or a running server
+type Target interface {
+	// Register makes the scenario's processors known to the target
+	Register(processors []*domain.Processor) error
+	// Wait lets d of scenario time pass
+	Wait(d time.Duration)
+	// Recommend asks for a routing decision for the corridor
+	Recommend(p domain.Payment) (*domain.RoutingRecommendation, error)
+	// Record reports a payment outcome
+	Record(tx domain.Transaction) error
+	// Transitions returns health transitions since the scenario started
+	Transitions(since time.Time) ([]domain.HealthTransition, error)
+}
+
+// Tally counts outcomes of payments sent to one processor
+type Tally struct {
+	Payments int `json:"payments"`
+	Approved int `json:"approved"`
+	Declined int `json:"declined"`
+	Errors   int `json:"errors"`
+	Timeouts int `json:"timeouts"`
+}
+
+func (t *Tally) add(result domain.TransactionResult) {
+	t.Payments++
+	switch result {
+	case domain.ResultApproved:
+		t.Approved++
+	case domain.ResultDeclined:
+		t.Declined++
+	case domain.ResultError:
+		t.Errors++
+	case domain.ResultTimeout:
+		t.Timeouts++
+	}
+}
+
+// EventResult shows how routing reacted to one event: of the payments the
+// affected processor could have taken while the event was active, how many
+// were still sent to it
+type EventResult struct {
+	Event            Event `json:"event"`
+	Payments         int   `json:"payments"`
+	RoutedToAffected int   `json:"routed_to_affected"`
+}
+
+// Result summarizes a simulation run
+type Result struct {
+	Scenario    string                    `json:"scenario"`
+	Seed        int64                     `json:"seed"`
+	Total       Tally                     `json:"total"`
+	AuthRate    float64                   `json:"auth_rate"` // approved / payments
+	Unroutable  int                       `json:"unroutable"`
+	Fallbacks   int                       `json:"fallbacks"`
+	Processors  map[string]*Tally         `json:"processors"`
+	Events      []EventResult             `json:"events"`
+	Transitions []domain.HealthTransition `json:"transitions"`
+	Errors      int                       `json:"target_errors"`
+}
+
+// Runner plays a scenario against a target
+type Runner struct {
+	scenario *Scenario
+	target   Target
+	seed     int64
+	rng      *rand.Rand
+	seq      int
+
+	// Progress, if set, is called after every simulated minute
+	Progress func(offset time.Duration, r *Result)
+	// OnPayment, if set, is called with every payment the target accepted
+	OnPayment func(tx domain.Transaction)
+}
+
+// NewRunner creates a runner; seed overrides the scenario's seed when non-zero
+func NewRunner(s *Scenario, target Target, seed int64) *Runner {
+	if seed == 0 {
+		seed = s.Seed
+	}
+	return &Runner{
+		scenario: s,
+		target:   target,
+		seed:     seed,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Run generates payments with exponential inter-arrival times for the
+// scenario's duration. Each payment is routed to the recommended processor,
+// or to the best-ranked one when nothing is recommended, and its outcome is
+// drawn from that processor's behaviour at that moment.
+func (r *Runner) Run() (*Result, error) {
+	s := r.scenario
+	if err := r.target.Register(s.Processors); err != nil {
+		return nil, fmt.Errorf("registering processors: %w", err)
+	}
+
+	result := &Result{
+		Scenario:   s.Name,
+		Seed:       r.seed,
+		Processors: make(map[string]*Tally),
+		Events:     make([]EventResult, len(s.Events)),
+	}
+	for i, e := range s.Events {
+		result.Events[i].Event = e
+	}
+	for _, p := range s.Processors {
+		result.Processors[p.ID] = &Tally{}
+	}
+
+	perSecond := s.RatePerMinute / 60
+	total := time.Duration(s.Duration)
+	var offset time.Duration
+	nextMinute := time.Minute
+
+	for {
+		gap := time.Duration(r.rng.ExpFloat64() / perSecond * float64(time.Second))
+		if offset+gap >= total {
+			r.target.Wait(total - offset)
+			break
+		}
+		r.target.Wait(gap)
+		offset += gap
+
+		for r.Progress != nil && offset >= nextMinute {
+			r.Progress(nextMinute, result)
+			nextMinute += time.Minute
+		}
+
+		r.payment(offset, result)
+	}
+
+	transitions, err := r.target.Transitions(s.Start)
+	if err != nil {
+		return nil, fmt.Errorf("fetching transitions: %w", err)
+	}
+	result.Transitions = transitions
+	if result.Total.Payments > 0 {
+		result.AuthRate = float64(result.Total.Approved) / float64(result.Total.Payments)
+	}
+	return result, nil
+}
+
+// payment simulates a single payment at offset into the scenario
+func (r *Runner) payment(offset time.Duration, result *Result) {
+	s := r.scenario
+	c := r.corridor()
+	amount := c.MinAmount + r.rng.Float64()*(c.MaxAmount-c.MinAmount)
+
+	rec, err := r.target.Recommend(domain.Payment{
+		PaymentMethod: c.PaymentMethod,
+		Country:       c.Country,
+		Amount:        amount,
+		Currency:      c.Currency,
+	})
+	if err != nil {
+		result.Errors++
+		return
+	}
+	if len(rec.Recommendations) == 0 {
+		result.Unroutable++
+		return
+	}
+	top := rec.Recommendations[0]
+	if !top.Recommended {
+		result.Fallbacks++
+	}
+
+	outcome := s.behaviourAt(offset, top.ProcessorID, c.PaymentMethod, c.Country).
+		draw(r.rng, time.Duration(s.TimeoutAfter))
+
+	r.seq++
+	tx := domain.Transaction{
+		ID:            fmt.Sprintf("sim-%d", r.seq),
+		ProcessorID:   top.ProcessorID,
+		Timestamp:     s.Start.Add(offset),
+		Result:        outcome,
+		PaymentMethod: c.PaymentMethod,
+		Country:       c.Country,
+		Amount:        amount,
+		Currency:      c.Currency,
+		DecisionID:    rec.DecisionID,
+	}
+	if err := r.target.Record(tx); err != nil {
+		result.Errors++
+		return
+	}
+	if r.OnPayment != nil {
+		r.OnPayment(tx)
+	}
+
+	result.Total.add(outcome)
+	if result.Processors[tx.ProcessorID] == nil {
+		result.Processors[tx.ProcessorID] = &Tally{}
+	}
+	result.Processors[tx.ProcessorID].add(outcome)
+
+	for i := range s.Events {
+		e := &s.Events[i]
+		if !e.active(offset, e.ProcessorID, c.PaymentMethod, c.Country) || !r.supports(e.ProcessorID, c) {
+			continue
+		}
+		result.Events[i].Payments++
+		if tx.ProcessorID == e.ProcessorID {
+			result.Events[i].RoutedToAffected++
+		}
+	}
+}
+
+// corridor picks a corridor according to the weights
+func (r *Runner) corridor() Corridor {
+	var sum float64
+	for _, c := range r.scenario.Corridors {
+		sum += c.Weight
+	}
+	x := r.rng.Float64() * sum
+	for _, c := range r.scenario.Corridors {
+		if x < c.Weight {
+			return c
+		}
+		x -= c.Weight
+	}
+	return r.scenario.Corridors[len(r.scenario.Corridors)-1]
+}
+
+func (r *Runner) supports(processorID string, c Corridor) bool {
+	for _, p := range r.scenario.Processors {
+		if p.ID != processorID {
+			continue
+		}
+		method, country := false, false
+		for _, m := range p.PaymentMethods {
+			method = method || m == c.PaymentMethod
+		}
+		for _, k := range p.Countries {
+			country = country || k == c.Country
+		}
+		return method && country
+	}
+	return false
+}
+
+// ProcessorIDs returns the processors in the result in a stable order
+func (res *Result) ProcessorIDs() []string {
+	ids := make([]string, 0, len(res.Processors))
+	for id := range res.Processors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
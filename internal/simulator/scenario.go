@@ -0,0 +1,241 @@
+// Package simulator generates payment traffic from a scenario file:
+// per-corridor base behaviour for each processor plus timed events such as
+// outages, gradual degradation, latency spikes and country failures. Every
+// simulated payment is routed through Recommend before its outcome is drawn,
+// so the scenario exercises the full feedback loop.
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// Event types
+const (
+	EventOutage         = "outage"          // error rate jumps for the processor
+	EventDegradation    = "degradation"     // auth rate ramps down over the event
+	EventLatencySpike   = "latency_spike"   // latency rises, causing timeouts
+	EventCountryFailure = "country_failure" // one country fails for the processor
+)
+
+// DefaultTimeoutAfter is the latency above which a payment times out
+const DefaultTimeoutAfter = 3 * time.Second
+
+// Duration is a time.Duration written as a string like "90m" in scenarios
+type Duration time.Duration
+
+// UnmarshalJSON parses a time.Duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON writes the duration in time.Duration string form
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Scenario describes processors, traffic and timed events
+type Scenario struct {
+	Name          string              `json:"name"`
+	Seed          int64               `json:"seed"`
+	Start         time.Time           `json:"start"`
+	Duration      Duration            `json:"duration"`
+	RatePerMinute float64             `json:"rate_per_minute"`
+	TimeoutAfter  Duration            `json:"timeout_after"`
+	Processors    []*domain.Processor `json:"processors"`
+	Corridors     []Corridor          `json:"corridors"`
+	Profiles      []Profile           `json:"profiles"`
+	Events        []Event             `json:"events"`
+}
+
+// Corridor is a share of traffic for a payment method and country
+type Corridor struct {
+	PaymentMethod domain.PaymentMethod `json:"payment_method"`
+	Country       domain.Country       `json:"country"`
+	Weight        float64              `json:"weight"`
+	Currency      string               `json:"currency"`
+	MinAmount     float64              `json:"min_amount"`
+	MaxAmount     float64              `json:"max_amount"`
+}
+
+// Profile is a processor's base behaviour, optionally for one corridor.
+// AuthRate is approved / (approved + declined); ErrorRate is the share of
+// attempts that fail technically.
+type Profile struct {
+	ProcessorID   string               `json:"processor_id"`
+	PaymentMethod domain.PaymentMethod `json:"payment_method,omitempty"`
+	Country       domain.Country       `json:"country,omitempty"`
+	AuthRate      float64              `json:"auth_rate"`
+	ErrorRate     float64              `json:"error_rate"`
+	LatencyMean   float64              `json:"latency_ms"`
+	LatencyStdDev float64              `json:"latency_stddev_ms"`
+}
+
+// Event changes a processor's behaviour for a period. Country and
+// PaymentMethod narrow the event to part of the processor's traffic.
+type Event struct {
+	Type          string               `json:"type"`
+	ProcessorID   string               `json:"processor_id"`
+	PaymentMethod domain.PaymentMethod `json:"payment_method,omitempty"`
+	Country       domain.Country       `json:"country,omitempty"`
+	Start         Duration             `json:"start"`
+	Duration      Duration             `json:"duration"`
+	ErrorRate     *float64             `json:"error_rate,omitempty"`
+	AuthRate      *float64             `json:"auth_rate,omitempty"`
+	LatencyMean   *float64             `json:"latency_ms,omitempty"`
+}
+
+// Load reads and validates a scenario file, filling in defaults
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Scenario
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.Prepare(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Prepare fills defaults and validates the scenario
+func (s *Scenario) Prepare() error {
+	if s.Start.IsZero() {
+		s.Start = time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC)
+	}
+	if s.TimeoutAfter == 0 {
+		s.TimeoutAfter = Duration(DefaultTimeoutAfter)
+	}
+	if s.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if s.RatePerMinute <= 0 {
+		return fmt.Errorf("rate_per_minute must be positive")
+	}
+	if len(s.Processors) == 0 || len(s.Corridors) == 0 {
+		return fmt.Errorf("at least one processor and one corridor are required")
+	}
+
+	known := make(map[string]bool)
+	for _, p := range s.Processors {
+		known[p.ID] = true
+	}
+	for i, c := range s.Corridors {
+		if c.Weight <= 0 {
+			return fmt.Errorf("corridors[%d]: weight must be positive", i)
+		}
+		if c.MaxAmount < c.MinAmount {
+			return fmt.Errorf("corridors[%d]: max_amount below min_amount", i)
+		}
+	}
+	for i, p := range s.Profiles {
+		if !known[p.ProcessorID] {
+			return fmt.Errorf("profiles[%d]: unknown processor %q", i, p.ProcessorID)
+		}
+		if err := rate("auth_rate", p.AuthRate); err != nil {
+			return fmt.Errorf("profiles[%d]: %w", i, err)
+		}
+		if err := rate("error_rate", p.ErrorRate); err != nil {
+			return fmt.Errorf("profiles[%d]: %w", i, err)
+		}
+	}
+	for i := range s.Events {
+		if err := s.Events[i].prepare(known); err != nil {
+			return fmt.Errorf("events[%d] (%s): %w", i, s.Events[i].Type, err)
+		}
+	}
+	return nil
+}
+
+func (e *Event) prepare(known map[string]bool) error {
+	if !known[e.ProcessorID] {
+		return fmt.Errorf("unknown processor %q", e.ProcessorID)
+	}
+	if e.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+
+	switch e.Type {
+	case EventOutage:
+		e.ErrorRate = orDefault(e.ErrorRate, 0.9)
+	case EventCountryFailure:
+		if e.Country == "" {
+			return fmt.Errorf("country is required")
+		}
+		e.ErrorRate = orDefault(e.ErrorRate, 1.0)
+	case EventDegradation:
+		if e.AuthRate == nil && e.ErrorRate == nil {
+			return fmt.Errorf("auth_rate or error_rate target is required")
+		}
+	case EventLatencySpike:
+		if e.LatencyMean == nil {
+			return fmt.Errorf("latency_ms is required")
+		}
+	default:
+		return fmt.Errorf("unknown event type")
+	}
+
+	if e.ErrorRate != nil {
+		if err := rate("error_rate", *e.ErrorRate); err != nil {
+			return err
+		}
+	}
+	if e.AuthRate != nil {
+		return rate("auth_rate", *e.AuthRate)
+	}
+	return nil
+}
+
+func orDefault(v *float64, def float64) *float64 {
+	if v != nil {
+		return v
+	}
+	return &def
+}
+
+func rate(name string, v float64) error {
+	if v < 0 || v > 1 {
+		return fmt.Errorf("%s must be between 0 and 1, got %g", name, v)
+	}
+	return nil
+}
+
+// active reports whether the event applies at offset for the corridor
+func (e *Event) active(offset time.Duration, processorID string, method domain.PaymentMethod, country domain.Country) bool {
+	if e.ProcessorID != processorID {
+		return false
+	}
+	if e.PaymentMethod != "" && e.PaymentMethod != method {
+		return false
+	}
+	if e.Country != "" && e.Country != country {
+		return false
+	}
+	start := time.Duration(e.Start)
+	return offset >= start && offset < start+time.Duration(e.Duration)
+}
+
+// progress is how far through the event offset is, from 0 to 1
+func (e *Event) progress(offset time.Duration) float64 {
+	return float64(offset-time.Duration(e.Start)) / float64(e.Duration)
+}
@@ -0,0 +1,235 @@
+package simulator
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/api"
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/idempotency"
+	"github.com/yuno/techcart-failover/internal/ingest"
+	"github.com/yuno/techcart-failover/internal/tenant"
+)
+
+func TestLoad_Scenarios(t *testing.T) {
+	paths, _ := filepath.Glob("../../scenarios/*.json")
+	if len(paths) == 0 {
+		t.Fatal("no scenario files found")
+	}
+	for _, path := range paths {
+		if _, err := Load(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func TestPrepare_Rejects(t *testing.T) {
+	tests := map[string]string{
+		"unknown processor": `"events":[{"type":"outage","processor_id":"nope","start":"1m","duration":"1m"}]`,
+		"country required":  `"events":[{"type":"country_failure","processor_id":"p1","start":"1m","duration":"1m"}]`,
+		"unknown type":      `"events":[{"type":"meteor","processor_id":"p1","start":"1m","duration":"1m"}]`,
+		"bad rate":          `"profiles":[{"processor_id":"p1","auth_rate":1.5}]`,
+		"unknown field":     `"colour":"blue"`,
+	}
+	for name, extra := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writeScenario(t, `{
+				"duration": "10m", "rate_per_minute": 10,
+				"processors": [{"id":"p1","countries":["BR"],"payment_methods":["PIX"]}],
+				"corridors": [{"payment_method":"PIX","country":"BR","weight":1}],
+				`+extra+`}`)
+			if _, err := Load(path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestBehaviourAt_DegradationRamps(t *testing.T) {
+	target := 0.2
+	s := testScenario()
+	s.Events = []Event{{Type: EventDegradation, ProcessorID: "primary", Start: Duration(time.Hour), Duration: Duration(time.Hour), AuthRate: &target}}
+	if err := s.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	before := s.behaviourAt(30*time.Minute, "primary", domain.MethodPIX, domain.CountryBR).authRate
+	half := s.behaviourAt(90*time.Minute, "primary", domain.MethodPIX, domain.CountryBR).authRate
+	after := s.behaviourAt(3*time.Hour, "primary", domain.MethodPIX, domain.CountryBR).authRate
+
+	if before != 0.9 || after != 0.9 {
+		t.Errorf("expected base rate outside the event, got %.2f and %.2f", before, after)
+	}
+	if half < 0.54 || half > 0.56 {
+		t.Errorf("expected auth rate halfway to target (0.55), got %.3f", half)
+	}
+}
+
+func TestBehaviourAt_CountryFailureIsScoped(t *testing.T) {
+	s := testScenario()
+	s.Events = []Event{{Type: EventCountryFailure, ProcessorID: "primary", Country: domain.CountryMX, Start: 0, Duration: Duration(time.Hour)}}
+	if err := s.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.behaviourAt(time.Minute, "primary", domain.MethodCard, domain.CountryMX).errorRate; got != 1.0 {
+		t.Errorf("expected MX to fail, got error rate %.2f", got)
+	}
+	if got := s.behaviourAt(time.Minute, "primary", domain.MethodPIX, domain.CountryBR).errorRate; got != 0.02 {
+		t.Errorf("expected BR unaffected, got error rate %.2f", got)
+	}
+}
+
+func TestDraw_LatencyBeyondTimeoutTimesOut(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	b := behaviour{authRate: 1, latencyMean: 5000}
+	for i := 0; i < 100; i++ {
+		if got := b.draw(rng, 3*time.Second); got != domain.ResultTimeout {
+			t.Fatalf("expected timeout, got %s", got)
+		}
+	}
+}
+
+func TestRunner_FailsOverDuringOutage(t *testing.T) {
+	s := testScenario()
+	s.Events = []Event{{Type: EventOutage, ProcessorID: "primary", Start: Duration(time.Hour), Duration: Duration(time.Hour)}}
+	if err := s.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewRunner(s, NewLocal(s), 0).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outage := result.Events[0]
+	if outage.Payments < 500 {
+		t.Fatalf("expected ~600 payments during the outage, got %d", outage.Payments)
+	}
+	// Detection takes a few dozen payments; after that traffic moves away
+	if share := float64(outage.RoutedToAffected) / float64(outage.Payments); share > 0.15 {
+		t.Errorf("expected failover, but %.0f%% of outage traffic still went to primary", share*100)
+	}
+
+	// Traffic may move away while primary is DEGRADED, before it hits DOWN
+	flagged := false
+	for _, tr := range result.Transitions {
+		if tr.ProcessorID == "primary" && (tr.ToStatus == domain.StatusDegraded || tr.ToStatus == domain.StatusDown) {
+			flagged = true
+		}
+	}
+	if !flagged {
+		t.Error("expected primary to be flagged during the outage")
+	}
+}
+
+func TestRunner_Deterministic(t *testing.T) {
+	run := func() *Result {
+		s := testScenario()
+		s.Events = []Event{{Type: EventLatencySpike, ProcessorID: "primary", Start: Duration(30 * time.Minute), Duration: Duration(time.Hour), LatencyMean: ptr(4000)}}
+		if err := s.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		result, err := NewRunner(s, NewLocal(s), 99).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	a, b := run(), run()
+	if !reflect.DeepEqual(a, b) {
+		t.Error("expected identical results for the same seed")
+	}
+	if a.Total.Timeouts == 0 {
+		t.Error("expected the latency spike to cause timeouts")
+	}
+}
+
+func TestRunner_RemoteTarget(t *testing.T) {
+	clk := clock.Real()
+	tenants := tenant.NewRegistry(health.NewCalculatorWithClock(clk), clk)
+	tenants.Get("sim")
+	queue := ingest.NewQueue(ingest.DefaultDepth, 1, tenants.RecordTransactionContext)
+	defer queue.Close()
+	mux := http.NewServeMux()
+	api.NewHandler(tenants, idempotency.NewStore(time.Hour), queue).RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s := testScenario()
+	s.Duration = Duration(10 * time.Minute)
+	if err := s.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Speed 0 sends as fast as possible
+	remote := NewRemote(server.URL, 0)
+	remote.TenantID = "sim"
+	result, err := NewRunner(s, remote, 0).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Errors > 0 || result.Total.Payments == 0 {
+		t.Fatalf("expected clean run, got %d payments and %d errors", result.Total.Payments, result.Errors)
+	}
+
+	h := tenants.Get("sim").Calculator.GetHealth("primary")
+	if h.TotalTransactions == 0 {
+		t.Error("expected the server to have recorded transactions for primary")
+	}
+
+	// Every payment echoes its decision ID and follows the recommendation
+	if report := tenants.Get("sim").Feedback.Report("sim"); report.Total.Followed.Transactions != result.Total.Payments-result.Fallbacks {
+		t.Errorf("expected %d followed transactions, got %d", result.Total.Payments-result.Fallbacks, report.Total.Followed.Transactions)
+	}
+}
+
+// Helper functions
+
+func testScenario() *Scenario {
+	return &Scenario{
+		Name:          "test",
+		Seed:          1,
+		Duration:      Duration(3 * time.Hour),
+		RatePerMinute: 10,
+		Processors: []*domain.Processor{
+			{ID: "primary", Countries: []domain.Country{domain.CountryBR, domain.CountryMX}, PaymentMethods: []domain.PaymentMethod{domain.MethodPIX, domain.MethodCard}},
+			{ID: "backup", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodPIX}},
+		},
+		Corridors: []Corridor{
+			{PaymentMethod: domain.MethodPIX, Country: domain.CountryBR, Weight: 1, Currency: "BRL", MinAmount: 10, MaxAmount: 500},
+		},
+		Profiles: []Profile{
+			{ProcessorID: "primary", AuthRate: 0.9, ErrorRate: 0.02, LatencyMean: 300, LatencyStdDev: 50},
+			{ProcessorID: "backup", AuthRate: 0.85, ErrorRate: 0.03, LatencyMean: 400, LatencyStdDev: 50},
+		},
+	}
+}
+
+func writeScenario(t *testing.T, body string) string {
+	t.Helper()
+	var check map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &check); err != nil {
+		t.Fatalf("test scenario is not valid JSON: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(strings.TrimSpace(body)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
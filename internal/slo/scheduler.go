@@ -0,0 +1,24 @@
+package slo
+
+import (
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/schedule"
+)
+
+// DefaultEvaluateInterval is how often burn rates are evaluated
+const DefaultEvaluateInterval = 30 * time.Second
+
+// Scheduler periodically evaluates burn rates, so alerts fire and resolve
+// whether or not transactions keep arriving. OnEvent receives every alert.
+type Scheduler = schedule.Scheduler[*Tracker, domain.SLOAlert]
+
+// NewScheduler creates a scheduler over the trackers returned by source
+func NewScheduler(clk clock.Clock, interval time.Duration, source func() []*Tracker) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultEvaluateInterval
+	}
+	return schedule.New(clk, interval, source, (*Tracker).Evaluate)
+}
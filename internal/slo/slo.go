@@ -0,0 +1,163 @@
+// Package slo tracks service level objectives on processor outcomes and
+// raises multi-window burn-rate alerts when a processor consumes its error
+// budget too fast, catching slow erosion that status thresholds miss.
+package slo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// SLO defaults
+const (
+	DefaultObjective  = 0.99                // Share of good events
+	DefaultWindow     = 30 * 24 * time.Hour // Period the objective applies to
+	MinEvents         = 10                  // Events needed in a long window before it can alert
+	MaxSkew           = time.Minute         // Transactions further in the future are not counted
+	CorrectionHorizon = 24 * time.Hour      // How long a transaction ID can be corrected
+)
+
+// Indicator selects which transactions count as good and bad
+type Indicator string
+
+const (
+	// IndicatorErrors: good when not an error or timeout
+	IndicatorErrors Indicator = "errors"
+	// IndicatorAuthorization: good when approved, bad when declined (errors are ignored)
+	IndicatorAuthorization Indicator = "authorization"
+)
+
+// SLO is an objective on the transactions of a processor, or of every
+// processor when ProcessorID is empty, optionally narrowed to a corridor
+type SLO struct {
+	ID            string               `json:"id"`
+	ProcessorID   string               `json:"processor_id,omitempty"`
+	PaymentMethod domain.PaymentMethod `json:"payment_method,omitempty"`
+	Country       domain.Country       `json:"country,omitempty"`
+	Indicator     Indicator            `json:"indicator"`
+	Objective     float64              `json:"objective"`
+	Window        time.Duration        `json:"window"`
+}
+
+// DefaultSLOs returns the SLOs every tenant starts with
+func DefaultSLOs() []SLO {
+	return []SLO{{
+		ID:        "availability",
+		Indicator: IndicatorErrors,
+		Objective: DefaultObjective,
+		Window:    DefaultWindow,
+	}}
+}
+
+// Validate reports the first invalid setting
+func (s SLO) Validate() error {
+	switch {
+	case s.ID == "":
+		return fmt.Errorf("id is required")
+	case s.Indicator != IndicatorErrors && s.Indicator != IndicatorAuthorization:
+		return fmt.Errorf("indicator must be %q or %q, got %q", IndicatorErrors, IndicatorAuthorization, s.Indicator)
+	case s.Objective <= 0 || s.Objective >= 1:
+		return fmt.Errorf("objective must be between 0 and 1 (exclusive), got %g", s.Objective)
+	case s.Window < time.Hour || s.Window > 90*24*time.Hour:
+		return fmt.Errorf("window must be between 1h and 90 days, got %s", s.Window)
+	}
+	return nil
+}
+
+// matches reports whether tx falls under the SLO
+func (s SLO) matches(tx domain.Transaction) bool {
+	return (s.ProcessorID == "" || s.ProcessorID == tx.ProcessorID) &&
+		(s.PaymentMethod == "" || s.PaymentMethod == tx.PaymentMethod) &&
+		(s.Country == "" || s.Country == tx.Country)
+}
+
+// classify returns a result's good and bad events (both zero when the
+// indicator ignores it)
+func (s SLO) classify(result domain.TransactionResult) (good, bad int) {
+	switch {
+	case result == domain.ResultError || result == domain.ResultTimeout:
+		if s.Indicator == IndicatorErrors {
+			return 0, 1
+		}
+	case result == domain.ResultApproved:
+		return 1, 0
+	case result == domain.ResultDeclined:
+		if s.Indicator == IndicatorErrors {
+			return 1, 0
+		}
+		return 0, 1
+	}
+	return 0, 0
+}
+
+// sloJSON mirrors SLO with the window written as a string like "720h"
+type sloJSON struct {
+	ID            string               `json:"id"`
+	ProcessorID   string               `json:"processor_id,omitempty"`
+	PaymentMethod domain.PaymentMethod `json:"payment_method,omitempty"`
+	Country       domain.Country       `json:"country,omitempty"`
+	Indicator     Indicator            `json:"indicator"`
+	Objective     float64              `json:"objective"`
+	Window        string               `json:"window"`
+}
+
+// MarshalJSON writes the window in time.Duration string form
+func (s SLO) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sloJSON{
+		ID:            s.ID,
+		ProcessorID:   s.ProcessorID,
+		PaymentMethod: s.PaymentMethod,
+		Country:       s.Country,
+		Indicator:     s.Indicator,
+		Objective:     s.Objective,
+		Window:        s.Window.String(),
+	})
+}
+
+// UnmarshalJSON reads an SLO; indicator, objective and window default to
+// an error-rate objective of 99% over 30 days
+func (s *SLO) UnmarshalJSON(data []byte) error {
+	raw := sloJSON{Indicator: IndicatorErrors, Objective: DefaultObjective}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	window := DefaultWindow
+	if raw.Window != "" {
+		var err error
+		if window, err = time.ParseDuration(raw.Window); err != nil {
+			return fmt.Errorf("window: %w", err)
+		}
+	}
+	*s = SLO{
+		ID:            raw.ID,
+		ProcessorID:   raw.ProcessorID,
+		PaymentMethod: raw.PaymentMethod,
+		Country:       raw.Country,
+		Indicator:     raw.Indicator,
+		Objective:     raw.Objective,
+		Window:        window,
+	}
+	return nil
+}
+
+// BurnWindow is a pair of windows that alert together: the long one shows
+// the budget burn is significant, the short one that it is still going on
+type BurnWindow struct {
+	Name      string
+	Long      time.Duration
+	Short     time.Duration
+	Threshold float64 // Burn rate (multiple of the sustainable rate) that alerts
+}
+
+// DefaultBurnWindows returns the fast (1h/5m at 14.4x, 2% of a 30-day budget
+// in an hour) and slow (6h/30m at 6x, 5% in six hours) alert pairs
+func DefaultBurnWindows() []BurnWindow {
+	return []BurnWindow{
+		{Name: "fast", Long: time.Hour, Short: 5 * time.Minute, Threshold: 14.4},
+		{Name: "slow", Long: 6 * time.Hour, Short: 30 * time.Minute, Threshold: 6},
+	}
+}
@@ -0,0 +1,389 @@
+package slo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+// events counts good and bad events per fixed bucket in a ring: bucket n
+// lives at n % len, so a slot holding a newer bucket means n has expired
+type events struct {
+	step    time.Duration
+	buckets []eventBucket
+}
+
+type eventBucket struct {
+	num       int64
+	good, bad int
+}
+
+func newEvents(step, span time.Duration) *events {
+	return &events{step: step, buckets: make([]eventBucket, span/step)}
+}
+
+func (e *events) number(t time.Time) int64 {
+	return t.UnixNano() / int64(e.step)
+}
+
+// slot is where bucket num lives, also for buckets before 1970
+func (e *events) slot(num int64) *eventBucket {
+	n := int64(len(e.buckets))
+	return &e.buckets[(num%n+n)%n]
+}
+
+// add counts (or with negative counts, uncounts) events in the bucket of
+// at. Events older than the ring's span or beyond MaxSkew of now are dropped.
+func (e *events) add(at, now time.Time, good, bad int) {
+	num, last := e.number(at), e.number(now.Add(MaxSkew))
+	if num > last || num <= last-int64(len(e.buckets)) {
+		return
+	}
+	b := e.slot(num)
+	if b.num != num {
+		// Past the span, or a correction for a bucket already recycled
+		if b.num > num || good < 0 || bad < 0 {
+			return
+		}
+		*b = eventBucket{num: num}
+	}
+	b.good += good
+	b.bad += bad
+}
+
+// sum adds up the buckets covering the span ending at now
+func (e *events) sum(now time.Time, span time.Duration) (good, bad int) {
+	last := e.number(now)
+	for num := last - int64(span/e.step) + 1; num <= last; num++ {
+		if b := e.slot(num); b.num == num {
+			good += b.good
+			bad += b.bad
+		}
+	}
+	return good, bad
+}
+
+// target is one processor measured against one SLO
+type target struct {
+	slo         SLO
+	processorID string
+	minutes     *events // for burn-rate windows
+	hours       *events // for the SLO window
+	firing      map[string]bool
+}
+
+type targetKey struct {
+	sloID       string
+	processorID string
+}
+
+// report is what one transaction added to each target, kept so a later
+// report with the same ID replaces it instead of adding to it
+type report struct {
+	at      time.Time
+	counted []counted
+}
+
+type counted struct {
+	target    *target
+	good, bad int
+}
+
+// reportID is a transaction ID in the order its first report arrived
+type reportID struct {
+	id   string
+	seen time.Time
+}
+
+// Tracker measures a tenant's processors against its SLOs and keeps the
+// burn-rate alerts raised. SLOs only count transactions recorded after they
+// were set.
+type Tracker struct {
+	mu       sync.RWMutex
+	tenantID string
+	windows  []BurnWindow
+	slos     []SLO
+	targets  map[targetKey]*target
+	alerts   []domain.SLOAlert
+	reports  map[string]*report // by transaction ID, for CorrectionHorizon
+	order    []reportID
+}
+
+// NewTracker creates a tracker for a tenant with the default SLOs and
+// burn-rate windows
+func NewTracker(tenantID string) *Tracker {
+	return &Tracker{
+		tenantID: tenantID,
+		windows:  DefaultBurnWindows(),
+		slos:     DefaultSLOs(),
+		targets:  make(map[targetKey]*target),
+		reports:  make(map[string]*report),
+	}
+}
+
+// SLOs returns the tracked SLOs sorted by ID
+func (t *Tracker) SLOs() []SLO {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]SLO(nil), t.slos...)
+}
+
+// Set adds an SLO or replaces the one with the same ID, discarding what
+// was measured for it
+func (t *Tracker) Set(s SLO) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.drop(s.ID)
+	t.slos = append(t.slos, s)
+	sort.Slice(t.slos, func(i, j int) bool { return t.slos[i].ID < t.slos[j].ID })
+	return nil
+}
+
+// Remove deletes an SLO and reports whether it existed
+func (t *Tracker) Remove(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.drop(id)
+}
+
+// drop removes an SLO and its targets. Caller must hold t.mu.
+func (t *Tracker) drop(id string) bool {
+	found := false
+	kept := t.slos[:0]
+	for _, s := range t.slos {
+		if s.ID == id {
+			found = true
+			continue
+		}
+		kept = append(kept, s)
+	}
+	t.slos = kept
+	for k := range t.targets {
+		if k.sloID == id {
+			delete(t.targets, k)
+		}
+	}
+	return found
+}
+
+// Record counts tx against every SLO it falls under, as of now. A
+// transaction whose ID was reported within CorrectionHorizon replaces the
+// earlier report (e.g. a timeout later resolved as approved).
+func (t *Tracker) Record(tx domain.Transaction, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forget(now.Add(-CorrectionHorizon))
+
+	if tx.ID != "" {
+		if prev, exists := t.reports[tx.ID]; exists {
+			for _, c := range prev.counted {
+				c.target.add(prev.at, now, -c.good, -c.bad)
+			}
+			prev.at, prev.counted = tx.Timestamp, t.count(tx, now)
+			return
+		}
+	}
+
+	counted := t.count(tx, now)
+	if tx.ID != "" {
+		t.reports[tx.ID] = &report{at: tx.Timestamp, counted: counted}
+		t.order = append(t.order, reportID{id: tx.ID, seen: now})
+	}
+}
+
+// count adds tx to the targets of the SLOs it falls under and returns what
+// it added. Caller must hold t.mu.
+func (t *Tracker) count(tx domain.Transaction, now time.Time) []counted {
+	var result []counted
+	for _, s := range t.slos {
+		// Outside the SLO window, or too far ahead to be a real report
+		if !s.matches(tx) || tx.Timestamp.After(now.Add(MaxSkew)) || !tx.Timestamp.After(now.Add(-s.Window)) {
+			continue
+		}
+		good, bad := s.classify(tx.Result)
+		if good+bad == 0 {
+			continue
+		}
+
+		k := targetKey{sloID: s.ID, processorID: tx.ProcessorID}
+		tg, exists := t.targets[k]
+		if !exists {
+			tg = &target{
+				slo:         s,
+				processorID: tx.ProcessorID,
+				minutes:     newEvents(time.Minute, t.longestWindow()),
+				hours:       newEvents(time.Hour, s.Window),
+				firing:      make(map[string]bool),
+			}
+			t.targets[k] = tg
+		}
+		tg.add(tx.Timestamp, now, good, bad)
+		result = append(result, counted{target: tg, good: good, bad: bad})
+	}
+	return result
+}
+
+// forget drops the IDs first reported before cutoff. Caller must hold t.mu.
+func (t *Tracker) forget(cutoff time.Time) {
+	for len(t.order) > 0 && t.order[0].seen.Before(cutoff) {
+		delete(t.reports, t.order[0].id)
+		t.order = t.order[1:]
+	}
+}
+
+func (tg *target) add(at, now time.Time, good, bad int) {
+	tg.minutes.add(at, now, good, bad)
+	tg.hours.add(at, now, good, bad)
+}
+
+// longestWindow is the span the minute buckets must cover. Caller must hold t.mu.
+func (t *Tracker) longestWindow() time.Duration {
+	var longest time.Duration
+	for _, w := range t.windows {
+		longest = max(longest, w.Long)
+	}
+	return longest
+}
+
+// burnRate is the bad event share over a span as a multiple of the
+// sustainable share, with the number of events in the span
+func burnRate(e *events, now time.Time, span time.Duration, objective float64) (float64, int) {
+	good, bad := e.sum(now, span)
+	if good+bad == 0 {
+		return 0, 0
+	}
+	return float64(bad) / float64(good+bad) / (1 - objective), good + bad
+}
+
+// burnRates computes every window pair for a target as of now
+func (t *Tracker) burnRates(tg *target, now time.Time) []domain.BurnRate {
+	rates := make([]domain.BurnRate, 0, len(t.windows))
+	for _, w := range t.windows {
+		long, n := burnRate(tg.minutes, now, w.Long, tg.slo.Objective)
+		short, _ := burnRate(tg.minutes, now, w.Short, tg.slo.Objective)
+		rates = append(rates, domain.BurnRate{
+			Window:    w.Name,
+			Long:      long,
+			Short:     short,
+			Threshold: w.Threshold,
+			Firing:    n >= MinEvents && long >= w.Threshold && short >= w.Threshold,
+		})
+	}
+	return rates
+}
+
+// Evaluate checks every target's burn rates as of now and returns the
+// alerts for pairs that started or stopped firing
+func (t *Tracker) Evaluate(now time.Time) []domain.SLOAlert {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var raised []domain.SLOAlert
+	for _, tg := range t.sortedTargets() {
+		for i, rate := range t.burnRates(tg, now) {
+			w := t.windows[i]
+			if rate.Firing == tg.firing[rate.Window] {
+				continue
+			}
+			tg.firing[rate.Window] = rate.Firing
+
+			alert := domain.SLOAlert{
+				Type:          domain.AlertSLOBurnRate,
+				SLOID:         tg.slo.ID,
+				TenantID:      t.tenantID,
+				ProcessorID:   tg.processorID,
+				PaymentMethod: tg.slo.PaymentMethod,
+				Country:       tg.slo.Country,
+				Window:        rate.Window,
+				State:         domain.SLOFiring,
+				BurnRate:      rate.Long,
+				ShortBurnRate: rate.Short,
+				Threshold:     rate.Threshold,
+				Objective:     tg.slo.Objective,
+				Timestamp:     now,
+				Reason: fmt.Sprintf("%s budget burning at %.1fx over %s and %.1fx over %s (threshold %gx) - exhausted in %s at this rate",
+					tg.slo.ID, rate.Long, w.Long, rate.Short, w.Short, w.Threshold, exhaustion(tg.slo.Window, rate.Long)),
+			}
+			if !rate.Firing {
+				alert.State = domain.SLOResolved
+				alert.Reason = fmt.Sprintf("%s budget burn back to %.1fx over %s and %.1fx over %s (threshold %gx)",
+					tg.slo.ID, rate.Long, w.Long, rate.Short, w.Short, w.Threshold)
+			}
+			raised = append(raised, alert)
+		}
+	}
+	t.alerts = append(t.alerts, raised...)
+	return raised
+}
+
+// Alerts returns the burn-rate alerts raised after since
+func (t *Tracker) Alerts(since time.Time) []domain.SLOAlert {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var result []domain.SLOAlert
+	for _, a := range t.alerts {
+		if a.Timestamp.After(since) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// Status returns every target's standing against its SLO as of now,
+// sorted by SLO and processor
+func (t *Tracker) Status(now time.Time) []domain.SLOStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	targets := t.sortedTargets()
+	result := make([]domain.SLOStatus, 0, len(targets))
+	for _, tg := range targets {
+		good, bad := tg.hours.sum(now, tg.slo.Window)
+		status := domain.SLOStatus{
+			SLOID:           tg.slo.ID,
+			ProcessorID:     tg.processorID,
+			PaymentMethod:   tg.slo.PaymentMethod,
+			Country:         tg.slo.Country,
+			Objective:       tg.slo.Objective,
+			Events:          good + bad,
+			BadEvents:       bad,
+			Compliance:      1,
+			BudgetRemaining: 1,
+			BurnRates:       t.burnRates(tg, now),
+		}
+		if good+bad > 0 {
+			status.Compliance = float64(good) / float64(good+bad)
+			status.BudgetRemaining = 1 - (1-status.Compliance)/(1-tg.slo.Objective)
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+// sortedTargets returns targets by SLO and processor. Caller must hold t.mu.
+func (t *Tracker) sortedTargets() []*target {
+	result := make([]*target, 0, len(t.targets))
+	for _, tg := range t.targets {
+		result = append(result, tg)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].slo.ID != result[j].slo.ID {
+			return result[i].slo.ID < result[j].slo.ID
+		}
+		return result[i].processorID < result[j].processorID
+	})
+	return result
+}
+
+// exhaustion is how long a whole budget lasts at burn rate
+func exhaustion(window time.Duration, rate float64) time.Duration {
+	return (time.Duration(float64(window) / rate)).Round(time.Minute)
+}
@@ -0,0 +1,266 @@
+package slo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+)
+
+var start = time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)
+
+func TestTracker_FastBurnFiresAndResolves(t *testing.T) {
+	tracker := NewTracker("acme")
+
+	// Six hours of 20 transactions a minute, half of them errors in the last
+	// 20 minutes: 17x over the last hour but under 3x over six hours
+	for minute := 0; minute < 360; minute++ {
+		result := domain.ResultApproved
+		if minute >= 340 {
+			result = domain.ResultError
+		}
+		at := start.Add(time.Duration(minute) * time.Minute)
+		for i := 0; i < 20; i++ {
+			r := domain.ResultApproved
+			if i%2 == 0 {
+				r = result
+			}
+			tracker.Record(sloTx("processor_a", at, r), at)
+		}
+	}
+	now := start.Add(359 * time.Minute)
+
+	alerts := tracker.Evaluate(now)
+	if len(alerts) != 1 {
+		t.Fatalf("expected only the fast window to fire, got %+v", alerts)
+	}
+	a := alerts[0]
+	if a.Type != domain.AlertSLOBurnRate || a.State != domain.SLOFiring || a.Window != "fast" || a.TenantID != "acme" {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+	if a.BurnRate < 14.4 || a.ShortBurnRate < 14.4 {
+		t.Errorf("expected burn rates above threshold, got %+v", a)
+	}
+
+	if again := tracker.Evaluate(now); len(again) != 0 {
+		t.Errorf("expected no repeat while still firing, got %+v", again)
+	}
+
+	// Errors stop: the 5m window drops below threshold
+	for minute := 360; minute < 370; minute++ {
+		at := start.Add(time.Duration(minute) * time.Minute)
+		for i := 0; i < 20; i++ {
+			tracker.Record(sloTx("processor_a", at, domain.ResultApproved), at)
+		}
+	}
+	resolved := tracker.Evaluate(start.Add(369 * time.Minute))
+	if len(resolved) != 1 || resolved[0].State != domain.SLOResolved || resolved[0].Window != "fast" {
+		t.Fatalf("expected the fast alert to resolve, got %+v", resolved)
+	}
+
+	if got := tracker.Alerts(start); len(got) != 2 {
+		t.Errorf("expected firing and resolved alerts to be kept, got %+v", got)
+	}
+}
+
+func TestTracker_NeedsMinimumEvents(t *testing.T) {
+	tracker := NewTracker("")
+	for i := 0; i < MinEvents-1; i++ {
+		tracker.Record(sloTx("processor_a", start, domain.ResultError), start)
+	}
+
+	if alerts := tracker.Evaluate(start); len(alerts) != 0 {
+		t.Errorf("expected no alert below %d events, got %+v", MinEvents, alerts)
+	}
+}
+
+func TestTracker_CorridorSLOOnlyCountsItsCorridor(t *testing.T) {
+	tracker := NewTracker("")
+	tracker.Remove("availability")
+	err := tracker.Set(SLO{
+		ID:            "card-br",
+		ProcessorID:   "processor_a",
+		PaymentMethod: domain.MethodCard,
+		Country:       domain.CountryBR,
+		Indicator:     IndicatorErrors,
+		Objective:     0.99,
+		Window:        DefaultWindow,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tracker.Record(sloTx("processor_a", start, domain.ResultError), start)
+	tracker.Record(sloTx("processor_b", start, domain.ResultError), start)
+	pix := sloTx("processor_a", start, domain.ResultError)
+	pix.PaymentMethod = domain.MethodPIX
+	tracker.Record(pix, start)
+
+	status := tracker.Status(start)
+	if len(status) != 1 || status[0].ProcessorID != "processor_a" || status[0].Events != 1 {
+		t.Fatalf("expected one CARD/BR event on processor_a, got %+v", status)
+	}
+	if status[0].Compliance != 0 || status[0].BudgetRemaining >= 0 {
+		t.Errorf("expected an exhausted budget, got %+v", status[0])
+	}
+}
+
+func TestTracker_AuthorizationIndicatorIgnoresErrors(t *testing.T) {
+	tracker := NewTracker("")
+	tracker.Remove("availability")
+	if err := tracker.Set(SLO{ID: "auth", Indicator: IndicatorAuthorization, Objective: 0.5, Window: DefaultWindow}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, r := range []domain.TransactionResult{domain.ResultApproved, domain.ResultDeclined, domain.ResultError, domain.ResultTimeout} {
+		tracker.Record(sloTx("processor_a", start, r), start)
+	}
+
+	status := tracker.Status(start)
+	if len(status) != 1 || status[0].Events != 2 || status[0].BadEvents != 1 {
+		t.Errorf("expected 1 approved and 1 declined event, got %+v", status)
+	}
+}
+
+func TestTracker_SetReplacesAndResets(t *testing.T) {
+	tracker := NewTracker("")
+	tracker.Record(sloTx("processor_a", start, domain.ResultError), start)
+
+	if err := tracker.Set(SLO{ID: "availability", Indicator: IndicatorErrors, Objective: 0.999, Window: 7 * 24 * time.Hour}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	slos := tracker.SLOs()
+	if len(slos) != 1 || slos[0].Objective != 0.999 {
+		t.Errorf("expected the SLO to be replaced, got %+v", slos)
+	}
+	if status := tracker.Status(start); len(status) != 0 {
+		t.Errorf("expected measurements to be reset, got %+v", status)
+	}
+	if tracker.Remove("missing") {
+		t.Error("expected removing an unknown SLO to report false")
+	}
+}
+
+func TestTracker_CorrectionReplacesReport(t *testing.T) {
+	tracker := NewTracker("")
+	timeout := sloTx("processor_a", start, domain.ResultTimeout)
+	timeout.ID = "tx-1"
+	tracker.Record(timeout, start)
+	tracker.Record(timeout, start)
+
+	approved := timeout
+	approved.Result = domain.ResultApproved
+	tracker.Record(approved, start.Add(time.Minute))
+
+	status := tracker.Status(start.Add(time.Minute))
+	if len(status) != 1 || status[0].Events != 1 || status[0].BadEvents != 0 {
+		t.Fatalf("expected the correction to replace the timeout, got %+v", status)
+	}
+	if _, n := burnRate(status0Minutes(tracker), start.Add(time.Minute), time.Hour, 0.99); n != 1 {
+		t.Errorf("expected one event in the burn-rate window, got %d", n)
+	}
+
+	// Past the horizon the ID is forgotten and counts as a new transaction
+	later := start.Add(CorrectionHorizon + time.Hour)
+	approved.Timestamp = later
+	tracker.Record(approved, later)
+	if status := tracker.Status(later); status[0].Events != 2 {
+		t.Errorf("expected a new event after the horizon, got %+v", status)
+	}
+}
+
+func TestTracker_IgnoresTimestampsOutsideWindow(t *testing.T) {
+	tracker := NewTracker("")
+	for _, at := range []time.Time{time.Unix(-86400, 0), start.Add(-DefaultWindow), start.Add(time.Hour)} {
+		tracker.Record(sloTx("processor_a", at, domain.ResultError), start)
+	}
+
+	if status := tracker.Status(start); len(status) != 0 {
+		t.Errorf("expected no events counted, got %+v", status)
+	}
+}
+
+func TestEvents_BeforeEpochDoesNotPanic(t *testing.T) {
+	// Negative bucket numbers must still map into the ring
+	e := newEvents(time.Minute, 7*time.Minute)
+	at := time.Unix(-86400, 0)
+	e.add(at, at.Add(time.Minute), 1, 1)
+	e.sum(at, 7*time.Minute)
+}
+
+func TestSLO_Validate(t *testing.T) {
+	valid := SLO{ID: "x", Indicator: IndicatorErrors, Objective: 0.99, Window: DefaultWindow}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := []SLO{
+		{Indicator: IndicatorErrors, Objective: 0.99, Window: DefaultWindow},
+		{ID: "x", Indicator: "latency", Objective: 0.99, Window: DefaultWindow},
+		{ID: "x", Indicator: IndicatorErrors, Objective: 1, Window: DefaultWindow},
+		{ID: "x", Indicator: IndicatorErrors, Objective: 0.99, Window: time.Minute},
+	}
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", s)
+		}
+	}
+}
+
+func TestSLO_JSONDefaults(t *testing.T) {
+	var s SLO
+	if err := json.Unmarshal([]byte(`{"id":"a","processor_id":"processor_a","window":"168h"}`), &s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Indicator != IndicatorErrors || s.Objective != DefaultObjective || s.Window != 7*24*time.Hour {
+		t.Errorf("unexpected SLO: %+v", s)
+	}
+
+	data, _ := json.Marshal(s)
+	var back SLO
+	if err := json.Unmarshal(data, &back); err != nil || back != s {
+		t.Errorf("expected round trip, got %+v (%v)", back, err)
+	}
+}
+
+func TestScheduler_RunOnceEvaluatesEveryTracker(t *testing.T) {
+	clk := clock.NewFake(start)
+	trackers := []*Tracker{NewTracker("a"), NewTracker("b")}
+	for _, tr := range trackers {
+		for i := 0; i < 20; i++ {
+			tr.Record(sloTx("processor_a", start, domain.ResultError), start)
+		}
+	}
+
+	var seen []domain.SLOAlert
+	scheduler := NewScheduler(clk, 0, func() []*Tracker { return trackers })
+	scheduler.OnEvent = func(a domain.SLOAlert) { seen = append(seen, a) }
+
+	// Fully failing: both fast and slow windows fire for both tenants
+	if alerts := scheduler.RunOnce(); len(alerts) != 4 || len(seen) != 4 {
+		t.Errorf("expected 4 alerts, got %+v", alerts)
+	}
+}
+
+// Helper functions
+
+// status0Minutes returns the minute events of the tracker's only target
+func status0Minutes(tracker *Tracker) *events {
+	for _, tg := range tracker.targets {
+		return tg.minutes
+	}
+	return nil
+}
+
+func sloTx(processorID string, at time.Time, result domain.TransactionResult) domain.Transaction {
+	return domain.Transaction{
+		ProcessorID:   processorID,
+		Timestamp:     at,
+		Result:        result,
+		PaymentMethod: domain.MethodCard,
+		Country:       domain.CountryBR,
+	}
+}
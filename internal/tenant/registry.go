@@ -0,0 +1,257 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/feedback"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/routing"
+	"github.com/yuno/techcart-failover/internal/slo"
+)
+
+// DefaultID is the tenant used when a request does not identify a merchant
+const DefaultID = "techcart"
+
+// Errors returned by Resolve
+var (
+	ErrUnknownTenant = errors.New("unknown tenant")
+	ErrOtherTenant   = errors.New("API key is not allowed to access this tenant")
+)
+
+// Tenant bundles the health and routing state of a single merchant
+type Tenant struct {
+	ID         string
+	Calculator *health.Calculator
+	Engine     *routing.Engine
+	Feedback   *feedback.Tracker
+	SLO        *slo.Tracker
+}
+
+// Registry holds per-tenant state plus an optional shared network view
+type Registry struct {
+	mu           sync.RWMutex
+	tenants      map[string]*Tenant
+	network      *health.Calculator
+	clock        clock.Clock
+	defaults     []*domain.Processor
+	policy       health.Policy
+	strategy     routing.Strategy
+	onTransition func(domain.HealthTransition)
+}
+
+// NewRegistry creates a tenant registry whose calculators run on clk.
+// Every transaction is also recorded in the network calculator, which
+// tenants blend in while they have little data of their own. Pass a nil
+// network to keep tenants fully isolated.
+func NewRegistry(network *health.Calculator, clk clock.Clock) *Registry {
+	return &Registry{
+		tenants:  make(map[string]*Tenant),
+		network:  network,
+		clock:    clk,
+		policy:   health.DefaultPolicy(),
+		strategy: routing.DefaultStrategy(),
+	}
+}
+
+// SetDefaultProcessors sets the processors every new tenant starts with
+func (r *Registry) SetDefaultProcessors(processors []*domain.Processor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaults = processors
+}
+
+// SetPolicy sets the health policy of tenants created afterwards. Existing
+// tenants keep theirs: their windows are sized by the policy they started with.
+func (r *Registry) SetPolicy(p health.Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policy = p
+}
+
+// SetStrategy sets the routing strategy of every tenant, existing and new
+func (r *Registry) SetStrategy(s routing.Strategy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strategy = s
+	for _, t := range r.tenants {
+		t.Engine.SetStrategy(s)
+	}
+}
+
+// SetOnTransition sets a callback for every status transition of every
+// tenant and of the network view (see health.Calculator.SetOnTransition)
+func (r *Registry) SetOnTransition(fn func(domain.HealthTransition)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onTransition = fn
+	for _, t := range r.tenants {
+		t.Calculator.SetOnTransition(fn)
+	}
+	if r.network != nil {
+		r.network.SetOnTransition(fn)
+	}
+}
+
+// Get returns the tenant with the given ID, creating it on first use.
+// Requests go through Resolve instead, so only the tenants created at
+// startup (or by tools) exist.
+func (r *Registry) Get(id string) *Tenant {
+	if id == "" {
+		id = DefaultID
+	}
+
+	r.mu.RLock()
+	t, exists := r.tenants[id]
+	r.mu.RUnlock()
+	if exists {
+		return t
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, exists := r.tenants[id]; exists {
+		return t
+	}
+
+	calc := health.NewTenantCalculator(id, r.clock, r.policy)
+	calc.SetOnTransition(r.onTransition)
+	engine := routing.NewEngine(calc)
+	engine.SetStrategy(r.strategy)
+	if r.network != nil {
+		engine.SetNetworkView(r.network)
+	}
+	for _, p := range r.defaults {
+		engine.RegisterProcessor(withTenant(p, id))
+	}
+
+	t = &Tenant{
+		ID:         id,
+		Calculator: calc,
+		Engine:     engine,
+		Feedback:   feedback.NewTracker(r.clock.Now()),
+		SLO:        slo.NewTracker(id),
+	}
+	r.tenants[id] = t
+	return t
+}
+
+// Lookup returns an existing tenant without creating it
+func (r *Registry) Lookup(id string) (*Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, exists := r.tenants[id]
+	return t, exists
+}
+
+// Resolve returns the tenant a request asks for without creating it. An
+// empty id means the bound tenant, or DefaultID when bound is empty. A
+// request bound to a tenant (by its API key) may only access that one.
+func (r *Registry) Resolve(id, bound string) (*Tenant, error) {
+	if id == "" {
+		id = bound
+	}
+	if id == "" {
+		id = DefaultID
+	}
+	if bound != "" && id != bound {
+		return nil, ErrOtherTenant
+	}
+	t, exists := r.Lookup(id)
+	if !exists {
+		return nil, ErrUnknownTenant
+	}
+	return t, nil
+}
+
+// List returns all known tenants sorted by ID
+func (r *Registry) List() []*Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Calculators returns every tenant calculator plus the network view
+func (r *Registry) Calculators() []*health.Calculator {
+	tenants := r.List()
+	result := make([]*health.Calculator, 0, len(tenants)+1)
+	for _, t := range tenants {
+		result = append(result, t.Calculator)
+	}
+	if r.network != nil {
+		result = append(result, r.network)
+	}
+	return result
+}
+
+// SLOTrackers returns every tenant's SLO tracker
+func (r *Registry) SLOTrackers() []*slo.Tracker {
+	tenants := r.List()
+	result := make([]*slo.Tracker, 0, len(tenants))
+	for _, t := range tenants {
+		result = append(result, t.SLO)
+	}
+	return result
+}
+
+// Network returns the shared network calculator (nil when disabled)
+func (r *Registry) Network() *health.Calculator {
+	return r.network
+}
+
+// RegisterProcessor adds a processor to a single tenant's registry
+func (r *Registry) RegisterProcessor(tenantID string, p *domain.Processor) *domain.Processor {
+	t := r.Get(tenantID)
+	registered := withTenant(p, t.ID)
+	t.Engine.RegisterProcessor(registered)
+	return registered
+}
+
+// RecordTransaction records a transaction for its tenant and the network
+// view, and links it to the routing decision it echoes, if any
+func (r *Registry) RecordTransaction(tx domain.Transaction) *domain.ProcessorHealth {
+	return r.RecordTransactionContext(context.Background(), tx)
+}
+
+// RecordTransactionContext is RecordTransaction with the calculator updates
+// traced as children of the span in ctx
+func (r *Registry) RecordTransactionContext(ctx context.Context, tx domain.Transaction) *domain.ProcessorHealth {
+	t := r.Get(tx.TenantID)
+	tx.TenantID = t.ID
+
+	if tx.DecisionID != "" {
+		decision, _ := t.Engine.Decision(tx.DecisionID)
+		t.Feedback.Record(tx, decision)
+	}
+	t.Engine.RecordTransaction(tx)
+	t.SLO.Record(tx, r.clock.Now())
+
+	if r.network != nil {
+		// Transaction IDs are only unique within a tenant
+		shared := tx
+		if shared.ID != "" {
+			shared.ID = t.ID + "/" + shared.ID
+		}
+		r.network.RecordTransactionContext(ctx, shared)
+	}
+	return t.Calculator.RecordTransactionContext(ctx, tx)
+}
+
+// withTenant returns a copy of p owned by the given tenant
+func withTenant(p *domain.Processor, tenantID string) *domain.Processor {
+	cp := *p
+	cp.TenantID = tenantID
+	return &cp
+}
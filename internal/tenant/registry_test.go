@@ -0,0 +1,184 @@
+package tenant
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/routing"
+)
+
+func pixProcessors() []*domain.Processor {
+	return []*domain.Processor{
+		{
+			ID:             "processor_a",
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+		},
+		{
+			ID:             "processor_b",
+			Countries:      []domain.Country{domain.CountryBR},
+			PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+		},
+	}
+}
+
+func tenantTx(tenantID, processorID string, result domain.TransactionResult) domain.Transaction {
+	return domain.Transaction{
+		TenantID:      tenantID,
+		ProcessorID:   processorID,
+		Timestamp:     time.Now(),
+		Result:        result,
+		PaymentMethod: domain.MethodPIX,
+		Country:       domain.CountryBR,
+	}
+}
+
+func TestRegistry_TenantsAreIsolated(t *testing.T) {
+	reg := NewRegistry(nil, clock.Real())
+	reg.SetDefaultProcessors(pixProcessors())
+
+	for i := 0; i < 50; i++ {
+		reg.RecordTransaction(tenantTx("shop_a", "processor_a", domain.ResultError))
+	}
+
+	if got := reg.Get("shop_a").Calculator.GetHealth("processor_a").Status; got != domain.StatusDown {
+		t.Errorf("expected shop_a processor_a DOWN, got %s", got)
+	}
+	if got := reg.Get("shop_b").Calculator.GetHealth("processor_a").Status; got != domain.StatusHealthy {
+		t.Errorf("expected shop_b processor_a HEALTHY, got %s", got)
+	}
+}
+
+func TestRegistry_EmptyTenantUsesDefault(t *testing.T) {
+	reg := NewRegistry(nil, clock.Real())
+	reg.RecordTransaction(tenantTx("", "processor_a", domain.ResultApproved))
+
+	if _, ok := reg.Lookup(DefaultID); !ok {
+		t.Fatalf("expected transaction without tenant to create %q", DefaultID)
+	}
+}
+
+func TestRegistry_ResolveNeverCreatesTenants(t *testing.T) {
+	reg := NewRegistry(nil, clock.Real())
+	reg.Get(DefaultID)
+	reg.Get("shop_a")
+
+	if got, err := reg.Resolve("", ""); err != nil || got.ID != DefaultID {
+		t.Errorf("expected an empty id to resolve to %q, got %v, %v", DefaultID, got, err)
+	}
+	if _, err := reg.Resolve("unknown", ""); err != ErrUnknownTenant {
+		t.Errorf("expected ErrUnknownTenant, got %v", err)
+	}
+	if _, ok := reg.Lookup("unknown"); ok {
+		t.Error("expected Resolve not to create the tenant")
+	}
+}
+
+func TestRegistry_ResolveKeepsBoundRequestsInTheirTenant(t *testing.T) {
+	reg := NewRegistry(nil, clock.Real())
+	reg.Get(DefaultID)
+	reg.Get("shop_a")
+
+	if got, err := reg.Resolve("", "shop_a"); err != nil || got.ID != "shop_a" {
+		t.Errorf("expected an empty id to resolve to the bound tenant, got %v, %v", got, err)
+	}
+	if _, err := reg.Resolve(DefaultID, "shop_a"); err != ErrOtherTenant {
+		t.Errorf("expected ErrOtherTenant, got %v", err)
+	}
+}
+
+func TestRegistry_RegisterProcessorOnlyForTenant(t *testing.T) {
+	reg := NewRegistry(nil, clock.Real())
+	reg.SetDefaultProcessors(pixProcessors())
+
+	reg.RegisterProcessor("shop_a", &domain.Processor{
+		ID:             "processor_local",
+		Countries:      []domain.Country{domain.CountryBR},
+		PaymentMethods: []domain.PaymentMethod{domain.MethodPIX},
+	})
+
+	if got := len(reg.Get("shop_a").Engine.GetProcessors()); got != 3 {
+		t.Errorf("expected 3 processors for shop_a, got %d", got)
+	}
+	if got := len(reg.Get("shop_b").Engine.GetProcessors()); got != 2 {
+		t.Errorf("expected 2 processors for shop_b, got %d", got)
+	}
+}
+
+func TestRegistry_NetworkViewBlendsIntoSparseTenant(t *testing.T) {
+	reg := NewRegistry(health.NewCalculator(), clock.Real())
+	reg.SetDefaultProcessors(pixProcessors())
+
+	// A large tenant sees processor_a failing and processor_b healthy
+	for i := 0; i < 50; i++ {
+		reg.RecordTransaction(tenantTx("big_shop", "processor_a", domain.ResultError))
+		reg.RecordTransaction(tenantTx("big_shop", "processor_b", domain.ResultApproved))
+	}
+
+	// A new tenant with no data of its own should follow the network view
+	rec := reg.Get("new_shop").Engine.Recommend(domain.MethodPIX, domain.CountryBR, 100)
+	if rec.TenantID != "new_shop" {
+		t.Errorf("expected tenant new_shop, got %q", rec.TenantID)
+	}
+	if rec.Recommendations[0].ProcessorID != "processor_b" {
+		t.Errorf("expected processor_b first from network view, got %s", rec.Recommendations[0].ProcessorID)
+	}
+	if rec.Recommendations[1].Status != domain.StatusDown {
+		t.Errorf("expected processor_a DOWN from network view, got %s", rec.Recommendations[1].Status)
+	}
+}
+
+func TestRegistry_LinksTransactionsToDecisions(t *testing.T) {
+	reg := NewRegistry(nil, clock.Real())
+	reg.SetDefaultProcessors(pixProcessors())
+
+	rec := reg.Get("shop").Engine.Recommend(domain.MethodPIX, domain.CountryBR, 100)
+	top := rec.Recommendations[0].ProcessorID
+
+	followed := tenantTx("shop", top, domain.ResultApproved)
+	followed.DecisionID = rec.DecisionID
+	reg.RecordTransaction(followed)
+
+	unknown := tenantTx("shop", top, domain.ResultApproved)
+	unknown.DecisionID = "dec-missing"
+	reg.RecordTransaction(unknown)
+
+	// Transactions without a decision ID are not part of the report
+	reg.RecordTransaction(tenantTx("shop", top, domain.ResultApproved))
+
+	report := reg.Get("shop").Feedback.Report("shop")
+	if report.Total.Followed.Transactions != 1 {
+		t.Errorf("expected 1 followed transaction, got %d", report.Total.Followed.Transactions)
+	}
+	if report.Unmatched != 1 {
+		t.Errorf("expected 1 unmatched transaction, got %d", report.Unmatched)
+	}
+}
+
+func TestRegistry_PolicyAndStrategy(t *testing.T) {
+	reg := NewRegistry(nil, clock.Real())
+	existing := reg.Get("acme")
+
+	policy := health.DefaultPolicy()
+	policy.HealthyThreshold = 0.9
+	reg.SetPolicy(policy)
+	strategy := routing.DefaultStrategy()
+	strategy.DegradedPenalty = 0.25
+	reg.SetStrategy(strategy)
+
+	if existing.Calculator.Policy().HealthyThreshold != health.HealthyThreshold {
+		t.Error("expected an existing tenant to keep its policy")
+	}
+	if existing.Engine.Strategy().DegradedPenalty != 0.25 {
+		t.Error("expected an existing tenant to get the new strategy")
+	}
+
+	created := reg.Get("globex")
+	if created.Calculator.Policy().HealthyThreshold != 0.9 || created.Engine.Strategy().DegradedPenalty != 0.25 {
+		t.Errorf("expected a new tenant to get the policy and strategy, got %+v %+v",
+			created.Calculator.Policy(), created.Engine.Strategy())
+	}
+}
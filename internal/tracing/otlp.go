@@ -0,0 +1,259 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Export tuning
+const (
+	QueueDepth    = 2048            // Ended spans waiting for export before new ones are dropped
+	BatchSize     = 512             // Spans per OTLP request
+	BatchInterval = 2 * time.Second // Longest a span waits for its batch
+	Timeout       = 5 * time.Second // Per export request
+)
+
+// Exporter sends ended spans to an OTLP/HTTP collector as JSON, in batches,
+// from a background worker. Spans are dropped, not retried, when the
+// collector fails or the queue is full.
+type Exporter struct {
+	url     string
+	service string
+	client  *http.Client
+	queue   chan *Span
+	dropped sync.Once
+	OnError func(error)
+
+	mu    sync.Mutex
+	batch []*Span // taken from the queue, not sent yet
+}
+
+// NewExporter creates an exporter for the collector at endpoint (a base URL
+// such as http://localhost:4318; spans go to /v1/traces), reporting spans
+// as coming from service
+func NewExporter(endpoint, service string) *Exporter {
+	return &Exporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: Timeout},
+		queue:   make(chan *Span, QueueDepth),
+	}
+}
+
+func (e *Exporter) export(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		// Report once: a stalled collector would otherwise flood the log
+		e.dropped.Do(func() { e.fail(fmt.Errorf("trace export queue full, dropping spans")) })
+	}
+}
+
+// Run exports spans in batches until ctx is cancelled. A partial batch
+// left behind is sent by Flush.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(BatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-e.queue:
+			if batch := e.add(s); batch != nil {
+				e.send(ctx, batch)
+			}
+		case <-ticker.C:
+			if batch := e.take(); len(batch) > 0 {
+				e.send(ctx, batch)
+			}
+		}
+	}
+}
+
+// add appends s to the pending batch and returns the batch once full
+func (e *Exporter) add(s *Span) []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.batch = append(e.batch, s); len(e.batch) < BatchSize {
+		return nil
+	}
+	batch := e.batch
+	e.batch = nil
+	return batch
+}
+
+// take returns the pending batch, leaving it empty
+func (e *Exporter) take() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	batch := e.batch
+	e.batch = nil
+	return batch
+}
+
+// Flush exports the pending batch and the spans still queued, e.g. on
+// shutdown after Run has stopped, until both are empty or ctx is done
+func (e *Exporter) Flush(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch := e.take()
+	fill:
+		for len(batch) < BatchSize {
+			select {
+			case s := <-e.queue:
+				batch = append(batch, s)
+			default:
+				break fill
+			}
+		}
+		if len(batch) == 0 {
+			return
+		}
+		e.send(ctx, batch)
+	}
+}
+
+func (e *Exporter) send(ctx context.Context, batch []*Span) {
+	if err := e.post(ctx, batch); err != nil {
+		e.fail(err)
+	}
+}
+
+func (e *Exporter) post(ctx context.Context, batch []*Span) error {
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("trace export: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("trace collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *Exporter) fail(err error) {
+	if e.OnError != nil {
+		e.OnError(err)
+	}
+}
+
+// OTLP/JSON request body (opentelemetry-proto ExportTraceServiceRequest):
+// IDs are hex, timestamps are decimal strings of Unix nanoseconds
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *Exporter) request(batch []*Span) otlpRequest {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		s.mu.Lock()
+		spans[i] = otlpSpan{
+			TraceID:           s.context.TraceID.String(),
+			SpanID:            s.context.SpanID.String(),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: s.status, Message: s.message},
+		}
+		if s.parent.IsValid() {
+			spans[i].ParentSpanID = s.parent.String()
+		}
+		for _, a := range s.attributes {
+			spans[i].Attributes = append(spans[i].Attributes, keyValue(a.Key, a.Value))
+		}
+		s.mu.Unlock()
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{keyValue("service.name", e.service)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: e.service}, Spans: spans}},
+	}}}
+}
+
+func keyValue(key string, value any) otlpKeyValue {
+	var v otlpValue
+	switch x := value.(type) {
+	case bool:
+		v.BoolValue = &x
+	case int:
+		s := strconv.Itoa(x)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(x, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &x
+	case string:
+		v.StringValue = &x
+	default:
+		s := fmt.Sprint(x)
+		v.StringValue = &s
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
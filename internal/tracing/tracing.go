@@ -0,0 +1,247 @@
+// Package tracing records OpenTelemetry-compatible spans: W3C traceparent
+// propagation, spans carried in the context and export over OTLP/HTTP.
+// Without a tracer in the context every call is a no-op, so instrumented
+// code costs next to nothing while tracing is disabled.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/httputil"
+)
+
+// TraceparentHeader carries the W3C trace context
+const TraceparentHeader = "traceparent"
+
+// Span kinds, as numbered by OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+)
+
+// Status codes, as numbered by OTLP
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the ID is not all zeros
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether the ID is not all zeros
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext is the part of a span that crosses process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// ParseTraceparent reads a W3C traceparent header
+// ("00-<32 hex trace ID>-<16 hex span ID>-<2 hex flags>")
+func ParseTraceparent(header string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, false
+	}
+	if !sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// Traceparent formats the span context as a W3C traceparent header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// Tracer starts root spans and hands ended, sampled spans to its exporter
+type Tracer struct {
+	exporter    *Exporter
+	sampleRatio float64
+}
+
+// NewTracer creates a tracer exporting to exporter. Traces started here
+// (without a sampled parent) are kept with probability sampleRatio.
+func NewTracer(exporter *Exporter, sampleRatio float64) *Tracer {
+	return &Tracer{exporter: exporter, sampleRatio: sampleRatio}
+}
+
+// Start begins a root span, continuing parent's trace when it is valid
+func (t *Tracer) Start(ctx context.Context, name string, kind int, parent SpanContext) (context.Context, *Span) {
+	sc := SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+	if !parent.TraceID.IsValid() {
+		rand.Read(sc.TraceID[:])
+		sc.Sampled = mathrand.Float64() < t.sampleRatio
+	}
+	span := &Span{tracer: t, name: name, kind: kind, context: sc, parent: parent.SpanID, start: time.Now()}
+	return ContextWithSpan(ctx, span), span
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns ctx carrying span as the parent of new spans
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx (nil if none)
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start begins a child of the span in ctx. Without one it returns ctx and
+// a nil span, whose methods do nothing.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, KindInternal, parent.context)
+}
+
+// Span is a timed operation. A nil span is valid and records nothing.
+type Span struct {
+	tracer  *Tracer
+	name    string
+	kind    int
+	context SpanContext
+	parent  SpanID
+	start   time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []Attribute
+	status     int
+	message    string
+}
+
+// Attribute is a key and a string, bool, int, int64 or float64 value
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Context returns the span's IDs (zero for a nil span)
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttributes adds key/value pairs: SetAttributes("tenant", id, "count", 3)
+func (s *Span) SetAttributes(kv ...any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		if key, ok := kv[i].(string); ok {
+			s.attributes = append(s.attributes, Attribute{Key: key, Value: kv[i+1]})
+		}
+	}
+}
+
+// SetError marks the span failed with message
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.message = StatusError, message
+}
+
+// End finishes the span and exports it if sampled. Later calls are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.context.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.export(s)
+	}
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
+
+// Middleware starts a server span for every request, continuing the
+// caller's trace from traceparent. route names the matched route pattern.
+// With a nil tracer it returns next unchanged.
+func Middleware(tracer *Tracer, next http.Handler, route func(*http.Request) string) http.Handler {
+	if tracer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent, _ := ParseTraceparent(r.Header.Get(TraceparentHeader))
+		pattern := route(r)
+		ctx, span := tracer.Start(r.Context(), pattern, KindServer, parent)
+		defer span.End()
+
+		rec := httputil.NewRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(
+			"http.request.method", r.Method,
+			"http.route", pattern,
+			"url.path", r.URL.Path,
+			"http.response.status_code", rec.Status,
+		)
+		if rec.Status >= 500 {
+			span.SetError(http.StatusText(rec.Status))
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(header)
+	if !ok || !sc.Sampled {
+		t.Fatalf("expected a sampled span context, got %+v, %v", sc, ok)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("unexpected IDs %s %s", sc.TraceID, sc.SpanID)
+	}
+	if sc.Traceparent() != header {
+		t.Errorf("expected round trip to %q, got %q", header, sc.Traceparent())
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, ok := ParseTraceparent(invalid); ok {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestStart_NoopWithoutTracer(t *testing.T) {
+	ctx, span := Start(context.Background(), "work")
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("expected no span without a tracer in the context")
+	}
+	// Methods on a nil span must not panic
+	span.SetAttributes("key", "value")
+	span.SetError("boom")
+	span.End()
+}
+
+func TestMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	receiver := newReceiver()
+	defer receiver.Close()
+	exporter := NewExporter(receiver.URL, "test-service")
+	tracer := NewTracer(exporter, 0)
+
+	handler := Middleware(tracer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, child := Start(r.Context(), "child")
+		child.SetAttributes("count", 3)
+		child.End()
+		w.WriteHeader(http.StatusBadGateway)
+	}), func(*http.Request) string { return "GET /test" })
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	exporter.Flush(context.Background())
+
+	spans := receiver.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected child and server spans, got %d", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "GET /test" || server.Kind != KindServer || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("unexpected server span %+v", server)
+	}
+	if server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || child.TraceID != server.TraceID {
+		t.Errorf("expected both spans in the incoming trace, got %s and %s", child.TraceID, server.TraceID)
+	}
+	if child.ParentSpanID != server.SpanID {
+		t.Errorf("expected child of %s, got parent %s", server.SpanID, child.ParentSpanID)
+	}
+	if server.Status.Code != StatusError {
+		t.Errorf("expected error status for a 502, got %+v", server.Status)
+	}
+	if got := attribute(child, "count"); got.IntValue == nil || *got.IntValue != "3" {
+		t.Errorf("expected int attribute count=3, got %+v", got)
+	}
+	if got := attribute(server, "http.response.status_code"); got.IntValue == nil || *got.IntValue != "502" {
+		t.Errorf("expected status code attribute, got %+v", got)
+	}
+	if receiver.Service() != "test-service" {
+		t.Errorf("expected service.name test-service, got %q", receiver.Service())
+	}
+}
+
+func TestMiddleware_HonoursUnsampledParent(t *testing.T) {
+	receiver := newReceiver()
+	defer receiver.Close()
+	exporter := NewExporter(receiver.URL, "test-service")
+	handler := Middleware(NewTracer(exporter, 1), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		func(*http.Request) string { return "GET /test" })
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	exporter.Flush(context.Background())
+
+	if spans := receiver.Spans(); len(spans) != 0 {
+		t.Errorf("expected an unsampled trace not to be exported, got %d spans", len(spans))
+	}
+}
+
+func TestExporter_RunBatches(t *testing.T) {
+	receiver := newReceiver()
+	defer receiver.Close()
+	exporter := NewExporter(receiver.URL+"/", "test-service")
+	tracer := NewTracer(exporter, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go exporter.Run(ctx)
+
+	for i := 0; i < BatchSize; i++ {
+		_, span := tracer.Start(context.Background(), "root", KindInternal, SpanContext{})
+		span.End()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(receiver.Spans()) < BatchSize && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(receiver.Spans()); got != BatchSize {
+		t.Errorf("expected a full batch of %d spans, got %d", BatchSize, got)
+	}
+	if receiver.Requests() != 1 {
+		t.Errorf("expected one OTLP request, got %d", receiver.Requests())
+	}
+}
+
+// Helper functions
+
+// receiver is a stub OTLP/HTTP collector
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	spans    []otlpSpan
+	service  string
+	requests int
+}
+
+func newReceiver() *receiver {
+	r := &receiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/traces" || req.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var body otlpRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests++
+		for _, rs := range body.ResourceSpans {
+			if v := rs.Resource.Attributes[0].Value.StringValue; v != nil {
+				r.service = *v
+			}
+			for _, ss := range rs.ScopeSpans {
+				r.spans = append(r.spans, ss.Spans...)
+			}
+		}
+	}))
+	return r
+}
+
+func (r *receiver) Spans() []otlpSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]otlpSpan(nil), r.spans...)
+}
+
+func (r *receiver) Service() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.service
+}
+
+func (r *receiver) Requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+func attribute(s otlpSpan, key string) otlpValue {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return otlpValue{}
+}
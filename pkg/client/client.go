@@ -0,0 +1,283 @@
+// Package client is a Go client for the techcart-failover HTTP API. It has
+// no dependencies outside the standard library so merchants can import it
+// directly:
+//
+//	c := client.New("https://failover.example.com")
+//	c.SetAPIKey(key)
+//	rec, err := c.Recommend(ctx, client.RoutingRequest{PaymentMethod: client.MethodCard, Country: "BR", Amount: 120})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers understood by the server
+const (
+	APIKeyHeader        = "X-API-Key"
+	TenantHeader        = "X-Tenant-ID"
+	RequestIDHeader     = "X-Request-ID"
+	TransactionIDHeader = "X-Transaction-ID"
+	ReplayedHeader      = "Idempotent-Replayed"
+)
+
+// Error is a non-2xx response from the server
+type Error struct {
+	StatusCode int
+	Message    string
+	RequestID  string        // quote this when reporting problems
+	RetryAfter time.Duration // set on 429 and 503
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%d %s (request %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the server
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// Client calls the API of one server. It is safe for concurrent use once
+// configured.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	apiKey     string
+	tenantID   string
+	maxRetries int
+}
+
+// New creates a client for the server at baseURL (e.g. http://localhost:8080)
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SetAPIKey authenticates requests with key
+func (c *Client) SetAPIKey(key string) { c.apiKey = key }
+
+// SetTenant scopes requests to a tenant
+func (c *Client) SetTenant(id string) { c.tenantID = id }
+
+// SetHTTPClient replaces the default client (10s timeout)
+func (c *Client) SetHTTPClient(hc *http.Client) { c.httpClient = hc }
+
+// SetMaxRetries retries requests rate limited (429) or shed (503) up to n
+// times, waiting as long as Retry-After asks. The default is no retries,
+// since waiting is rarely right on the payment path.
+func (c *Client) SetMaxRetries(n int) { c.maxRetries = n }
+
+// RecordTransaction reports a transaction and waits for it to be recorded,
+// returning the processor's updated health. Health is nil if the server
+// could not record it in time; the report stays queued and retrying with
+// the same ID is safe.
+func (c *Client) RecordTransaction(ctx context.Context, tx TransactionRequest) (*Recorded, error) {
+	return c.record(ctx, tx, false)
+}
+
+// EnqueueTransaction reports a transaction without waiting for it to be
+// recorded
+func (c *Client) EnqueueTransaction(ctx context.Context, tx TransactionRequest) (*Recorded, error) {
+	return c.record(ctx, tx, true)
+}
+
+func (c *Client) record(ctx context.Context, tx TransactionRequest, async bool) (*Recorded, error) {
+	header := http.Header{}
+	if async {
+		header.Set("Prefer", "respond-async")
+	}
+	var health ProcessorHealth
+	resp, err := c.do(ctx, http.MethodPost, "/api/v1/transactions", header, tx, &health)
+	if err != nil {
+		return nil, err
+	}
+	rec := &Recorded{
+		TransactionID: resp.Header.Get(TransactionIDHeader),
+		Replayed:      resp.Header.Get(ReplayedHeader) == "true",
+	}
+	if resp.StatusCode == http.StatusOK {
+		rec.Health = &health
+	}
+	return rec, nil
+}
+
+// Recommend ranks processors for a payment
+func (c *Client) Recommend(ctx context.Context, req RoutingRequest) (*RoutingRecommendation, error) {
+	var rec RoutingRecommendation
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/routing/recommend", nil, req, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Decision fetches a past recommendation with its explanation
+func (c *Client) Decision(ctx context.Context, decisionID string) (*RoutingRecommendation, error) {
+	var rec RoutingRecommendation
+	if _, err := c.do(ctx, http.MethodGet, "/api/v1/routing/decisions/"+url.PathEscape(decisionID), nil, nil, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Health fetches the health of every processor
+func (c *Client) Health(ctx context.Context) (*HealthList, error) {
+	var list HealthList
+	if _, err := c.do(ctx, http.MethodGet, "/api/v1/health", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ProcessorHealth fetches one processor's health and recent transactions
+func (c *Client) ProcessorHealth(ctx context.Context, processorID string) (*ProcessorHealthDetail, error) {
+	var detail ProcessorHealthDetail
+	if _, err := c.do(ctx, http.MethodGet, "/api/v1/health/"+url.PathEscape(processorID), nil, nil, &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// Processors lists the registered processors
+func (c *Client) Processors(ctx context.Context) (*ProcessorList, error) {
+	var list ProcessorList
+	if _, err := c.do(ctx, http.MethodGet, "/api/v1/processors", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// RegisterProcessor registers or replaces a processor
+func (c *Client) RegisterProcessor(ctx context.Context, p Processor) (*Processor, error) {
+	var registered Processor
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/processors", nil, p, &registered); err != nil {
+		return nil, err
+	}
+	return &registered, nil
+}
+
+// Alerts fetches health transitions and SLO alerts raised since a time
+// (the last hour when zero)
+func (c *Client) Alerts(ctx context.Context, since time.Time) (*Alerts, error) {
+	path := "/api/v1/alerts"
+	if !since.IsZero() {
+		path += "?since=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+	var alerts Alerts
+	if _, err := c.do(ctx, http.MethodGet, path, nil, nil, &alerts); err != nil {
+		return nil, err
+	}
+	return &alerts, nil
+}
+
+// do sends a JSON request and decodes a 2xx body into out, retrying 429
+// and 503 responses as configured
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, body, out any) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, data, err := c.send(ctx, method, path, header, payload)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 300 {
+			if out != nil && resp.StatusCode != http.StatusAccepted {
+				if err := json.Unmarshal(data, out); err != nil {
+					return nil, fmt.Errorf("decoding %s %s: %w", method, path, err)
+				}
+			}
+			return resp, nil
+		}
+
+		apiErr := responseError(resp, data)
+		if apiErr.RetryAfter == 0 || attempt >= c.maxRetries {
+			return nil, apiErr
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(apiErr.RetryAfter):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, header http.Header, payload []byte) (*http.Response, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set(APIKeyHeader, c.apiKey)
+	}
+	if c.tenantID != "" {
+		req.Header.Set(TenantHeader, c.tenantID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
+
+func responseError(resp *http.Response, data []byte) *Error {
+	var body struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	e := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get(RequestIDHeader)}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		e.Message = body.Error
+		if body.RequestID != "" {
+			e.RequestID = body.RequestID
+		}
+	} else {
+		e.Message = strings.TrimSpace(string(data))
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		e.RetryAfter = time.Duration(max(seconds, 1)) * time.Second
+	}
+	return e
+}
@@ -0,0 +1,247 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yuno/techcart-failover/internal/api"
+	"github.com/yuno/techcart-failover/internal/auth"
+	"github.com/yuno/techcart-failover/internal/clock"
+	"github.com/yuno/techcart-failover/internal/domain"
+	"github.com/yuno/techcart-failover/internal/health"
+	"github.com/yuno/techcart-failover/internal/idempotency"
+	"github.com/yuno/techcart-failover/internal/ingest"
+	"github.com/yuno/techcart-failover/internal/logging"
+	"github.com/yuno/techcart-failover/internal/tenant"
+)
+
+func TestClient_RecommendAndRecord(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	rec, err := c.Recommend(ctx, RoutingRequest{PaymentMethod: MethodCard, Country: "BR", Amount: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Best() != "processor_a" || rec.DecisionID == "" {
+		t.Fatalf("expected processor_a with a decision ID, got %+v", rec)
+	}
+
+	tx := TransactionRequest{
+		ID: "tx-1", ProcessorID: rec.Best(), Result: ResultApproved, PaymentMethod: MethodCard,
+		Country: "BR", Amount: 100, Currency: "BRL", DecisionID: rec.DecisionID,
+	}
+	recorded, err := c.RecordTransaction(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.TransactionID != "tx-1" || recorded.Replayed || recorded.Health == nil || recorded.Health.TotalTransactions != 1 {
+		t.Errorf("unexpected first report %+v", recorded)
+	}
+	if recorded, err = c.RecordTransaction(ctx, tx); err != nil || !recorded.Replayed {
+		t.Errorf("expected the retry to be replayed, got %+v, %v", recorded, err)
+	}
+
+	queued, err := c.EnqueueTransaction(ctx, TransactionRequest{
+		ProcessorID: "processor_a", Result: ResultDeclined, PaymentMethod: MethodCard, Country: "BR", Amount: 50, Currency: "BRL",
+	})
+	if err != nil || queued.TransactionID == "" || queued.Health != nil {
+		t.Errorf("expected a queued report with a generated ID, got %+v, %v", queued, err)
+	}
+
+	decision, err := c.Decision(ctx, rec.DecisionID)
+	if err != nil || decision.Explanation == nil {
+		t.Errorf("expected the decision with its explanation, got %+v, %v", decision, err)
+	}
+	detail, err := c.ProcessorHealth(ctx, "processor_a")
+	if err != nil || len(detail.RecentTransactions) == 0 || detail.RecentTransactions[0].DecisionID != rec.DecisionID {
+		t.Errorf("expected the recorded transaction in the detail, got %+v, %v", detail, err)
+	}
+	list, err := c.Health(ctx)
+	if err != nil || list.Count == 0 {
+		t.Errorf("expected processor health, got %+v, %v", list, err)
+	}
+	if _, err := c.Alerts(ctx, time.Now().Add(-time.Minute)); err != nil {
+		t.Errorf("alerts: %v", err)
+	}
+}
+
+func TestClient_RegisterProcessor(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	p, err := c.RegisterProcessor(ctx, Processor{
+		ID: "processor_z", Name: "Z", Countries: []string{"CO"}, PaymentMethods: []string{MethodCard},
+		Limits: map[string]AmountLimit{"COP": {MaxAmount: 5000000}},
+	})
+	if err != nil || p.ID != "processor_z" {
+		t.Fatalf("expected processor_z registered, got %+v, %v", p, err)
+	}
+	list, err := c.Processors(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range list.Processors {
+		found = found || p.ID == "processor_z" && p.Limits["COP"].MaxAmount == 5000000
+	}
+	if !found {
+		t.Errorf("expected processor_z with its limit in %+v", list.Processors)
+	}
+}
+
+func TestClient_ErrorsCarryRequestID(t *testing.T) {
+	c := newTestClient(t)
+
+	_, err := c.Decision(context.Background(), "dec-missing")
+	if !IsNotFound(err) {
+		t.Fatalf("expected a 404, got %v", err)
+	}
+	var apiErr *Error
+	errors.As(err, &apiErr)
+	if apiErr.RequestID == "" || apiErr.Message == "" {
+		t.Errorf("expected a message and request ID, got %+v", apiErr)
+	}
+
+	c.SetAPIKey("wrong-key")
+	if _, err := c.Health(context.Background()); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a 401, got %v", err)
+	}
+}
+
+func TestClient_RetriesWhenShed(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"ingest queue full"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(HealthList{TenantID: "default", Count: 0})
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	_, err := c.Health(context.Background())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Second || apiErr.Message != "ingest queue full" {
+		t.Fatalf("expected a 503 with Retry-After and no retry by default, got %v", err)
+	}
+
+	c.SetMaxRetries(1)
+	if list, err := c.Health(context.Background()); err != nil || list.TenantID != "default" {
+		t.Fatalf("expected the retry to succeed, got %+v, %v", list, err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 calls, got %d", calls.Load())
+	}
+}
+
+func TestClient_TypesMatchSpec(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(api.OpenAPISpec(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, typ := range map[string]reflect.Type{
+		"TransactionRequest":    reflect.TypeOf(TransactionRequest{}),
+		"Transaction":           reflect.TypeOf(Transaction{}),
+		"ProcessorHealth":       reflect.TypeOf(ProcessorHealth{}),
+		"BandHealth":            reflect.TypeOf(BandHealth{}),
+		"CardHealth":            reflect.TypeOf(CardHealth{}),
+		"HealthList":            reflect.TypeOf(HealthList{}),
+		"ProcessorHealthDetail": reflect.TypeOf(ProcessorHealthDetail{}),
+		"RoutingRequest":        reflect.TypeOf(RoutingRequest{}),
+		"RoutingRecommendation": reflect.TypeOf(RoutingRecommendation{}),
+		"ProcessorRank":         reflect.TypeOf(ProcessorRank{}),
+		"DecisionExplanation":   reflect.TypeOf(DecisionExplanation{}),
+		"FilteredProcessor":     reflect.TypeOf(FilteredProcessor{}),
+		"ScoreBreakdown":        reflect.TypeOf(ScoreBreakdown{}),
+		"Processor":             reflect.TypeOf(Processor{}),
+		"AmountLimit":           reflect.TypeOf(AmountLimit{}),
+		"ProcessorList":         reflect.TypeOf(ProcessorList{}),
+		"HealthTransition":      reflect.TypeOf(HealthTransition{}),
+		"SLOAlert":              reflect.TypeOf(SLOAlert{}),
+		"Alerts":                reflect.TypeOf(Alerts{}),
+	} {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("%s: not in the document", name)
+			continue
+		}
+		documented := make([]string, 0, len(schema.Properties))
+		for p := range schema.Properties {
+			documented = append(documented, p)
+		}
+		sort.Strings(documented)
+		if fields := jsonFields(typ); strings.Join(fields, ",") != strings.Join(documented, ",") {
+			t.Errorf("%s: client has %v, document has %v", name, fields, documented)
+		}
+	}
+}
+
+// Helper functions
+
+// newTestClient serves the real API handlers behind an API key and
+// returns a client for it
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	clk := clock.Real()
+	tenants := tenant.NewRegistry(health.NewCalculatorWithClock(clk), clk)
+	tenants.SetDefaultProcessors([]*domain.Processor{
+		{ID: "processor_a", Name: "A", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodCard}},
+		{ID: "processor_b", Name: "B", Countries: []domain.Country{domain.CountryBR}, PaymentMethods: []domain.PaymentMethod{domain.MethodCard}},
+	})
+	tenants.Get(tenant.DefaultID)
+
+	queue := ingest.NewQueue(10, 1, tenants.RecordTransactionContext)
+	t.Cleanup(queue.Close)
+	logger := logging.New(discard{}, logging.FormatJSON, nil)
+	handler := api.NewHandler(tenants, idempotency.NewStore(time.Hour), queue)
+	handler.SetLogger(logger)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	route := func(r *http.Request) string { _, p := mux.Handler(r); return p }
+	client := func(r *http.Request) string { return "test" }
+	server := httptest.NewServer(logging.Middleware(logger, auth.NewKeys([]string{"test-key"}).Middleware(mux), route, client))
+	t.Cleanup(server.Close)
+
+	c := New(server.URL + "/")
+	c.SetAPIKey("test-key")
+	return c
+}
+
+// jsonFields lists the sorted JSON names of typ's fields
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// discard drops log output
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
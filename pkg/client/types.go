@@ -0,0 +1,292 @@
+package client
+
+import "time"
+
+// Types mirror the schemas of the OpenAPI document served at
+// /api/v1/openapi.json; the package tests keep their fields in line with it.
+
+// Health statuses
+const (
+	StatusHealthy  = "HEALTHY"
+	StatusDegraded = "DEGRADED"
+	StatusDown     = "DOWN"
+	StatusStale    = "STALE"
+	StatusUnknown  = "UNKNOWN"
+)
+
+// Payment methods
+const (
+	MethodPIX  = "PIX"
+	MethodCard = "CARD"
+	MethodOXXO = "OXXO"
+	MethodPSE  = "PSE"
+)
+
+// Transaction results
+const (
+	ResultApproved = "approved"
+	ResultDeclined = "declined"
+	ResultError    = "error"
+	ResultTimeout  = "timeout"
+)
+
+// TransactionRequest reports the outcome of a payment sent to a processor.
+// Set ID to make retries idempotent and DecisionID to the recommendation
+// the payment followed, if any.
+type TransactionRequest struct {
+	ID            string  `json:"id,omitempty"`
+	TenantID      string  `json:"tenant_id,omitempty"`
+	ProcessorID   string  `json:"processor_id"`
+	Result        string  `json:"result"`
+	PaymentMethod string  `json:"payment_method"`
+	Country       string  `json:"country"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	Timestamp     string  `json:"timestamp,omitempty"` // RFC 3339; the server's now when empty
+	DecisionID    string  `json:"decision_id,omitempty"`
+	BIN           string  `json:"bin,omitempty"`
+	CardBrand     string  `json:"card_brand,omitempty"`
+	IssuerCountry string  `json:"issuer_country,omitempty"`
+	PaymentID     string  `json:"payment_id,omitempty"`
+	CustomerID    string  `json:"customer_id,omitempty"`
+}
+
+// Recorded is the outcome of reporting a transaction. Health is nil when
+// the transaction was queued rather than recorded before the response.
+type Recorded struct {
+	TransactionID string
+	Replayed      bool // a retry of an already recorded report
+	Health        *ProcessorHealth
+}
+
+// Transaction is a recorded transaction
+type Transaction struct {
+	ID            string    `json:"id"`
+	TenantID      string    `json:"tenant_id,omitempty"`
+	ProcessorID   string    `json:"processor_id"`
+	Timestamp     time.Time `json:"timestamp"`
+	Result        string    `json:"result"`
+	PaymentMethod string    `json:"payment_method"`
+	Country       string    `json:"country"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	DecisionID    string    `json:"decision_id,omitempty"`
+	BIN           string    `json:"bin,omitempty"`
+	CardBrand     string    `json:"card_brand,omitempty"`
+	IssuerCountry string    `json:"issuer_country,omitempty"`
+	PaymentID     string    `json:"payment_id,omitempty"`
+	CustomerID    string    `json:"customer_id,omitempty"`
+	RequestID     string    `json:"request_id,omitempty"`
+}
+
+// ProcessorHealth is the current health of a processor
+type ProcessorHealth struct {
+	ProcessorID       string       `json:"processor_id"`
+	TenantID          string       `json:"tenant_id,omitempty"`
+	Status            string       `json:"status"`
+	AuthorizationRate float64      `json:"authorization_rate"`
+	TotalTransactions int          `json:"total_transactions"`
+	SuccessCount      int          `json:"success_count"`
+	FailureCount      int          `json:"failure_count"`
+	ErrorCount        int          `json:"error_count"`
+	LastUpdated       time.Time    `json:"last_updated"`
+	LastTransactionAt *time.Time   `json:"last_transaction_at,omitempty"`
+	StatusChangedAt   *time.Time   `json:"status_changed_at,omitempty"`
+	PreviousStatus    string       `json:"previous_status,omitempty"`
+	NetworkWeight     float64      `json:"network_weight,omitempty"`
+	Bands             []BandHealth `json:"bands,omitempty"`
+	Cards             []CardHealth `json:"cards,omitempty"`
+}
+
+// BandHealth is a processor's health in one amount band of a currency
+type BandHealth struct {
+	Currency          string  `json:"currency"`
+	MinAmount         float64 `json:"min_amount"`
+	MaxAmount         float64 `json:"max_amount,omitempty"`
+	Status            string  `json:"status"`
+	AuthorizationRate float64 `json:"authorization_rate"`
+	ErrorRate         float64 `json:"error_rate"`
+	TotalTransactions int     `json:"total_transactions"`
+	SuccessCount      int     `json:"success_count"`
+	FailureCount      int     `json:"failure_count"`
+	ErrorCount        int     `json:"error_count"`
+}
+
+// CardHealth is a processor's health for one card brand or BIN range
+type CardHealth struct {
+	Brand             string  `json:"brand,omitempty"`
+	BIN               string  `json:"bin,omitempty"`
+	Status            string  `json:"status"`
+	AuthorizationRate float64 `json:"authorization_rate"`
+	ErrorRate         float64 `json:"error_rate"`
+	TotalTransactions int     `json:"total_transactions"`
+	SuccessCount      int     `json:"success_count"`
+	FailureCount      int     `json:"failure_count"`
+	ErrorCount        int     `json:"error_count"`
+}
+
+// HealthList is the health of every processor of a tenant
+type HealthList struct {
+	TenantID   string            `json:"tenant_id"`
+	Processors []ProcessorHealth `json:"processors"`
+	Count      int               `json:"count"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// ProcessorHealthDetail is a processor's health and recent transactions
+type ProcessorHealthDetail struct {
+	Health             ProcessorHealth `json:"health"`
+	RecentTransactions []Transaction   `json:"recent_transactions"`
+	TransactionCount   int             `json:"transaction_count"`
+}
+
+// RoutingRequest describes the payment to route. Currency defaults to the
+// country's; Attempted lists processors already tried for PaymentID.
+type RoutingRequest struct {
+	TenantID      string   `json:"tenant_id,omitempty"`
+	PaymentMethod string   `json:"payment_method"`
+	Country       string   `json:"country"`
+	Amount        float64  `json:"amount"`
+	Currency      string   `json:"currency,omitempty"`
+	BIN           string   `json:"bin,omitempty"`
+	CardBrand     string   `json:"card_brand,omitempty"`
+	PaymentID     string   `json:"payment_id,omitempty"`
+	Attempted     []string `json:"attempted,omitempty"`
+	CustomerID    string   `json:"customer_id,omitempty"`
+	Sticky        bool     `json:"sticky,omitempty"`
+}
+
+// RoutingRecommendation ranks processors for a payment. Echo DecisionID
+// in the transaction report.
+type RoutingRecommendation struct {
+	DecisionID      string               `json:"decision_id,omitempty"`
+	TenantID        string               `json:"tenant_id,omitempty"`
+	Recommendations []ProcessorRank      `json:"recommendations"`
+	PaymentMethod   string               `json:"payment_method"`
+	Country         string               `json:"country"`
+	Amount          float64              `json:"amount,omitempty"`
+	Currency        string               `json:"currency,omitempty"`
+	BIN             string               `json:"bin,omitempty"`
+	CardBrand       string               `json:"card_brand,omitempty"`
+	PaymentID       string               `json:"payment_id,omitempty"`
+	Attempt         int                  `json:"attempt,omitempty"`
+	Timestamp       time.Time            `json:"timestamp"`
+	Explanation     *DecisionExplanation `json:"explanation,omitempty"`
+}
+
+// Best returns the recommended processor ("" when none is)
+func (r *RoutingRecommendation) Best() string {
+	if len(r.Recommendations) == 0 || !r.Recommendations[0].Recommended {
+		return ""
+	}
+	return r.Recommendations[0].ProcessorID
+}
+
+// ProcessorRank is one processor's place in a recommendation
+type ProcessorRank struct {
+	ProcessorID       string  `json:"processor_id"`
+	Rank              int     `json:"rank"`
+	Status            string  `json:"status"`
+	AuthorizationRate float64 `json:"authorization_rate"`
+	Score             float64 `json:"score"`
+	Recommended       bool    `json:"recommended"`
+	Saturated         bool    `json:"saturated,omitempty"`
+	Reason            string  `json:"reason"`
+}
+
+// DecisionExplanation records how a recommendation was reached
+type DecisionExplanation struct {
+	Candidates      []string            `json:"candidates"`
+	Filtered        []FilteredProcessor `json:"filtered"`
+	Scores          []ScoreBreakdown    `json:"scores"`
+	StickyProcessor string              `json:"sticky_processor,omitempty"`
+}
+
+// FilteredProcessor is a processor excluded from the candidates, and why
+type FilteredProcessor struct {
+	ProcessorID string `json:"processor_id"`
+	Reason      string `json:"reason"`
+}
+
+// ScoreBreakdown shows each component of a candidate's score
+type ScoreBreakdown struct {
+	ProcessorID         string          `json:"processor_id"`
+	AuthRateScore       float64         `json:"auth_rate_score"`
+	StatusAdjustment    float64         `json:"status_adjustment"`
+	ConfidenceBonus     float64         `json:"confidence_bonus"`
+	VolumeCapAdjustment float64         `json:"volume_cap_adjustment"`
+	VolumeUtilization   float64         `json:"volume_utilization,omitempty"`
+	AmountBand          *BandHealth     `json:"amount_band,omitempty"`
+	CardSegment         *CardHealth     `json:"card_segment,omitempty"`
+	CapacityUtilization float64         `json:"capacity_utilization,omitempty"`
+	Total               float64         `json:"total"`
+	Health              ProcessorHealth `json:"health"`
+}
+
+// Processor is a processor registered for a tenant
+type Processor struct {
+	ID             string                 `json:"id"`
+	TenantID       string                 `json:"tenant_id,omitempty"`
+	Name           string                 `json:"name"`
+	Countries      []string               `json:"countries"`
+	PaymentMethods []string               `json:"payment_methods"`
+	Currencies     []string               `json:"currencies,omitempty"`
+	Limits         map[string]AmountLimit `json:"limits,omitempty"`
+	MaxTPS         float64                `json:"max_tps,omitempty"`
+	MaxInFlight    int                    `json:"max_in_flight,omitempty"`
+}
+
+// AmountLimit bounds what a processor accepts in one currency (zero: none)
+type AmountLimit struct {
+	MinAmount        float64 `json:"min_amount,omitempty"`
+	MaxAmount        float64 `json:"max_amount,omitempty"`
+	DailyVolumeCap   float64 `json:"daily_volume_cap,omitempty"`
+	MonthlyVolumeCap float64 `json:"monthly_volume_cap,omitempty"`
+}
+
+// ProcessorList is the processors of a tenant
+type ProcessorList struct {
+	TenantID   string      `json:"tenant_id"`
+	Processors []Processor `json:"processors"`
+	Count      int         `json:"count"`
+}
+
+// HealthTransition is a processor changing health status
+type HealthTransition struct {
+	ProcessorID string    `json:"processor_id"`
+	TenantID    string    `json:"tenant_id,omitempty"`
+	FromStatus  string    `json:"from_status"`
+	ToStatus    string    `json:"to_status"`
+	Timestamp   time.Time `json:"timestamp"`
+	Reason      string    `json:"reason"`
+	RequestID   string    `json:"request_id,omitempty"`
+}
+
+// SLOAlert is an SLO burn-rate alert firing or resolving
+type SLOAlert struct {
+	Type          string    `json:"type"`
+	SLOID         string    `json:"slo_id"`
+	TenantID      string    `json:"tenant_id,omitempty"`
+	ProcessorID   string    `json:"processor_id"`
+	PaymentMethod string    `json:"payment_method,omitempty"`
+	Country       string    `json:"country,omitempty"`
+	Window        string    `json:"window"`
+	State         string    `json:"state"`
+	BurnRate      float64   `json:"burn_rate"`
+	ShortBurnRate float64   `json:"short_burn_rate"`
+	Threshold     float64   `json:"threshold"`
+	Objective     float64   `json:"objective"`
+	Timestamp     time.Time `json:"timestamp"`
+	Reason        string    `json:"reason"`
+}
+
+// Alerts is the transitions and SLO alerts raised since a time
+type Alerts struct {
+	TenantID  string             `json:"tenant_id"`
+	Alerts    []HealthTransition `json:"alerts"`
+	Count     int                `json:"count"`
+	SLOAlerts []SLOAlert         `json:"slo_alerts"`
+	SLOCount  int                `json:"slo_count"`
+	Since     time.Time          `json:"since"`
+	Timestamp time.Time          `json:"timestamp"`
+}
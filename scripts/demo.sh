@@ -22,10 +22,10 @@ fi
 echo "✅ Server is running"
 echo ""
 
-# Run the data generator
-echo "🚀 Running data generator with outage simulation..."
+# Drive the server with the outage scenario (6 simulated hours in ~1 minute)
+echo "🚀 Running the processor_a outage scenario against the server..."
 echo ""
-go run scripts/generate_data.go
+go run ./cmd/simulate -scenario scenarios/techcart_outage.json -url http://localhost:8080 -speed 360
 
 echo ""
 echo "================================================"